@@ -20,14 +20,18 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 
 	"github.com/kubernetes-csi/csi-lib-utils/leaderelection"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	cnstypes "github.com/vmware/govmomi/cns/types"
 
 	"sigs.k8s.io/vsphere-csi-driver/pkg/common/config"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/prometheus"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/tracing"
 	k8s "sigs.k8s.io/vsphere-csi-driver/pkg/kubernetes"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/syncer"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/syncer/admissionhandler"
@@ -43,16 +47,28 @@ const operationModeWebHookServer = "WEBHOOK_SERVER"
 // OperationModeWebHookServer starts container for metadata sync
 const operationModeMetaDataSync = "METADATA_SYNC"
 
+// operationModeMigrationRollback rolls back the volumes migrated to CSI to the in-tree vSphere plugin and exits
+const operationModeMigrationRollback = "MIGRATION_ROLLBACK"
+
+// operationModeMigrationPreRegister registers the in-tree vSphere volumes with CNS ahead of CSI migration and exits
+const operationModeMigrationPreRegister = "MIGRATION_PREREGISTER"
+
 var (
 	enableLeaderElection    = flag.Bool("leader-election", false, "Enable leader election.")
 	leaderElectionNamespace = flag.String("leader-election-namespace", "", "Namespace where the leader election resource lives. Defaults to the pod namespace if not set.")
 	printVersion            = flag.Bool("version", false, "Print syncer version and exit")
-	operationMode           = flag.String("operation-mode", operationModeMetaDataSync, "specify operation mode METADATA_SYNC or WEBHOOK_SERVER")
+	operationMode           = flag.String("operation-mode", operationModeMetaDataSync, "specify operation mode METADATA_SYNC, WEBHOOK_SERVER, MIGRATION_ROLLBACK or MIGRATION_PREREGISTER")
+	migrationRollbackDryRun = flag.Bool("migration-rollback-dry-run", false, "Only report the volumes MIGRATION_ROLLBACK would roll back.")
+
+	migrationPreRegisterConcurrency = flag.Int("migration-preregister-concurrency", 4, "Number of in-tree volumes MIGRATION_PREREGISTER registers concurrently.")
+	migrationPreRegisterDryRun      = flag.Bool("migration-preregister-dry-run", false, "Only report the volumes MIGRATION_PREREGISTER would register.")
 
 	supervisorFSSName      = flag.String("supervisor-fss-name", "", "Name of the feature state switch configmap in supervisor cluster")
 	supervisorFSSNamespace = flag.String("supervisor-fss-namespace", "", "Namespace of the feature state switch configmap in supervisor cluster")
 	internalFSSName        = flag.String("fss-name", "", "Name of the feature state switch configmap")
 	internalFSSNamespace   = flag.String("fss-namespace", "", "Namespace of the feature state switch configmap")
+
+	metricsAddress = flag.String("metrics-address", ":2113", "Address on which to expose Prometheus metrics of the syncer. Empty to disable.")
 )
 
 // main for vsphere syncer
@@ -83,6 +99,29 @@ func main() {
 		}
 	} else if *operationMode == operationModeMetaDataSync {
 		log.Infof("Starting container with operation mode: %v", operationModeMetaDataSync)
+		// Export the spans of the syncer if an OTLP endpoint is configured. The syncer runs until it is
+		// stopped by a signal, so the queued spans are flushed on the signal too.
+		if shutdownTracing, err := tracing.InitTracerProvider(ctx, "vsphere-syncer"); err != nil {
+			log.Warnf("failed to initialize tracing, spans are not exported. Err: %+v", err)
+		} else {
+			defer tracing.Shutdown(ctx, shutdownTracing)
+			tracing.ShutdownOnSignal(ctx, shutdownTracing)
+		}
+		if *metricsAddress != "" {
+			// Go module to keep the metrics http server running all the time.
+			go func() {
+				prometheus.SyncerInfo.WithLabelValues(syncer.Version).Set(1)
+				http.Handle("/metrics", promhttp.Handler())
+				for {
+					log.Infof("Starting the http server on %s to expose Prometheus metrics..", *metricsAddress)
+					err := http.ListenAndServe(*metricsAddress, nil)
+					if err != nil {
+						log.Warnf("Http server that exposes the Prometheus exited with err: %+v", err)
+					}
+					log.Info("Restarting http server to expose Prometheus metrics..")
+				}
+			}()
+		}
 		var err error
 		configInfo, err := types.InitConfigInfo(ctx)
 		if err != nil {
@@ -124,6 +163,17 @@ func main() {
 				log.Fatalf("Error initializing leader election: %v", err)
 			}
 		}
+	} else if *operationMode == operationModeMigrationRollback {
+		log.Infof("Starting container with operation mode: %v", operationModeMigrationRollback)
+		if err := syncer.RollbackVolumeMigration(ctx, clusterFlavor, &syncer.COInitParams, *migrationRollbackDryRun); err != nil {
+			log.Fatalf("failed to roll back the CSI migration. err: %v", err)
+		}
+	} else if *operationMode == operationModeMigrationPreRegister {
+		log.Infof("Starting container with operation mode: %v", operationModeMigrationPreRegister)
+		if err := syncer.PreRegisterInTreeVolumes(ctx, clusterFlavor, &syncer.COInitParams, *migrationPreRegisterConcurrency,
+			*migrationPreRegisterDryRun); err != nil {
+			log.Fatalf("failed to pre-register the in-tree volumes. err: %v", err)
+		}
 	} else {
 		log.Fatalf("unsupported operation mode: %v", *operationMode)
 	}
@@ -150,6 +200,14 @@ func initSyncerComponents(ctx context.Context, clusterFlavor cnstypes.CnsCluster
 				}
 			}()
 		}
+		// Initialize StoragePools for the datastores of Vanilla clusters
+		if clusterFlavor == cnstypes.CnsClusterFlavorVanilla {
+			go func() {
+				if err := storagepool.InitVanillaStoragePoolService(ctx, configInfo, coInitParams); err != nil {
+					log.Errorf("Error initializing StoragePool Service. Error: %+v", err)
+				}
+			}()
+		}
 		if err := syncer.InitMetadataSyncer(ctx, clusterFlavor, configInfo); err != nil {
 			log.Errorf("Error initializing Metadata Syncer. Error: %+v", err)
 			os.Exit(1)
@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// FullSyncReport is the Schema for the fullsyncreports API
+// It records the outcome of one full sync cycle of the syncer
+type FullSyncReport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status FullSyncReportStatus `json:"status,omitempty"`
+}
+
+// FullSyncReportStatus defines the observed outcome of a full sync cycle
+type FullSyncReportStatus struct {
+	// DryRun is true if the full sync cycle computed the operations without performing them.
+	DryRun bool `json:"dryRun"`
+	// StartTime is the time the full sync cycle started.
+	StartTime metav1.Time `json:"startTime"`
+	// EndTime is the time the full sync cycle ended.
+	EndTime metav1.Time `json:"endTime,omitempty"`
+	// Error is set if the full sync cycle was aborted before computing the operations.
+	Error string `json:"error,omitempty"`
+	// CreateCount is the number of volumes to be created in CNS.
+	CreateCount int `json:"createCount"`
+	// UpdateCount is the number of volumes whose metadata is to be updated in CNS.
+	UpdateCount int `json:"updateCount"`
+	// DeleteCount is the number of volumes to be deleted from CNS.
+	DeleteCount int `json:"deleteCount"`
+	// VolumesToCreate holds the IDs of volumes to be created in CNS.
+	VolumesToCreate []string `json:"volumesToCreate,omitempty"`
+	// VolumesToUpdate holds the IDs of volumes whose metadata is to be updated in CNS.
+	VolumesToUpdate []string `json:"volumesToUpdate,omitempty"`
+	// VolumesToDelete holds the IDs of volumes to be deleted from CNS.
+	VolumesToDelete []string `json:"volumesToDelete,omitempty"`
+	// Failures holds the operations which failed during the full sync cycle.
+	Failures []FullSyncFailure `json:"failures,omitempty"`
+}
+
+// FullSyncFailure describes a failed full sync operation on a volume
+type FullSyncFailure struct {
+	// Operation is one of "create", "update" or "delete".
+	Operation string `json:"operation"`
+	// VolumeID is the ID of the volume the operation failed on.
+	VolumeID string `json:"volumeID"`
+	// Error is the error returned by the operation.
+	Error string `json:"error"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// FullSyncReportList contains a list of FullSyncReport
+type FullSyncReportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []FullSyncReport `json:"items"`
+}
@@ -36,6 +36,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -62,12 +63,24 @@ type VolumeMigrationService interface {
 	// Returns an error if not able to retrieve VolumeID.
 	GetVolumeID(ctx context.Context, volumeSpec *VolumeSpec) (string, error)
 
+	// GetRegisteredVolumeID returns VolumeID for given VolumePath if the volume is already registered with CNS.
+	// Unlike GetVolumeID, the volume is not registered if it is not found.
+	GetRegisteredVolumeID(ctx context.Context, volumePath string) (string, bool)
+
 	// GetVolumePath returns VolumePath for given VolumeID
 	// Returns an error if not able to retrieve VolumePath.
 	GetVolumePath(ctx context.Context, volumeID string) (string, error)
 
 	// DeleteVolumeInfo helps delete mapping of volumePath to VolumeID for specified volumeID
 	DeleteVolumeInfo(ctx context.Context, volumeID string) error
+
+	// RollbackMigratedVolumes unregisters all the migrated volumes from CNS without deleting their VMDK
+	// and hands them back to the in-tree vSphere plugin. Returns the outcome for each volume.
+	RollbackMigratedVolumes(ctx context.Context, k8sClient clientset.Interface, dryRun bool) ([]VolumeRollbackResult, error)
+
+	// PreRegisterVolumes registers all the in-tree vSphere volumes with CNS ahead of enabling CSI migration,
+	// at most concurrency at a time. Returns the outcome for each volume.
+	PreRegisterVolumes(ctx context.Context, k8sClient clientset.Interface, concurrency int, dryRun bool) ([]VolumeRegistrationResult, error)
 }
 
 // volumeMigration holds migrated volume information and provides functionality around it.
@@ -214,6 +227,16 @@ func (volumeMigration *volumeMigration) GetVolumeID(ctx context.Context, volumeS
 	return volumeID, nil
 }
 
+// GetRegisteredVolumeID returns VolumeID for given VolumePath if the volume is already registered with CNS.
+// Unlike GetVolumeID, the volume is not registered if it is not found.
+func (volumeMigration *volumeMigration) GetRegisteredVolumeID(ctx context.Context, volumePath string) (string, bool) {
+	info, found := volumeMigration.volumePathToVolumeID.Load(volumePath)
+	if !found {
+		return "", false
+	}
+	return info.(string), true
+}
+
 // GetVolumePath returns VolumePath for given VolumeID
 // Returns an error if not able to retrieve VolumePath.
 func (volumeMigration *volumeMigration) GetVolumePath(ctx context.Context, volumeID string) (string, error) {
@@ -0,0 +1,147 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"context"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	migrationv1alpha1 "sigs.k8s.io/vsphere-csi-driver/pkg/apis/migration/v1alpha1"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+)
+
+const (
+	// RegistrationStatusRegistered is the status of a volume registered with CNS by the pre-registration
+	RegistrationStatusRegistered = "Registered"
+	// RegistrationStatusAlreadyRegistered is the status of a volume registered with CNS before the pre-registration
+	RegistrationStatusAlreadyRegistered = "AlreadyRegistered"
+	// RegistrationStatusWouldRegister is the status of a volume that would be registered in a dry run
+	RegistrationStatusWouldRegister = "WouldRegister"
+	// RegistrationStatusFailed is the status of a volume whose registration failed
+	RegistrationStatusFailed = "Failed"
+)
+
+// VolumeRegistrationResult is the outcome of the pre-registration of an in-tree volume with CNS
+type VolumeRegistrationResult struct {
+	PVName     string `json:"pvName"`
+	VolumePath string `json:"volumePath"`
+	// VolumeID is the CNS volume ID of the volume, empty if it is not registered
+	VolumeID string `json:"volumeID,omitempty"`
+	Status   string `json:"status"`
+	Message  string `json:"message,omitempty"`
+}
+
+// PreRegisterVolumes registers all the in-tree vSphere volumes of the cluster with CNS ahead of enabling
+// CSI migration, so that they are not registered lazily on their first use. Volumes are registered like
+// GetVolumeID does, at most concurrency at a time. Volumes which already have a CnsVSphereVolumeMigration CR
+// are left untouched. With dryRun, the volumes are only reported.
+func (volumeMigration *volumeMigration) PreRegisterVolumes(ctx context.Context, k8sClient clientset.Interface,
+	concurrency int, dryRun bool) ([]VolumeRegistrationResult, error) {
+	log := logger.GetLogger(ctx)
+	// the CR informer may not have filled the cache yet, the CRs are listed so that registered volumes are known
+	volumeMigrationResourceList := &migrationv1alpha1.CnsVSphereVolumeMigrationList{}
+	err := volumeMigration.k8sClient.List(ctx, volumeMigrationResourceList)
+	if err != nil {
+		log.Errorf("failed to get CnsVSphereVolumeMigration list from the cluster. Err: %+v", err)
+		return nil, err
+	}
+	registeredVolumes := make(map[string]string)
+	for _, volumeMigrationResource := range volumeMigrationResourceList.Items {
+		registeredVolumes[volumeMigrationResource.Spec.VolumePath] = volumeMigrationResource.Spec.VolumeID
+		volumeMigration.volumePathToVolumeID.Store(volumeMigrationResource.Spec.VolumePath, volumeMigrationResource.Spec.VolumeID)
+	}
+	pvList, err := k8sClient.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("failed to list PVs. Err: %+v", err)
+		return nil, err
+	}
+
+	// registration is the index in results of a volume to register and its spec
+	type registration struct {
+		index      int
+		volumeSpec VolumeSpec
+	}
+	results := make([]VolumeRegistrationResult, 0)
+	pending := make([]registration, 0)
+	for _, pv := range pvList.Items {
+		if pv.Spec.VsphereVolume == nil {
+			continue
+		}
+		result := VolumeRegistrationResult{PVName: pv.Name, VolumePath: pv.Spec.VsphereVolume.VolumePath}
+		if volumeID, found := registeredVolumes[result.VolumePath]; found {
+			result.VolumeID = volumeID
+			result.Status = RegistrationStatusAlreadyRegistered
+		} else if dryRun {
+			result.Status = RegistrationStatusWouldRegister
+		} else {
+			pending = append(pending, registration{
+				index: len(results),
+				volumeSpec: VolumeSpec{
+					VolumePath:        pv.Spec.VsphereVolume.VolumePath,
+					StoragePolicyName: pv.Spec.VsphereVolume.StoragePolicyName,
+				},
+			})
+		}
+		results = append(results, result)
+	}
+	log.Infof("Found %d in-tree volume(s), %d to register with concurrency %d, dry run: %v", len(results),
+		len(pending), concurrency, dryRun)
+
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	jobs := make(chan registration)
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// each worker only writes the results of the registrations it receives
+			for job := range jobs {
+				result := &results[job.index]
+				volumeID, err := volumeMigration.GetVolumeID(ctx, &job.volumeSpec)
+				if err != nil {
+					log.Errorf("failed to register volume %q of PV %q. Err: %v", result.VolumePath, result.PVName, err)
+					result.Status = RegistrationStatusFailed
+					result.Message = err.Error()
+					continue
+				}
+				result.VolumeID = volumeID
+				result.Status = RegistrationStatusRegistered
+			}
+		}()
+	}
+	for _, job := range pending {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- job
+	}
+	close(jobs)
+	wg.Wait()
+	for _, job := range pending {
+		// volumes not sent to the workers because ctx is done
+		if results[job.index].Status == "" {
+			results[job.index].Status = RegistrationStatusFailed
+			results[job.index].Message = ctx.Err().Error()
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	migrationv1alpha1 "sigs.k8s.io/vsphere-csi-driver/pkg/apis/migration/v1alpha1"
+	cnsvolume "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/volume"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/vsphere"
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/pkg/common/config"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/pkg/csi/types"
+)
+
+const testVCHost = "preregister-test-vc"
+
+// fakeRegistrationManager is a cnsvolume.Manager registering volumes with createVolume.
+// Methods which are not overridden panic.
+type fakeRegistrationManager struct {
+	cnsvolume.Manager
+	createVolume func(ctx context.Context, spec *cnstypes.CnsVolumeCreateSpec) (*cnsvolume.CnsVolumeInfo, error)
+}
+
+func (m *fakeRegistrationManager) CreateVolume(ctx context.Context,
+	spec *cnstypes.CnsVolumeCreateSpec) (*cnsvolume.CnsVolumeInfo, error) {
+	return m.createVolume(ctx, spec)
+}
+
+// newTestRegistration returns a volumeMigration registering volumes with createVolume. The vCenter of its
+// config is registered without being connected, as registerVolume only needs it for storage policies.
+func newTestRegistration(t *testing.T, createVolume func(ctx context.Context,
+	spec *cnstypes.CnsVolumeCreateSpec) (*cnsvolume.CnsVolumeInfo, error),
+	crs ...*migrationv1alpha1.CnsVSphereVolumeMigration) *volumeMigration {
+	ctx := context.Background()
+	_, err := vsphere.GetVirtualCenterManager(ctx).RegisterVirtualCenter(ctx, &vsphere.VirtualCenterConfig{Host: testVCHost})
+	if err != nil && err != vsphere.ErrVCAlreadyRegistered {
+		t.Fatalf("failed to register vCenter: %v", err)
+	}
+	volumeMigration := newTestVolumeMigration(t, &fakeRegistrationManager{createVolume: createVolume}, crs...)
+	volumeMigration.cnsConfig = &cnsconfig.Config{VirtualCenter: map[string]*cnsconfig.VirtualCenterConfig{
+		testVCHost: {User: "user", Datacenters: "dc-1"},
+	}}
+	return volumeMigration
+}
+
+// registeredVolumeID returns the volume ID the fake manager gives to the volume of the given create spec
+func registeredVolumeID(spec *cnstypes.CnsVolumeCreateSpec) string {
+	backingDiskURLPath := spec.BackingObjectDetails.(*cnstypes.CnsBlockBackingDetails).BackingDiskUrlPath
+	vmdk := backingDiskURLPath[strings.LastIndex(backingDiskURLPath, "/")+1 : strings.Index(backingDiskURLPath, ".vmdk")]
+	return "volume-" + vmdk
+}
+
+func TestPreRegisterVolumes(t *testing.T) {
+	ctx := context.Background()
+	var (
+		lock       sync.Mutex
+		running    int
+		maxRunning int
+		created    int
+	)
+	volumeMigration := newTestRegistration(t, func(ctx context.Context,
+		spec *cnstypes.CnsVolumeCreateSpec) (*cnsvolume.CnsVolumeInfo, error) {
+		lock.Lock()
+		running++
+		created++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lock.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		lock.Lock()
+		running--
+		lock.Unlock()
+		volumeID := registeredVolumeID(spec)
+		if volumeID == "volume-failed" {
+			return nil, errors.New("CNS is unavailable")
+		}
+		return &cnsvolume.CnsVolumeInfo{VolumeID: cnstypes.CnsVolumeId{Id: volumeID}}, nil
+	}, newVolumeMigrationCR("volume-registered", "[vsanDatastore] kubevols/registered.vmdk"))
+
+	objs := []runtime.Object{
+		newInTreePV("pv-registered", "[vsanDatastore] kubevols/registered.vmdk", nil),
+		newInTreePV("pv-failed", "[vsanDatastore] kubevols/failed.vmdk", nil),
+		&v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "csi-pv"},
+			Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{Driver: csitypes.Name, VolumeHandle: "volume-csi"}}},
+		},
+	}
+	for i := 0; i < 6; i++ {
+		objs = append(objs, newInTreePV(fmt.Sprintf("pv-%d", i), fmt.Sprintf("[vsanDatastore] kubevols/%d.vmdk", i), nil))
+	}
+	k8sClient := k8sfake.NewSimpleClientset(objs...)
+
+	preRegister := func(dryRun bool) map[string]VolumeRegistrationResult {
+		results, err := volumeMigration.PreRegisterVolumes(ctx, k8sClient, 3, dryRun)
+		if err != nil {
+			t.Fatalf("PreRegisterVolumes failed: %v", err)
+		}
+		resultsByPVName := make(map[string]VolumeRegistrationResult)
+		for _, result := range results {
+			resultsByPVName[result.PVName] = result
+		}
+		if len(results) != 8 || len(resultsByPVName) != 8 {
+			t.Fatalf("expected a result for each of the 8 in-tree PVs, got %v", results)
+		}
+		return resultsByPVName
+	}
+
+	results := preRegister(true)
+	for pvName, result := range results {
+		expected := RegistrationStatusWouldRegister
+		if pvName == "pv-registered" {
+			expected = RegistrationStatusAlreadyRegistered
+		}
+		if result.Status != expected {
+			t.Errorf("expected status %q for PV %q in a dry run, got %+v", expected, pvName, result)
+		}
+	}
+	if created != 0 {
+		t.Errorf("expected no volume to be registered in a dry run, got %d", created)
+	}
+
+	results = preRegister(false)
+	for pvName, result := range results {
+		switch pvName {
+		case "pv-registered":
+			if result.Status != RegistrationStatusAlreadyRegistered || result.VolumeID != "volume-registered" {
+				t.Errorf("unexpected result for the registered PV: %+v", result)
+			}
+		case "pv-failed":
+			if result.Status != RegistrationStatusFailed || result.Message == "" || result.VolumeID != "" {
+				t.Errorf("unexpected result for the failed PV: %+v", result)
+			}
+		default:
+			volumeID := "volume-" + strings.TrimPrefix(pvName, "pv-")
+			if result.Status != RegistrationStatusRegistered || result.VolumeID != volumeID {
+				t.Errorf("unexpected result for PV %q: %+v", pvName, result)
+				continue
+			}
+			err := volumeMigration.k8sClient.Get(ctx, client.ObjectKey{Name: volumeID},
+				&migrationv1alpha1.CnsVSphereVolumeMigration{})
+			if err != nil {
+				t.Errorf("expected a CR for volume %q: %v", volumeID, err)
+			}
+		}
+	}
+	if created != 7 {
+		t.Errorf("expected 7 volumes to be registered, got %d", created)
+	}
+	if maxRunning != 3 {
+		t.Errorf("expected 3 concurrent registrations, got %d", maxRunning)
+	}
+}
+
+func TestPreRegisterVolumesCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	volumeMigration := newTestRegistration(t, func(ctx context.Context,
+		spec *cnstypes.CnsVolumeCreateSpec) (*cnsvolume.CnsVolumeInfo, error) {
+		cancel()
+		return &cnsvolume.CnsVolumeInfo{VolumeID: cnstypes.CnsVolumeId{Id: registeredVolumeID(spec)}}, nil
+	})
+	objs := make([]runtime.Object, 0)
+	for i := 0; i < 5; i++ {
+		objs = append(objs, newInTreePV(fmt.Sprintf("pv-%d", i), fmt.Sprintf("[vsanDatastore] kubevols/%d.vmdk", i), nil))
+	}
+
+	results, err := volumeMigration.PreRegisterVolumes(ctx, k8sfake.NewSimpleClientset(objs...), 1, false)
+	if err != nil {
+		t.Fatalf("PreRegisterVolumes failed: %v", err)
+	}
+	if len(results) != len(objs) {
+		t.Fatalf("expected a result for each of the %d in-tree PVs, got %v", len(objs), results)
+	}
+	// the worker may have received one more volume before the cancellation
+	canceled := 0
+	for _, result := range results {
+		switch result.Status {
+		case RegistrationStatusRegistered:
+		case RegistrationStatusFailed:
+			if result.Message != context.Canceled.Error() {
+				t.Errorf("expected the registration of PV %q to fail with %q, got %q", result.PVName,
+					context.Canceled.Error(), result.Message)
+			}
+			canceled++
+		default:
+			t.Errorf("unexpected result for PV %q: %+v", result.PVName, result)
+		}
+	}
+	if canceled < len(objs)-2 {
+		t.Errorf("expected at least %d registrations to be canceled, got %d", len(objs)-2, canceled)
+	}
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	migrationv1alpha1 "sigs.k8s.io/vsphere-csi-driver/pkg/apis/migration/v1alpha1"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/pkg/csi/types"
+)
+
+const (
+	// RollbackStatusRolledBack is the status of a volume unregistered from CNS and handed back to the in-tree plugin
+	RollbackStatusRolledBack = "RolledBack"
+	// RollbackStatusWouldRollBack is the status of a volume that would be rolled back in a dry run
+	RollbackStatusWouldRollBack = "WouldRollBack"
+	// RollbackStatusSkipped is the status of a volume left migrated because it is attached through the CSI driver
+	RollbackStatusSkipped = "Skipped"
+	// RollbackStatusFailed is the status of a volume whose rollback failed
+	RollbackStatusFailed = "Failed"
+)
+
+// VolumeRollbackResult is the outcome of the rollback of a migrated volume to the in-tree vSphere plugin
+type VolumeRollbackResult struct {
+	VolumeID   string `json:"volumeID"`
+	VolumePath string `json:"volumePath"`
+	// PVName is the name of the in-tree PV of the volume, empty if there is none
+	PVName  string `json:"pvName,omitempty"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// RollbackMigratedVolumes rolls back all the volumes migrated from the in-tree vSphere plugin to the CSI driver.
+// For each CnsVSphereVolumeMigration CR, the volume is unregistered from CNS without deleting its VMDK, the CR is
+// deleted and the migrated-to annotation set by CSI migration is removed from the in-tree PV and its PVC.
+// Volumes attached through the CSI driver are skipped, as they must be detached first.
+// With dryRun, the volumes are only reported.
+// CSI migration must be disabled beforehand, otherwise the volumes are registered again on their next use.
+func (volumeMigration *volumeMigration) RollbackMigratedVolumes(ctx context.Context, k8sClient clientset.Interface,
+	dryRun bool) ([]VolumeRollbackResult, error) {
+	log := logger.GetLogger(ctx)
+	volumeMigrationResourceList := &migrationv1alpha1.CnsVSphereVolumeMigrationList{}
+	err := volumeMigration.k8sClient.List(ctx, volumeMigrationResourceList)
+	if err != nil {
+		log.Errorf("failed to get CnsVSphereVolumeMigration list from the cluster. Err: %+v", err)
+		return nil, err
+	}
+	pvList, err := k8sClient.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("failed to list PVs. Err: %+v", err)
+		return nil, err
+	}
+	volumeAttachmentList, err := k8sClient.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("failed to list VolumeAttachments. Err: %+v", err)
+		return nil, err
+	}
+	log.Infof("Rolling back %d migrated volume(s), dry run: %v", len(volumeMigrationResourceList.Items), dryRun)
+	results := make([]VolumeRollbackResult, 0, len(volumeMigrationResourceList.Items))
+	for i := range volumeMigrationResourceList.Items {
+		volumeMigrationResource := &volumeMigrationResourceList.Items[i]
+		result := VolumeRollbackResult{
+			VolumeID:   volumeMigrationResource.Spec.VolumeID,
+			VolumePath: volumeMigrationResource.Spec.VolumePath,
+		}
+		pv := findInTreePV(pvList.Items, volumeMigrationResource.Spec.VolumePath)
+		if pv != nil {
+			result.PVName = pv.Name
+			if isAttachedByCSI(volumeAttachmentList.Items, pv.Name) {
+				result.Status = RollbackStatusSkipped
+				result.Message = "PV is attached through the CSI driver, detach it before rolling back"
+				results = append(results, result)
+				continue
+			}
+		}
+		if dryRun {
+			result.Status = RollbackStatusWouldRollBack
+			results = append(results, result)
+			continue
+		}
+		if err := volumeMigration.rollbackVolume(ctx, k8sClient, volumeMigrationResource, pv); err != nil {
+			result.Status = RollbackStatusFailed
+			result.Message = err.Error()
+		} else {
+			result.Status = RollbackStatusRolledBack
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// rollbackVolume unregisters the given migrated volume from CNS, deletes its CR and restores the annotations of
+// its in-tree PV, if any. The volume is unregistered first so that a failure leaves the migration untouched.
+// A volume which is no longer registered with CNS is rolled back too, so that a failed rollback can be retried.
+func (volumeMigration *volumeMigration) rollbackVolume(ctx context.Context, k8sClient clientset.Interface,
+	volumeMigrationResource *migrationv1alpha1.CnsVSphereVolumeMigration, pv *v1.PersistentVolume) error {
+	log := logger.GetLogger(ctx)
+	volumeID := volumeMigrationResource.Spec.VolumeID
+	// deleteDisk is false so that the VMDK is kept for the in-tree plugin
+	err := (*volumeMigration.volumeManager).DeleteVolume(ctx, volumeID, false)
+	if err != nil {
+		if !vsphere.IsNotFoundError(err) {
+			log.Errorf("failed to unregister volume %q from CNS. Err: %+v", volumeID, err)
+			return fmt.Errorf("failed to unregister volume from CNS: %v", err)
+		}
+		// a previous rollback may have failed after unregistering the volume, carry on with its CR and PV
+		log.Infof("Volume %q is not registered with CNS, treating it as already unregistered", volumeID)
+	} else {
+		log.Infof("Unregistered volume %q with path %q from CNS", volumeID, volumeMigrationResource.Spec.VolumePath)
+	}
+	err = volumeMigration.DeleteVolumeInfo(ctx, volumeMigrationResource.Name)
+	if err != nil {
+		return fmt.Errorf("failed to delete CnsVSphereVolumeMigration CR: %v", err)
+	}
+	volumeMigration.volumePathToVolumeID.Delete(volumeMigrationResource.Spec.VolumePath)
+	if pv == nil {
+		return nil
+	}
+	if removeMigratedToAnnotation(pv.Annotations) {
+		_, err = k8sClient.CoreV1().PersistentVolumes().Update(ctx, pv, metav1.UpdateOptions{})
+		if err != nil {
+			log.Errorf("failed to restore the annotations of PV %q. Err: %+v", pv.Name, err)
+			return fmt.Errorf("failed to restore the annotations of the PV: %v", err)
+		}
+	}
+	if pv.Spec.ClaimRef == nil {
+		return nil
+	}
+	pvc, err := k8sClient.CoreV1().PersistentVolumeClaims(pv.Spec.ClaimRef.Namespace).Get(ctx, pv.Spec.ClaimRef.Name, metav1.GetOptions{})
+	if err != nil {
+		log.Errorf("failed to get PVC %s/%s. Err: %+v", pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name, err)
+		return fmt.Errorf("failed to get the PVC of the PV: %v", err)
+	}
+	if removeMigratedToAnnotation(pvc.Annotations) {
+		_, err = k8sClient.CoreV1().PersistentVolumeClaims(pvc.Namespace).Update(ctx, pvc, metav1.UpdateOptions{})
+		if err != nil {
+			log.Errorf("failed to restore the annotations of PVC %s/%s. Err: %+v", pvc.Namespace, pvc.Name, err)
+			return fmt.Errorf("failed to restore the annotations of the PVC: %v", err)
+		}
+	}
+	return nil
+}
+
+// findInTreePV returns the in-tree vSphere PV with the given volume path, nil if there is none
+func findInTreePV(pvs []v1.PersistentVolume, volumePath string) *v1.PersistentVolume {
+	for i := range pvs {
+		if pvs[i].Spec.VsphereVolume != nil && pvs[i].Spec.VsphereVolume.VolumePath == volumePath {
+			return &pvs[i]
+		}
+	}
+	return nil
+}
+
+// isAttachedByCSI returns true if the given PV is attached by the CSI driver
+func isAttachedByCSI(volumeAttachments []storagev1.VolumeAttachment, pvName string) bool {
+	for _, volumeAttachment := range volumeAttachments {
+		if volumeAttachment.Spec.Attacher == csitypes.Name && volumeAttachment.Spec.Source.PersistentVolumeName != nil &&
+			*volumeAttachment.Spec.Source.PersistentVolumeName == pvName {
+			return true
+		}
+	}
+	return false
+}
+
+// removeMigratedToAnnotation removes the migrated-to annotation set to the CSI driver from the given annotations.
+// It returns true if the annotations were changed.
+func removeMigratedToAnnotation(annotations map[string]string) bool {
+	if annotations[common.AnnMigratedTo] != csitypes.Name {
+		return false
+	}
+	delete(annotations, common.AnnMigratedTo)
+	return true
+}
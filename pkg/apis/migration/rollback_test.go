@@ -0,0 +1,259 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/vmware/govmomi/vim25/soap"
+	vim25types "github.com/vmware/govmomi/vim25/types"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	migrationv1alpha1 "sigs.k8s.io/vsphere-csi-driver/pkg/apis/migration/v1alpha1"
+	cnsvolume "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/volume"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/pkg/csi/types"
+)
+
+// fakeVolumeManager is a cnsvolume.Manager recording the volumes unregistered from CNS.
+// Methods which are not overridden panic.
+type fakeVolumeManager struct {
+	cnsvolume.Manager
+	lock sync.Mutex
+	// deleteErrors is the error returned by DeleteVolume for a volume ID
+	deleteErrors   map[string]error
+	deletedVolumes []string
+}
+
+func (m *fakeVolumeManager) DeleteVolume(ctx context.Context, volumeID string, deleteDisk bool) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if deleteDisk {
+		return errors.New("unexpected deletion of the disk")
+	}
+	m.deletedVolumes = append(m.deletedVolumes, volumeID)
+	return m.deleteErrors[volumeID]
+}
+
+// newTestVolumeMigration returns a volumeMigration using the given volume manager and a fake client with the given CRs
+func newTestVolumeMigration(t *testing.T, volumeManager cnsvolume.Manager,
+	crs ...*migrationv1alpha1.CnsVSphereVolumeMigration) *volumeMigration {
+	scheme := runtime.NewScheme()
+	if err := migrationv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add the migration types to the scheme: %v", err)
+	}
+	objs := make([]runtime.Object, 0, len(crs))
+	volumeMigration := &volumeMigration{volumeManager: &volumeManager}
+	for _, cr := range crs {
+		objs = append(objs, cr)
+		volumeMigration.volumePathToVolumeID.Store(cr.Spec.VolumePath, cr.Spec.VolumeID)
+	}
+	volumeMigration.k8sClient = fake.NewFakeClientWithScheme(scheme, objs...)
+	return volumeMigration
+}
+
+func newVolumeMigrationCR(volumeID, volumePath string) *migrationv1alpha1.CnsVSphereVolumeMigration {
+	return &migrationv1alpha1.CnsVSphereVolumeMigration{
+		ObjectMeta: metav1.ObjectMeta{Name: volumeID},
+		Spec:       migrationv1alpha1.CnsVSphereVolumeMigrationSpec{VolumePath: volumePath, VolumeID: volumeID},
+	}
+}
+
+func newInTreePV(name, volumePath string, annotations map[string]string) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations},
+		Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+			VsphereVolume: &v1.VsphereVirtualDiskVolumeSource{VolumePath: volumePath}}},
+	}
+}
+
+func TestRollbackMigratedVolumes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	migratedTo := map[string]string{common.AnnMigratedTo: csitypes.Name}
+	rolledBackPV := newInTreePV("pv-rolled-back", "[vsanDatastore] kubevols/rolled-back.vmdk", migratedTo)
+	rolledBackPV.Spec.ClaimRef = &v1.ObjectReference{Namespace: "default", Name: "pvc-rolled-back"}
+	rolledBackPVC := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "default",
+		Name: "pvc-rolled-back", Annotations: map[string]string{common.AnnMigratedTo: csitypes.Name}}}
+	attachedPV := newInTreePV("pv-attached", "[vsanDatastore] kubevols/attached.vmdk",
+		map[string]string{common.AnnMigratedTo: csitypes.Name})
+	attachedPVName := attachedPV.Name
+	volumeAttachment := &storagev1.VolumeAttachment{
+		ObjectMeta: metav1.ObjectMeta{Name: "csi-attached"},
+		Spec: storagev1.VolumeAttachmentSpec{Attacher: csitypes.Name,
+			Source: storagev1.VolumeAttachmentSource{PersistentVolumeName: &attachedPVName}},
+	}
+	notFoundFault := &soap.Fault{String: "volume not found"}
+	notFoundFault.Detail.Fault = vim25types.NotFound{}
+	volumeManager := &fakeVolumeManager{deleteErrors: map[string]error{
+		"volume-failed":       errors.New("CNS is unavailable"),
+		"volume-unregistered": soap.WrapSoapFault(notFoundFault),
+	}}
+	volumeMigration := newTestVolumeMigration(t, volumeManager,
+		newVolumeMigrationCR("volume-rolled-back", rolledBackPV.Spec.VsphereVolume.VolumePath),
+		newVolumeMigrationCR("volume-attached", attachedPV.Spec.VsphereVolume.VolumePath),
+		newVolumeMigrationCR("volume-failed", "[vsanDatastore] kubevols/failed.vmdk"),
+		newVolumeMigrationCR("volume-unregistered", "[vsanDatastore] kubevols/unregistered.vmdk"))
+	k8sClient := k8sfake.NewSimpleClientset(rolledBackPV, rolledBackPVC, attachedPV, volumeAttachment)
+
+	rollback := func(dryRun bool) map[string]VolumeRollbackResult {
+		results, err := volumeMigration.RollbackMigratedVolumes(ctx, k8sClient, dryRun)
+		if err != nil {
+			t.Fatalf("RollbackMigratedVolumes failed: %v", err)
+		}
+		resultsByVolumeID := make(map[string]VolumeRollbackResult)
+		for _, result := range results {
+			resultsByVolumeID[result.VolumeID] = result
+		}
+		if len(resultsByVolumeID) != 4 {
+			t.Fatalf("expected a result for each of the 4 volumes, got %v", results)
+		}
+		return resultsByVolumeID
+	}
+	checkStatuses := func(results map[string]VolumeRollbackResult, expected map[string]string) {
+		for volumeID, status := range expected {
+			if results[volumeID].Status != status {
+				t.Errorf("expected status %q for volume %q, got %+v", status, volumeID, results[volumeID])
+			}
+		}
+	}
+	crExists := func(volumeID string) bool {
+		err := volumeMigration.k8sClient.Get(ctx, client.ObjectKey{Name: volumeID},
+			&migrationv1alpha1.CnsVSphereVolumeMigration{})
+		return err == nil
+	}
+
+	results := rollback(true)
+	checkStatuses(results, map[string]string{
+		"volume-rolled-back":  RollbackStatusWouldRollBack,
+		"volume-attached":     RollbackStatusSkipped,
+		"volume-failed":       RollbackStatusWouldRollBack,
+		"volume-unregistered": RollbackStatusWouldRollBack,
+	})
+	if results["volume-rolled-back"].PVName != rolledBackPV.Name {
+		t.Errorf("expected PV %q for volume-rolled-back, got %q", rolledBackPV.Name, results["volume-rolled-back"].PVName)
+	}
+	if len(volumeManager.deletedVolumes) != 0 {
+		t.Errorf("expected no volume to be unregistered in a dry run, got %v", volumeManager.deletedVolumes)
+	}
+	for _, volumeID := range []string{"volume-rolled-back", "volume-attached", "volume-failed", "volume-unregistered"} {
+		if !crExists(volumeID) {
+			t.Errorf("expected the CR of %q to be kept in a dry run", volumeID)
+		}
+	}
+
+	results = rollback(false)
+	checkStatuses(results, map[string]string{
+		"volume-rolled-back":  RollbackStatusRolledBack,
+		"volume-attached":     RollbackStatusSkipped,
+		"volume-failed":       RollbackStatusFailed,
+		"volume-unregistered": RollbackStatusRolledBack,
+	})
+	if results["volume-failed"].Message == "" {
+		t.Errorf("expected a message for the failed rollback")
+	}
+	if len(volumeManager.deletedVolumes) != 3 {
+		t.Errorf("expected 3 volumes to be unregistered, got %v", volumeManager.deletedVolumes)
+	}
+	for volumeID, exists := range map[string]bool{"volume-rolled-back": false, "volume-attached": true,
+		"volume-failed": true, "volume-unregistered": false} {
+		if crExists(volumeID) != exists {
+			t.Errorf("expected the CR of %q to exist: %v", volumeID, exists)
+		}
+	}
+	if _, found := volumeMigration.GetRegisteredVolumeID(ctx, rolledBackPV.Spec.VsphereVolume.VolumePath); found {
+		t.Errorf("expected the rolled back volume to be removed from the cache")
+	}
+	pv, err := k8sClient.CoreV1().PersistentVolumes().Get(ctx, rolledBackPV.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get PV: %v", err)
+	}
+	if _, found := pv.Annotations[common.AnnMigratedTo]; found {
+		t.Errorf("expected the migrated-to annotation to be removed from the PV, got %v", pv.Annotations)
+	}
+	pvc, err := k8sClient.CoreV1().PersistentVolumeClaims("default").Get(ctx, rolledBackPVC.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get PVC: %v", err)
+	}
+	if _, found := pvc.Annotations[common.AnnMigratedTo]; found {
+		t.Errorf("expected the migrated-to annotation to be removed from the PVC, got %v", pvc.Annotations)
+	}
+	pv, err = k8sClient.CoreV1().PersistentVolumes().Get(ctx, attachedPV.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get PV: %v", err)
+	}
+	if pv.Annotations[common.AnnMigratedTo] != csitypes.Name {
+		t.Errorf("expected the skipped PV to keep its migrated-to annotation, got %v", pv.Annotations)
+	}
+}
+
+func TestRollbackHelpers(t *testing.T) {
+	volumePath := "[vsanDatastore] kubevols/pv-1.vmdk"
+	pvs := []v1.PersistentVolume{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "csi-pv"},
+			Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{Driver: csitypes.Name, VolumeHandle: "volume-1"}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-1"},
+			Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+				VsphereVolume: &v1.VsphereVirtualDiskVolumeSource{VolumePath: volumePath}}},
+		},
+	}
+	pv := findInTreePV(pvs, volumePath)
+	if pv == nil || pv.Name != "pv-1" {
+		t.Fatalf("expected PV pv-1 for %q, got %v", volumePath, pv)
+	}
+	if pv = findInTreePV(pvs, "[vsanDatastore] kubevols/pv-2.vmdk"); pv != nil {
+		t.Errorf("expected no PV, got %v", pv.Name)
+	}
+
+	pvName := "pv-1"
+	volumeAttachments := []storagev1.VolumeAttachment{
+		{Spec: storagev1.VolumeAttachmentSpec{Attacher: common.InTreePluginName,
+			Source: storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName}}},
+	}
+	if isAttachedByCSI(volumeAttachments, pvName) {
+		t.Errorf("expected PV attached by the in-tree plugin not to be attached by CSI")
+	}
+	volumeAttachments[0].Spec.Attacher = csitypes.Name
+	if !isAttachedByCSI(volumeAttachments, pvName) {
+		t.Errorf("expected PV to be attached by CSI")
+	}
+
+	annotations := map[string]string{common.AnnMigratedTo: csitypes.Name, common.AnnDynamicallyProvisioned: common.InTreePluginName}
+	if !removeMigratedToAnnotation(annotations) {
+		t.Errorf("expected the migrated-to annotation to be removed")
+	}
+	if _, found := annotations[common.AnnMigratedTo]; found || annotations[common.AnnDynamicallyProvisioned] != common.InTreePluginName {
+		t.Errorf("unexpected annotations after rollback: %v", annotations)
+	}
+	if removeMigratedToAnnotation(annotations) || removeMigratedToAnnotation(nil) {
+		t.Errorf("expected annotations without migrated-to annotation to be unchanged")
+	}
+}
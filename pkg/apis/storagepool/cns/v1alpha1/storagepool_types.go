@@ -26,11 +26,19 @@ type StoragePoolSpec struct {
 	// Name of the driver
 	Driver string `json:"driver"`
 
-	// Opaque parameters describing attributes of the storage pool
+	// Opaque parameters describing attributes of the storage pool. The drainPreviewMode parameter,
+	// "ensureAccessibility" or "evacuateAll", requests a dry-run of the disk decommission of the storage pool.
 	// +optional
 	Parameters map[string]string `json:"parameters,omitempty"`
 }
 
+// Well-known keys of StoragePoolSpec.Parameters
+const (
+	// ParameterDrainPreviewMode requests a dry-run of the disk decommission of the storage pool in the
+	// given mode, "ensureAccessibility" or "evacuateAll". The plan is reported in StoragePoolStatus.DrainPreview.
+	ParameterDrainPreviewMode = "drainPreviewMode"
+)
+
 // StoragePoolStatus defines the observed state of StoragePool
 type StoragePoolStatus struct {
 	// Nodes the storage pool has access to
@@ -45,6 +53,85 @@ type StoragePoolStatus struct {
 	// Error that has occurred on the storage pool. Present only when there is an error.
 	// +optional
 	Error StoragePoolError `json:"error,omitempty"`
+	// Conditions of the storage pool, e.g. the forecast exhaustion of its capacity
+	// +optional
+	Conditions []StoragePoolCondition `json:"conditions,omitempty"`
+	// Status of the disk decommission of the storage pool. Present only when a disk decommission is requested.
+	// +optional
+	DiskDecomm *DiskDecommStatus `json:"diskDecomm,omitempty"`
+	// Storage vMotion plan that decommissioning the storage pool in the mode requested by the
+	// drainPreviewMode parameter would execute. Present only when a drain preview is requested.
+	// +optional
+	DrainPreview *DrainPreview `json:"drainPreview,omitempty"`
+}
+
+// DiskDecommStatus is the status of the disk decommission of a storage pool
+type DiskDecommStatus struct {
+	// Status of the disk decommission, "done" or "fail" once it is over
+	// +optional
+	Status string `json:"status,omitempty"`
+	// Reason of the failure of the disk decommission
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Migrations maps the <namespace>/<name> of the PVCs migrated away from the storage pool to the status
+	// of their migration. The migrations are cleared once the disk decommission is done or cancelled.
+	// +optional
+	Migrations map[string]VolumeMigrationStatus `json:"migrations,omitempty"`
+}
+
+// VolumeMigrationStatus is the status of the migration of a PVC away from a storage pool under disk decommission
+type VolumeMigrationStatus struct {
+	PVCName           string `json:"pvcName"`
+	PVCNamespace      string `json:"pvcNamespace"`
+	TargetStoragePool string `json:"targetStoragePool"`
+	// State is one of "queued", "running", "done" or "failed"
+	State string `json:"state"`
+	// Attempts is the number of relocations of the volume attempted so far
+	Attempts int64 `json:"attempts"`
+	// LastError is the error of the last failed relocation, if any
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+	// LastTransitionTime is the time of the last change of State
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+}
+
+// DrainPreview is the storage vMotion plan that decommissioning a storage pool would execute
+type DrainPreview struct {
+	// MaintenanceMode is the disk decommission mode the plan is computed for
+	MaintenanceMode string `json:"maintenanceMode"`
+	// GeneratedAt is the time the plan was computed at
+	GeneratedAt metav1.Time `json:"generatedAt"`
+	// Error is the reason no plan could be computed, if any
+	// +optional
+	Error string `json:"error,omitempty"`
+	// Migrations are the volumes which would be migrated along with their target storage pool
+	// +optional
+	Migrations []PlannedMigration `json:"migrations,omitempty"`
+	// UnplaceableVolumes are the volumes for which no target storage pool could be found
+	// +optional
+	UnplaceableVolumes []UnplaceableVolume `json:"unplaceableVolumes,omitempty"`
+	// ProjectedFreeSpace maps the names of the vSAN Direct storage pools to their free space
+	// in bytes once the migrations are done
+	// +optional
+	ProjectedFreeSpace map[string]int64 `json:"projectedFreeSpace,omitempty"`
+}
+
+// PlannedMigration is the migration of a volume to a target storage pool
+type PlannedMigration struct {
+	PVName            string `json:"pvName"`
+	PVCName           string `json:"pvcName"`
+	PVCNamespace      string `json:"pvcNamespace"`
+	SizeInBytes       int64  `json:"sizeInBytes"`
+	TargetStoragePool string `json:"targetStoragePool"`
+}
+
+// UnplaceableVolume is a volume for which no target storage pool could be found
+type UnplaceableVolume struct {
+	PVName       string `json:"pvName"`
+	PVCName      string `json:"pvcName"`
+	PVCNamespace string `json:"pvcNamespace"`
+	SizeInBytes  int64  `json:"sizeInBytes"`
+	Reason       string `json:"reason"`
 }
 
 // PoolCapacity is the storage capacity of the storage pool
@@ -55,8 +142,38 @@ type PoolCapacity struct {
 	// Free Space of the storage pool
 	// +optional
 	FreeSpace *resource.Quantity `json:"freeSpace,omitempty"`
+	// Rate at which the used space of the storage pool grows per day, negative when it shrinks
+	// +optional
+	FillRatePerDay *resource.Quantity `json:"fillRatePerDay,omitempty"`
+	// Number of days until the storage pool is full at its current fill rate. Present only when it is filling up.
+	// +optional
+	DaysToFull *int64 `json:"daysToFull,omitempty"`
 }
 
+// StoragePoolCondition describes the state of the storage pool at a certain point
+type StoragePoolCondition struct {
+	// Type of the condition, e.g. "CapacityExhaustion"
+	Type string `json:"type"`
+	// Status of the condition, one of "True", "False" or "Unknown"
+	Status string `json:"status"`
+	// Reason is a single word description of the last transition of the condition
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Message details the last transition of the condition
+	// +optional
+	Message string `json:"message,omitempty"`
+	// LastTransitionTime is the last time the status of the condition changed
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// Conditions used in StoragePool.Status.Conditions
+const (
+	// ConditionCapacityExhaustion is True when the storage pool is forecast to be full within the configured
+	// number of days at its current fill rate
+	ConditionCapacityExhaustion = "CapacityExhaustion"
+)
+
 // StoragePoolError describes an error encountered on the pool
 type StoragePoolError struct {
 	// State indicates a single word description of the error state that has occurred on the StoragePool,
@@ -24,13 +24,122 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskDecommStatus) DeepCopyInto(out *DiskDecommStatus) {
+	*out = *in
+	if in.Migrations != nil {
+		in, out := &in.Migrations, &out.Migrations
+		*out = make(map[string]VolumeMigrationStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskDecommStatus.
+func (in *DiskDecommStatus) DeepCopy() *DiskDecommStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskDecommStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DrainPreview) DeepCopyInto(out *DrainPreview) {
+	*out = *in
+	in.GeneratedAt.DeepCopyInto(&out.GeneratedAt)
+	if in.Migrations != nil {
+		in, out := &in.Migrations, &out.Migrations
+		*out = make([]PlannedMigration, len(*in))
+		copy(*out, *in)
+	}
+	if in.UnplaceableVolumes != nil {
+		in, out := &in.UnplaceableVolumes, &out.UnplaceableVolumes
+		*out = make([]UnplaceableVolume, len(*in))
+		copy(*out, *in)
+	}
+	if in.ProjectedFreeSpace != nil {
+		in, out := &in.ProjectedFreeSpace, &out.ProjectedFreeSpace
+		*out = make(map[string]int64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DrainPreview.
+func (in *DrainPreview) DeepCopy() *DrainPreview {
+	if in == nil {
+		return nil
+	}
+	out := new(DrainPreview)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlannedMigration) DeepCopyInto(out *PlannedMigration) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlannedMigration.
+func (in *PlannedMigration) DeepCopy() *PlannedMigration {
+	if in == nil {
+		return nil
+	}
+	out := new(PlannedMigration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PoolCapacity) DeepCopyInto(out *PoolCapacity) {
+	*out = *in
+	if in.Total != nil {
+		in, out := &in.Total, &out.Total
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.FreeSpace != nil {
+		in, out := &in.FreeSpace, &out.FreeSpace
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.FillRatePerDay != nil {
+		in, out := &in.FillRatePerDay, &out.FillRatePerDay
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.DaysToFull != nil {
+		in, out := &in.DaysToFull, &out.DaysToFull
+		*out = new(int64)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PoolCapacity.
+func (in *PoolCapacity) DeepCopy() *PoolCapacity {
+	if in == nil {
+		return nil
+	}
+	out := new(PoolCapacity)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StoragePool) DeepCopyInto(out *StoragePool) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -52,6 +161,39 @@ func (in *StoragePool) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StoragePoolCondition) DeepCopyInto(out *StoragePoolCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StoragePoolCondition.
+func (in *StoragePoolCondition) DeepCopy() *StoragePoolCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(StoragePoolCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StoragePoolError) DeepCopyInto(out *StoragePoolError) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StoragePoolError.
+func (in *StoragePoolError) DeepCopy() *StoragePoolError {
+	if in == nil {
+		return nil
+	}
+	out := new(StoragePoolError)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StoragePoolList) DeepCopyInto(out *StoragePoolList) {
 	*out = *in
@@ -88,6 +230,13 @@ func (in *StoragePoolList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StoragePoolSpec) DeepCopyInto(out *StoragePoolSpec) {
 	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -104,6 +253,39 @@ func (in *StoragePoolSpec) DeepCopy() *StoragePoolSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StoragePoolStatus) DeepCopyInto(out *StoragePoolStatus) {
 	*out = *in
+	if in.AccessibleNodes != nil {
+		in, out := &in.AccessibleNodes, &out.AccessibleNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CompatibleStorageClasses != nil {
+		in, out := &in.CompatibleStorageClasses, &out.CompatibleStorageClasses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Capacity != nil {
+		in, out := &in.Capacity, &out.Capacity
+		*out = new(PoolCapacity)
+		(*in).DeepCopyInto(*out)
+	}
+	out.Error = in.Error
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]StoragePoolCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DiskDecomm != nil {
+		in, out := &in.DiskDecomm, &out.DiskDecomm
+		*out = new(DiskDecommStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DrainPreview != nil {
+		in, out := &in.DrainPreview, &out.DrainPreview
+		*out = new(DrainPreview)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -116,3 +298,36 @@ func (in *StoragePoolStatus) DeepCopy() *StoragePoolStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UnplaceableVolume) DeepCopyInto(out *UnplaceableVolume) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UnplaceableVolume.
+func (in *UnplaceableVolume) DeepCopy() *UnplaceableVolume {
+	if in == nil {
+		return nil
+	}
+	out := new(UnplaceableVolume)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeMigrationStatus) DeepCopyInto(out *VolumeMigrationStatus) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeMigrationStatus.
+func (in *VolumeMigrationStatus) DeepCopy() *VolumeMigrationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeMigrationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
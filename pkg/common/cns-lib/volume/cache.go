@@ -0,0 +1,329 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	"github.com/vmware/govmomi/object"
+	vim25types "github.com/vmware/govmomi/vim25/types"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+)
+
+// consistentReadKey is the context key used to request reads bypassing the volume cache.
+type consistentReadKey struct{}
+
+// WithConsistentRead returns a context which makes QueryVolume calls on a caching
+// Manager skip the cache and fetch the volumes from CNS. The fetched volumes are
+// still used to refresh the cache.
+func WithConsistentRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, consistentReadKey{}, true)
+}
+
+// isConsistentRead returns true if the context requests reads bypassing the cache.
+func isConsistentRead(ctx context.Context) bool {
+	consistent, ok := ctx.Value(consistentReadKey{}).(bool)
+	return ok && consistent
+}
+
+// cachedVolume holds a CNS volume along with its expiration time.
+type cachedVolume struct {
+	volume         cnstypes.CnsVolume
+	expirationTime time.Time
+}
+
+// cachingManager is a Manager which serves QueryVolume calls filtered only by
+// volume IDs from a read-through cache and delegates all other calls to the
+// wrapped Manager. Cached volumes are invalidated when they are mutated through
+// this Manager and expire after the configured TTL. Callers get copies of the
+// cached volumes, so that they cannot modify the cache.
+type cachingManager struct {
+	Manager
+	ttl time.Duration
+	// lock protects volumes and generation.
+	lock    sync.Mutex
+	volumes map[string]*cachedVolume
+	// generation is incremented on every invalidation, so that results of
+	// queries racing with a mutation are not stored in the cache.
+	generation uint64
+}
+
+// NewCachingManager returns a Manager caching the results of QueryVolume calls
+// filtered only by volume IDs for the given TTL. If ttl is not positive, the
+// specified Manager is returned as is.
+func NewCachingManager(manager Manager, ttl time.Duration) Manager {
+	if ttl <= 0 {
+		return manager
+	}
+	return &cachingManager{
+		Manager: manager,
+		ttl:     ttl,
+		volumes: make(map[string]*cachedVolume),
+	}
+}
+
+// GetManagerWithQueryCache returns the Manager instance for the specified vCenter,
+// wrapped with a QueryVolume cache if ttl is positive.
+func GetManagerWithQueryCache(ctx context.Context, vc *cnsvsphere.VirtualCenter, ttl time.Duration) Manager {
+	log := logger.GetLogger(ctx)
+	manager := GetManager(ctx, vc)
+	if ttl > 0 {
+		log.Infof("Enabling QueryVolume cache with TTL %v", ttl)
+	}
+	return NewCachingManager(manager, ttl)
+}
+
+// isVolumeIDOnlyFilter returns true if queryFilter selects volumes only by their IDs.
+func isVolumeIDOnlyFilter(queryFilter cnstypes.CnsQueryFilter) bool {
+	if len(queryFilter.VolumeIds) == 0 {
+		return false
+	}
+	idOnlyFilter := cnstypes.CnsQueryFilter{VolumeIds: queryFilter.VolumeIds}
+	return reflect.DeepEqual(queryFilter, idOnlyFilter)
+}
+
+// QueryVolume returns volumes matching the given filter. Queries filtered only by
+// volume IDs are served from the cache when all requested volumes are cached and
+// the context does not request a consistent read.
+func (m *cachingManager) QueryVolume(ctx context.Context, queryFilter cnstypes.CnsQueryFilter) (*cnstypes.CnsQueryResult, error) {
+	log := logger.GetLogger(ctx)
+	if !isVolumeIDOnlyFilter(queryFilter) {
+		return m.Manager.QueryVolume(ctx, queryFilter)
+	}
+	var (
+		cached  []cnstypes.CnsVolume
+		missing []cnstypes.CnsVolumeId
+	)
+	if isConsistentRead(ctx) {
+		missing = queryFilter.VolumeIds
+	} else {
+		cached, missing = m.lookup(queryFilter.VolumeIds)
+		if len(missing) == 0 {
+			log.Debugf("QueryVolume: serving %d volume(s) from cache", len(cached))
+			return &cnstypes.CnsQueryResult{Volumes: cached}, nil
+		}
+	}
+	m.lock.Lock()
+	generation := m.generation
+	m.lock.Unlock()
+
+	res, err := m.Manager.QueryVolume(ctx, cnstypes.CnsQueryFilter{VolumeIds: missing})
+	if err != nil {
+		return nil, err
+	}
+	m.store(generation, res.Volumes)
+	res.Volumes = append(cached, res.Volumes...)
+	return res, nil
+}
+
+// lookup returns the unexpired cached volumes for the specified IDs along with the
+// IDs which are not present in the cache.
+func (m *cachingManager) lookup(volumeIDs []cnstypes.CnsVolumeId) ([]cnstypes.CnsVolume, []cnstypes.CnsVolumeId) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var (
+		cached  []cnstypes.CnsVolume
+		missing []cnstypes.CnsVolumeId
+	)
+	now := time.Now()
+	for _, volumeID := range volumeIDs {
+		entry, ok := m.volumes[volumeID.Id]
+		if ok && now.Before(entry.expirationTime) {
+			cached = append(cached, copyVolume(entry.volume))
+			continue
+		}
+		if ok {
+			delete(m.volumes, volumeID.Id)
+		}
+		missing = append(missing, volumeID)
+	}
+	return cached, missing
+}
+
+// store caches the specified volumes unless the cache was invalidated after
+// generation was read.
+func (m *cachingManager) store(generation uint64, volumes []cnstypes.CnsVolume) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if generation != m.generation {
+		return
+	}
+	expirationTime := time.Now().Add(m.ttl)
+	for _, volume := range volumes {
+		m.volumes[volume.VolumeId.Id] = &cachedVolume{
+			volume:         copyVolume(volume),
+			expirationTime: expirationTime,
+		}
+	}
+}
+
+// copyVolume returns a deep copy of the specified volume.
+func copyVolume(volume cnstypes.CnsVolume) cnstypes.CnsVolume {
+	var copied cnstypes.CnsVolume
+	deepCopyValue(reflect.ValueOf(&copied).Elem(), reflect.ValueOf(volume))
+	return copied
+}
+
+// deepCopyValue copies src into dst, allocating new pointers, interfaces, slices
+// and maps. The govmomi types have no DeepCopy methods and CNS volumes hold
+// interfaces, such as their backing object details, hence the reflection.
+// Unexported struct fields are copied as is.
+func deepCopyValue(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		copied := reflect.New(src.Elem().Type())
+		deepCopyValue(copied.Elem(), src.Elem())
+		dst.Set(copied)
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		copied := reflect.New(src.Elem().Type()).Elem()
+		deepCopyValue(copied, src.Elem())
+		dst.Set(copied)
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		copied := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			deepCopyValue(copied.Index(i), src.Index(i))
+		}
+		dst.Set(copied)
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		copied := reflect.MakeMapWithSize(src.Type(), src.Len())
+		for _, key := range src.MapKeys() {
+			value := reflect.New(src.Type().Elem()).Elem()
+			deepCopyValue(value, src.MapIndex(key))
+			copied.SetMapIndex(key, value)
+		}
+		dst.Set(copied)
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			deepCopyValue(dst.Index(i), src.Index(i))
+		}
+	case reflect.Struct:
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				deepCopyValue(dst.Field(i), src.Field(i))
+			}
+		}
+	default:
+		dst.Set(src)
+	}
+}
+
+// invalidate removes the specified volumes from the cache.
+func (m *cachingManager) invalidate(volumeIDs ...string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.generation++
+	for _, volumeID := range volumeIDs {
+		delete(m.volumes, volumeID)
+	}
+}
+
+// invalidateAll removes all volumes from the cache.
+func (m *cachingManager) invalidateAll() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.generation++
+	m.volumes = make(map[string]*cachedVolume)
+}
+
+// CreateVolume creates a new volume given its spec.
+func (m *cachingManager) CreateVolume(ctx context.Context, spec *cnstypes.CnsVolumeCreateSpec) (*CnsVolumeInfo, error) {
+	volumeInfo, err := m.Manager.CreateVolume(ctx, spec)
+	if volumeInfo != nil {
+		m.invalidate(volumeInfo.VolumeID.Id)
+	}
+	return volumeInfo, err
+}
+
+// AttachVolume attaches a volume to a virtual machine given the spec.
+func (m *cachingManager) AttachVolume(ctx context.Context, vm *cnsvsphere.VirtualMachine, volumeID string) (string, error) {
+	defer m.invalidate(volumeID)
+	return m.Manager.AttachVolume(ctx, vm, volumeID)
+}
+
+// DetachVolume detaches a volume from the virtual machine given the spec.
+func (m *cachingManager) DetachVolume(ctx context.Context, vm *cnsvsphere.VirtualMachine, volumeID string) error {
+	defer m.invalidate(volumeID)
+	return m.Manager.DetachVolume(ctx, vm, volumeID)
+}
+
+// DeleteVolume deletes a volume given its spec.
+func (m *cachingManager) DeleteVolume(ctx context.Context, volumeID string, deleteDisk bool) error {
+	defer m.invalidate(volumeID)
+	return m.Manager.DeleteVolume(ctx, volumeID, deleteDisk)
+}
+
+// UpdateVolumeMetadata updates a volume metadata given its spec.
+func (m *cachingManager) UpdateVolumeMetadata(ctx context.Context, spec *cnstypes.CnsVolumeMetadataUpdateSpec) error {
+	defer m.invalidate(spec.VolumeId.Id)
+	return m.Manager.UpdateVolumeMetadata(ctx, spec)
+}
+
+// RelocateVolume migrates volumes to their target datastore as specified in relocateSpecList.
+// The relocation completes asynchronously, so callers waiting on the returned task
+// should use WithConsistentRead to query the relocated volumes.
+func (m *cachingManager) RelocateVolume(ctx context.Context, relocateSpecList ...cnstypes.BaseCnsVolumeRelocateSpec) (*object.Task, error) {
+	var volumeIDs []string
+	for _, relocateSpec := range relocateSpecList {
+		volumeIDs = append(volumeIDs, relocateSpec.GetCnsVolumeRelocateSpec().VolumeId.Id)
+	}
+	defer m.invalidate(volumeIDs...)
+	return m.Manager.RelocateVolume(ctx, relocateSpecList...)
+}
+
+// ExpandVolume expands a volume to a new size.
+func (m *cachingManager) ExpandVolume(ctx context.Context, volumeID string, size int64) error {
+	defer m.invalidate(volumeID)
+	return m.Manager.ExpandVolume(ctx, volumeID, size)
+}
+
+// ResetManager helps set new manager instance and VC configuration
+func (m *cachingManager) ResetManager(ctx context.Context, vcenter *cnsvsphere.VirtualCenter) {
+	defer m.invalidateAll()
+	m.Manager.ResetManager(ctx, vcenter)
+}
+
+// ConfigureVolumeACLs configures net permissions for a given CnsVolumeACLConfigureSpec
+func (m *cachingManager) ConfigureVolumeACLs(ctx context.Context, spec cnstypes.CnsVolumeACLConfigureSpec) error {
+	defer m.invalidate(spec.VolumeId.Id)
+	return m.Manager.ConfigureVolumeACLs(ctx, spec)
+}
+
+// UpdateVolumeStoragePolicy applies a storage policy to a block volume on the given datastore without relocating it.
+func (m *cachingManager) UpdateVolumeStoragePolicy(ctx context.Context, volumeID string,
+	datastore vim25types.ManagedObjectReference, storagePolicyID string) error {
+	defer m.invalidate(volumeID)
+	return m.Manager.UpdateVolumeStoragePolicy(ctx, volumeID, datastore, storagePolicyID)
+}
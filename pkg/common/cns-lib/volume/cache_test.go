@@ -0,0 +1,187 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	vim25types "github.com/vmware/govmomi/vim25/types"
+)
+
+// fakeQueryManager is a Manager counting QueryVolume calls and returning a
+// volume with the current storage policy for every requested volume ID.
+type fakeQueryManager struct {
+	Manager
+	queriedIDs      []string
+	storagePolicyID string
+}
+
+func (m *fakeQueryManager) QueryVolume(ctx context.Context, queryFilter cnstypes.CnsQueryFilter) (*cnstypes.CnsQueryResult, error) {
+	res := &cnstypes.CnsQueryResult{}
+	for _, volumeID := range queryFilter.VolumeIds {
+		m.queriedIDs = append(m.queriedIDs, volumeID.Id)
+		res.Volumes = append(res.Volumes, cnstypes.CnsVolume{
+			VolumeId:        volumeID,
+			StoragePolicyId: m.storagePolicyID,
+			Metadata: cnstypes.CnsVolumeMetadata{
+				EntityMetadata: []cnstypes.BaseCnsEntityMetadata{
+					&cnstypes.CnsKubernetesEntityMetadata{CnsEntityMetadata: cnstypes.CnsEntityMetadata{EntityName: "pvc-1"}},
+				},
+			},
+			BackingObjectDetails: &cnstypes.CnsBlockBackingDetails{BackingDiskId: volumeID.Id},
+		})
+	}
+	return res, nil
+}
+
+func (m *fakeQueryManager) UpdateVolumeStoragePolicy(ctx context.Context, volumeID string,
+	datastore vim25types.ManagedObjectReference, storagePolicyID string) error {
+	m.storagePolicyID = storagePolicyID
+	return nil
+}
+
+func (m *fakeQueryManager) ExpandVolume(ctx context.Context, volumeID string, size int64) error {
+	return nil
+}
+
+func idFilter(volumeIDs ...string) cnstypes.CnsQueryFilter {
+	queryFilter := cnstypes.CnsQueryFilter{}
+	for _, volumeID := range volumeIDs {
+		queryFilter.VolumeIds = append(queryFilter.VolumeIds, cnstypes.CnsVolumeId{Id: volumeID})
+	}
+	return queryFilter
+}
+
+func TestCachingManagerQueryVolume(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeQueryManager{}
+	manager := NewCachingManager(fake, time.Minute)
+
+	if _, err := manager.QueryVolume(ctx, idFilter("vol-1", "vol-2")); err != nil {
+		t.Fatalf("QueryVolume failed: %v", err)
+	}
+	// Served from cache except for vol-3.
+	res, err := manager.QueryVolume(ctx, idFilter("vol-1", "vol-3"))
+	if err != nil {
+		t.Fatalf("QueryVolume failed: %v", err)
+	}
+	if len(res.Volumes) != 2 {
+		t.Errorf("expected 2 volumes, got %d", len(res.Volumes))
+	}
+	if len(fake.queriedIDs) != 3 || fake.queriedIDs[2] != "vol-3" {
+		t.Errorf("unexpected volumes queried from CNS: %v", fake.queriedIDs)
+	}
+	// Mutations invalidate the cached volume.
+	if err := manager.ExpandVolume(ctx, "vol-1", 1024); err != nil {
+		t.Fatalf("ExpandVolume failed: %v", err)
+	}
+	if _, err := manager.QueryVolume(ctx, idFilter("vol-1")); err != nil {
+		t.Fatalf("QueryVolume failed: %v", err)
+	}
+	if len(fake.queriedIDs) != 4 || fake.queriedIDs[3] != "vol-1" {
+		t.Errorf("expected vol-1 to be queried from CNS after invalidation, got: %v", fake.queriedIDs)
+	}
+	// Consistent reads bypass the cache.
+	if _, err := manager.QueryVolume(WithConsistentRead(ctx), idFilter("vol-2")); err != nil {
+		t.Fatalf("QueryVolume failed: %v", err)
+	}
+	if len(fake.queriedIDs) != 5 {
+		t.Errorf("expected consistent read to query CNS, got: %v", fake.queriedIDs)
+	}
+	// Filters other than volume IDs are not cached.
+	queryFilter := idFilter("vol-2")
+	queryFilter.ContainerClusterIds = []string{"cluster-1"}
+	if _, err := manager.QueryVolume(ctx, queryFilter); err != nil {
+		t.Fatalf("QueryVolume failed: %v", err)
+	}
+	if len(fake.queriedIDs) != 6 {
+		t.Errorf("expected non volume ID filter to query CNS, got: %v", fake.queriedIDs)
+	}
+}
+
+func TestCachingManagerExpiry(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeQueryManager{}
+	manager := NewCachingManager(fake, time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		if _, err := manager.QueryVolume(ctx, idFilter("vol-1")); err != nil {
+			t.Fatalf("QueryVolume failed: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(fake.queriedIDs) != 2 {
+		t.Errorf("expected expired volume to be queried again, got: %v", fake.queriedIDs)
+	}
+	if NewCachingManager(fake, 0) != Manager(fake) {
+		t.Errorf("expected caching to be disabled for zero TTL")
+	}
+}
+
+func TestCachingManagerStoragePolicyUpdate(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeQueryManager{storagePolicyID: "policy-1"}
+	manager := NewCachingManager(fake, time.Minute)
+
+	if _, err := manager.QueryVolume(ctx, idFilter("vol-1")); err != nil {
+		t.Fatalf("QueryVolume failed: %v", err)
+	}
+	err := manager.UpdateVolumeStoragePolicy(ctx, "vol-1", vim25types.ManagedObjectReference{Type: "Datastore", Value: "ds-1"}, "policy-2")
+	if err != nil {
+		t.Fatalf("UpdateVolumeStoragePolicy failed: %v", err)
+	}
+	res, err := manager.QueryVolume(ctx, idFilter("vol-1"))
+	if err != nil {
+		t.Fatalf("QueryVolume failed: %v", err)
+	}
+	if len(res.Volumes) != 1 || res.Volumes[0].StoragePolicyId != "policy-2" {
+		t.Errorf("expected the updated storage policy after invalidation, got: %+v", res.Volumes)
+	}
+}
+
+func TestCachingManagerReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeQueryManager{storagePolicyID: "policy-1"}
+	manager := NewCachingManager(fake, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		// The first query is served by CNS and the second one from the cache.
+		res, err := manager.QueryVolume(ctx, idFilter("vol-1"))
+		if err != nil {
+			t.Fatalf("QueryVolume failed: %v", err)
+		}
+		res.Volumes[0].StoragePolicyId = "modified"
+		res.Volumes[0].Metadata.EntityMetadata[0].GetCnsEntityMetadata().EntityName = "modified"
+		res.Volumes[0].BackingObjectDetails.(*cnstypes.CnsBlockBackingDetails).BackingDiskId = "modified"
+	}
+	res, err := manager.QueryVolume(ctx, idFilter("vol-1"))
+	if err != nil {
+		t.Fatalf("QueryVolume failed: %v", err)
+	}
+	if len(fake.queriedIDs) != 1 {
+		t.Fatalf("expected vol-1 to be served from the cache, got: %v", fake.queriedIDs)
+	}
+	volume := res.Volumes[0]
+	if volume.StoragePolicyId != "policy-1" ||
+		volume.Metadata.EntityMetadata[0].GetCnsEntityMetadata().EntityName != "pvc-1" ||
+		volume.BackingObjectDetails.(*cnstypes.CnsBlockBackingDetails).BackingDiskId != "vol-1" {
+		t.Errorf("expected the cached volume not to be modified by callers, got: %+v", volume)
+	}
+}
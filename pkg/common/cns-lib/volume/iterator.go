@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"context"
+	"errors"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+)
+
+// QueryVolumeIterator pages through the volumes matching a query filter using
+// the CNS query cursor, so that callers can process very large inventories
+// without holding all volumes in memory.
+type QueryVolumeIterator struct {
+	manager     Manager
+	queryFilter cnstypes.CnsQueryFilter
+	done        bool
+}
+
+// NewQueryVolumeIterator returns a QueryVolumeIterator over the volumes matching
+// queryFilter, retrieving at most pageSize volumes per CNS QueryVolume call.
+// Any cursor already set in queryFilter is replaced.
+func NewQueryVolumeIterator(manager Manager, queryFilter cnstypes.CnsQueryFilter, pageSize int64) *QueryVolumeIterator {
+	queryFilter.Cursor = &cnstypes.CnsCursor{
+		Offset: 0,
+		Limit:  pageSize,
+	}
+	return &QueryVolumeIterator{
+		manager:     manager,
+		queryFilter: queryFilter,
+	}
+}
+
+// Done returns true if all pages have been retrieved.
+func (it *QueryVolumeIterator) Done() bool {
+	return it.done
+}
+
+// Next retrieves the next page of volumes. Calling Next after all pages have
+// been retrieved returns an error.
+func (it *QueryVolumeIterator) Next(ctx context.Context) ([]cnstypes.CnsVolume, error) {
+	log := logger.GetLogger(ctx)
+	if it.done {
+		return nil, errors.New("all volumes have already been queried")
+	}
+	log.Debugf("Query volumes with offset: %v and limit: %v", it.queryFilter.Cursor.Offset, it.queryFilter.Cursor.Limit)
+	queryResult, err := it.manager.QueryVolume(ctx, it.queryFilter)
+	if err != nil {
+		log.Errorf("failed to QueryVolume using filter: %+v. err: %v", it.queryFilter, err)
+		return nil, err
+	}
+	if queryResult == nil {
+		log.Info("Observed empty queryResult")
+		it.done = true
+		return nil, nil
+	}
+	log.Debugf("%v more volumes to be queried", queryResult.Cursor.TotalRecords-queryResult.Cursor.Offset)
+	if len(queryResult.Volumes) == 0 || queryResult.Cursor.Offset >= queryResult.Cursor.TotalRecords {
+		it.done = true
+	} else {
+		cursor := queryResult.Cursor
+		it.queryFilter.Cursor = &cursor
+	}
+	return queryResult.Volumes, nil
+}
+
+// ForEachVolumePage calls fn with every page of volumes matching queryFilter.
+// Iteration stops at the first error returned by CNS or by fn.
+func ForEachVolumePage(ctx context.Context, manager Manager, queryFilter cnstypes.CnsQueryFilter,
+	pageSize int64, fn func(volumes []cnstypes.CnsVolume) error) error {
+	it := NewQueryVolumeIterator(manager, queryFilter, pageSize)
+	for !it.Done() {
+		volumes, err := it.Next(ctx)
+		if err != nil {
+			return err
+		}
+		if len(volumes) == 0 {
+			continue
+		}
+		if err := fn(volumes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
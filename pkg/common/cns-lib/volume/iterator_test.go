@@ -0,0 +1,85 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+)
+
+// fakePagingManager is a Manager returning totalVolumes volumes page by page
+// according to the cursor in the query filter.
+type fakePagingManager struct {
+	Manager
+	totalVolumes int64
+	queries      int
+}
+
+func (m *fakePagingManager) QueryVolume(ctx context.Context, queryFilter cnstypes.CnsQueryFilter) (*cnstypes.CnsQueryResult, error) {
+	m.queries++
+	res := &cnstypes.CnsQueryResult{}
+	offset := queryFilter.Cursor.Offset
+	for i := offset; i < m.totalVolumes && i < offset+queryFilter.Cursor.Limit; i++ {
+		res.Volumes = append(res.Volumes, cnstypes.CnsVolume{VolumeId: cnstypes.CnsVolumeId{Id: fmt.Sprintf("vol-%d", i)}})
+	}
+	res.Cursor = cnstypes.CnsCursor{
+		Offset:       offset + int64(len(res.Volumes)),
+		Limit:        queryFilter.Cursor.Limit,
+		TotalRecords: m.totalVolumes,
+	}
+	return res, nil
+}
+
+func TestForEachVolumePage(t *testing.T) {
+	tests := []struct {
+		totalVolumes  int64
+		pageSize      int64
+		expectedPages int
+	}{
+		{totalVolumes: 0, pageSize: 10, expectedPages: 0},
+		{totalVolumes: 10, pageSize: 10, expectedPages: 1},
+		{totalVolumes: 25, pageSize: 10, expectedPages: 3},
+	}
+	for _, test := range tests {
+		fake := &fakePagingManager{totalVolumes: test.totalVolumes}
+		var pages int
+		seen := make(map[string]bool)
+		err := ForEachVolumePage(context.Background(), fake, cnstypes.CnsQueryFilter{}, test.pageSize,
+			func(volumes []cnstypes.CnsVolume) error {
+				pages++
+				if int64(len(volumes)) > test.pageSize {
+					t.Errorf("page of %d volumes exceeds page size %d", len(volumes), test.pageSize)
+				}
+				for _, volume := range volumes {
+					seen[volume.VolumeId.Id] = true
+				}
+				return nil
+			})
+		if err != nil {
+			t.Fatalf("ForEachVolumePage failed: %v", err)
+		}
+		if pages != test.expectedPages {
+			t.Errorf("expected %d pages for %d volumes, got %d", test.expectedPages, test.totalVolumes, pages)
+		}
+		if int64(len(seen)) != test.totalVolumes {
+			t.Errorf("expected %d distinct volumes, got %d", test.totalVolumes, len(seen))
+		}
+	}
+}
@@ -23,7 +23,9 @@ import (
 	"sync"
 	"time"
 
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/prometheus"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/tracing"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/vmware/govmomi/cns"
@@ -32,6 +34,7 @@ import (
 	"github.com/vmware/govmomi/property"
 	"github.com/vmware/govmomi/vim25/mo"
 	vim25types "github.com/vmware/govmomi/vim25/types"
+	"go.opentelemetry.io/otel/attribute"
 
 	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/vsphere"
 )
@@ -75,6 +78,8 @@ type Manager interface {
 	ResetManager(ctx context.Context, vcenter *cnsvsphere.VirtualCenter)
 	// ConfigureVolumeACLs configures net permissions for a given CnsVolumeACLConfigureSpec
 	ConfigureVolumeACLs(ctx context.Context, spec cnstypes.CnsVolumeACLConfigureSpec) error
+	// UpdateVolumeStoragePolicy applies a storage policy to a block volume on the given datastore without relocating it.
+	UpdateVolumeStoragePolicy(ctx context.Context, volumeID string, datastore vim25types.ManagedObjectReference, storagePolicyID string) error
 }
 
 // CnsVolumeInfo hold information related to volume created by CNS
@@ -160,9 +165,11 @@ func (m *defaultManager) ResetManager(ctx context.Context, vcenter *cnsvsphere.V
 }
 
 // CreateVolume creates a new volume given its spec.
-func (m *defaultManager) CreateVolume(ctx context.Context, spec *cnstypes.CnsVolumeCreateSpec) (*CnsVolumeInfo, error) {
+func (m *defaultManager) CreateVolume(ctx context.Context, spec *cnstypes.CnsVolumeCreateSpec) (_ *CnsVolumeInfo, err error) {
 	log := logger.GetLogger(ctx)
-	err := validateManager(ctx, m)
+	ctx, span := tracing.StartSpan(ctx, "cns.CreateVolume", attribute.String("cns.volume.name", spec.Name))
+	defer func() { tracing.EndSpan(span, err) }()
+	err = validateManager(ctx, m)
 	if err != nil {
 		return nil, err
 	}
@@ -233,7 +240,7 @@ func (m *defaultManager) CreateVolume(ctx context.Context, spec *cnstypes.CnsVol
 		}
 	}
 	// Get the taskInfo
-	taskInfo, err = cns.GetTaskInfo(ctx, task)
+	taskInfo, err = getTaskInfo(ctx, task, prometheus.PrometheusCreateVolumeOpType)
 	if err != nil || taskInfo == nil {
 		log.Errorf("failed to get taskInfo for CreateVolume task from vCenter %q with err: %v", m.virtualCenter.Config.Host, err)
 		return nil, err
@@ -254,6 +261,7 @@ func (m *defaultManager) CreateVolume(ctx context.Context, spec *cnstypes.CnsVol
 	}
 	volumeOperationRes := taskResult.GetCnsVolumeOperationResult()
 	if volumeOperationRes.Fault != nil {
+		recordVolumeOperationFault(prometheus.PrometheusCreateVolumeOpType, volumeOperationRes.Fault)
 		fault, ok := volumeOperationRes.Fault.Fault.(cnstypes.CnsAlreadyRegisteredFault)
 		if ok {
 			log.Infof("CreateVolume: Volume is already registered with CNS. VolumeName: %q, volumeID: %q, opId: %q", spec.Name, fault.VolumeId.Id, taskInfo.ActivationId)
@@ -306,9 +314,11 @@ func (m *defaultManager) CreateVolume(ctx context.Context, spec *cnstypes.CnsVol
 }
 
 // AttachVolume attaches a volume to a virtual machine given the spec.
-func (m *defaultManager) AttachVolume(ctx context.Context, vm *cnsvsphere.VirtualMachine, volumeID string) (string, error) {
+func (m *defaultManager) AttachVolume(ctx context.Context, vm *cnsvsphere.VirtualMachine, volumeID string) (_ string, err error) {
 	log := logger.GetLogger(ctx)
-	err := validateManager(ctx, m)
+	ctx, span := tracing.StartSpan(ctx, "cns.AttachVolume", attribute.String("cns.volume.id", volumeID))
+	defer func() { tracing.EndSpan(span, err) }()
+	err = validateManager(ctx, m)
 	if err != nil {
 		return "", err
 	}
@@ -334,7 +344,7 @@ func (m *defaultManager) AttachVolume(ctx context.Context, vm *cnsvsphere.Virtua
 		return "", err
 	}
 	// Get the taskInfo
-	taskInfo, err := cns.GetTaskInfo(ctx, task)
+	taskInfo, err := getTaskInfo(ctx, task, prometheus.PrometheusAttachVolumeOpType)
 	if err != nil || taskInfo == nil {
 		log.Errorf("failed to get taskInfo for AttachVolume task from vCenter %q with err: %v", m.virtualCenter.Config.Host, err)
 		return "", err
@@ -355,6 +365,7 @@ func (m *defaultManager) AttachVolume(ctx context.Context, vm *cnsvsphere.Virtua
 
 	volumeOperationRes := taskResult.GetCnsVolumeOperationResult()
 	if volumeOperationRes.Fault != nil {
+		recordVolumeOperationFault(prometheus.PrometheusAttachVolumeOpType, volumeOperationRes.Fault)
 		_, isResourceInUseFault := volumeOperationRes.Fault.Fault.(*vim25types.ResourceInUse)
 		if isResourceInUseFault {
 			log.Infof("observed ResourceInUse fault while attaching volume: %q with vm: %q", volumeID, vm.String())
@@ -377,9 +388,11 @@ func (m *defaultManager) AttachVolume(ctx context.Context, vm *cnsvsphere.Virtua
 }
 
 // DetachVolume detaches a volume from the virtual machine given the spec.
-func (m *defaultManager) DetachVolume(ctx context.Context, vm *cnsvsphere.VirtualMachine, volumeID string) error {
+func (m *defaultManager) DetachVolume(ctx context.Context, vm *cnsvsphere.VirtualMachine, volumeID string) (err error) {
 	log := logger.GetLogger(ctx)
-	err := validateManager(ctx, m)
+	ctx, span := tracing.StartSpan(ctx, "cns.DetachVolume", attribute.String("cns.volume.id", volumeID))
+	defer func() { tracing.EndSpan(span, err) }()
+	err = validateManager(ctx, m)
 	if err != nil {
 		return err
 	}
@@ -425,7 +438,7 @@ func (m *defaultManager) DetachVolume(ctx context.Context, vm *cnsvsphere.Virtua
 	}
 
 	// Get the taskInfo
-	taskInfo, err := cns.GetTaskInfo(ctx, task)
+	taskInfo, err := getTaskInfo(ctx, task, prometheus.PrometheusDetachVolumeOpType)
 	if err != nil || taskInfo == nil {
 		log.Errorf("failed to get taskInfo for DetachVolume task from vCenter %q with err: %v", m.virtualCenter.Config.Host, err)
 		return err
@@ -444,6 +457,7 @@ func (m *defaultManager) DetachVolume(ctx context.Context, vm *cnsvsphere.Virtua
 	}
 	volumeOperationRes := taskResult.GetCnsVolumeOperationResult()
 	if volumeOperationRes.Fault != nil {
+		recordVolumeOperationFault(prometheus.PrometheusDetachVolumeOpType, volumeOperationRes.Fault)
 		// Volume is already attached to VM
 		diskUUID, err := IsDiskAttached(ctx, vm, volumeID)
 		if err != nil {
@@ -465,9 +479,11 @@ func (m *defaultManager) DetachVolume(ctx context.Context, vm *cnsvsphere.Virtua
 }
 
 // DeleteVolume deletes a volume given its spec.
-func (m *defaultManager) DeleteVolume(ctx context.Context, volumeID string, deleteDisk bool) error {
+func (m *defaultManager) DeleteVolume(ctx context.Context, volumeID string, deleteDisk bool) (err error) {
 	log := logger.GetLogger(ctx)
-	err := validateManager(ctx, m)
+	ctx, span := tracing.StartSpan(ctx, "cns.DeleteVolume", attribute.String("cns.volume.id", volumeID))
+	defer func() { tracing.EndSpan(span, err) }()
+	err = validateManager(ctx, m)
 	if err != nil {
 		return err
 	}
@@ -494,7 +510,7 @@ func (m *defaultManager) DeleteVolume(ctx context.Context, volumeID string, dele
 		return err
 	}
 	// Get the taskInfo
-	taskInfo, err := cns.GetTaskInfo(ctx, task)
+	taskInfo, err := getTaskInfo(ctx, task, prometheus.PrometheusDeleteVolumeOpType)
 	if err != nil || taskInfo == nil {
 		log.Errorf("failed to get taskInfo for DeleteVolume task from vCenter %q with err: %v", m.virtualCenter.Config.Host, err)
 		return err
@@ -513,6 +529,7 @@ func (m *defaultManager) DeleteVolume(ctx context.Context, volumeID string, dele
 	}
 	volumeOperationRes := taskResult.GetCnsVolumeOperationResult()
 	if volumeOperationRes.Fault != nil {
+		recordVolumeOperationFault(prometheus.PrometheusDeleteVolumeOpType, volumeOperationRes.Fault)
 		msg := fmt.Sprintf("failed to delete volume: %q, fault: %q, opID: %q", volumeID, spew.Sdump(volumeOperationRes.Fault), taskInfo.ActivationId)
 		log.Error(msg)
 		return errors.New(msg)
@@ -522,9 +539,11 @@ func (m *defaultManager) DeleteVolume(ctx context.Context, volumeID string, dele
 }
 
 // UpdateVolume updates a volume given its spec.
-func (m *defaultManager) UpdateVolumeMetadata(ctx context.Context, spec *cnstypes.CnsVolumeMetadataUpdateSpec) error {
+func (m *defaultManager) UpdateVolumeMetadata(ctx context.Context, spec *cnstypes.CnsVolumeMetadataUpdateSpec) (err error) {
 	log := logger.GetLogger(ctx)
-	err := validateManager(ctx, m)
+	ctx, span := tracing.StartSpan(ctx, "cns.UpdateVolumeMetadata", attribute.String("cns.volume.id", spec.VolumeId.Id))
+	defer func() { tracing.EndSpan(span, err) }()
+	err = validateManager(ctx, m)
 	if err != nil {
 		return err
 	}
@@ -558,7 +577,7 @@ func (m *defaultManager) UpdateVolumeMetadata(ctx context.Context, spec *cnstype
 		return err
 	}
 	// Get the taskInfo
-	taskInfo, err := cns.GetTaskInfo(ctx, task)
+	taskInfo, err := getTaskInfo(ctx, task, prometheus.PrometheusUpdateVolumeMetadataOpType)
 	if err != nil || taskInfo == nil {
 		log.Errorf("failed to get taskInfo for UpdateVolume task from vCenter %q with err: %v", m.virtualCenter.Config.Host, err)
 		return err
@@ -577,6 +596,7 @@ func (m *defaultManager) UpdateVolumeMetadata(ctx context.Context, spec *cnstype
 	}
 	volumeOperationRes := taskResult.GetCnsVolumeOperationResult()
 	if volumeOperationRes.Fault != nil {
+		recordVolumeOperationFault(prometheus.PrometheusUpdateVolumeMetadataOpType, volumeOperationRes.Fault)
 		msg := fmt.Sprintf("failed to update volume. updateSpec: %q, fault: %q, opID: %q", spew.Sdump(spec), spew.Sdump(volumeOperationRes.Fault), taskInfo.ActivationId)
 		log.Error(msg)
 		return errors.New(msg)
@@ -586,9 +606,11 @@ func (m *defaultManager) UpdateVolumeMetadata(ctx context.Context, spec *cnstype
 }
 
 // ExpandVolume expands a volume given its spec.
-func (m *defaultManager) ExpandVolume(ctx context.Context, volumeID string, size int64) error {
+func (m *defaultManager) ExpandVolume(ctx context.Context, volumeID string, size int64) (err error) {
 	log := logger.GetLogger(ctx)
-	err := validateManager(ctx, m)
+	ctx, span := tracing.StartSpan(ctx, "cns.ExpandVolume", attribute.String("cns.volume.id", volumeID))
+	defer func() { tracing.EndSpan(span, err) }()
+	err = validateManager(ctx, m)
 	if err != nil {
 		log.Errorf("validateManager failed with err: %+v", err)
 		return err
@@ -620,7 +642,7 @@ func (m *defaultManager) ExpandVolume(ctx context.Context, volumeID string, size
 		return err
 	}
 	// Get the taskInfo
-	taskInfo, err := cns.GetTaskInfo(ctx, task)
+	taskInfo, err := getTaskInfo(ctx, task, prometheus.PrometheusExpandVolumeOpType)
 	if err != nil || taskInfo == nil {
 		log.Errorf("failed to get taskInfo for ExtendVolume task from vCenter %q with err: %v", m.virtualCenter.Config.Host, err)
 		return err
@@ -639,6 +661,7 @@ func (m *defaultManager) ExpandVolume(ctx context.Context, volumeID string, size
 	}
 	volumeOperationRes := taskResult.GetCnsVolumeOperationResult()
 	if volumeOperationRes.Fault != nil {
+		recordVolumeOperationFault(prometheus.PrometheusExpandVolumeOpType, volumeOperationRes.Fault)
 		msg := fmt.Sprintf("failed to extend volume: %q, fault: %q, opID: %q", volumeID, spew.Sdump(volumeOperationRes.Fault), taskInfo.ActivationId)
 		log.Error(msg)
 		return errors.New(msg)
@@ -648,9 +671,11 @@ func (m *defaultManager) ExpandVolume(ctx context.Context, volumeID string, size
 }
 
 // QueryVolume returns volumes matching the given filter.
-func (m *defaultManager) QueryVolume(ctx context.Context, queryFilter cnstypes.CnsQueryFilter) (*cnstypes.CnsQueryResult, error) {
+func (m *defaultManager) QueryVolume(ctx context.Context, queryFilter cnstypes.CnsQueryFilter) (_ *cnstypes.CnsQueryResult, err error) {
 	log := logger.GetLogger(ctx)
-	err := validateManager(ctx, m)
+	ctx, span := tracing.StartSpan(ctx, "cns.QueryVolume")
+	defer func() { tracing.EndSpan(span, err) }()
+	err = validateManager(ctx, m)
 	if err != nil {
 		return nil, err
 	}
@@ -673,9 +698,11 @@ func (m *defaultManager) QueryVolume(ctx context.Context, queryFilter cnstypes.C
 }
 
 // QueryAllVolume returns all volumes matching the given filter and selection.
-func (m *defaultManager) QueryAllVolume(ctx context.Context, queryFilter cnstypes.CnsQueryFilter, querySelection cnstypes.CnsQuerySelection) (*cnstypes.CnsQueryResult, error) {
+func (m *defaultManager) QueryAllVolume(ctx context.Context, queryFilter cnstypes.CnsQueryFilter, querySelection cnstypes.CnsQuerySelection) (_ *cnstypes.CnsQueryResult, err error) {
 	log := logger.GetLogger(ctx)
-	err := validateManager(ctx, m)
+	ctx, span := tracing.StartSpan(ctx, "cns.QueryAllVolume")
+	defer func() { tracing.EndSpan(span, err) }()
+	err = validateManager(ctx, m)
 	if err != nil {
 		return nil, err
 	}
@@ -696,9 +723,11 @@ func (m *defaultManager) QueryAllVolume(ctx context.Context, queryFilter cnstype
 }
 
 // QueryVolumeInfo calls the CNS QueryVolumeInfo API and return a task, from which CnsQueryVolumeInfoResult is extracted
-func (m *defaultManager) QueryVolumeInfo(ctx context.Context, volumeIDList []cnstypes.CnsVolumeId) (*cnstypes.CnsQueryVolumeInfoResult, error) {
+func (m *defaultManager) QueryVolumeInfo(ctx context.Context, volumeIDList []cnstypes.CnsVolumeId) (_ *cnstypes.CnsQueryVolumeInfoResult, err error) {
 	log := logger.GetLogger(ctx)
-	err := validateManager(ctx, m)
+	ctx, span := tracing.StartSpan(ctx, "cns.QueryVolumeInfo")
+	defer func() { tracing.EndSpan(span, err) }()
+	err = validateManager(ctx, m)
 	if err != nil {
 		return nil, err
 	}
@@ -716,7 +745,7 @@ func (m *defaultManager) QueryVolumeInfo(ctx context.Context, volumeIDList []cns
 	}
 
 	// Get the taskInfo
-	taskInfo, err := cns.GetTaskInfo(ctx, queryVolumeInfoTask)
+	taskInfo, err := getTaskInfo(ctx, queryVolumeInfoTask, prometheus.PrometheusQueryVolumeInfoOpType)
 	if err != nil || taskInfo == nil {
 		log.Errorf("failed to get taskInfo for QueryVolumeInfo task from vCenter %q with err: %v", m.virtualCenter.Config.Host, err)
 		return nil, err
@@ -735,6 +764,7 @@ func (m *defaultManager) QueryVolumeInfo(ctx context.Context, volumeIDList []cns
 	}
 	volumeOperationRes := taskResult.GetCnsVolumeOperationResult()
 	if volumeOperationRes.Fault != nil {
+		recordVolumeOperationFault(prometheus.PrometheusQueryVolumeInfoOpType, volumeOperationRes.Fault)
 		msg := fmt.Sprintf("failed to Query volumes: %v, fault: %q, opID: %q", volumeIDList, spew.Sdump(volumeOperationRes.Fault), taskInfo.ActivationId)
 		log.Error(msg)
 		return nil, errors.New(msg)
@@ -744,9 +774,11 @@ func (m *defaultManager) QueryVolumeInfo(ctx context.Context, volumeIDList []cns
 	return volumeInfoResult, nil
 }
 
-func (m *defaultManager) RelocateVolume(ctx context.Context, relocateSpecList ...cnstypes.BaseCnsVolumeRelocateSpec) (*object.Task, error) {
+func (m *defaultManager) RelocateVolume(ctx context.Context, relocateSpecList ...cnstypes.BaseCnsVolumeRelocateSpec) (_ *object.Task, err error) {
 	log := logger.GetLogger(ctx)
-	err := validateManager(ctx, m)
+	ctx, span := tracing.StartSpan(ctx, "cns.RelocateVolume")
+	defer func() { tracing.EndSpan(span, err) }()
+	err = validateManager(ctx, m)
 	if err != nil {
 		log.Errorf("validateManager failed with err: %+v", err)
 		return nil, err
@@ -767,9 +799,11 @@ func (m *defaultManager) RelocateVolume(ctx context.Context, relocateSpecList ..
 }
 
 // ConfigureVolumeACLs configures net permissions for a given CnsVolumeACLConfigureSpec
-func (m *defaultManager) ConfigureVolumeACLs(ctx context.Context, spec cnstypes.CnsVolumeACLConfigureSpec) error {
+func (m *defaultManager) ConfigureVolumeACLs(ctx context.Context, spec cnstypes.CnsVolumeACLConfigureSpec) (err error) {
 	log := logger.GetLogger(ctx)
-	err := validateManager(ctx, m)
+	ctx, span := tracing.StartSpan(ctx, "cns.ConfigureVolumeACLs", attribute.String("cns.volume.id", spec.VolumeId.Id))
+	defer func() { tracing.EndSpan(span, err) }()
+	err = validateManager(ctx, m)
 	if err != nil {
 		return err
 	}
@@ -790,7 +824,7 @@ func (m *defaultManager) ConfigureVolumeACLs(ctx context.Context, spec cnstypes.
 	}
 
 	// Get the taskInfo
-	taskInfo, err = cns.GetTaskInfo(ctx, task)
+	taskInfo, err = getTaskInfo(ctx, task, prometheus.PrometheusConfigureVolumeACLsOpType)
 	if err != nil {
 		log.Errorf("failed to get taskInfo for ConfigureVolumeACLs task from vCenter %q with err: %v", m.virtualCenter.Config.Host, err)
 		return err
@@ -809,6 +843,7 @@ func (m *defaultManager) ConfigureVolumeACLs(ctx context.Context, spec cnstypes.
 	}
 	volumeOperationRes := taskResult.GetCnsVolumeOperationResult()
 	if volumeOperationRes.Fault != nil {
+		recordVolumeOperationFault(prometheus.PrometheusConfigureVolumeACLsOpType, volumeOperationRes.Fault)
 		msg := fmt.Sprintf("failed to apply ConfigureVolumeACLs. Volume ID: %s. ConfigureVolumeACLsSpec: %q, fault: %q, opId: %q", spec.VolumeId.Id, spew.Sdump(spec), spew.Sdump(volumeOperationRes.Fault), taskInfo.ActivationId)
 		log.Error(msg)
 		return errors.New(msg)
@@ -817,3 +852,31 @@ func (m *defaultManager) ConfigureVolumeACLs(ctx context.Context, spec cnstypes.
 	log.Infof("ConfigureVolumeACLs: Volume ACLs configured successfully. VolumeName: %q, opId: %q, volumeID: %q", spec.VolumeId.Id, taskInfo.ActivationId, volumeOperationRes.VolumeId.Id)
 	return nil
 }
+
+// UpdateVolumeStoragePolicy applies the storage policy with the given ID to the block volume residing on the
+// given datastore without relocating it. CNS has no API for it, so the policy of the first class disk backing
+// the volume is updated.
+func (m *defaultManager) UpdateVolumeStoragePolicy(ctx context.Context, volumeID string,
+	datastore vim25types.ManagedObjectReference, storagePolicyID string) (err error) {
+	log := logger.GetLogger(ctx)
+	ctx, span := tracing.StartSpan(ctx, "cns.UpdateVolumeStoragePolicy", attribute.String("cns.volume.id", volumeID))
+	defer func() { tracing.EndSpan(span, err) }()
+	err = validateManager(ctx, m)
+	if err != nil {
+		return err
+	}
+	// Set up the VC connection
+	err = m.virtualCenter.Connect(ctx)
+	if err != nil {
+		log.Errorf("Connect failed with err: %+v", err)
+		return err
+	}
+	err = m.virtualCenter.UpdateVStorageObjectPolicy(ctx, volumeID, datastore, storagePolicyID)
+	if err != nil {
+		log.Errorf("failed to update storage policy of volume %q to %q from vCenter %q with err: %v",
+			volumeID, storagePolicyID, m.virtualCenter.Config.Host, err)
+		return err
+	}
+	log.Infof("UpdateVolumeStoragePolicy: storage policy of volume %q updated to %q", volumeID, storagePolicyID)
+	return nil
+}
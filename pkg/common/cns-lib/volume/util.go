@@ -19,11 +19,17 @@ package volume
 import (
 	"context"
 	"errors"
+	"reflect"
+	"time"
 
 	"github.com/vmware/govmomi/cns"
 	cnstypes "github.com/vmware/govmomi/cns/types"
+	"github.com/vmware/govmomi/object"
+	"go.opentelemetry.io/otel/attribute"
 
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/prometheus"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/tracing"
 
 	vimtypes "github.com/vmware/govmomi/vim25/types"
 	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/vsphere"
@@ -98,3 +104,39 @@ func updateQueryResult(ctx context.Context, m *defaultManager, res *cnstypes.Cns
 	}
 	return res
 }
+
+// getTaskInfo waits for the CNS task to complete, observes the time spent waiting
+// for the task of the given operation type and records it in a span
+func getTaskInfo(ctx context.Context, task *object.Task, opType string) (*vimtypes.TaskInfo, error) {
+	ctx, span := tracing.StartSpan(ctx, "cns.WaitForTask", attribute.String("cns.operation", opType))
+	start := time.Now()
+	taskInfo, err := cns.GetTaskInfo(ctx, task)
+	tracing.EndSpan(span, err)
+	if err != nil || taskInfo == nil {
+		prometheus.CnsTaskWaitHistVec.WithLabelValues(opType, prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
+	} else {
+		prometheus.CnsTaskWaitHistVec.WithLabelValues(opType, prometheus.PrometheusPassStatus).Observe(time.Since(start).Seconds())
+	}
+	return taskInfo, err
+}
+
+// recordVolumeOperationFault counts the fault returned by the CNS volume operation of the given type
+// per vCenter fault class
+func recordVolumeOperationFault(opType string, fault *vimtypes.LocalizedMethodFault) {
+	if fault == nil {
+		return
+	}
+	prometheus.CnsVolumeOpsFaultCounterVec.WithLabelValues(opType, getFaultType(fault)).Inc()
+}
+
+// getFaultType returns the vCenter fault class of the given fault, e.g. "NotFound"
+func getFaultType(fault *vimtypes.LocalizedMethodFault) string {
+	if fault == nil || fault.Fault == nil {
+		return "unknown"
+	}
+	faultType := reflect.TypeOf(fault.Fault)
+	if faultType.Kind() == reflect.Ptr {
+		faultType = faultType.Elem()
+	}
+	return faultType.Name()
+}
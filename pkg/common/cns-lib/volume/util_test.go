@@ -0,0 +1,42 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"testing"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	vimtypes "github.com/vmware/govmomi/vim25/types"
+)
+
+func TestGetFaultType(t *testing.T) {
+	tests := []struct {
+		fault    *vimtypes.LocalizedMethodFault
+		expected string
+	}{
+		{nil, "unknown"},
+		{&vimtypes.LocalizedMethodFault{}, "unknown"},
+		{&vimtypes.LocalizedMethodFault{Fault: &vimtypes.NotFound{}}, "NotFound"},
+		{&vimtypes.LocalizedMethodFault{Fault: &vimtypes.ResourceInUse{}}, "ResourceInUse"},
+		{&vimtypes.LocalizedMethodFault{Fault: cnstypes.CnsAlreadyRegisteredFault{}}, "CnsAlreadyRegisteredFault"},
+	}
+	for _, test := range tests {
+		if faultType := getFaultType(test.fault); faultType != test.expected {
+			t.Errorf("expected fault type %q, got %q", test.expected, faultType)
+		}
+	}
+}
@@ -20,9 +20,11 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/pbm"
 	pbmmethods "github.com/vmware/govmomi/pbm/methods"
 	pbmtypes "github.com/vmware/govmomi/pbm/types"
+	"github.com/vmware/govmomi/vim25/methods"
 	vimtypes "github.com/vmware/govmomi/vim25/types"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
 )
@@ -95,6 +97,25 @@ func (vc *VirtualCenter) GetStoragePolicyIDByName(ctx context.Context, storagePo
 	return storagePolicyID, nil
 }
 
+// GetStoragePolicyNameByID gets storage policy name by ID.
+func (vc *VirtualCenter) GetStoragePolicyNameByID(ctx context.Context, storagePolicyID string) (string, error) {
+	log := logger.GetLogger(ctx)
+	err := vc.ConnectPbm(ctx)
+	if err != nil {
+		log.Errorf("Error occurred while connecting to PBM, err: %+v", err)
+		return "", err
+	}
+	profiles, err := vc.PbmClient.RetrieveContent(ctx, []pbmtypes.PbmProfileId{{UniqueId: storagePolicyID}})
+	if err != nil {
+		log.Errorf("failed to get StoragePolicyName from StoragePolicyID %s with err: %v", storagePolicyID, err)
+		return "", err
+	}
+	if len(profiles) == 0 {
+		return "", fmt.Errorf("storage policy %s not found", storagePolicyID)
+	}
+	return profiles[0].GetPbmProfile().Name, nil
+}
+
 // PbmCheckCompatibility performs a compatibility check for the given profileID with the given datastores
 func (vc *VirtualCenter) PbmCheckCompatibility(ctx context.Context, datastores []vimtypes.ManagedObjectReference, profileID string) (pbm.PlacementCompatibilityResult, error) {
 	hubs := make([]pbmtypes.PbmPlacementHub, 0)
@@ -120,6 +141,36 @@ func (vc *VirtualCenter) PbmCheckCompatibility(ctx context.Context, datastores [
 	return res.Returnval, nil
 }
 
+// UpdateVStorageObjectPolicy applies the storage policy with the given profileID to the
+// first class disk with the given ID residing on the given datastore, without relocating it.
+func (vc *VirtualCenter) UpdateVStorageObjectPolicy(ctx context.Context, id string, datastore vimtypes.ManagedObjectReference, profileID string) error {
+	log := logger.GetLogger(ctx)
+	if vc.Client == nil || vc.Client.ServiceContent.VStorageObjectManager == nil {
+		return fmt.Errorf("VStorageObjectManager is not available on vCenter %q", vc.Config.Host)
+	}
+	req := vimtypes.UpdateVStorageObjectPolicy_Task{
+		This:      *vc.Client.ServiceContent.VStorageObjectManager,
+		Id:        vimtypes.ID{Id: id},
+		Datastore: datastore,
+		Profile: []vimtypes.BaseVirtualMachineProfileSpec{
+			&vimtypes.VirtualMachineDefinedProfileSpec{
+				ProfileId: profileID,
+			},
+		},
+	}
+	res, err := methods.UpdateVStorageObjectPolicy_Task(ctx, vc.Client.Client, &req)
+	if err != nil {
+		log.Errorf("failed to update policy of disk %q to %q. err: %v", id, profileID, err)
+		return err
+	}
+	task := object.NewTask(vc.Client.Client, res.Returnval)
+	if _, err = task.WaitForResult(ctx, nil); err != nil {
+		log.Errorf("failed to update policy of disk %q to %q. err: %v", id, profileID, err)
+		return err
+	}
+	return nil
+}
+
 // PbmRetrieveContent fetches the policy content of all given policies from SPBM
 func (vc *VirtualCenter) PbmRetrieveContent(ctx context.Context, policyIds []string) ([]SpbmPolicyContent, error) {
 	pbmPolicyIds := make([]pbmtypes.PbmProfileId, 0)
@@ -57,6 +57,26 @@ type Config struct {
 
 		//CSIAuthCheckIntervalInMin specifies the interval that the auth check for datastores will be trigger
 		CSIAuthCheckIntervalInMin int `gcfg:"csi-auth-check-intervalinmin"`
+		// QueryVolumeCacheTTLInSec specifies the time for which results of CNS
+		// QueryVolume calls filtered by volume IDs are cached.
+		// If not set, QueryVolume results are not cached.
+		QueryVolumeCacheTTLInSec int `gcfg:"query-volume-cache-ttl-insec"`
+		// VolumeHealthIntervalInMin specifies the interval at which the syncer polls the health
+		// of the volumes of the cluster. The VOLUME_HEALTH_INTERVAL_MINUTES environment variable
+		// takes precedence. If neither is set, the health is polled every 5 minutes.
+		VolumeHealthIntervalInMin int `gcfg:"volume-health-intervalinmin"`
+		// VolumeMigrationConcurrency specifies the number of volumes relocated concurrently during
+		// the disk decommission of a StoragePool. If not set, volumes are relocated one at a time.
+		VolumeMigrationConcurrency int `gcfg:"volume-migration-concurrency"`
+		// VolumeMigrationIntervalInSec specifies the minimum interval between the start of two volume
+		// relocations during the disk decommission of a StoragePool. If not set, relocations are not paced.
+		VolumeMigrationIntervalInSec int `gcfg:"volume-migration-intervalinsec"`
+		// VolumeMigrationMaxRetries specifies the number of times the relocation of a volume is retried
+		// before it is reported as failed. If not set, relocations are retried 3 times.
+		VolumeMigrationMaxRetries int `gcfg:"volume-migration-max-retries"`
+		// StoragePoolCapacityWarningDays specifies the number of days until a StoragePool is forecast to be full
+		// below which its CapacityExhaustion condition is raised. If not set, the threshold is 14 days.
+		StoragePoolCapacityWarningDays int `gcfg:"storagepool-capacity-warning-days"`
 	}
 
 	// Multiple sets of Net Permissions applied to all file shares
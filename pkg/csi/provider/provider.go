@@ -18,8 +18,10 @@ package provider
 
 import (
 	"github.com/rexray/gocsi"
+	"google.golang.org/grpc"
 
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/tracing"
 )
 
 // New returns a new CSI Storage Plug-in Provider.
@@ -33,6 +35,13 @@ func New() gocsi.StoragePluginProvider {
 		Node:        svc,
 		BeforeServe: svc.BeforeServe,
 
+		// Start a span and attach the request fields to the logger of each RPC
+		// ahead of the gocsi interceptors.
+		Interceptors: []grpc.UnaryServerInterceptor{
+			tracing.UnaryServerInterceptor(),
+			service.LoggerUnaryServerInterceptor(),
+		},
+
 		EnvVars: []string{
 			// Enable request validation.
 			gocsi.EnvVarSpecReqValidation + "=true",
@@ -42,6 +42,15 @@ var (
 	k8sOrchestratorInstanceInitialized uint32
 )
 
+const (
+	// loggerConfigMapName is the name of the optional configmap holding the log verbosity.
+	// It is watched in the namespace of the feature state switch configmaps.
+	loggerConfigMapName = "vsphere-csi-logger-config"
+	// loggerConfigMapLogLevelKey is the key of the log verbosity in the logger configmap,
+	// one of "debug", "info", "warn" or "error"
+	loggerConfigMapLogLevelKey = "log-level"
+)
+
 // FSSConfigMapInfo contains details about the FSS configmap(s) present in all flavors
 type FSSConfigMapInfo struct {
 	featureStates      map[string]string
@@ -86,6 +95,8 @@ type K8sOrchestrator struct {
 	clusterFlavor    cnstypes.CnsClusterFlavor
 	volumeIDToPvcMap *volumeIDToPvcMap
 	k8sClient        clientset.Interface
+	// loggerConfigMapNamespace is the namespace of the logger configmap
+	loggerConfigMapNamespace string
 }
 
 // K8sGuestInitParams lists the set of parameters required to run the init for K8sOrchestrator in Guest cluster
@@ -236,6 +247,8 @@ func initFSS(ctx context.Context, k8sClient clientset.Interface, controllerClust
 		}
 	}
 
+	k8sOrchestratorInstance.loggerConfigMapNamespace = configMapNamespaceToListen
+
 	// Set up kubernetes resource listeners for k8s orchestrator
 	k8sOrchestratorInstance.informerManager.AddConfigMapListener(ctx, k8sClient, configMapNamespaceToListen,
 		// Add
@@ -271,6 +284,8 @@ func configMapAdded(obj interface{}) {
 		fssConfigMap.Namespace == k8sOrchestratorInstance.internalFSS.configMapNamespace {
 		k8sOrchestratorInstance.internalFSS.featureStates = fssConfigMap.Data
 		log.Infof("New feature states values from %q stored successfully: %v", fssConfigMap.Name, k8sOrchestratorInstance.internalFSS.featureStates)
+	} else if isLoggerConfigMap(fssConfigMap) {
+		setLogVerbosity(ctx, fssConfigMap.Data[loggerConfigMapLogLevelKey])
 	}
 }
 
@@ -291,6 +306,8 @@ func configMapUpdated(oldObj, newObj interface{}) {
 	} else if fssConfigMap.Name == k8sOrchestratorInstance.internalFSS.configMapName && fssConfigMap.Namespace == k8sOrchestratorInstance.internalFSS.configMapNamespace {
 		k8sOrchestratorInstance.internalFSS.featureStates = fssConfigMap.Data
 		log.Infof("New feature states values from %q stored successfully: %v", fssConfigMap.Name, k8sOrchestratorInstance.internalFSS.featureStates)
+	} else if isLoggerConfigMap(fssConfigMap) {
+		setLogVerbosity(ctx, fssConfigMap.Data[loggerConfigMapLogLevelKey])
 	}
 }
 
@@ -321,6 +338,26 @@ func configMapDeleted(obj interface{}) {
 		log.Infof("configMapDeleted: %v deleted. Setting internal feature state values to false %v", fssConfigMap.Name,
 			k8sOrchestratorInstance.internalFSS.featureStates)
 	}
+	// Logger configmap
+	if isLoggerConfigMap(fssConfigMap) {
+		log.Infof("configMapDeleted: %v deleted. Restoring the default log verbosity", fssConfigMap.Name)
+		setLogVerbosity(ctx, "")
+	}
+}
+
+// isLoggerConfigMap returns true if the given configmap is the logger configmap
+func isLoggerConfigMap(configMap *v1.ConfigMap) bool {
+	return configMap.Name == loggerConfigMapName &&
+		configMap.Namespace == k8sOrchestratorInstance.loggerConfigMapNamespace
+}
+
+// setLogVerbosity sets the log verbosity to the given level, an empty level restores the default verbosity
+func setLogVerbosity(ctx context.Context, level string) {
+	log := logger.GetLogger(ctx)
+	if err := logger.SetLogVerbosity(level); err != nil {
+		log.Errorf("invalid %q value %q in configmap %q. Err: %v", loggerConfigMapLogLevelKey, level,
+			loggerConfigMapName, err)
+	}
 }
 
 // initVolumeHandleToPvcMap performs all the operations required to initialize the volume id to PVC name map.
@@ -66,6 +66,12 @@ const (
 	// the given storage policy. For Example: HostLocal: "True"
 	AttributeHostLocal = "hostlocal"
 
+	// AttributePVCName, AttributePVCNamespace and AttributePVName are passed in the
+	// CreateVolume parameters by the external-provisioner started with --extra-create-metadata
+	AttributePVCName      = "csi.storage.k8s.io/pvc/name"
+	AttributePVCNamespace = "csi.storage.k8s.io/pvc/namespace"
+	AttributePVName       = "csi.storage.k8s.io/pv/name"
+
 	// HostMoidAnnotationKey represents the Node annotation key that has the value
 	// of VC's ESX host moid of this node.
 	HostMoidAnnotationKey = "vmware-system-esxi-node-moid"
@@ -225,4 +231,17 @@ const (
 	FileVolume = "file-volume"
 	// FakeAttach is the feature flag for fake attach support in WCP
 	FakeAttach = "fake-attach"
+	// StoragePolicyCompliance is the feature flag for storage policy compliance monitoring
+	StoragePolicyCompliance = "storage-policy-compliance"
+	// StoragePolicyChange is the feature flag for changing the storage policy of a volume via PVC annotation
+	StoragePolicyChange = "storage-policy-change"
+	// CSIStorageClassValidation is the feature flag for validating the parameters of CSI StorageClasses
+	// against vCenter in the admission webhook
+	CSIStorageClassValidation = "csi-storageclass-validation"
+	// CSIPVCValidation is the feature flag for validating the resize and access modes of PVCs
+	// in the admission webhook
+	CSIPVCValidation = "csi-pvc-validation"
+	// VanillaStoragePool is the feature flag for creating StoragePools for the datastores accessible to the
+	// nodes of Vanilla clusters
+	VanillaStoragePool = "vanilla-storage-pool"
 )
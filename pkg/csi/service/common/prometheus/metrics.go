@@ -17,8 +17,11 @@ limitations under the License.
 package prometheus
 
 import (
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -37,6 +40,47 @@ const (
 	PrometheusAttachVolumeOpType = "attach-volume"
 	// PrometheusDetachVolumeOpType represents the DetachVolume operation.
 	PrometheusDetachVolumeOpType = "detach-volume"
+	// PrometheusExpandVolumeOpType represents the ExpandVolume operation.
+	PrometheusExpandVolumeOpType = "expand-volume"
+
+	// PrometheusUpdateVolumeMetadataOpType represents the CNS UpdateVolumeMetadata operation.
+	PrometheusUpdateVolumeMetadataOpType = "update-volume-metadata"
+	// PrometheusQueryVolumeInfoOpType represents the CNS QueryVolumeInfo operation.
+	PrometheusQueryVolumeInfoOpType = "query-volume-info"
+	// PrometheusConfigureVolumeACLsOpType represents the CNS ConfigureVolumeACLs operation.
+	PrometheusConfigureVolumeACLsOpType = "configure-volume-acls"
+
+	// PrometheusStageVolumeOpType represents the NodeStageVolume operation.
+	PrometheusStageVolumeOpType = "stage-volume"
+	// PrometheusUnstageVolumeOpType represents the NodeUnstageVolume operation.
+	PrometheusUnstageVolumeOpType = "unstage-volume"
+	// PrometheusPublishVolumeOpType represents the NodePublishVolume operation.
+	PrometheusPublishVolumeOpType = "publish-volume"
+	// PrometheusUnpublishVolumeOpType represents the NodeUnpublishVolume operation.
+	PrometheusUnpublishVolumeOpType = "unpublish-volume"
+
+	// PrometheusFullSyncCreateOpType represents a volume create operation during full sync.
+	PrometheusFullSyncCreateOpType = "create"
+	// PrometheusFullSyncUpdateOpType represents a volume metadata update operation during full sync.
+	PrometheusFullSyncUpdateOpType = "update"
+	// PrometheusFullSyncDeleteOpType represents a volume delete operation during full sync.
+	PrometheusFullSyncDeleteOpType = "delete"
+
+	// PrometheusPVCUpdatedHandler represents the PVC update informer handler of the syncer.
+	PrometheusPVCUpdatedHandler = "pvc-updated"
+	// PrometheusPVCDeletedHandler represents the PVC delete informer handler of the syncer.
+	PrometheusPVCDeletedHandler = "pvc-deleted"
+	// PrometheusPVUpdatedHandler represents the PV update informer handler of the syncer.
+	PrometheusPVUpdatedHandler = "pv-updated"
+	// PrometheusPVDeletedHandler represents the PV delete informer handler of the syncer.
+	PrometheusPVDeletedHandler = "pv-deleted"
+	// PrometheusPodUpdatedHandler represents the Pod update and delete informer handlers of the syncer.
+	PrometheusPodUpdatedHandler = "pod-updated"
+
+	// PrometheusStoragePoolUpdateOpType represents an update of a single StoragePool.
+	PrometheusStoragePoolUpdateOpType = "update"
+	// PrometheusStoragePoolReconcileAllOpType represents a reconciliation of all StoragePools.
+	PrometheusStoragePoolReconcileAllOpType = "reconcile-all"
 
 	// PrometheusPassStatus represents a successful API run.
 	PrometheusPassStatus = "pass"
@@ -65,4 +109,218 @@ var (
 		// Possible optype - "create-volume", "delete-volume", "attach-volume", "detach-volume", "expand-volume"
 		// Possible status - "pass", "fail"
 		[]string{"voltype", "optype", "status"})
+
+	// VolumeControlOpsFailureCounterVec is a counter vector metric to observe the failed control
+	// operations in CSI per fault type.
+	VolumeControlOpsFailureCounterVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "csi_volume_ops_failures_total",
+		Help: "Number of failed CSI volume operations",
+	},
+		// Possible voltype - "unknown", "block", "file"
+		// Possible optype - "create-volume", "delete-volume", "attach-volume", "detach-volume", "expand-volume"
+		// Possible faulttype - gRPC status codes, e.g. "NotFound", "InvalidArgument", "Internal"
+		[]string{"voltype", "optype", "faulttype"})
+
+	// VolumeControlOpsInFlightGaugeVec is a gauge vector metric to observe the number of control
+	// operations in CSI which are in progress.
+	VolumeControlOpsInFlightGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "csi_volume_ops_in_flight",
+		Help: "Number of CSI volume operations in progress",
+	},
+		// Possible optype - "create-volume", "delete-volume", "attach-volume", "detach-volume", "expand-volume"
+		[]string{"optype"})
+
+	// CnsTaskWaitHistVec is a histogram vector metric to observe the time spent waiting for
+	// CNS tasks to complete on vCenter.
+	CnsTaskWaitHistVec = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "vsphere_cns_task_wait_histogram",
+		Help:    "Histogram vector for the time spent waiting for CNS tasks.",
+		Buckets: []float64{1, 2, 3, 4, 5, 7, 10, 12, 15, 18, 20, 25, 30, 60, 120, 180, 300},
+	},
+		// Possible optype - "create-volume", "delete-volume", "attach-volume", "detach-volume", "expand-volume",
+		// "update-volume-metadata", "query-volume-info", "configure-volume-acls"
+		// Possible status - "pass", "fail"
+		[]string{"optype", "status"})
+
+	// CnsVolumeOpsFaultCounterVec is a counter vector metric to observe the faults returned by
+	// CNS volume operations per vCenter fault class.
+	CnsVolumeOpsFaultCounterVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_cns_volume_ops_faults_total",
+		Help: "Number of faults returned by CNS volume operations",
+	},
+		// Possible optype - "create-volume", "delete-volume", "attach-volume", "detach-volume", "expand-volume",
+		// "update-volume-metadata", "query-volume-info", "configure-volume-acls"
+		// Possible faulttype - vCenter fault class, e.g. "CnsFault", "NotFound", "ResourceInUse"
+		[]string{"optype", "faulttype"})
+
+	// VolumeComplianceGaugeVec is a gauge vector metric to observe the number of volumes
+	// in the cluster per storage policy compliance status.
+	VolumeComplianceGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_syncer_volume_compliance_status",
+		Help: "Number of volumes per storage policy compliance status",
+	},
+		// Possible status - "compliant", "nonCompliant", "unknown", "notApplicable", "outOfDate"
+		[]string{"status"})
+
+	// StoragePolicyReapplyOpsCounterVec is a counter vector metric to observe the number of
+	// storage policy reapply operations on non-compliant volumes.
+	StoragePolicyReapplyOpsCounterVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_syncer_storage_policy_reapply_total",
+		Help: "Number of storage policy reapply operations on non-compliant volumes",
+	},
+		// Possible status - "pass", "fail"
+		[]string{"status"})
+
+	// SyncerInfo is a gauge metric to observe the syncer version.
+	SyncerInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_syncer_info",
+		Help: "Syncer Info",
+	}, []string{"version"})
+
+	// FullSyncOpsHistVec is a histogram vector metric to observe the duration of full sync cycles.
+	FullSyncOpsHistVec = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "vsphere_full_sync_ops_histogram",
+		Help:    "Histogram vector for full sync cycles of the syncer.",
+		Buckets: []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200, 1800, 3600},
+	},
+		// Possible status - "pass", "fail"
+		[]string{"status"})
+
+	// FullSyncVolumeOpsCounterVec is a counter vector metric to observe the volume operations
+	// performed by full sync.
+	FullSyncVolumeOpsCounterVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_full_sync_volume_ops_total",
+		Help: "Number of volume operations performed by full sync",
+	},
+		// Possible optype - "create", "update", "delete"
+		// Possible status - "pass", "fail"
+		[]string{"optype", "status"})
+
+	// MetadataSyncOpsCounterVec is a counter vector metric to observe the CNS metadata updates
+	// performed by the syncer informer handlers.
+	MetadataSyncOpsCounterVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_syncer_metadata_sync_ops_total",
+		Help: "Number of CNS metadata updates performed by the syncer informer handlers",
+	},
+		// Possible handler - "pvc-updated", "pvc-deleted", "pv-updated", "pv-deleted", "pod-updated"
+		// Possible status - "pass", "fail"
+		[]string{"handler", "status"})
+
+	// VolumeHealthPollHistVec is a histogram vector metric to observe the duration of volume
+	// health status polls.
+	VolumeHealthPollHistVec = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "vsphere_syncer_volume_health_poll_histogram",
+		Help:    "Histogram vector for volume health status polls of the syncer.",
+		Buckets: []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300},
+	},
+		// Possible status - "pass", "fail"
+		[]string{"status"})
+
+	// UnhealthyVolumesGaugeVec is a gauge vector metric to observe the number of unhealthy
+	// volumes in the cluster per reason.
+	UnhealthyVolumesGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_syncer_unhealthy_volumes",
+		Help: "Number of volumes whose health is not green per reason",
+	},
+		// Possible reason - "DatastoreInaccessible", "ObjectDegraded"
+		[]string{"reason"})
+
+	// ResizeReconcileOpsCounterVec is a counter vector metric to observe the PVC resize
+	// reconciliations between the guest and supervisor clusters.
+	ResizeReconcileOpsCounterVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_syncer_resize_reconcile_ops_total",
+		Help: "Number of PVC resize reconciliations performed by the syncer",
+	},
+		// Possible status - "pass", "fail"
+		[]string{"status"})
+
+	// StoragePoolReconcileHistVec is a histogram vector metric to observe the duration of
+	// StoragePool reconciliations.
+	StoragePoolReconcileHistVec = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "vsphere_syncer_storagepool_reconcile_histogram",
+		Help:    "Histogram vector for StoragePool reconciliations of the syncer.",
+		Buckets: []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120},
+	},
+		// Possible optype - "update", "reconcile-all"
+		// Possible status - "pass", "fail"
+		[]string{"optype", "status"})
+
+	// StoragePoolFillRateGaugeVec is a gauge vector metric to observe the rate at which the used
+	// space of each StoragePool grows, in bytes per day.
+	StoragePoolFillRateGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_syncer_storagepool_fill_rate_bytes_per_day",
+		Help: "Rate at which the used space of the StoragePool grows in bytes per day",
+	}, []string{"storagepool"})
+
+	// StoragePoolDaysToFullGaugeVec is a gauge vector metric to observe the number of days until
+	// each filling StoragePool is full at its current fill rate.
+	StoragePoolDaysToFullGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_syncer_storagepool_days_to_full",
+		Help: "Number of days until the StoragePool is full at its current fill rate",
+	}, []string{"storagepool"})
+
+	// NodeVolumeOpsHistVec is a histogram vector metric to observe the volume operations
+	// of the node plugin.
+	NodeVolumeOpsHistVec = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "vsphere_csi_node_volume_ops_histogram",
+		Help:    "Histogram vector for CSI node volume operations.",
+		Buckets: []float64{0.1, 0.5, 1, 2, 3, 5, 7, 10, 15, 20, 30, 60, 120, 300},
+	},
+		// Possible voltype - "unknown", "block", "file"
+		// Possible optype - "stage-volume", "unstage-volume", "publish-volume", "unpublish-volume", "expand-volume"
+		// Possible status - "pass", "fail"
+		[]string{"voltype", "optype", "status"})
+
+	// NodeVolumeOpsFailureCounterVec is a counter vector metric to observe the failed volume
+	// operations of the node plugin per gRPC error code.
+	NodeVolumeOpsFailureCounterVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_csi_node_volume_ops_failures_total",
+		Help: "Number of failed CSI node volume operations",
+	},
+		// Possible voltype - "unknown", "block", "file"
+		// Possible optype - "stage-volume", "unstage-volume", "publish-volume", "unpublish-volume", "expand-volume"
+		// Possible code - gRPC status codes, e.g. "NotFound", "Internal", "InvalidArgument"
+		[]string{"voltype", "optype", "code"})
+
+	// DeviceDiscoveryHistVec is a histogram vector metric to observe the time taken by the
+	// node plugin to discover the device of an attached volume.
+	DeviceDiscoveryHistVec = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "vsphere_csi_node_device_discovery_histogram",
+		Help:    "Histogram vector for device discovery of attached volumes on the node.",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 2, 5, 10, 30},
+	},
+		// Possible status - "pass", "fail"
+		[]string{"status"})
+
+	// ScsiRescanCounterVec is a counter vector metric to observe the SCSI rescans performed
+	// by the node plugin.
+	ScsiRescanCounterVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_csi_node_scsi_rescan_total",
+		Help: "Number of SCSI device rescans performed by the node plugin",
+	},
+		// Possible status - "pass", "fail"
+		[]string{"status"})
 )
+
+// RecordVolumeControlOp observes the duration of the CSI control operation started at the given time
+// and, if the operation failed, counts the failure per gRPC status code of the returned error.
+func RecordVolumeControlOp(volumeType string, opType string, start time.Time, err error) {
+	recordVolumeOp(VolumeControlOpsHistVec, VolumeControlOpsFailureCounterVec, volumeType, opType, start, err)
+}
+
+// RecordNodeVolumeOp observes the duration of the CSI node operation started at the given time
+// and, if the operation failed, counts the failure per gRPC status code of the returned error.
+func RecordNodeVolumeOp(volumeType string, opType string, start time.Time, err error) {
+	recordVolumeOp(NodeVolumeOpsHistVec, NodeVolumeOpsFailureCounterVec, volumeType, opType, start, err)
+}
+
+// recordVolumeOp observes the duration of a volume operation in histVec and counts its failure in failureCounterVec
+func recordVolumeOp(histVec *prometheus.HistogramVec, failureCounterVec *prometheus.CounterVec, volumeType string,
+	opType string, start time.Time, err error) {
+	if err != nil {
+		histVec.WithLabelValues(volumeType, opType, PrometheusFailStatus).Observe(time.Since(start).Seconds())
+		failureCounterVec.WithLabelValues(volumeType, opType, status.Code(err).String()).Inc()
+		return
+	}
+	histVec.WithLabelValues(volumeType, opType, PrometheusPassStatus).Observe(time.Since(start).Seconds())
+}
@@ -60,6 +60,25 @@ func GetVCenter(ctx context.Context, manager *Manager) (*cnsvsphere.VirtualCente
 	return vcenter, nil
 }
 
+// CheckVCenterReadiness verifies that the controller backed by the specified Manager
+// holds a valid vCenter session and an initialized CNS client.
+func CheckVCenterReadiness(ctx context.Context, manager *Manager) error {
+	if manager == nil || manager.VcenterConfig == nil || manager.VcenterManager == nil {
+		return fmt.Errorf("controller is not initialized")
+	}
+	if manager.VolumeManager == nil {
+		return fmt.Errorf("CNS volume manager is not initialized")
+	}
+	vcenter, err := GetVCenter(ctx, manager)
+	if err != nil {
+		return fmt.Errorf("failed to establish session with vCenter %q. err: %v", manager.VcenterConfig.Host, err)
+	}
+	if vcenter.CnsClient == nil {
+		return fmt.Errorf("CNS client is not initialized for vCenter %q", manager.VcenterConfig.Host)
+	}
+	return nil
+}
+
 // GetUUIDFromProviderID Returns VM UUID from Node's providerID
 func GetUUIDFromProviderID(providerID string) string {
 	return strings.TrimPrefix(providerID, ProviderPrefix)
@@ -198,6 +217,12 @@ func IsTargetInMounts(ctx context.Context, target string, mnts []gofsutil.Info)
 	return false
 }
 
+// IsCreateMetadataParam returns true if the given CreateVolume parameter is one of the
+// PVC/PV metadata parameters added by the external-provisioner
+func IsCreateMetadataParam(param string) bool {
+	return param == AttributePVCName || param == AttributePVCNamespace || param == AttributePVName
+}
+
 // ParseStorageClassParams parses the params in the CSI CreateVolumeRequest API call back
 // to StorageClassParams structure.
 func ParseStorageClassParams(ctx context.Context, params map[string]string, csiMigrationFeatureState bool) (*StorageClassParams, error) {
@@ -215,6 +240,8 @@ func ParseStorageClassParams(ctx context.Context, params map[string]string, csiM
 				scParams.StoragePolicyName = value
 			} else if param == AttributeFsType {
 				log.Warnf("param 'fstype' is deprecated, please use 'csi.storage.k8s.io/fstype' instead")
+			} else if IsCreateMetadataParam(param) {
+				continue
 			} else {
 				return nil, fmt.Errorf("Invalid param: %q and value: %q", param, value)
 			}
@@ -231,6 +258,8 @@ func ParseStorageClassParams(ctx context.Context, params map[string]string, csiM
 				log.Warnf("param 'fstype' is deprecated, please use 'csi.storage.k8s.io/fstype' instead")
 			} else if param == CSIMigrationParams {
 				scParams.CSIMigration = value
+			} else if IsCreateMetadataParam(param) {
+				continue
 			} else {
 				otherParams[param] = value
 			}
@@ -21,6 +21,10 @@ import (
 	"testing"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/vmware/govmomi/object"
+	vim25types "github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/vsphere"
 )
 
 var (
@@ -304,6 +308,28 @@ func TestParseStorageClassParamsWithValidParams(t *testing.T) {
 	}
 }
 
+func TestParseStorageClassParamsWithCreateMetadata(t *testing.T) {
+	params := map[string]string{
+		AttributeStoragePolicyName: "policy1",
+		AttributePVCName:           "pvc1",
+		AttributePVCNamespace:      "default",
+		AttributePVName:            "pvc-1",
+	}
+	expectedScParams := &StorageClassParams{
+		StoragePolicyName: "policy1",
+	}
+	for _, csiMigrationFeatureState := range []bool{false, true} {
+		actualScParams, err := ParseStorageClassParams(ctx, params, csiMigrationFeatureState)
+		if err != nil {
+			t.Errorf("failed to parse params: %+v. err: %v", params, err)
+			continue
+		}
+		if !isStorageClassParamsEqual(expectedScParams, actualScParams) {
+			t.Errorf("Expected: %+v\n Actual: %+v", expectedScParams, actualScParams)
+		}
+	}
+}
+
 func TestParseStorageClassParamsWithMigrationEnabledNagative(t *testing.T) {
 	csiMigrationFeatureState := true
 	params := map[string]string{
@@ -390,3 +416,50 @@ func TestParseStorageClassParamsWithMigrationDisabled(t *testing.T) {
 	}
 	t.Logf("expected err received. err: %v", err)
 }
+
+func TestSelectDatastoreWithMostFreeSpace(t *testing.T) {
+	newDatastoreInfo := func(moRef string, url string, freeSpace int64) *vsphere.DatastoreInfo {
+		return &vsphere.DatastoreInfo{
+			Datastore: &vsphere.Datastore{
+				Datastore: object.NewDatastore(nil, vim25types.ManagedObjectReference{Type: "Datastore", Value: moRef}),
+			},
+			Info: &vim25types.DatastoreInfo{Url: url, FreeSpace: freeSpace},
+		}
+	}
+	dsURLInfoMap := map[string]*vsphere.DatastoreInfo{
+		"ds:///vmfs/volumes/ds-1/": newDatastoreInfo("datastore-1", "ds:///vmfs/volumes/ds-1/", 100),
+		"ds:///vmfs/volumes/ds-2/": newDatastoreInfo("datastore-2", "ds:///vmfs/volumes/ds-2/", 300),
+		"ds:///vmfs/volumes/ds-3/": newDatastoreInfo("datastore-3", "ds:///vmfs/volumes/ds-3/", 200),
+	}
+	tests := []struct {
+		compatibleDatastores map[string]bool
+		expectedURL          string
+	}{
+		{compatibleDatastores: map[string]bool{}, expectedURL: ""},
+		{compatibleDatastores: map[string]bool{"datastore-1": true}, expectedURL: "ds:///vmfs/volumes/ds-1/"},
+		{compatibleDatastores: map[string]bool{"datastore-1": true, "datastore-3": true}, expectedURL: "ds:///vmfs/volumes/ds-3/"},
+		{compatibleDatastores: map[string]bool{"datastore-1": true, "datastore-2": true, "datastore-3": true}, expectedURL: "ds:///vmfs/volumes/ds-2/"},
+	}
+	for _, test := range tests {
+		selected := selectDatastoreWithMostFreeSpace(dsURLInfoMap, test.compatibleDatastores)
+		if test.expectedURL == "" {
+			if selected != nil {
+				t.Errorf("expected no datastore for %v, got %s", test.compatibleDatastores, selected.Info.Url)
+			}
+			continue
+		}
+		if selected == nil || selected.Info.Url != test.expectedURL {
+			t.Errorf("expected datastore %s for %v, got %v", test.expectedURL, test.compatibleDatastores, selected)
+		}
+	}
+	// ds-2 has the most free space but is not accessible to the nodes of the volume on ds-1
+	candidates := filterCandidateDatastores(dsURLInfoMap, map[string]bool{"ds:///vmfs/volumes/ds-3/": true},
+		"ds:///vmfs/volumes/ds-1/")
+	if len(candidates) != 2 || candidates["ds:///vmfs/volumes/ds-1/"] == nil || candidates["ds:///vmfs/volumes/ds-3/"] == nil {
+		t.Errorf("expected the current and candidate datastores, got %v", candidates)
+	}
+	selected := selectDatastoreWithMostFreeSpace(candidates, map[string]bool{"datastore-2": true, "datastore-3": true})
+	if selected == nil || selected.Info.Url != "ds:///vmfs/volumes/ds-3/" {
+		t.Errorf("expected candidate datastore ds-3, got %v", selected)
+	}
+}
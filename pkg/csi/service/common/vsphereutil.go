@@ -524,6 +524,153 @@ func getDatastoreMoRefs(datastores []*vsphere.DatastoreInfo) []vim25types.Manage
 	return datastoreMoRefs
 }
 
+// HasCompatibleDatastore returns true if at least one of the given datastores is compatible
+// with the storage policy with the given ID
+func HasCompatibleDatastore(ctx context.Context, vc *vsphere.VirtualCenter, storagePolicyID string,
+	datastores []*vsphere.DatastoreInfo) (bool, error) {
+	log := logger.GetLogger(ctx)
+	if len(datastores) == 0 {
+		return false, nil
+	}
+	compatibilityResult, err := vc.PbmCheckCompatibility(ctx, getDatastoreMoRefs(datastores), storagePolicyID)
+	if err != nil {
+		log.Errorf("failed to check compatibility of datastores with storage policy %q. err: %v", storagePolicyID, err)
+		return false, err
+	}
+	return len(compatibilityResult.CompatibleDatastores()) > 0, nil
+}
+
+// ApplyVolumeStoragePolicy applies the storage policy with the given ID to the block volume
+// residing on the datastore with the given URL. The volume is not relocated.
+func ApplyVolumeStoragePolicy(ctx context.Context, vc *vsphere.VirtualCenter, volumeManager cnsvolume.Manager,
+	volumeID string, datastoreURL string, storagePolicyID string) error {
+	log := logger.GetLogger(ctx)
+	datastore, err := getDatastore(ctx, vc, datastoreURL)
+	if err != nil {
+		log.Errorf("failed to find datastore %q of volume %q. err: %v", datastoreURL, volumeID, err)
+		return err
+	}
+	err = volumeManager.UpdateVolumeStoragePolicy(ctx, volumeID, datastore, storagePolicyID)
+	if err != nil {
+		log.Errorf("failed to apply storage policy %q to volume %q. err: %v", storagePolicyID, volumeID, err)
+		return err
+	}
+	log.Infof("Successfully applied storage policy %q to volume %q", storagePolicyID, volumeID)
+	return nil
+}
+
+// ChangeVolumeStoragePolicy changes the storage policy of the block volume residing on the datastore
+// with the given URL to the policy with the given ID. If the current datastore is compatible with the
+// policy, the policy is applied in place. Otherwise the volume is relocated with the new policy to the
+// compatible datastore with the most free space among candidateDatastoreURLs in the same datacenter,
+// which the caller restricts to the datastores the volume can be attached from. It returns the URL of
+// the datastore the volume resides on afterwards.
+func ChangeVolumeStoragePolicy(ctx context.Context, vc *vsphere.VirtualCenter, volumeManager cnsvolume.Manager,
+	volumeID string, datastoreURL string, storagePolicyID string, candidateDatastoreURLs map[string]bool) (string, error) {
+	log := logger.GetLogger(ctx)
+	datacenters, err := vc.GetDatacenters(ctx)
+	if err != nil {
+		log.Errorf("failed to get datacenters from VC %q. err: %v", vc.Config.Host, err)
+		return "", err
+	}
+	for _, datacenter := range datacenters {
+		dsURLInfoMap, err := datacenter.GetAllDatastores(ctx)
+		if err != nil {
+			log.Warnf("failed to get datastores in datacenter %q from VC %q, Error: %+v",
+				datacenter.InventoryPath, vc.Config.Host, err)
+			continue
+		}
+		currentDatastore, found := dsURLInfoMap[datastoreURL]
+		if !found {
+			continue
+		}
+		dsURLInfoMap = filterCandidateDatastores(dsURLInfoMap, candidateDatastoreURLs, datastoreURL)
+		var datastoreMoRefs []vim25types.ManagedObjectReference
+		for _, dsInfo := range dsURLInfoMap {
+			datastoreMoRefs = append(datastoreMoRefs, dsInfo.Reference())
+		}
+		compatibilityResult, err := vc.PbmCheckCompatibility(ctx, datastoreMoRefs, storagePolicyID)
+		if err != nil {
+			log.Errorf("failed to check compatibility of datastores with storage policy %q. err: %v", storagePolicyID, err)
+			return "", err
+		}
+		compatibleDatastores := make(map[string]bool)
+		for _, hub := range compatibilityResult.CompatibleDatastores() {
+			compatibleDatastores[hub.HubId] = true
+		}
+		if compatibleDatastores[currentDatastore.Reference().Value] {
+			err = volumeManager.UpdateVolumeStoragePolicy(ctx, volumeID, currentDatastore.Reference(), storagePolicyID)
+			if err != nil {
+				log.Errorf("failed to apply storage policy %q to volume %q. err: %v", storagePolicyID, volumeID, err)
+				return "", err
+			}
+			log.Infof("Successfully applied storage policy %q to volume %q", storagePolicyID, volumeID)
+			return datastoreURL, nil
+		}
+		targetDatastore := selectDatastoreWithMostFreeSpace(dsURLInfoMap, compatibleDatastores)
+		if targetDatastore == nil {
+			return "", fmt.Errorf("no datastore compatible with storage policy %q found in datacenter %q for volume %q",
+				storagePolicyID, datacenter.InventoryPath, volumeID)
+		}
+		log.Infof("Datastore %q is not compatible with storage policy %q. Relocating volume %q to datastore %q",
+			datastoreURL, storagePolicyID, volumeID, targetDatastore.Info.Url)
+		relocateSpec := cnstypes.NewCnsBlockVolumeRelocateSpec(volumeID, targetDatastore.Reference(),
+			&vim25types.VirtualMachineDefinedProfileSpec{ProfileId: storagePolicyID})
+		task, err := volumeManager.RelocateVolume(ctx, relocateSpec)
+		if err != nil {
+			log.Errorf("failed to relocate volume %q to datastore %q. err: %v", volumeID, targetDatastore.Info.Url, err)
+			return "", err
+		}
+		taskInfo, err := task.WaitForResult(ctx)
+		if err != nil {
+			log.Errorf("failed to relocate volume %q to datastore %q. err: %v", volumeID, targetDatastore.Info.Url, err)
+			return "", err
+		}
+		results := taskInfo.Result.(cnstypes.CnsVolumeOperationBatchResult)
+		for _, result := range results.VolumeResults {
+			if fault := result.GetCnsVolumeOperationResult().Fault; fault != nil {
+				log.Errorf("Fault: %+v encountered while relocating volume %q", fault, volumeID)
+				return "", errors.New(fault.LocalizedMessage)
+			}
+		}
+		log.Infof("Successfully relocated volume %q to datastore %q with storage policy %q",
+			volumeID, targetDatastore.Info.Url, storagePolicyID)
+		return targetDatastore.Info.Url, nil
+	}
+	msg := fmt.Sprintf("Unable to find datastore for datastore URL %s in VC %+v", datastoreURL, vc)
+	return "", errors.New(msg)
+}
+
+// filterCandidateDatastores returns the datastores of dsURLInfoMap whose URL is in candidateDatastoreURLs,
+// along with the current datastore of the volume.
+func filterCandidateDatastores(dsURLInfoMap map[string]*vsphere.DatastoreInfo, candidateDatastoreURLs map[string]bool,
+	currentDatastoreURL string) map[string]*vsphere.DatastoreInfo {
+	candidates := make(map[string]*vsphere.DatastoreInfo)
+	for url, dsInfo := range dsURLInfoMap {
+		if url == currentDatastoreURL || candidateDatastoreURLs[url] {
+			candidates[url] = dsInfo
+		}
+	}
+	return candidates
+}
+
+// selectDatastoreWithMostFreeSpace returns the datastore with the most free space among the datastores
+// whose MoRef values are in compatibleDatastores, or nil if there is none.
+func selectDatastoreWithMostFreeSpace(dsURLInfoMap map[string]*vsphere.DatastoreInfo,
+	compatibleDatastores map[string]bool) *vsphere.DatastoreInfo {
+	var selected *vsphere.DatastoreInfo
+	for _, dsInfo := range dsURLInfoMap {
+		if !compatibleDatastores[dsInfo.Reference().Value] {
+			continue
+		}
+		if selected == nil || dsInfo.Info.FreeSpace > selected.Info.FreeSpace ||
+			(dsInfo.Info.FreeSpace == selected.Info.FreeSpace && dsInfo.Info.Url < selected.Info.Url) {
+			selected = dsInfo
+		}
+	}
+	return selected
+}
+
 // Helper function to get DatastoreMoRef for given datastoreURL in the given virtual center.
 func getDatastore(ctx context.Context, vc *vsphere.VirtualCenter, datastoreURL string) (vim25types.ManagedObjectReference, error) {
 	log := logger.GetLogger(ctx)
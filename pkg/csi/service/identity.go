@@ -18,20 +18,59 @@ package service
 
 import (
 	"context"
+	"strings"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
 	csitypes "sigs.k8s.io/vsphere-csi-driver/pkg/csi/types"
 )
 
 // Version of the driver. This should be set via ldflags.
 var Version string
 
+// Probe reports the readiness of the plugin. The plugin is reported as not ready
+// while the container orchestrator utility or the controller is still being
+// initialized. A FailedPrecondition error is returned when a dependency required
+// to serve requests is unavailable, i.e. the vCenter session or CNS client in
+// controller mode and the VM UUID of the node in node mode.
 func (s *service) Probe(
 	ctx context.Context,
 	req *csi.ProbeRequest) (
 	*csi.ProbeResponse, error) {
+	ctx = logger.NewContextWithLogger(ctx)
+	log := logger.GetLogger(ctx)
 
-	return &csi.ProbeResponse{}, nil
+	notReady := &csi.ProbeResponse{Ready: &wrappers.BoolValue{Value: false}}
+	if commonco.ContainerOrchestratorUtility == nil {
+		log.Warnf("Probe: container orchestrator utility is not initialized yet")
+		return notReady, nil
+	}
+	if strings.EqualFold(s.mode, "node") {
+		// Node VM UUID is not needed to serve node requests in guest clusters.
+		if clusterFlavor != cnstypes.CnsClusterFlavorGuest {
+			if _, err := getSystemUUID(ctx); err != nil {
+				log.Errorf("Probe: failed to get system UUID of the node VM. err: %v", err)
+				return nil, status.Errorf(codes.FailedPrecondition,
+					"failed to get system UUID of the node VM. err: %v", err)
+			}
+		}
+	} else {
+		if s.cnscs == nil {
+			log.Warnf("Probe: controller is not initialized yet")
+			return notReady, nil
+		}
+		if err := s.cnscs.CheckReadiness(ctx); err != nil {
+			log.Errorf("Probe: controller is not ready. err: %v", err)
+			return nil, status.Errorf(codes.FailedPrecondition, "controller is not ready. err: %v", err)
+		}
+	}
+	return &csi.ProbeResponse{Ready: &wrappers.BoolValue{Value: true}}, nil
 }
 
 func (s *service) GetPluginInfo(
@@ -0,0 +1,129 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sigs.k8s.io/vsphere-csi-driver/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/common/unittestcommon"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/commonco"
+)
+
+// fakeController is a CnsController whose readiness is set by the test
+type fakeController struct {
+	csi.ControllerServer
+	readinessErr error
+}
+
+func (f *fakeController) Init(config *config.Config, version string) error {
+	return nil
+}
+
+func (f *fakeController) CheckReadiness(ctx context.Context) error {
+	return f.readinessErr
+}
+
+func TestControllerProbe(t *testing.T) {
+	ctx := context.Background()
+	defer func(co commonco.COCommonInterface) { commonco.ContainerOrchestratorUtility = co }(
+		commonco.ContainerOrchestratorUtility)
+
+	s := &service{mode: "controller"}
+	commonco.ContainerOrchestratorUtility = nil
+	resp, err := s.Probe(ctx, &csi.ProbeRequest{})
+	if err != nil || resp.Ready == nil || resp.Ready.Value {
+		t.Errorf("expected not ready before the CO utility is initialized, got %v, err: %v", resp, err)
+	}
+
+	var coErr error
+	commonco.ContainerOrchestratorUtility, coErr = unittestcommon.GetFakeContainerOrchestratorInterface(common.Kubernetes)
+	if coErr != nil {
+		t.Fatalf("failed to create co agnostic interface. err: %v", coErr)
+	}
+	resp, err = s.Probe(ctx, &csi.ProbeRequest{})
+	if err != nil || resp.Ready == nil || resp.Ready.Value {
+		t.Errorf("expected not ready before the controller is initialized, got %v, err: %v", resp, err)
+	}
+
+	s.cnscs = &fakeController{readinessErr: errors.New("no vCenter session")}
+	_, err = s.Probe(ctx, &csi.ProbeRequest{})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected FailedPrecondition for a controller which is not ready, got %v", err)
+	}
+
+	s.cnscs = &fakeController{}
+	resp, err = s.Probe(ctx, &csi.ProbeRequest{})
+	if err != nil || resp.Ready == nil || !resp.Ready.Value {
+		t.Errorf("expected ready controller, got %v, err: %v", resp, err)
+	}
+}
+
+func TestNodeProbe(t *testing.T) {
+	ctx := context.Background()
+	defer func(co commonco.COCommonInterface) { commonco.ContainerOrchestratorUtility = co }(
+		commonco.ContainerOrchestratorUtility)
+	defer func(getUUID func(ctx context.Context) (string, error)) { getSystemUUID = getUUID }(getSystemUUID)
+	defer func(flavor cnstypes.CnsClusterFlavor) { clusterFlavor = flavor }(clusterFlavor)
+
+	var err error
+	commonco.ContainerOrchestratorUtility, err = unittestcommon.GetFakeContainerOrchestratorInterface(common.Kubernetes)
+	if err != nil {
+		t.Fatalf("failed to create co agnostic interface. err: %v", err)
+	}
+	tests := []struct {
+		name          string
+		clusterFlavor cnstypes.CnsClusterFlavor
+		uuidErr       error
+		expectedCode  codes.Code
+	}{
+		{name: "vanilla node with UUID", clusterFlavor: cnstypes.CnsClusterFlavorVanilla, expectedCode: codes.OK},
+		{name: "vanilla node without UUID", clusterFlavor: cnstypes.CnsClusterFlavorVanilla,
+			uuidErr: errors.New("no product_uuid"), expectedCode: codes.FailedPrecondition},
+		{name: "guest node with UUID", clusterFlavor: cnstypes.CnsClusterFlavorGuest, expectedCode: codes.OK},
+		{name: "guest node without UUID", clusterFlavor: cnstypes.CnsClusterFlavorGuest,
+			uuidErr: errors.New("no product_uuid"), expectedCode: codes.OK},
+	}
+	s := &service{mode: "node"}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clusterFlavor = test.clusterFlavor
+			uuidErr := test.uuidErr
+			getSystemUUID = func(ctx context.Context) (string, error) {
+				if uuidErr != nil {
+					return "", uuidErr
+				}
+				return "42208c6b-d10d-37d0-156f-435f999d94c1", nil
+			}
+			resp, err := s.Probe(ctx, &csi.ProbeRequest{})
+			if status.Code(err) != test.expectedCode {
+				t.Fatalf("expected code %v, got %v", test.expectedCode, err)
+			}
+			if test.expectedCode == codes.OK && (resp.Ready == nil || !resp.Ready.Value) {
+				t.Errorf("expected ready node, got %v", resp)
+			}
+		})
+	}
+}
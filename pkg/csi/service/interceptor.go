@@ -0,0 +1,63 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+)
+
+// LoggerUnaryServerInterceptor returns a gRPC interceptor associating a logger with the context of
+// each CSI RPC. The logger logs the volume, node and PVC of the request, if any, so that the
+// loggers created from this context with NewContextWithLogger log them as well.
+func LoggerUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		if fields := requestFields(req); len(fields) > 0 {
+			ctx = logger.NewContextWithFields(ctx, fields...)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// requestFields returns the log fields identifying the volume, node and PVC of the given CSI request
+func requestFields(req interface{}) []zapcore.Field {
+	var fields []zapcore.Field
+	if r, ok := req.(interface{ GetVolumeId() string }); ok && r.GetVolumeId() != "" {
+		fields = append(fields, zap.String(logger.LogVolumeIDKey, r.GetVolumeId()))
+	}
+	if r, ok := req.(interface{ GetNodeId() string }); ok && r.GetNodeId() != "" {
+		fields = append(fields, zap.String(logger.LogNodeIDKey, r.GetNodeId()))
+	}
+	if r, ok := req.(*csi.CreateVolumeRequest); ok {
+		fields = append(fields, zap.String(logger.LogVolumeNameKey, r.GetName()))
+		// the PVC parameters are added by the external-provisioner started with --extra-create-metadata
+		params := r.GetParameters()
+		if params[common.AttributePVCName] != "" {
+			fields = append(fields, zap.String(logger.LogPVCNameKey, params[common.AttributePVCName]),
+				zap.String(logger.LogPVCNamespaceKey, params[common.AttributePVCNamespace]))
+		}
+	}
+	return fields
+}
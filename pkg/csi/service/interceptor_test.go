@@ -0,0 +1,52 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+)
+
+func TestRequestFields(t *testing.T) {
+	tests := []struct {
+		req      interface{}
+		expected map[string]string
+	}{
+		{&csi.GetPluginInfoRequest{}, map[string]string{}},
+		{&csi.ControllerPublishVolumeRequest{VolumeId: "vol-1", NodeId: "node-1"},
+			map[string]string{logger.LogVolumeIDKey: "vol-1", logger.LogNodeIDKey: "node-1"}},
+		{&csi.CreateVolumeRequest{Name: "pvc-1", Parameters: map[string]string{
+			common.AttributePVCName: "claim", common.AttributePVCNamespace: "default"}},
+			map[string]string{logger.LogVolumeNameKey: "pvc-1", logger.LogPVCNameKey: "claim", logger.LogPVCNamespaceKey: "default"}},
+	}
+	for _, test := range tests {
+		fields := requestFields(test.req)
+		if len(fields) != len(test.expected) {
+			t.Errorf("expected fields %v for %T, got %v", test.expected, test.req, fields)
+			continue
+		}
+		for _, field := range fields {
+			if test.expected[field.Key] != field.String {
+				t.Errorf("expected %s=%q for %T, got %q", field.Key, test.expected[field.Key], test.req, field.String)
+			}
+		}
+	}
+}
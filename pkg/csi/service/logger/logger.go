@@ -2,8 +2,10 @@ package logger
 
 import (
 	"context"
+	"strings"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -20,10 +22,26 @@ const (
 	EnvLoggerLevel = "LOGGER_LEVEL"
 	// LogCtxIDKey holds the TraceId for log
 	LogCtxIDKey = "TraceId"
+	// LogOTelTraceIDKey holds the OpenTelemetry trace ID for log, if the context holds a span
+	LogOTelTraceIDKey = "OTelTraceId"
+	// LogVolumeIDKey holds the volume ID of the CSI request
+	LogVolumeIDKey = "VolumeID"
+	// LogVolumeNameKey holds the name of the volume to create
+	LogVolumeNameKey = "VolumeName"
+	// LogNodeIDKey holds the node ID of the CSI request
+	LogNodeIDKey = "NodeID"
+	// LogPVCNameKey holds the name of the PVC the volume is created for
+	LogPVCNameKey = "PVCName"
+	// LogPVCNamespaceKey holds the namespace of the PVC the volume is created for
+	LogPVCNamespaceKey = "PVCNamespace"
 )
 
 var defaultLogLevel LogLevel
 
+// atomicLevel is the minimum enabled level of all loggers. It is shared by the loggers so that
+// a change of the level at runtime also applies to the loggers that are already created.
+var atomicLevel = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+
 // loggerKey holds the context key used for loggers.
 type loggerKey struct{}
 
@@ -34,9 +52,35 @@ func SetLoggerLevel(logLevel LogLevel) {
 	if logLevel != ProductionLogLevel && logLevel != DevelopmentLogLevel {
 		defaultLogLevel = ProductionLogLevel
 	}
+	atomicLevel.SetLevel(defaultVerbosity())
 	newLogger().Sugar().Infof("Setting default log level to :%q", defaultLogLevel)
 }
 
+// SetLogVerbosity sets the minimum enabled level of all loggers at runtime to the given
+// level, one of "debug", "info", "warn" or "error". An empty level restores the default
+// level of the LogLevel set with SetLoggerLevel.
+func SetLogVerbosity(level string) error {
+	verbosity := defaultVerbosity()
+	if level != "" {
+		if err := verbosity.UnmarshalText([]byte(strings.ToLower(level))); err != nil {
+			return err
+		}
+	}
+	if verbosity != atomicLevel.Level() {
+		newLogger().Sugar().Infof("Setting log verbosity to :%q", verbosity)
+		atomicLevel.SetLevel(verbosity)
+	}
+	return nil
+}
+
+// defaultVerbosity returns the minimum enabled level of the loggers for defaultLogLevel
+func defaultVerbosity() zapcore.Level {
+	if defaultLogLevel == DevelopmentLogLevel {
+		return zapcore.DebugLevel
+	}
+	return zapcore.InfoLevel
+}
+
 // getLogger returns the logger associated with the given context.
 // If there is no logger associated with context, getLogger func will return a new logger.
 func getLogger(ctx context.Context) *zap.Logger {
@@ -52,12 +96,22 @@ func GetLogger(ctx context.Context) *zap.SugaredLogger {
 	return getLogger(ctx).Sugar()
 }
 
-// NewContextWithLogger returns a new child context with context UUID set using key CtxId
+// NewContextWithLogger returns a new child context with context UUID set using key CtxId.
+// If ctx holds a span, its trace ID is set using key OTelTraceId.
 func NewContextWithLogger(ctx context.Context) context.Context {
-	newCtx := withFields(ctx, zap.String(LogCtxIDKey, uuid.New().String()))
+	fields := []zapcore.Field{zap.String(LogCtxIDKey, uuid.New().String())}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		fields = append(fields, zap.String(LogOTelTraceIDKey, spanContext.TraceID().String()))
+	}
+	newCtx := withFields(ctx, fields...)
 	return newCtx
 }
 
+// NewContextWithFields returns a new child context whose logger always logs the given fields
+func NewContextWithFields(ctx context.Context, fields ...zapcore.Field) context.Context {
+	return withFields(ctx, fields...)
+}
+
 // GetNewContextWithLogger creates a new context with context UUID and logger set
 // func returns both context and logger to the caller.
 func GetNewContextWithLogger() (context.Context, *zap.SugaredLogger) {
@@ -81,9 +135,12 @@ func withFields(ctx context.Context, fields ...zapcore.Field) context.Context {
 func newLogger() *zap.Logger {
 	var logger *zap.Logger
 	if defaultLogLevel == DevelopmentLogLevel {
-		logger, _ = zap.NewDevelopment()
+		loggerConfig := zap.NewDevelopmentConfig()
+		loggerConfig.Level = atomicLevel
+		logger, _ = loggerConfig.Build()
 	} else {
 		loggerConfig := zap.NewProductionConfig()
+		loggerConfig.Level = atomicLevel
 		loggerConfig.EncoderConfig.TimeKey = "time"
 		loggerConfig.EncoderConfig.EncodeTime = zapcore.RFC3339NanoTimeEncoder
 		logger, _ = loggerConfig.Build()
@@ -0,0 +1,36 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSetLogVerbosity(t *testing.T) {
+	SetLoggerLevel(ProductionLogLevel)
+	if err := SetLogVerbosity("DEBUG"); err != nil || atomicLevel.Level() != zapcore.DebugLevel {
+		t.Errorf("expected debug verbosity, got %v. err: %v", atomicLevel.Level(), err)
+	}
+	if err := SetLogVerbosity("verbose"); err == nil || atomicLevel.Level() != zapcore.DebugLevel {
+		t.Errorf("expected invalid verbosity to be rejected, got %v", atomicLevel.Level())
+	}
+	if err := SetLogVerbosity(""); err != nil || atomicLevel.Level() != zapcore.InfoLevel {
+		t.Errorf("expected default info verbosity, got %v. err: %v", atomicLevel.Level(), err)
+	}
+}
@@ -25,9 +25,11 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/akutz/gofsutil"
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-csi/csi-lib-utils/protosanitizer"
 	csictx "github.com/rexray/gocsi/context"
 	cnstypes "github.com/vmware/govmomi/cns/types"
 	"github.com/vmware/govmomi/units"
@@ -47,6 +49,7 @@ import (
 	cnsconfig "sigs.k8s.io/vsphere-csi-driver/pkg/common/config"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/prometheus"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
 	csitypes "sigs.k8s.io/vsphere-csi-driver/pkg/csi/types"
 )
@@ -91,43 +94,53 @@ func (s *service) NodeStageVolume(
 	ctx context.Context,
 	req *csi.NodeStageVolumeRequest) (
 	*csi.NodeStageVolumeResponse, error) {
-	ctx = logger.NewContextWithLogger(ctx)
-	log := logger.GetLogger(ctx)
-	log.Infof("NodeStageVolume: called with args %+v", *req)
-
-	volumeID := req.GetVolumeId()
-	volCap := req.GetVolumeCapability()
-	// Check for block volume or file share
-	if common.IsFileVolumeRequest(ctx, []*csi.VolumeCapability{volCap}) {
-		log.Infof("NodeStageVolume: Volume %q detected as a file share volume. Ignoring staging for file volumes.", volumeID)
-		return &csi.NodeStageVolumeResponse{}, nil
-	}
+	start := time.Now()
+	volumeType := prometheus.PrometheusUnknownVolumeType
+	nodeStageVolumeInternal := func() (
+		*csi.NodeStageVolumeResponse, error) {
+		ctx = logger.NewContextWithLogger(ctx)
+		log := logger.GetLogger(ctx)
+		log.Infof("NodeStageVolume: called with args %+v", protosanitizer.StripSecrets(req))
+
+		volumeID := req.GetVolumeId()
+		volCap := req.GetVolumeCapability()
+		// Check for block volume or file share
+		if common.IsFileVolumeRequest(ctx, []*csi.VolumeCapability{volCap}) {
+			volumeType = prometheus.PrometheusFileVolumeType
+			log.Infof("NodeStageVolume: Volume %q detected as a file share volume. Ignoring staging for file volumes.", volumeID)
+			return &csi.NodeStageVolumeResponse{}, nil
+		}
+		volumeType = prometheus.PrometheusBlockVolumeType
 
-	var err error
-	params := nodeStageParams{
-		volID: volumeID,
-		// Retrieve accessmode - RO/RW
-		ro: common.IsVolumeReadOnly(req.GetVolumeCapability()),
-	}
-	// TODO: Verify if volume exists and return a NotFound error in negative scenario
-
-	// Check if this is a MountVolume or Raw BlockVolume
-	if _, ok := volCap.GetAccessType().(*csi.VolumeCapability_Mount); ok {
-		// Mount Volume
-		// Extract mount volume details
-		log.Debug("NodeStageVolume: Volume detected as a mount volume")
-		params.fsType, params.mntFlags, err = ensureMountVol(ctx, volCap)
-		if err != nil {
-			return nil, err
+		var err error
+		params := nodeStageParams{
+			volID: volumeID,
+			// Retrieve accessmode - RO/RW
+			ro: common.IsVolumeReadOnly(req.GetVolumeCapability()),
 		}
+		// TODO: Verify if volume exists and return a NotFound error in negative scenario
+
+		// Check if this is a MountVolume or Raw BlockVolume
+		if _, ok := volCap.GetAccessType().(*csi.VolumeCapability_Mount); ok {
+			// Mount Volume
+			// Extract mount volume details
+			log.Debug("NodeStageVolume: Volume detected as a mount volume")
+			params.fsType, params.mntFlags, err = ensureMountVol(ctx, volCap)
+			if err != nil {
+				return nil, err
+			}
 
-		// Check that staging path is created by CO and is a directory
-		params.stagingTarget = req.GetStagingTargetPath()
-		if _, err = verifyTargetDir(ctx, params.stagingTarget, true); err != nil {
-			return nil, err
+			// Check that staging path is created by CO and is a directory
+			params.stagingTarget = req.GetStagingTargetPath()
+			if _, err = verifyTargetDir(ctx, params.stagingTarget, true); err != nil {
+				return nil, err
+			}
 		}
+		return nodeStageBlockVolume(ctx, req, params)
 	}
-	return nodeStageBlockVolume(ctx, req, params)
+	resp, err := nodeStageVolumeInternal()
+	prometheus.RecordNodeVolumeOp(volumeType, prometheus.PrometheusStageVolumeOpType, start, err)
+	return resp, err
 }
 
 func nodeStageBlockVolume(
@@ -237,52 +250,61 @@ func (s *service) NodeUnstageVolume(
 	ctx context.Context,
 	req *csi.NodeUnstageVolumeRequest) (
 	*csi.NodeUnstageVolumeResponse, error) {
-	ctx = logger.NewContextWithLogger(ctx)
-	log := logger.GetLogger(ctx)
-	log.Infof("NodeUnstageVolume: called with args %+v", *req)
-
-	stagingTarget := req.GetStagingTargetPath()
-	// Fetch all the mount points
-	mnts, err := gofsutil.GetMounts(ctx)
-	if err != nil {
-		return nil, status.Errorf(codes.Internal,
-			"could not retrieve existing mount points: %v", err)
-	}
-	log.Debugf("NodeUnstageVolume: node mounts %+v", mnts)
-	// Figure out if the target path is present in mounts or not - Unstage is not required for file volumes
-	targetFound := common.IsTargetInMounts(ctx, stagingTarget, mnts)
-	if !targetFound {
-		log.Infof("NodeUnstageVolume: Target path %q is not mounted. Skipping unstage.", stagingTarget)
-		return &csi.NodeUnstageVolumeResponse{}, nil
-	}
+	start := time.Now()
+	volumeType := prometheus.PrometheusUnknownVolumeType
+	nodeUnstageVolumeInternal := func() (
+		*csi.NodeUnstageVolumeResponse, error) {
+		ctx = logger.NewContextWithLogger(ctx)
+		log := logger.GetLogger(ctx)
+		log.Infof("NodeUnstageVolume: called with args %+v", protosanitizer.StripSecrets(req))
+
+		stagingTarget := req.GetStagingTargetPath()
+		// Fetch all the mount points
+		mnts, err := gofsutil.GetMounts(ctx)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal,
+				"could not retrieve existing mount points: %v", err)
+		}
+		log.Debugf("NodeUnstageVolume: node mounts %+v", mnts)
+		// Figure out if the target path is present in mounts or not - Unstage is not required for file volumes
+		targetFound := common.IsTargetInMounts(ctx, stagingTarget, mnts)
+		if !targetFound {
+			log.Infof("NodeUnstageVolume: Target path %q is not mounted. Skipping unstage.", stagingTarget)
+			return &csi.NodeUnstageVolumeResponse{}, nil
+		}
 
-	volID := req.GetVolumeId()
-	dirExists, err := verifyTargetDir(ctx, stagingTarget, false)
-	if err != nil {
-		return nil, err
-	}
-	// This will take care of idempotent requests
-	if !dirExists {
-		log.Infof("NodeUnstageVolume: Target path %q does not exist. Assuming unstage is complete.", stagingTarget)
-		return &csi.NodeUnstageVolumeResponse{}, nil
-	}
+		volID := req.GetVolumeId()
+		dirExists, err := verifyTargetDir(ctx, stagingTarget, false)
+		if err != nil {
+			return nil, err
+		}
+		// This will take care of idempotent requests
+		if !dirExists {
+			log.Infof("NodeUnstageVolume: Target path %q does not exist. Assuming unstage is complete.", stagingTarget)
+			return &csi.NodeUnstageVolumeResponse{}, nil
+		}
 
-	// Block volume
-	isMounted, err := isBlockVolumeMounted(ctx, volID, stagingTarget)
-	if err != nil {
-		return nil, err
-	}
+		// Block volume
+		volumeType = prometheus.PrometheusBlockVolumeType
+		isMounted, err := isBlockVolumeMounted(ctx, volID, stagingTarget)
+		if err != nil {
+			return nil, err
+		}
 
-	// Volume is still mounted. Unstage the volume
-	if isMounted {
-		log.Infof("Attempting to unmount target %q for volume %q", stagingTarget, volID)
-		if err := gofsutil.Unmount(ctx, stagingTarget); err != nil {
-			return nil, status.Errorf(codes.Internal,
-				"Error unmounting stagingTarget: %v", err)
+		// Volume is still mounted. Unstage the volume
+		if isMounted {
+			log.Infof("Attempting to unmount target %q for volume %q", stagingTarget, volID)
+			if err := gofsutil.Unmount(ctx, stagingTarget); err != nil {
+				return nil, status.Errorf(codes.Internal,
+					"Error unmounting stagingTarget: %v", err)
+			}
 		}
+		log.Infof("NodeUnstageVolume successful for target %q for volume %q", stagingTarget, volID)
+		return &csi.NodeUnstageVolumeResponse{}, nil
 	}
-	log.Infof("NodeUnstageVolume successful for target %q for volume %q", stagingTarget, volID)
-	return &csi.NodeUnstageVolumeResponse{}, nil
+	resp, err := nodeUnstageVolumeInternal()
+	prometheus.RecordNodeVolumeOp(volumeType, prometheus.PrometheusUnstageVolumeOpType, start, err)
+	return resp, err
 }
 
 // isBlockVolumeMounted checks if the block volume is properly mounted or not.
@@ -341,129 +363,149 @@ func (s *service) NodePublishVolume(
 	ctx context.Context,
 	req *csi.NodePublishVolumeRequest) (
 	*csi.NodePublishVolumeResponse, error) {
-	ctx = logger.NewContextWithLogger(ctx)
-	log := logger.GetLogger(ctx)
-	log.Infof("NodePublishVolume: called with args %+v", *req)
-	var err error
-	params := nodePublishParams{
-		volID:  req.GetVolumeId(),
-		target: req.GetTargetPath(),
-		ro:     req.GetReadonly(),
-	}
-	// TODO: Verify if volume exists and return a NotFound error in negative scenario
-
-	params.stagingTarget = req.GetStagingTargetPath()
-	if params.stagingTarget == "" {
-		return nil, status.Errorf(codes.FailedPrecondition, "staging target path %q not set", params.stagingTarget)
-	}
-
-	// Check if this is a MountVolume or BlockVolume
-	volCap := req.GetVolumeCapability()
-	if !common.IsFileVolumeRequest(ctx, []*csi.VolumeCapability{volCap}) {
-		params.diskID, err = getDiskID(req.GetPublishContext())
-		if err != nil {
-			log.Errorf("error fetching DiskID. Parameters: %v", params)
-			return nil, err
+	start := time.Now()
+	volumeType := prometheus.PrometheusUnknownVolumeType
+	nodePublishVolumeInternal := func() (
+		*csi.NodePublishVolumeResponse, error) {
+		ctx = logger.NewContextWithLogger(ctx)
+		log := logger.GetLogger(ctx)
+		log.Infof("NodePublishVolume: called with args %+v", protosanitizer.StripSecrets(req))
+		var err error
+		params := nodePublishParams{
+			volID:  req.GetVolumeId(),
+			target: req.GetTargetPath(),
+			ro:     req.GetReadonly(),
 		}
+		// TODO: Verify if volume exists and return a NotFound error in negative scenario
 
-		log.Debugf("Checking if volume %q is attached to disk %q", params.volID, params.diskID)
-		volPath, err := verifyVolumeAttached(ctx, params.diskID)
-		if err != nil {
-			log.Errorf("error checking if volume is attached. Parameters: %v", params)
-			return nil, err
+		params.stagingTarget = req.GetStagingTargetPath()
+		if params.stagingTarget == "" {
+			return nil, status.Errorf(codes.FailedPrecondition, "staging target path %q not set", params.stagingTarget)
 		}
 
-		// Get underlying block device
-		dev, err := getDevice(volPath)
-		if err != nil {
-			msg := fmt.Sprintf("error getting block device for volume: %q. Parameters: %v err: %v", params.volID, params, err)
-			log.Error(msg)
-			return nil, status.Errorf(codes.Internal, msg)
-		}
-		params.volumePath = dev.FullPath
-		params.device = dev.RealDev
+		// Check if this is a MountVolume or BlockVolume
+		volCap := req.GetVolumeCapability()
+		if !common.IsFileVolumeRequest(ctx, []*csi.VolumeCapability{volCap}) {
+			volumeType = prometheus.PrometheusBlockVolumeType
+			params.diskID, err = getDiskID(req.GetPublishContext())
+			if err != nil {
+				log.Errorf("error fetching DiskID. Parameters: %v", params)
+				return nil, err
+			}
+
+			log.Debugf("Checking if volume %q is attached to disk %q", params.volID, params.diskID)
+			volPath, err := verifyVolumeAttached(ctx, params.diskID)
+			if err != nil {
+				log.Errorf("error checking if volume is attached. Parameters: %v", params)
+				return nil, err
+			}
 
-		// check for Block vs Mount
-		if _, ok := volCap.GetAccessType().(*csi.VolumeCapability_Block); ok {
-			// bind mount device to target
-			return publishBlockVol(ctx, req, dev, params)
+			// Get underlying block device
+			dev, err := getDevice(volPath)
+			if err != nil {
+				msg := fmt.Sprintf("error getting block device for volume: %q. Parameters: %v err: %v", params.volID, params, err)
+				log.Error(msg)
+				return nil, status.Errorf(codes.Internal, msg)
+			}
+			params.volumePath = dev.FullPath
+			params.device = dev.RealDev
+
+			// check for Block vs Mount
+			if _, ok := volCap.GetAccessType().(*csi.VolumeCapability_Block); ok {
+				// bind mount device to target
+				return publishBlockVol(ctx, req, dev, params)
+			}
+			// Volume must be a mount volume
+			return publishMountVol(ctx, req, dev, params)
 		}
-		// Volume must be a mount volume
-		return publishMountVol(ctx, req, dev, params)
+		// Volume must be a file share
+		volumeType = prometheus.PrometheusFileVolumeType
+		return publishFileVol(ctx, req, params)
 	}
-	// Volume must be a file share
-	return publishFileVol(ctx, req, params)
+	resp, err := nodePublishVolumeInternal()
+	prometheus.RecordNodeVolumeOp(volumeType, prometheus.PrometheusPublishVolumeOpType, start, err)
+	return resp, err
 }
 
 func (s *service) NodeUnpublishVolume(
 	ctx context.Context,
 	req *csi.NodeUnpublishVolumeRequest) (
 	*csi.NodeUnpublishVolumeResponse, error) {
-	ctx = logger.NewContextWithLogger(ctx)
-	log := logger.GetLogger(ctx)
-	log.Infof("NodeUnpublishVolume: called with args %+v", *req)
+	start := time.Now()
+	volumeType := prometheus.PrometheusUnknownVolumeType
+	nodeUnpublishVolumeInternal := func() (
+		*csi.NodeUnpublishVolumeResponse, error) {
+		ctx = logger.NewContextWithLogger(ctx)
+		log := logger.GetLogger(ctx)
+		log.Infof("NodeUnpublishVolume: called with args %+v", protosanitizer.StripSecrets(req))
+
+		volID := req.GetVolumeId()
+		target := req.GetTargetPath()
+
+		// Verify if the path exists
+		// NOTE: For raw block volumes, this path is a file. In all other cases, it is a directory
+		_, err := os.Stat(target)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// target path does not exist, so we must be Unpublished
+				log.Infof("NodeUnpublishVolume: Target path %q does not exist. Assuming NodeUnpublish is complete", target)
+				return &csi.NodeUnpublishVolumeResponse{}, nil
+			}
+			return nil, status.Errorf(codes.Internal,
+				"failed to stat target %q, err: %v", target, err)
+		}
 
-	volID := req.GetVolumeId()
-	target := req.GetTargetPath()
+		// Fetch all the mount points
+		mnts, err := gofsutil.GetMounts(ctx)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal,
+				"could not retrieve existing mount points: %q",
+				err.Error())
+		}
+		log.Debugf("NodeUnpublishVolume: node mounts %+v", mnts)
 
-	// Verify if the path exists
-	// NOTE: For raw block volumes, this path is a file. In all other cases, it is a directory
-	_, err := os.Stat(target)
-	if err != nil {
-		if os.IsNotExist(err) {
-			// target path does not exist, so we must be Unpublished
-			log.Infof("NodeUnpublishVolume: Target path %q does not exist. Assuming NodeUnpublish is complete", target)
+		// Check if the volume is already unpublished
+		// Also validates if path is a mounted directory or not
+		isPresent := common.IsTargetInMounts(ctx, target, mnts)
+		if !isPresent {
+			log.Infof("NodeUnpublishVolume: Target %s not present in mount points. Assuming it is already unpublished.", target)
 			return &csi.NodeUnpublishVolumeResponse{}, nil
 		}
-		return nil, status.Errorf(codes.Internal,
-			"failed to stat target %q, err: %v", target, err)
-	}
-
-	// Fetch all the mount points
-	mnts, err := gofsutil.GetMounts(ctx)
-	if err != nil {
-		return nil, status.Errorf(codes.Internal,
-			"could not retrieve existing mount points: %q",
-			err.Error())
-	}
-	log.Debugf("NodeUnpublishVolume: node mounts %+v", mnts)
-
-	// Check if the volume is already unpublished
-	// Also validates if path is a mounted directory or not
-	isPresent := common.IsTargetInMounts(ctx, target, mnts)
-	if !isPresent {
-		log.Infof("NodeUnpublishVolume: Target %s not present in mount points. Assuming it is already unpublished.", target)
-		return &csi.NodeUnpublishVolumeResponse{}, nil
-	}
 
-	// Figure out if the target path is a file or block volume
-	isFileMount, _ := common.IsFileVolumeMount(ctx, target, mnts)
-	isPublished := true
-	if !isFileMount {
-		isPublished, err = isBlockVolumePublished(ctx, volID, target)
-		if err != nil {
-			return nil, err
+		// Figure out if the target path is a file or block volume
+		isFileMount, _ := common.IsFileVolumeMount(ctx, target, mnts)
+		isPublished := true
+		volumeType = prometheus.PrometheusFileVolumeType
+		if !isFileMount {
+			volumeType = prometheus.PrometheusBlockVolumeType
+			isPublished, err = isBlockVolumePublished(ctx, volID, target)
+			if err != nil {
+				return nil, err
+			}
 		}
-	}
 
-	if isPublished {
-		log.Infof("NodeUnpublishVolume: Attempting to unmount target %q for volume %q", target, volID)
-		if err := gofsutil.Unmount(ctx, target); err != nil {
-			msg := fmt.Sprintf("Error unmounting target %q for volume %q. %q", target, volID, err.Error())
-			log.Debug(msg)
-			return nil, status.Error(codes.Internal, msg)
-		}
-		log.Debugf("Unmount successful for target %q for volume %q", target, volID)
-		// TODO Use a go routine here. The deletion of target path might not be a good reason to error out
-		// The SP is supposed to delete the files/directory it created in this target path
-		if err := rmpath(ctx, target); err != nil {
-			log.Debugf("failed to delete the target path %q", target)
-			return nil, err
+		if isPublished {
+			log.Infof("NodeUnpublishVolume: Attempting to unmount target %q for volume %q", target, volID)
+			if err := gofsutil.Unmount(ctx, target); err != nil {
+				msg := fmt.Sprintf("Error unmounting target %q for volume %q. %q", target, volID, err.Error())
+				log.Debug(msg)
+				return nil, status.Error(codes.Internal, msg)
+			}
+			log.Debugf("Unmount successful for target %q for volume %q", target, volID)
+			// TODO Use a go routine here. The deletion of target path might not be a good reason to error out
+			// The SP is supposed to delete the files/directory it created in this target path
+			if err := rmpath(ctx, target); err != nil {
+				log.Debugf("failed to delete the target path %q", target)
+				return nil, err
+			}
+			log.Debugf("Target path  %q successfully deleted", target)
 		}
-		log.Debugf("Target path  %q successfully deleted", target)
+		log.Infof("NodeUnpublishVolume successful for volume %q", volID)
+		return &csi.NodeUnpublishVolumeResponse{}, nil
 	}
-	log.Infof("NodeUnpublishVolume successful for volume %q", volID)
-	return &csi.NodeUnpublishVolumeResponse{}, nil
+	resp, err := nodeUnpublishVolumeInternal()
+	prometheus.RecordNodeVolumeOp(volumeType, prometheus.PrometheusUnpublishVolumeOpType, start, err)
+	return resp, err
 }
 
 // isBlockVolumePublished checks if the device backing block volume exists.
@@ -501,7 +543,7 @@ func (s *service) NodeGetVolumeStats(
 	*csi.NodeGetVolumeStatsResponse, error) {
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("NodeGetVolumeStats: called with args %+v", *req)
+	log.Infof("NodeGetVolumeStats: called with args %+v", protosanitizer.StripSecrets(req))
 
 	var err error
 	targetPath := req.GetVolumePath()
@@ -727,94 +769,103 @@ func (s *service) NodeExpandVolume(
 	ctx context.Context,
 	req *csi.NodeExpandVolumeRequest) (
 	*csi.NodeExpandVolumeResponse, error) {
-	ctx = logger.NewContextWithLogger(ctx)
-	log := logger.GetLogger(ctx)
-	log.Infof("NodeExpandVolume: called with args %+v", *req)
+	start := time.Now()
+	volumeType := prometheus.PrometheusUnknownVolumeType
+	nodeExpandVolumeInternal := func() (
+		*csi.NodeExpandVolumeResponse, error) {
+		ctx = logger.NewContextWithLogger(ctx)
+		log := logger.GetLogger(ctx)
+		log.Infof("NodeExpandVolume: called with args %+v", protosanitizer.StripSecrets(req))
+
+		volumeID := req.GetVolumeId()
+		if len(volumeID) == 0 {
+			return nil, status.Error(codes.InvalidArgument, "volume id must be provided")
+		} else if req.GetCapacityRange() == nil {
+			return nil, status.Error(codes.InvalidArgument, "capacity range must be provided")
+		} else if req.GetCapacityRange().GetRequiredBytes() < 0 || req.GetCapacityRange().GetLimitBytes() < 0 {
+			return nil, status.Error(codes.InvalidArgument, "capacity ranges values cannot be negative")
+		}
 
-	volumeID := req.GetVolumeId()
-	if len(volumeID) == 0 {
-		return nil, status.Error(codes.InvalidArgument, "volume id must be provided")
-	} else if req.GetCapacityRange() == nil {
-		return nil, status.Error(codes.InvalidArgument, "capacity range must be provided")
-	} else if req.GetCapacityRange().GetRequiredBytes() < 0 || req.GetCapacityRange().GetLimitBytes() < 0 {
-		return nil, status.Error(codes.InvalidArgument, "capacity ranges values cannot be negative")
-	}
+		reqVolSizeBytes := int64(req.GetCapacityRange().GetRequiredBytes())
+		reqVolSizeMB := int64(common.RoundUpSize(reqVolSizeBytes, common.MbInBytes))
 
-	reqVolSizeBytes := int64(req.GetCapacityRange().GetRequiredBytes())
-	reqVolSizeMB := int64(common.RoundUpSize(reqVolSizeBytes, common.MbInBytes))
+		// TODO(xyang): In CSI spec 1.2, NodeExpandVolume will be
+		// passing in a staging_target_path which is more precise
+		// than volume_path. Use the new staging_target_path
+		// instead of the volume_path when it is supported by Kubernetes.
 
-	// TODO(xyang): In CSI spec 1.2, NodeExpandVolume will be
-	// passing in a staging_target_path which is more precise
-	// than volume_path. Use the new staging_target_path
-	// instead of the volume_path when it is supported by Kubernetes.
+		volumePath := req.GetVolumePath()
+		if len(volumePath) == 0 {
+			return nil, status.Error(codes.InvalidArgument, "volume path must be provided to expand volume on node")
+		}
 
-	volumePath := req.GetVolumePath()
-	if len(volumePath) == 0 {
-		return nil, status.Error(codes.InvalidArgument, "volume path must be provided to expand volume on node")
-	}
+		// Look up block device mounted to staging target path
+		dev, err := getDevFromMount(volumePath)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal,
+				"error getting block device for volume: %q, err: %v",
+				volumeID, err)
+		} else if dev == nil {
+			return nil, status.Errorf(codes.Internal,
+				"volume %q is not mounted at the path %s",
+				volumeID, volumePath)
+		}
+		log.Debugf("NodeExpandVolume: staging target path %s, getDevFromMount %+v", volumePath, *dev)
+		volumeType = prometheus.PrometheusBlockVolumeType
+
+		realMounter := mount.New("")
+		realExec := utilexec.New()
+		mounter := &mount.SafeFormatAndMount{
+			Interface: realMounter,
+			Exec:      realExec,
+		}
 
-	// Look up block device mounted to staging target path
-	dev, err := getDevFromMount(volumePath)
-	if err != nil {
-		return nil, status.Errorf(codes.Internal,
-			"error getting block device for volume: %q, err: %v",
-			volumeID, err)
-	} else if dev == nil {
-		return nil, status.Errorf(codes.Internal,
-			"volume %q is not mounted at the path %s",
-			volumeID, volumePath)
-	}
-	log.Debugf("NodeExpandVolume: staging target path %s, getDevFromMount %+v", volumePath, *dev)
+		if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.OnlineVolumeExtend) {
+			// Fetch the current block size
+			currentBlockSizeBytes, err := getBlockSizeBytes(mounter, dev.RealDev)
+			if err != nil {
+				return nil, status.Error(codes.Internal, fmt.Sprintf("error when getting size of block volume at path %s: %v", dev.RealDev, err))
+			}
+			// Check if a rescan is required
+			if currentBlockSizeBytes < reqVolSizeBytes {
+				// If a device is expanded while it is attached to a VM, we need to rescan
+				// the device on the guest OS in order to see the modified size on the Guest OS
+				// Refer to https://kb.vmware.com/s/article/1006371
+				err = rescanDevice(ctx, dev)
+				if err != nil {
+					return nil, status.Error(codes.Internal, err.Error())
+				}
+			}
+		}
 
-	realMounter := mount.New("")
-	realExec := utilexec.New()
-	mounter := &mount.SafeFormatAndMount{
-		Interface: realMounter,
-		Exec:      realExec,
-	}
+		// Resize file system
+		resizer := resizefs.NewResizeFs(mounter)
+		_, err = resizer.Resize(dev.RealDev, volumePath)
+		if err != nil {
+			return nil, status.Error(codes.Internal, fmt.Sprintf("error when resizing filesystem on volume %q on node: %v", volumeID, err))
+		}
+		log.Debugf("NodeExpandVolume: Resized filesystem with devicePath %s volumePath %s", dev.RealDev, volumePath)
 
-	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.OnlineVolumeExtend) {
-		// Fetch the current block size
+		// Check the block size
 		currentBlockSizeBytes, err := getBlockSizeBytes(mounter, dev.RealDev)
 		if err != nil {
 			return nil, status.Error(codes.Internal, fmt.Sprintf("error when getting size of block volume at path %s: %v", dev.RealDev, err))
 		}
-		// Check if a rescan is required
+		// NOTE(xyang): Make sure new size is greater than or equal to the
+		// requested size. It is possible for volume size to be rounded up
+		// and therefore bigger than the requested size.
 		if currentBlockSizeBytes < reqVolSizeBytes {
-			// If a device is expanded while it is attached to a VM, we need to rescan
-			// the device on the guest OS in order to see the modified size on the Guest OS
-			// Refer to https://kb.vmware.com/s/article/1006371
-			err = rescanDevice(ctx, dev)
-			if err != nil {
-				return nil, status.Error(codes.Internal, err.Error())
-			}
+			return nil, status.Errorf(codes.Internal, "requested volume size was %d, but got volume with size %d", reqVolSizeBytes, currentBlockSizeBytes)
 		}
-	}
 
-	// Resize file system
-	resizer := resizefs.NewResizeFs(mounter)
-	_, err = resizer.Resize(dev.RealDev, volumePath)
-	if err != nil {
-		return nil, status.Error(codes.Internal, fmt.Sprintf("error when resizing filesystem on volume %q on node: %v", volumeID, err))
-	}
-	log.Debugf("NodeExpandVolume: Resized filesystem with devicePath %s volumePath %s", dev.RealDev, volumePath)
-
-	// Check the block size
-	currentBlockSizeBytes, err := getBlockSizeBytes(mounter, dev.RealDev)
-	if err != nil {
-		return nil, status.Error(codes.Internal, fmt.Sprintf("error when getting size of block volume at path %s: %v", dev.RealDev, err))
-	}
-	// NOTE(xyang): Make sure new size is greater than or equal to the
-	// requested size. It is possible for volume size to be rounded up
-	// and therefore bigger than the requested size.
-	if currentBlockSizeBytes < reqVolSizeBytes {
-		return nil, status.Errorf(codes.Internal, "requested volume size was %d, but got volume with size %d", reqVolSizeBytes, currentBlockSizeBytes)
+		log.Infof("NodeExpandVolume: expanded volume successfully. devicePath %s volumePath %s size %d", dev.RealDev, volumePath, int64(units.FileSize(reqVolSizeMB*common.MbInBytes)))
+		return &csi.NodeExpandVolumeResponse{
+			CapacityBytes: int64(units.FileSize(reqVolSizeMB * common.MbInBytes)),
+		}, nil
 	}
-
-	log.Infof("NodeExpandVolume: expanded volume successfully. devicePath %s volumePath %s size %d", dev.RealDev, volumePath, int64(units.FileSize(reqVolSizeMB*common.MbInBytes)))
-	return &csi.NodeExpandVolumeResponse{
-		CapacityBytes: int64(units.FileSize(reqVolSizeMB * common.MbInBytes)),
-	}, nil
+	resp, err := nodeExpandVolumeInternal()
+	prometheus.RecordNodeVolumeOp(volumeType, prometheus.PrometheusExpandVolumeOpType, start, err)
+	return resp, err
 }
 
 func getBlockSizeBytes(mounter *mount.SafeFormatAndMount, devicePath string) (int64, error) {
@@ -1098,10 +1149,12 @@ func rescanDevice(ctx context.Context, dev *Device) error {
 
 	err = ioutil.WriteFile(devRescanPath, []byte{'1'}, 0666)
 	if err != nil {
+		prometheus.ScsiRescanCounterVec.WithLabelValues(prometheus.PrometheusFailStatus).Inc()
 		msg := fmt.Sprintf("error rescanning block device %q. %v", dev.RealDev, err)
 		log.Error(msg)
 		return fmt.Errorf(msg)
 	}
+	prometheus.ScsiRescanCounterVec.WithLabelValues(prometheus.PrometheusPassStatus).Inc()
 	return nil
 }
 
@@ -1153,16 +1206,20 @@ func contains(list []string, item string) bool {
 
 func verifyVolumeAttached(ctx context.Context, diskID string) (string, error) {
 	log := logger.GetLogger(ctx)
+	start := time.Now()
 	// Check that volume is attached
 	volPath, err := getDiskPath(diskID, nil)
 	if err != nil {
+		prometheus.DeviceDiscoveryHistVec.WithLabelValues(prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
 		return "", status.Errorf(codes.Internal,
 			"Error trying to read attached disks: %v", err)
 	}
 	if volPath == "" {
+		prometheus.DeviceDiscoveryHistVec.WithLabelValues(prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
 		return "", status.Errorf(codes.NotFound,
 			"disk: %s not attached to node", diskID)
 	}
+	prometheus.DeviceDiscoveryHistVec.WithLabelValues(prometheus.PrometheusPassStatus).Observe(time.Since(start).Seconds())
 
 	log.Debugf("found disk: disk ID: %q, volume path: %q", diskID, volPath)
 	return volPath, nil
@@ -1293,7 +1350,8 @@ func getDevMounts(ctx context.Context,
 	return devMnts, nil
 }
 
-func getSystemUUID(ctx context.Context) (string, error) {
+// getSystemUUID returns the SMBIOS UUID of the node VM, it is a variable so that tests can stub it
+var getSystemUUID = func(ctx context.Context) (string, error) {
 	log := logger.GetLogger(ctx)
 	idb, err := ioutil.ReadFile(path.Join(dmiDir, "id", "product_uuid"))
 	if err != nil {
@@ -19,10 +19,12 @@ package service
 import (
 	"context"
 	"net"
+	"net/http"
 	"os"
 	"strings"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rexray/gocsi"
 	csictx "github.com/rexray/gocsi/context"
 	cnstypes "github.com/vmware/govmomi/cns/types"
@@ -30,7 +32,9 @@ import (
 	cnsconfig "sigs.k8s.io/vsphere-csi-driver/pkg/common/config"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/prometheus"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/tracing"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/vanilla"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/wcp"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/wcpguest"
@@ -42,6 +46,10 @@ const (
 
 	// UnixSocketPrefix is the prefix before the path on disk
 	UnixSocketPrefix = "unix://"
+
+	// defaultNodeMetricsAddress is the address on which the node plugin exposes Prometheus metrics,
+	// unless overridden by the NODE_METRICS_ADDRESS environment variable
+	defaultNodeMetricsAddress = ":2112"
 )
 
 var (
@@ -120,6 +128,18 @@ func (s *service) BeforeServe(
 
 	// Get the SP's operating mode.
 	s.mode = csictx.Getenv(ctx, gocsi.EnvVarMode)
+	// Export the spans of the RPCs if an OTLP endpoint is configured. gocsi exits the process from its
+	// signal handler rather than returning from Serve, so the queued spans are flushed on the signal,
+	// while gocsi stops the server gracefully.
+	tracingServiceName := "vsphere-csi-controller"
+	if strings.EqualFold(s.mode, "node") {
+		tracingServiceName = "vsphere-csi-node"
+	}
+	if shutdownTracing, err := tracing.InitTracerProvider(ctx, tracingServiceName); err != nil {
+		log.Warnf("failed to initialize tracing, spans are not exported. Error: %+v", err)
+	} else {
+		tracing.ShutdownOnSignal(ctx, shutdownTracing)
+	}
 	if !strings.EqualFold(s.mode, "node") {
 		// Controller service is needed
 		cfg, err = common.GetConfig(ctx)
@@ -131,6 +151,27 @@ func (s *service) BeforeServe(
 			log.Errorf("failed to init controller. Error: %+v", err)
 			return err
 		}
+	} else {
+		go serveNodeMetrics(ctx)
 	}
 	return nil
 }
+
+// serveNodeMetrics keeps the http server exposing the Prometheus metrics of the node plugin running
+func serveNodeMetrics(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	metricsAddress := os.Getenv(csitypes.EnvNodeMetricsAddress)
+	if metricsAddress == "" {
+		metricsAddress = defaultNodeMetricsAddress
+	}
+	prometheus.CsiInfo.WithLabelValues(Version).Set(1)
+	http.Handle("/metrics", promhttp.Handler())
+	for {
+		log.Infof("Starting the http server to expose Prometheus metrics on %q..", metricsAddress)
+		err := http.ListenAndServe(metricsAddress, nil)
+		if err != nil {
+			log.Warnf("Http server that exposes the Prometheus exited with err: %+v", err)
+		}
+		log.Info("Restarting http server to expose Prometheus metrics..")
+	}
+}
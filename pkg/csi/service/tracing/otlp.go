@@ -0,0 +1,266 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	// otlpExportTimeout is the timeout of a single export request to the collector
+	otlpExportTimeout = 10 * time.Second
+
+	// OTLP status codes, see opentelemetry/proto/trace/v1/trace.proto
+	otlpStatusCodeOk    = 1
+	otlpStatusCodeError = 2
+)
+
+// otlpHTTPExporter is a SpanExporter sending spans to an OTLP/HTTP collector using the
+// JSON encoding of the OTLP protocol.
+// The upstream otlptracehttp exporter is not used because its generated OTLP protos require
+// grpc v1.32 or later, which breaks the etcd v3.3 client gocsi depends on.
+type otlpHTTPExporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+// newOTLPHTTPExporter returns an exporter sending spans to the given OTLP/HTTP traces endpoint
+func newOTLPHTTPExporter(endpoint string) *otlpHTTPExporter {
+	return &otlpHTTPExporter{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: otlpExportTimeout},
+	}
+}
+
+// ExportSpans sends the given spans to the collector
+func (e *otlpHTTPExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(newOTLPTracesRequest(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to export %d spans to %q: %s", len(spans), e.endpoint, resp.Status)
+	}
+	return nil
+}
+
+// Shutdown stops the exporter
+func (e *otlpHTTPExporter) Shutdown(ctx context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+type otlpTracesRequest struct {
+	ResourceSpans []*otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource      `json:"resource"`
+	ScopeSpans []*otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *string         `json:"intValue,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+// newOTLPTracesRequest groups the given spans per resource and instrumentation library
+func newOTLPTracesRequest(spans []sdktrace.ReadOnlySpan) *otlpTracesRequest {
+	request := &otlpTracesRequest{}
+	resourceSpansMap := make(map[attribute.Distinct]*otlpResourceSpans)
+	scopeSpansMap := make(map[attribute.Distinct]map[string]*otlpScopeSpans)
+	for _, span := range spans {
+		resourceKey := span.Resource().Equivalent()
+		resourceSpans, ok := resourceSpansMap[resourceKey]
+		if !ok {
+			resourceSpans = &otlpResourceSpans{
+				Resource: otlpResource{Attributes: toOTLPAttributes(span.Resource().Attributes())},
+			}
+			resourceSpansMap[resourceKey] = resourceSpans
+			scopeSpansMap[resourceKey] = make(map[string]*otlpScopeSpans)
+			request.ResourceSpans = append(request.ResourceSpans, resourceSpans)
+		}
+		library := span.InstrumentationLibrary()
+		scopeKey := library.Name + "@" + library.Version
+		scopeSpans, ok := scopeSpansMap[resourceKey][scopeKey]
+		if !ok {
+			scopeSpans = &otlpScopeSpans{Scope: otlpScope{Name: library.Name, Version: library.Version}}
+			scopeSpansMap[resourceKey][scopeKey] = scopeSpans
+			resourceSpans.ScopeSpans = append(resourceSpans.ScopeSpans, scopeSpans)
+		}
+		scopeSpans.Spans = append(scopeSpans.Spans, toOTLPSpan(span))
+	}
+	return request
+}
+
+// toOTLPSpan converts the given span to its OTLP representation
+func toOTLPSpan(span sdktrace.ReadOnlySpan) otlpSpan {
+	otlpSpan := otlpSpan{
+		TraceID:           span.SpanContext().TraceID().String(),
+		SpanID:            span.SpanContext().SpanID().String(),
+		Name:              span.Name(),
+		Kind:              int(span.SpanKind()),
+		StartTimeUnixNano: strconv.FormatInt(span.StartTime().UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.EndTime().UnixNano(), 10),
+		Attributes:        toOTLPAttributes(span.Attributes()),
+	}
+	if span.Parent().HasSpanID() {
+		otlpSpan.ParentSpanID = span.Parent().SpanID().String()
+	}
+	for _, event := range span.Events() {
+		otlpSpan.Events = append(otlpSpan.Events, otlpEvent{
+			TimeUnixNano: strconv.FormatInt(event.Time.UnixNano(), 10),
+			Name:         event.Name,
+			Attributes:   toOTLPAttributes(event.Attributes),
+		})
+	}
+	switch span.Status().Code {
+	case otelcodes.Ok:
+		otlpSpan.Status.Code = otlpStatusCodeOk
+	case otelcodes.Error:
+		otlpSpan.Status.Code = otlpStatusCodeError
+		otlpSpan.Status.Message = span.Status().Description
+	}
+	return otlpSpan
+}
+
+// toOTLPAttributes converts the given attributes to their OTLP representation
+func toOTLPAttributes(attrs []attribute.KeyValue) []otlpKeyValue {
+	var otlpAttrs []otlpKeyValue
+	for _, attr := range attrs {
+		otlpAttrs = append(otlpAttrs, otlpKeyValue{Key: string(attr.Key), Value: toOTLPAnyValue(attr.Value)})
+	}
+	return otlpAttrs
+}
+
+// toOTLPAnyValue converts the given attribute value to its OTLP representation
+func toOTLPAnyValue(value attribute.Value) otlpAnyValue {
+	switch value.Type() {
+	case attribute.BOOL:
+		v := value.AsBool()
+		return otlpAnyValue{BoolValue: &v}
+	case attribute.INT64:
+		v := strconv.FormatInt(value.AsInt64(), 10)
+		return otlpAnyValue{IntValue: &v}
+	case attribute.FLOAT64:
+		v := value.AsFloat64()
+		return otlpAnyValue{DoubleValue: &v}
+	case attribute.BOOLSLICE:
+		var values []otlpAnyValue
+		for _, b := range value.AsBoolSlice() {
+			values = append(values, toOTLPAnyValue(attribute.BoolValue(b)))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case attribute.INT64SLICE:
+		var values []otlpAnyValue
+		for _, i := range value.AsInt64Slice() {
+			values = append(values, toOTLPAnyValue(attribute.Int64Value(i)))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case attribute.FLOAT64SLICE:
+		var values []otlpAnyValue
+		for _, f := range value.AsFloat64Slice() {
+			values = append(values, toOTLPAnyValue(attribute.Float64Value(f)))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case attribute.STRINGSLICE:
+		var values []otlpAnyValue
+		for _, s := range value.AsStringSlice() {
+			values = append(values, toOTLPAnyValue(attribute.StringValue(s)))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	default:
+		v := value.Emit()
+		return otlpAnyValue{StringValue: &v}
+	}
+}
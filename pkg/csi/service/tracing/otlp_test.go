@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestOTLPHTTPExporter(t *testing.T) {
+	var request otlpTracesRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != otlpTracesPath || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	traceID := trace.TraceID{0x01}
+	parent := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: trace.SpanID{0x01}})
+	start := time.Unix(0, 1000)
+	stubs := tracetest.SpanStubs{
+		{
+			Name:                   "cns.CreateVolume",
+			SpanContext:            trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: trace.SpanID{0x02}}),
+			Parent:                 parent,
+			SpanKind:               trace.SpanKindInternal,
+			StartTime:              start,
+			EndTime:                start.Add(time.Second),
+			Attributes:             []attribute.KeyValue{attribute.String("cns.volume.name", "pvc-1"), attribute.Int64("retries", 2)},
+			Status:                 sdktrace.Status{Code: otelcodes.Error, Description: "fault"},
+			Resource:               sdkresource.NewSchemaless(attribute.String("service.name", "test")),
+			InstrumentationLibrary: instrumentation.Library{Name: tracerName},
+		},
+	}
+	exporter := newOTLPHTTPExporter(server.URL + otlpTracesPath)
+	if err := exporter.ExportSpans(context.Background(), stubs.Snapshots()); err != nil {
+		t.Fatalf("failed to export spans: %v", err)
+	}
+
+	if len(request.ResourceSpans) != 1 || len(request.ResourceSpans[0].ScopeSpans) != 1 ||
+		len(request.ResourceSpans[0].ScopeSpans[0].Spans) != 1 {
+		t.Fatalf("expected one span, got %+v", request)
+	}
+	span := request.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if span.TraceID != traceID.String() || span.ParentSpanID != parent.SpanID().String() {
+		t.Errorf("unexpected span IDs: %+v", span)
+	}
+	if span.StartTimeUnixNano != "1000" || span.EndTimeUnixNano != "1000001000" {
+		t.Errorf("unexpected span times: %+v", span)
+	}
+	if span.Status.Code != otlpStatusCodeError || span.Status.Message != "fault" {
+		t.Errorf("unexpected span status: %+v", span.Status)
+	}
+	if len(span.Attributes) != 2 || *span.Attributes[0].Value.StringValue != "pvc-1" || *span.Attributes[1].Value.IntValue != "2" {
+		t.Errorf("unexpected span attributes: %+v", span.Attributes)
+	}
+
+	exporter = newOTLPHTTPExporter(server.URL + "/unknown")
+	if err := exporter.ExportSpans(context.Background(), stubs.Snapshots()); err == nil {
+		t.Errorf("expected export to an unknown path to fail")
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+)
+
+const (
+	// EnvOTLPEndpoint is the base URL of the OTLP/HTTP collector, e.g. "http://otel-collector:4318".
+	// Spans are sent to the "/v1/traces" path of this URL.
+	EnvOTLPEndpoint = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	// EnvOTLPTracesEndpoint is the full URL of the OTLP/HTTP traces endpoint and
+	// takes precedence over EnvOTLPEndpoint.
+	EnvOTLPTracesEndpoint = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	// EnvServiceName overrides the service name reported with the spans.
+	EnvServiceName = "OTEL_SERVICE_NAME"
+
+	// tracerName is the name of the tracer used for all spans of the driver
+	tracerName = "sigs.k8s.io/vsphere-csi-driver"
+	// otlpTracesPath is the path of the traces endpoint of an OTLP/HTTP collector
+	otlpTracesPath = "/v1/traces"
+	// shutdownTimeout bounds the time spent exporting the queued spans on exit
+	shutdownTimeout = 5 * time.Second
+)
+
+// InitTracerProvider installs the global tracer provider exporting spans to the OTLP/HTTP
+// endpoint configured in the environment. If no endpoint is configured the global no-op
+// tracer provider is left in place. The returned function flushes and stops the exporter.
+func InitTracerProvider(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	log := logger.GetLogger(ctx)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	endpoint := getOTLPTracesEndpoint()
+	if endpoint == "" {
+		log.Info("OTLP endpoint is not configured. Tracing is disabled.")
+		return func(context.Context) error { return nil }, nil
+	}
+	if name := os.Getenv(EnvServiceName); name != "" {
+		serviceName = name
+	}
+	res, err := sdkresource.Merge(sdkresource.Default(),
+		sdkresource.NewSchemaless(semconv.ServiceNameKey.String(serviceName)))
+	if err != nil {
+		log.Errorf("failed to create tracing resource. Err: %v", err)
+		return nil, err
+	}
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(newOTLPHTTPExporter(endpoint)),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tracerProvider)
+	log.Infof("Exporting traces of %q to %q", serviceName, endpoint)
+	return tracerProvider.Shutdown, nil
+}
+
+// Shutdown calls the shutdown function returned by InitTracerProvider, exporting the queued spans
+// within a bounded time.
+func Shutdown(ctx context.Context, shutdown func(context.Context) error) {
+	log := logger.GetLogger(ctx)
+	ctx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+	defer cancel()
+	if err := shutdown(ctx); err != nil {
+		log.Warnf("failed to export the queued spans. Err: %v", err)
+	}
+}
+
+// ShutdownOnSignal calls Shutdown when the process receives SIGTERM or SIGINT, then raises the signal
+// again, so that the process is stopped by the other handlers of the signal or by its default action.
+func ShutdownOnSignal(ctx context.Context, shutdown func(context.Context) error) {
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		sig := <-sigc
+		Shutdown(ctx, shutdown)
+		signal.Stop(sigc)
+		if process, err := os.FindProcess(os.Getpid()); err == nil {
+			_ = process.Signal(sig)
+		}
+	}()
+}
+
+// getOTLPTracesEndpoint returns the URL spans are exported to, or an empty string if tracing is disabled
+func getOTLPTracesEndpoint() string {
+	if endpoint := os.Getenv(EnvOTLPTracesEndpoint); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := os.Getenv(EnvOTLPEndpoint); endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/") + otlpTracesPath
+	}
+	return ""
+}
+
+// StartSpan starts a span with the given name as a child of the span in ctx, if any.
+// The returned context holds the new span and must be used for the calls made within the span.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan records err, if set, on the span and ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor starting a server span for each RPC.
+// The trace context propagated by the client in the W3C traceparent metadata is honored.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+		}
+		ctx, span := otel.Tracer(tracerName).Start(ctx, info.FullMethod,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.RPCSystemKey.String("grpc"), semconv.RPCMethodKey.String(info.FullMethod)))
+		resp, err := handler(ctx, req)
+		span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int64(int64(status.Code(err))))
+		EndSpan(span, err)
+		return resp, err
+	}
+}
+
+// metadataCarrier adapts gRPC metadata to a propagation.TextMapCarrier
+type metadataCarrier metadata.MD
+
+// Get returns the first value of the given key
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// Set sets the value of the given key
+func (c metadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// Keys returns the keys of the metadata
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
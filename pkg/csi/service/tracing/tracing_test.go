@@ -0,0 +1,75 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestShutdownExportsQueuedSpans(t *testing.T) {
+	var (
+		lock  sync.Mutex
+		names []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request otlpTracesRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		for _, resourceSpans := range request.ResourceSpans {
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				for _, span := range scopeSpans.Spans {
+					names = append(names, span.Name)
+				}
+			}
+		}
+	}))
+	defer server.Close()
+	defer os.Unsetenv(EnvOTLPEndpoint)
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	if err := os.Setenv(EnvOTLPEndpoint, server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	shutdown, err := InitTracerProvider(ctx, "test")
+	if err != nil {
+		t.Fatalf("failed to initialize tracing: %v", err)
+	}
+	_, span := StartSpan(ctx, "syncer.FullSync")
+	span.End()
+	// the batcher only exports on its schedule, the span is queued until the shutdown
+	Shutdown(ctx, shutdown)
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(names) != 1 || names[0] != "syncer.FullSync" {
+		t.Errorf("expected the queued span to be exported on shutdown, got %v", names)
+	}
+}
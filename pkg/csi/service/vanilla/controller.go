@@ -31,11 +31,13 @@ import (
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/fsnotify/fsnotify"
+	"github.com/kubernetes-csi/csi-lib-utils/protosanitizer"
 	"github.com/vmware/govmomi/cns"
 	cnstypes "github.com/vmware/govmomi/cns/types"
 	"github.com/vmware/govmomi/units"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
 
 	"sigs.k8s.io/vsphere-csi-driver/pkg/apis/migration"
 	cnsvolume "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/volume"
@@ -45,6 +47,7 @@ import (
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/commonco"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
 	csitypes "sigs.k8s.io/vsphere-csi-driver/pkg/csi/types"
+	k8s "sigs.k8s.io/vsphere-csi-driver/pkg/kubernetes"
 )
 
 // NodeManagerInterface provides functionality to manage nodes.
@@ -60,6 +63,9 @@ type controller struct {
 	manager *common.Manager
 	nodeMgr NodeManagerInterface
 	authMgr common.AuthorizationService
+	// eventRecorder records events on the PVCs and Nodes of the requests, it is nil if
+	// the recorder could not be created
+	eventRecorder *k8s.EventRecorder
 }
 
 // volumeMigrationService holds the pointer to VolumeMigration instance
@@ -108,10 +114,12 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 		log.Errorf("failed to register VC with virtualCenterManager. err=%v", err)
 		return err
 	}
+	volumeManager := cnsvolume.GetManagerWithQueryCache(ctx, vcenter,
+		time.Duration(config.Global.QueryVolumeCacheTTLInSec)*time.Second)
 	c.manager = &common.Manager{
 		VcenterConfig:  vcenterconfig,
 		CnsConfig:      config,
-		VolumeManager:  cnsvolume.GetManager(ctx, vcenter),
+		VolumeManager:  volumeManager,
 		VcenterManager: vcManager,
 	}
 
@@ -153,6 +161,12 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 		return err
 	}
 
+	// Events are best effort, the controller runs without them if the recorder cannot be created.
+	c.eventRecorder, err = k8s.NewEventRecorder(ctx, csitypes.Name)
+	if err != nil {
+		log.Warnf("failed to create event recorder, events will not be recorded. err=%v", err)
+	}
+
 	go cnsvolume.ClearTaskInfoObjects()
 	cfgPath := common.GetConfigPath(ctx)
 
@@ -229,6 +243,12 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 	return nil
 }
 
+// CheckReadiness verifies that the controller holds a valid vCenter session and
+// an initialized CNS client.
+func (c *controller) CheckReadiness(ctx context.Context) error {
+	return common.CheckVCenterReadiness(ctx, c.manager)
+}
+
 // ReloadConfiguration reloads configuration from the secret, and update controller's config cache
 // and VolumeManager's VC Config cache.
 func (c *controller) ReloadConfiguration(ctx context.Context) {
@@ -270,7 +290,8 @@ func (c *controller) ReloadConfiguration(ctx context.Context) {
 			vcenter.Config = newVCConfig
 		}
 		c.manager.VolumeManager.ResetManager(ctx, vcenter)
-		c.manager.VolumeManager = cnsvolume.GetManager(ctx, vcenter)
+		c.manager.VolumeManager = cnsvolume.GetManagerWithQueryCache(ctx, vcenter,
+			time.Duration(cfg.Global.QueryVolumeCacheTTLInSec)*time.Second)
 		c.manager.VcenterConfig = newVCConfig
 	}
 	if cfg != nil {
@@ -279,22 +300,52 @@ func (c *controller) ReloadConfiguration(ctx context.Context) {
 	}
 }
 
-func (c *controller) filterDatastores(ctx context.Context, sharedDatastores []*cnsvsphere.DatastoreInfo) []*cnsvsphere.DatastoreInfo {
+func (c *controller) filterDatastores(ctx context.Context, req *csi.CreateVolumeRequest,
+	sharedDatastores []*cnsvsphere.DatastoreInfo) []*cnsvsphere.DatastoreInfo {
 	log := logger.GetLogger(ctx)
 	dsMap := c.authMgr.GetDatastoreMapForBlockVolumes(ctx)
 	log.Debugf("filterDatastores: dsMap %v sharedDatastores %v", dsMap, sharedDatastores)
 	var filteredDatastores []*cnsvsphere.DatastoreInfo
+	var filteredOutDatastoreNames []string
 	for _, sharedDatastore := range sharedDatastores {
 		if _, existsInDsMap := dsMap[sharedDatastore.Info.Url]; existsInDsMap {
 			filteredDatastores = append(filteredDatastores, sharedDatastore)
 		} else {
 			log.Debugf("filter out datastore %v from create volume spec", sharedDatastore)
+			filteredOutDatastoreNames = append(filteredOutDatastoreNames, sharedDatastore.Info.Name)
 		}
 	}
 	log.Debugf("filterDatastores: filteredDatastores %v", filteredDatastores)
+	if len(filteredOutDatastoreNames) > 0 {
+		eventType := v1.EventTypeNormal
+		if len(filteredDatastores) == 0 {
+			eventType = v1.EventTypeWarning
+		}
+		c.eventRecorder.CreateVolumeEventf(ctx, req.Parameters, eventType, "DatastoresFilteredByAuthCheck",
+			"Datastores %v are not considered for placement as the vSphere user lacks privileges on them",
+			filteredOutDatastoreNames)
+	}
 	return filteredDatastores
 }
 
+// recordIfStoragePolicyIncompatible records an event on the PVC of the request if none of the
+// candidate datastores is compatible with the storage policy of the StorageClass
+func (c *controller) recordIfStoragePolicyIncompatible(ctx context.Context, req *csi.CreateVolumeRequest,
+	storagePolicyName string, storagePolicyID string, datastores []*cnsvsphere.DatastoreInfo) {
+	log := logger.GetLogger(ctx)
+	vc, err := common.GetVCenter(ctx, c.manager)
+	if err != nil {
+		log.Errorf("failed to get vCenter. err: %v", err)
+		return
+	}
+	compatible, err := common.HasCompatibleDatastore(ctx, vc, storagePolicyID, datastores)
+	if err != nil || compatible {
+		return
+	}
+	c.eventRecorder.CreateVolumeEventf(ctx, req.Parameters, v1.EventTypeWarning, "StoragePolicyIncompatible",
+		"None of the datastores shared by the nodes is compatible with storage policy %q", storagePolicyName)
+}
+
 // createBlockVolume creates a block volume based on the CreateVolumeRequest.
 func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolumeRequest) (
 	*csi.CreateVolumeResponse, error) {
@@ -383,6 +434,8 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 		if err != nil || len(sharedDatastores) == 0 {
 			msg := fmt.Sprintf("failed to get shared datastores in topology: %+v. Error: %+v", topologyRequirement, err)
 			log.Error(msg)
+			c.eventRecorder.CreateVolumeEventf(ctx, req.Parameters, v1.EventTypeWarning, "NoSharedDatastoresInTopology",
+				"No datastore is shared by all nodes of the requested topology zones/regions")
 			return nil, status.Error(codes.NotFound, msg)
 		}
 		log.Debugf("Shared datastores [%+v] retrieved for topologyRequirement [%+v] with datastoreTopologyMap [+%v]", sharedDatastores, topologyRequirement, datastoreTopologyMap)
@@ -399,6 +452,9 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 				errMsg := fmt.Sprintf("DatastoreURL: %s specified in the storage class is not accessible in the topology:[+%v]",
 					createVolumeSpec.ScParams.DatastoreURL, topologyRequirement)
 				log.Errorf(errMsg)
+				c.eventRecorder.CreateVolumeEventf(ctx, req.Parameters, v1.EventTypeWarning, "DatastoreNotInTopology",
+					"Datastore %s of the StorageClass is not shared by all nodes of the requested topology zones/regions",
+					createVolumeSpec.ScParams.DatastoreURL)
 				return nil, status.Error(codes.InvalidArgument, errMsg)
 			}
 		}
@@ -408,18 +464,24 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 		if err != nil || len(sharedDatastores) == 0 {
 			msg := fmt.Sprintf("failed to get shared datastores in kubernetes cluster. Error: %+v", err)
 			log.Error(msg)
+			c.eventRecorder.CreateVolumeEventf(ctx, req.Parameters, v1.EventTypeWarning, "NoSharedDatastores",
+				"No datastore is shared by all nodes of the cluster")
 			return nil, status.Errorf(codes.Internal, msg)
 		}
 	}
 
 	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.CSIAuthCheck) {
 		// filter datastores which in datastoreMap from sharedDatastores
-		sharedDatastores = c.filterDatastores(ctx, sharedDatastores)
+		sharedDatastores = c.filterDatastores(ctx, req, sharedDatastores)
 	}
 	volumeInfo, err := common.CreateBlockVolumeUtil(ctx, cnstypes.CnsClusterFlavorVanilla, c.manager, &createVolumeSpec, sharedDatastores)
 	if err != nil {
 		msg := fmt.Sprintf("failed to create volume. Error: %+v", err)
 		log.Error(msg)
+		if createVolumeSpec.StoragePolicyID != "" && createVolumeSpec.ScParams.DatastoreURL == "" {
+			c.recordIfStoragePolicyIncompatible(ctx, req, createVolumeSpec.ScParams.StoragePolicyName,
+				createVolumeSpec.StoragePolicyID, sharedDatastores)
+		}
 		return nil, status.Errorf(codes.Internal, msg)
 	}
 
@@ -535,6 +597,8 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 		if len(filteredDatastores) == 0 {
 			msg := "no datastores found to create file volume"
 			log.Error(msg)
+			c.eventRecorder.CreateVolumeEventf(ctx, req.Parameters, v1.EventTypeWarning, "DatastoresFilteredByAuthCheck",
+				"No vSAN datastore with file service enabled is accessible with the privileges of the vSphere user")
 			return nil, status.Errorf(codes.Internal, msg)
 		}
 		volumeID, err = common.CreateFileVolumeUtil(ctx, cnstypes.CnsClusterFlavorVanilla,
@@ -571,13 +635,15 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 func (c *controller) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (
 	*csi.CreateVolumeResponse, error) {
 	start := time.Now()
+	prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusCreateVolumeOpType).Inc()
+	defer prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusCreateVolumeOpType).Dec()
 	volumeType := prometheus.PrometheusUnknownVolumeType
 	createVolumeInternal := func() (
 		*csi.CreateVolumeResponse, error) {
 
 		ctx = logger.NewContextWithLogger(ctx)
 		log := logger.GetLogger(ctx)
-		log.Infof("CreateVolume: called with args %+v", *req)
+		log.Infof("CreateVolume: called with args %+v", protosanitizer.StripSecrets(req))
 		volumeCapabilities := req.GetVolumeCapabilities()
 		if err := common.IsValidVolumeCapabilities(ctx, volumeCapabilities); err != nil {
 			return nil, status.Errorf(codes.InvalidArgument, "Volume capability not supported. Err: %+v", err)
@@ -600,13 +666,7 @@ func (c *controller) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequ
 		return c.createBlockVolume(ctx, req)
 	}
 	resp, err := createVolumeInternal()
-	if err != nil {
-		prometheus.VolumeControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusCreateVolumeOpType,
-			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
-	} else {
-		prometheus.VolumeControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusCreateVolumeOpType,
-			prometheus.PrometheusPassStatus).Observe(time.Since(start).Seconds())
-	}
+	prometheus.RecordVolumeControlOp(volumeType, prometheus.PrometheusCreateVolumeOpType, start, err)
 	return resp, err
 }
 
@@ -614,13 +674,15 @@ func (c *controller) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequ
 func (c *controller) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (
 	*csi.DeleteVolumeResponse, error) {
 	start := time.Now()
+	prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusDeleteVolumeOpType).Inc()
+	defer prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusDeleteVolumeOpType).Dec()
 	volumeType := prometheus.PrometheusUnknownVolumeType
 
 	deleteVolumeInternal := func() (
 		*csi.DeleteVolumeResponse, error) {
 		ctx = logger.NewContextWithLogger(ctx)
 		log := logger.GetLogger(ctx)
-		log.Infof("DeleteVolume: called with args: %+v", *req)
+		log.Infof("DeleteVolume: called with args: %+v", protosanitizer.StripSecrets(req))
 		var err error
 		err = validateVanillaDeleteVolumeRequest(ctx, req)
 		if err != nil {
@@ -694,13 +756,7 @@ func (c *controller) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequ
 		return &csi.DeleteVolumeResponse{}, nil
 	}
 	resp, err := deleteVolumeInternal()
-	if err != nil {
-		prometheus.VolumeControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusDeleteVolumeOpType,
-			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
-	} else {
-		prometheus.VolumeControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusDeleteVolumeOpType,
-			prometheus.PrometheusPassStatus).Observe(time.Since(start).Seconds())
-	}
+	prometheus.RecordVolumeControlOp(volumeType, prometheus.PrometheusDeleteVolumeOpType, start, err)
 	return resp, err
 }
 
@@ -709,6 +765,8 @@ func (c *controller) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequ
 func (c *controller) ControllerPublishVolume(ctx context.Context, req *csi.ControllerPublishVolumeRequest) (
 	*csi.ControllerPublishVolumeResponse, error) {
 	start := time.Now()
+	prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusAttachVolumeOpType).Inc()
+	defer prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusAttachVolumeOpType).Dec()
 	volumeType := prometheus.PrometheusUnknownVolumeType
 
 	controllerPublishVolumeInternal := func() (
@@ -716,10 +774,10 @@ func (c *controller) ControllerPublishVolume(ctx context.Context, req *csi.Contr
 
 		ctx = logger.NewContextWithLogger(ctx)
 		log := logger.GetLogger(ctx)
-		log.Infof("ControllerPublishVolume: called with args %+v", *req)
+		log.Infof("ControllerPublishVolume: called with args %+v", protosanitizer.StripSecrets(req))
 		err := validateVanillaControllerPublishVolumeRequest(ctx, req)
 		if err != nil {
-			msg := fmt.Sprintf("Validation for PublishVolume Request: %+v has failed. Error: %v", *req, err)
+			msg := fmt.Sprintf("Validation for PublishVolume Request: %+v has failed. Error: %v", protosanitizer.StripSecrets(req), err)
 			log.Error(msg)
 			return nil, status.Errorf(codes.Internal, msg)
 		}
@@ -796,6 +854,8 @@ func (c *controller) ControllerPublishVolume(ctx context.Context, req *csi.Contr
 			if err != nil {
 				msg := fmt.Sprintf("failed to attach disk: %+q with node: %q err %+v", req.VolumeId, req.NodeId, err)
 				log.Error(msg)
+				c.eventRecorder.NodeEventf(ctx, req.NodeId, v1.EventTypeWarning, "VolumeAttachFailed",
+					"Failed to attach volume %s: %v", req.VolumeId, err)
 				return nil, status.Errorf(codes.Internal, msg)
 			}
 			publishInfo[common.AttributeDiskType] = common.DiskTypeBlockVolume
@@ -807,13 +867,7 @@ func (c *controller) ControllerPublishVolume(ctx context.Context, req *csi.Contr
 		}, nil
 	}
 	resp, err := controllerPublishVolumeInternal()
-	if err != nil {
-		prometheus.VolumeControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusAttachVolumeOpType,
-			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
-	} else {
-		prometheus.VolumeControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusAttachVolumeOpType,
-			prometheus.PrometheusPassStatus).Observe(time.Since(start).Seconds())
-	}
+	prometheus.RecordVolumeControlOp(volumeType, prometheus.PrometheusAttachVolumeOpType, start, err)
 	return resp, err
 }
 
@@ -822,16 +876,18 @@ func (c *controller) ControllerPublishVolume(ctx context.Context, req *csi.Contr
 func (c *controller) ControllerUnpublishVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest) (
 	*csi.ControllerUnpublishVolumeResponse, error) {
 	start := time.Now()
+	prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusDetachVolumeOpType).Inc()
+	defer prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusDetachVolumeOpType).Dec()
 	volumeType := prometheus.PrometheusUnknownVolumeType
 
 	controllerUnpublishVolumeInternal := func() (
 		*csi.ControllerUnpublishVolumeResponse, error) {
 		ctx = logger.NewContextWithLogger(ctx)
 		log := logger.GetLogger(ctx)
-		log.Infof("ControllerUnpublishVolume: called with args %+v", *req)
+		log.Infof("ControllerUnpublishVolume: called with args %+v", protosanitizer.StripSecrets(req))
 		err := validateVanillaControllerUnpublishVolumeRequest(ctx, req)
 		if err != nil {
-			msg := fmt.Sprintf("Validation for UnpublishVolume Request: %+v has failed. Error: %v", *req, err)
+			msg := fmt.Sprintf("Validation for UnpublishVolume Request: %+v has failed. Error: %v", protosanitizer.StripSecrets(req), err)
 			log.Error(msg)
 			return nil, status.Errorf(codes.Internal, msg)
 		}
@@ -897,19 +953,15 @@ func (c *controller) ControllerUnpublishVolume(ctx context.Context, req *csi.Con
 		if err != nil {
 			msg := fmt.Sprintf("failed to detach disk: %+q from node: %q err %+v", req.VolumeId, req.NodeId, err)
 			log.Error(msg)
+			c.eventRecorder.NodeEventf(ctx, req.NodeId, v1.EventTypeWarning, "VolumeDetachFailed",
+				"Failed to detach volume %s: %v", req.VolumeId, err)
 			return nil, status.Error(codes.Internal, msg)
 		}
 		log.Infof("ControllerUnpublishVolume successful for volume ID: %s", req.VolumeId)
 		return &csi.ControllerUnpublishVolumeResponse{}, nil
 	}
 	resp, err := controllerUnpublishVolumeInternal()
-	if err != nil {
-		prometheus.VolumeControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusDetachVolumeOpType,
-			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
-	} else {
-		prometheus.VolumeControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusDetachVolumeOpType,
-			prometheus.PrometheusPassStatus).Observe(time.Since(start).Seconds())
-	}
+	prometheus.RecordVolumeControlOp(volumeType, prometheus.PrometheusDetachVolumeOpType, start, err)
 	return resp, err
 }
 
@@ -917,49 +969,61 @@ func (c *controller) ControllerUnpublishVolume(ctx context.Context, req *csi.Con
 // volume id and size is retrieved from ControllerExpandVolumeRequest
 func (c *controller) ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest) (
 	*csi.ControllerExpandVolumeResponse, error) {
-	ctx = logger.NewContextWithLogger(ctx)
-	log := logger.GetLogger(ctx)
-	log.Infof("ControllerExpandVolume: called with args %+v", *req)
+	start := time.Now()
+	prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusExpandVolumeOpType).Inc()
+	defer prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusExpandVolumeOpType).Dec()
+	volumeType := prometheus.PrometheusUnknownVolumeType
+	controllerExpandVolumeInternal := func() (
+		*csi.ControllerExpandVolumeResponse, error) {
+		ctx = logger.NewContextWithLogger(ctx)
+		log := logger.GetLogger(ctx)
+		log.Infof("ControllerExpandVolume: called with args %+v", protosanitizer.StripSecrets(req))
+		// Only block volumes can be expanded
+		volumeType = prometheus.PrometheusBlockVolumeType
 
-	if strings.Contains(req.VolumeId, ".vmdk") {
-		msg := fmt.Sprintf("Cannot expand migrated vSphere volume. :%q", req.VolumeId)
-		log.Error(msg)
-		return nil, status.Errorf(codes.Unimplemented, msg)
-	}
-	isOnlineExpansionEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.OnlineVolumeExtend)
-	err := validateVanillaControllerExpandVolumeRequest(ctx, req, isOnlineExpansionEnabled)
-	if err != nil {
-		msg := fmt.Sprintf("validation for ExpandVolume Request: %+v has failed. Error: %v", *req, err)
-		log.Error(msg)
-		return nil, err
-	}
+		if strings.Contains(req.VolumeId, ".vmdk") {
+			msg := fmt.Sprintf("Cannot expand migrated vSphere volume. :%q", req.VolumeId)
+			log.Error(msg)
+			return nil, status.Errorf(codes.Unimplemented, msg)
+		}
+		isOnlineExpansionEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.OnlineVolumeExtend)
+		err := validateVanillaControllerExpandVolumeRequest(ctx, req, isOnlineExpansionEnabled)
+		if err != nil {
+			msg := fmt.Sprintf("validation for ExpandVolume Request: %+v has failed. Error: %v", protosanitizer.StripSecrets(req), err)
+			log.Error(msg)
+			return nil, err
+		}
 
-	volumeID := req.GetVolumeId()
-	volSizeBytes := int64(req.GetCapacityRange().GetRequiredBytes())
-	volSizeMB := int64(common.RoundUpSize(volSizeBytes, common.MbInBytes))
+		volumeID := req.GetVolumeId()
+		volSizeBytes := int64(req.GetCapacityRange().GetRequiredBytes())
+		volSizeMB := int64(common.RoundUpSize(volSizeBytes, common.MbInBytes))
 
-	err = common.ExpandVolumeUtil(ctx, c.manager, volumeID, volSizeMB)
-	if err != nil {
-		msg := fmt.Sprintf("failed to expand volume: %q to size: %d with error: %+v", volumeID, volSizeMB, err)
-		log.Error(msg)
-		return nil, status.Errorf(codes.Internal, msg)
-	}
+		err = common.ExpandVolumeUtil(ctx, c.manager, volumeID, volSizeMB)
+		if err != nil {
+			msg := fmt.Sprintf("failed to expand volume: %q to size: %d with error: %+v", volumeID, volSizeMB, err)
+			log.Error(msg)
+			return nil, status.Errorf(codes.Internal, msg)
+		}
 
-	// Always set nodeExpansionRequired to true, even if requested size is equal to current size.
-	// Volume expansion may succeed on CNS but external-resizer may fail to update API server.
-	// Requests are requeued in this case. Setting nodeExpandsionRequired to false marks PVC
-	// resize as finished which prevents kubelet from expanding the filesystem.
-	// Ref: https://github.com/kubernetes-csi/external-resizer/blob/master/pkg/controller/controller.go#L335
-	nodeExpansionRequired := true
-	// Node expansion is not required for raw block volumes
-	if _, ok := req.GetVolumeCapability().GetAccessType().(*csi.VolumeCapability_Block); ok {
-		nodeExpansionRequired = false
-	}
-	resp := &csi.ControllerExpandVolumeResponse{
-		CapacityBytes:         int64(units.FileSize(volSizeMB * common.MbInBytes)),
-		NodeExpansionRequired: nodeExpansionRequired,
+		// Always set nodeExpansionRequired to true, even if requested size is equal to current size.
+		// Volume expansion may succeed on CNS but external-resizer may fail to update API server.
+		// Requests are requeued in this case. Setting nodeExpandsionRequired to false marks PVC
+		// resize as finished which prevents kubelet from expanding the filesystem.
+		// Ref: https://github.com/kubernetes-csi/external-resizer/blob/master/pkg/controller/controller.go#L335
+		nodeExpansionRequired := true
+		// Node expansion is not required for raw block volumes
+		if _, ok := req.GetVolumeCapability().GetAccessType().(*csi.VolumeCapability_Block); ok {
+			nodeExpansionRequired = false
+		}
+		resp := &csi.ControllerExpandVolumeResponse{
+			CapacityBytes:         int64(units.FileSize(volSizeMB * common.MbInBytes)),
+			NodeExpansionRequired: nodeExpansionRequired,
+		}
+		return resp, nil
 	}
-	return resp, nil
+	resp, err := controllerExpandVolumeInternal()
+	prometheus.RecordVolumeControlOp(volumeType, prometheus.PrometheusExpandVolumeOpType, start, err)
+	return resp, err
 }
 
 // ValidateVolumeCapabilities returns the capabilities of the volume.
@@ -967,7 +1031,7 @@ func (c *controller) ValidateVolumeCapabilities(ctx context.Context, req *csi.Va
 	*csi.ValidateVolumeCapabilitiesResponse, error) {
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("ControllerGetCapabilities: called with args %+v", *req)
+	log.Infof("ControllerGetCapabilities: called with args %+v", protosanitizer.StripSecrets(req))
 	volCaps := req.GetVolumeCapabilities()
 	var confirmed *csi.ValidateVolumeCapabilitiesResponse_Confirmed
 	if err := common.IsValidVolumeCapabilities(ctx, volCaps); err == nil {
@@ -982,7 +1046,7 @@ func (c *controller) ListVolumes(ctx context.Context, req *csi.ListVolumesReques
 	*csi.ListVolumesResponse, error) {
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("ListVolumes: called with args %+v", *req)
+	log.Infof("ListVolumes: called with args %+v", protosanitizer.StripSecrets(req))
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
@@ -990,7 +1054,7 @@ func (c *controller) GetCapacity(ctx context.Context, req *csi.GetCapacityReques
 	*csi.GetCapacityResponse, error) {
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("GetCapacity: called with args %+v", *req)
+	log.Infof("GetCapacity: called with args %+v", protosanitizer.StripSecrets(req))
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
@@ -1032,7 +1096,7 @@ func (c *controller) ControllerGetCapabilities(ctx context.Context, req *csi.Con
 	*csi.ControllerGetCapabilitiesResponse, error) {
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("ControllerGetCapabilities: called with args %+v", *req)
+	log.Infof("ControllerGetCapabilities: called with args %+v", protosanitizer.StripSecrets(req))
 
 	var controllerCaps []csi.ControllerServiceCapability_RPC_Type
 
@@ -1065,7 +1129,7 @@ func (c *controller) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshot
 	*csi.CreateSnapshotResponse, error) {
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("CreateSnapshot: called with args %+v", *req)
+	log.Infof("CreateSnapshot: called with args %+v", protosanitizer.StripSecrets(req))
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
@@ -1073,7 +1137,7 @@ func (c *controller) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshot
 	*csi.DeleteSnapshotResponse, error) {
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("DeleteSnapshot: called with args %+v", *req)
+	log.Infof("DeleteSnapshot: called with args %+v", protosanitizer.StripSecrets(req))
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
@@ -1081,6 +1145,6 @@ func (c *controller) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRe
 	*csi.ListSnapshotsResponse, error) {
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("ListSnapshots: called with args %+v", *req)
+	log.Infof("ListSnapshots: called with args %+v", protosanitizer.StripSecrets(req))
 	return nil, status.Error(codes.Unimplemented, "")
 }
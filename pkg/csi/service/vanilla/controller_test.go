@@ -321,6 +321,17 @@ func getControllerTest(t *testing.T) *controllerTest {
 	return controllerTestInstance
 }
 
+func TestCheckReadiness(t *testing.T) {
+	ct := getControllerTest(t)
+	if err := ct.controller.CheckReadiness(ctx); err != nil {
+		t.Fatalf("expected controller connected to vCenter to be ready, err: %v", err)
+	}
+	notInitialized := &controller{manager: &common.Manager{}}
+	if err := notInitialized.CheckReadiness(ctx); err == nil {
+		t.Errorf("expected controller without vCenter config not to be ready")
+	}
+}
+
 func TestCreateVolumeWithStoragePolicy(t *testing.T) {
 	// Create context
 	ct := getControllerTest(t)
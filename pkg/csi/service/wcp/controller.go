@@ -28,6 +28,7 @@ import (
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/fsnotify/fsnotify"
+	"github.com/kubernetes-csi/csi-lib-utils/protosanitizer"
 	cnstypes "github.com/vmware/govmomi/cns/types"
 	"github.com/vmware/govmomi/units"
 	"golang.org/x/net/context"
@@ -42,6 +43,7 @@ import (
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/commonco"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
 	csitypes "sigs.k8s.io/vsphere-csi-driver/pkg/csi/types"
+	k8s "sigs.k8s.io/vsphere-csi-driver/pkg/kubernetes"
 )
 
 const (
@@ -63,6 +65,9 @@ var getCandidateDatastores = cnsvsphere.GetCandidateDatastoresInCluster
 type controller struct {
 	manager *common.Manager
 	authMgr common.AuthorizationService
+	// eventRecorder records events on the PVCs of the requests, it is nil if
+	// the recorder could not be created
+	eventRecorder *k8s.EventRecorder
 }
 
 // New creates a CNS controller
@@ -91,10 +96,12 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 		log.Errorf("failed to register VC with virtualCenterManager. err=%v", err)
 		return err
 	}
+	volumeManager := cnsvolume.GetManagerWithQueryCache(ctx, vcenter,
+		time.Duration(config.Global.QueryVolumeCacheTTLInSec)*time.Second)
 	c.manager = &common.Manager{
 		VcenterConfig:  vcenterconfig,
 		CnsConfig:      config,
-		VolumeManager:  cnsvolume.GetManager(ctx, vcenter),
+		VolumeManager:  volumeManager,
 		VcenterManager: cnsvsphere.GetVirtualCenterManager(ctx),
 	}
 
@@ -108,6 +115,12 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 		log.Errorf("checkAPI failed for vcenter API version: %s, err=%v", vc.Client.ServiceContent.About.ApiVersion, err)
 		return err
 	}
+	// Events are best effort, the controller runs without them if the recorder cannot be created.
+	c.eventRecorder, err = k8s.NewEventRecorder(ctx, csitypes.Name)
+	if err != nil {
+		log.Warnf("failed to create event recorder, events will not be recorded. err=%v", err)
+	}
+
 	go cnsvolume.ClearTaskInfoObjects()
 	cfgPath := common.GetConfigPath(ctx)
 	watcher, err := fsnotify.NewWatcher()
@@ -171,6 +184,12 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 	return nil
 }
 
+// CheckReadiness verifies that the controller holds a valid vCenter session and
+// an initialized CNS client.
+func (c *controller) CheckReadiness(ctx context.Context) error {
+	return common.CheckVCenterReadiness(ctx, c.manager)
+}
+
 // ReloadConfiguration reloads configuration from the secret, and update controller's config cache
 // and VolumeManager's VC Config cache.
 func (c *controller) ReloadConfiguration() {
@@ -213,7 +232,8 @@ func (c *controller) ReloadConfiguration() {
 			vcenter.Config = newVCConfig
 		}
 		c.manager.VolumeManager.ResetManager(ctx, vcenter)
-		c.manager.VolumeManager = cnsvolume.GetManager(ctx, vcenter)
+		c.manager.VolumeManager = cnsvolume.GetManagerWithQueryCache(ctx, vcenter,
+			time.Duration(cfg.Global.QueryVolumeCacheTTLInSec)*time.Second)
 		c.manager.VcenterConfig = newVCConfig
 	}
 	if cfg != nil {
@@ -223,6 +243,23 @@ func (c *controller) ReloadConfiguration() {
 	log.Info("Successfully reloaded configuration")
 }
 
+// recordIfStoragePolicyIncompatible records an event on the PVC of the request if none of the
+// candidate datastores is compatible with the storage policy of the StorageClass
+func (c *controller) recordIfStoragePolicyIncompatible(ctx context.Context, req *csi.CreateVolumeRequest,
+	vc *cnsvsphere.VirtualCenter, storagePolicyID string, datastores []*cnsvsphere.DatastoreInfo) {
+	compatible, err := common.HasCompatibleDatastore(ctx, vc, storagePolicyID, datastores)
+	if err != nil || compatible {
+		return
+	}
+	// the StorageClass only has the policy ID, the name is what the user knows the policy by
+	storagePolicyName, err := vc.GetStoragePolicyNameByID(ctx, storagePolicyID)
+	if err != nil {
+		storagePolicyName = storagePolicyID
+	}
+	c.eventRecorder.CreateVolumeEventf(ctx, req.Parameters, v1.EventTypeWarning, "StoragePolicyIncompatible",
+		"None of the datastores of the cluster is compatible with storage policy %q", storagePolicyName)
+}
+
 // createBlockVolume creates a block volume based on the CreateVolumeRequest.
 func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolumeRequest) (
 	*csi.CreateVolumeResponse, error) {
@@ -320,6 +357,9 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 	if err != nil {
 		msg := fmt.Sprintf("failed to create volume. Error: %+v", err)
 		log.Error(msg)
+		if storagePolicyID != "" && selectedDatastoreURL == "" {
+			c.recordIfStoragePolicyIncompatible(ctx, req, vc, storagePolicyID, candidateDatastores)
+		}
 		return nil, status.Errorf(codes.Internal, msg)
 	}
 
@@ -393,6 +433,8 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 	if len(filteredDatastores) == 0 {
 		msg := "no datastores found to create file volume"
 		log.Error(msg)
+		c.eventRecorder.CreateVolumeEventf(ctx, req.Parameters, v1.EventTypeWarning, "DatastoresFilteredByAuthCheck",
+			"No vSAN datastore with file service enabled is accessible with the privileges of the vSphere user")
 		return nil, status.Errorf(codes.Internal, msg)
 	}
 	volumeID, err = common.CreateFileVolumeUtil(ctx, cnstypes.CnsClusterFlavorWorkload,
@@ -422,12 +464,14 @@ func (c *controller) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequ
 	*csi.CreateVolumeResponse, error) {
 
 	start := time.Now()
+	prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusCreateVolumeOpType).Inc()
+	defer prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusCreateVolumeOpType).Dec()
 	volumeType := prometheus.PrometheusUnknownVolumeType
 	createVolumeInternal := func() (
 		*csi.CreateVolumeResponse, error) {
 		ctx = logger.NewContextWithLogger(ctx)
 		log := logger.GetLogger(ctx)
-		log.Infof("CreateVolume: called with args %+v", *req)
+		log.Infof("CreateVolume: called with args %+v", protosanitizer.StripSecrets(req))
 
 		isBlockRequest := !common.IsFileVolumeRequest(ctx, req.GetVolumeCapabilities())
 		if isBlockRequest {
@@ -438,7 +482,7 @@ func (c *controller) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequ
 		// Validate create request
 		err := validateWCPCreateVolumeRequest(ctx, req, isBlockRequest)
 		if err != nil {
-			msg := fmt.Sprintf("Validation for CreateVolume Request: %+v has failed. Error: %+v", *req, err)
+			msg := fmt.Sprintf("Validation for CreateVolume Request: %+v has failed. Error: %+v", protosanitizer.StripSecrets(req), err)
 			log.Error(msg)
 			return nil, err
 		}
@@ -454,13 +498,7 @@ func (c *controller) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequ
 		return c.createBlockVolume(ctx, req)
 	}
 	resp, err := createVolumeInternal()
-	if err != nil {
-		prometheus.VolumeControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusCreateVolumeOpType,
-			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
-	} else {
-		prometheus.VolumeControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusCreateVolumeOpType,
-			prometheus.PrometheusPassStatus).Observe(time.Since(start).Seconds())
-	}
+	prometheus.RecordVolumeControlOp(volumeType, prometheus.PrometheusCreateVolumeOpType, start, err)
 	return resp, err
 }
 
@@ -469,17 +507,19 @@ func (c *controller) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequ
 	*csi.DeleteVolumeResponse, error) {
 
 	start := time.Now()
+	prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusDeleteVolumeOpType).Inc()
+	defer prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusDeleteVolumeOpType).Dec()
 	volumeType := prometheus.PrometheusUnknownVolumeType
 
 	deleteVolumeInternal := func() (
 		*csi.DeleteVolumeResponse, error) {
 		ctx = logger.NewContextWithLogger(ctx)
 		log := logger.GetLogger(ctx)
-		log.Infof("DeleteVolume: called with args: %+v", *req)
+		log.Infof("DeleteVolume: called with args: %+v", protosanitizer.StripSecrets(req))
 		var err error
 		err = validateWCPDeleteVolumeRequest(ctx, req)
 		if err != nil {
-			msg := fmt.Sprintf("Validation for DeleteVolume Request: %+v has failed. Error: %+v", *req, err)
+			msg := fmt.Sprintf("Validation for DeleteVolume Request: %+v has failed. Error: %+v", protosanitizer.StripSecrets(req), err)
 			log.Error(msg)
 			return nil, err
 		}
@@ -517,13 +557,7 @@ func (c *controller) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequ
 		return &csi.DeleteVolumeResponse{}, nil
 	}
 	resp, err := deleteVolumeInternal()
-	if err != nil {
-		prometheus.VolumeControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusDeleteVolumeOpType,
-			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
-	} else {
-		prometheus.VolumeControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusDeleteVolumeOpType,
-			prometheus.PrometheusPassStatus).Observe(time.Since(start).Seconds())
-	}
+	prometheus.RecordVolumeControlOp(volumeType, prometheus.PrometheusDeleteVolumeOpType, start, err)
 	return resp, err
 }
 
@@ -531,135 +565,159 @@ func (c *controller) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequ
 // volume id and node name is retrieved from ControllerPublishVolumeRequest
 func (c *controller) ControllerPublishVolume(ctx context.Context, req *csi.ControllerPublishVolumeRequest) (
 	*csi.ControllerPublishVolumeResponse, error) {
-	ctx = logger.NewContextWithLogger(ctx)
-	log := logger.GetLogger(ctx)
-	log.Infof("ControllerPublishVolume: called with args %+v", *req)
-	err := validateWCPControllerPublishVolumeRequest(ctx, req)
-	if err != nil {
-		msg := fmt.Sprintf("Validation for PublishVolume Request: %+v has failed. Error: %v", *req, err)
-		log.Errorf(msg)
-		return nil, err
-	}
-
-	vmuuid, err := getVMUUIDFromK8sCloudOperatorService(ctx, req.VolumeId, req.NodeId)
-	if err != nil {
-		msg := fmt.Sprintf("Failed to get the pod vmuuid annotation from the k8sCloudOperator service when processing attach for volumeID: %s on node: %s. Error: %+v", req.VolumeId, req.NodeId, err)
-		log.Error(msg)
-		return nil, status.Errorf(codes.Internal, msg)
-	}
+	start := time.Now()
+	prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusAttachVolumeOpType).Inc()
+	defer prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusAttachVolumeOpType).Dec()
+	volumeType := prometheus.PrometheusUnknownVolumeType
+	controllerPublishVolumeInternal := func() (
+		*csi.ControllerPublishVolumeResponse, error) {
+		ctx = logger.NewContextWithLogger(ctx)
+		log := logger.GetLogger(ctx)
+		log.Infof("ControllerPublishVolume: called with args %+v", protosanitizer.StripSecrets(req))
+		volumeType = prometheus.PrometheusBlockVolumeType
+		if common.IsFileVolumeRequest(ctx, []*csi.VolumeCapability{req.GetVolumeCapability()}) {
+			volumeType = prometheus.PrometheusFileVolumeType
+		}
+		err := validateWCPControllerPublishVolumeRequest(ctx, req)
+		if err != nil {
+			msg := fmt.Sprintf("Validation for PublishVolume Request: %+v has failed. Error: %v", protosanitizer.StripSecrets(req), err)
+			log.Errorf(msg)
+			return nil, err
+		}
 
-	vcdcMap, err := getDatacenterFromConfig(c.manager.CnsConfig)
-	if err != nil {
-		msg := fmt.Sprintf("failed to get datacenter from config with error: %+v", err)
-		log.Error(msg)
-		return nil, status.Errorf(codes.Internal, msg)
-	}
-	var vCenterHost, dcMorefValue string
-	for key, value := range vcdcMap {
-		vCenterHost = key
-		dcMorefValue = value
-	}
-	vc, err := c.manager.VcenterManager.GetVirtualCenter(ctx, vCenterHost)
-	if err != nil {
-		msg := fmt.Sprintf("Cannot get virtual center %s from virtualcentermanager while attaching disk with error %+v",
-			vc.Config.Host, err)
-		log.Error(msg)
-		return nil, status.Errorf(codes.Internal, msg)
-	}
+		vmuuid, err := getVMUUIDFromK8sCloudOperatorService(ctx, req.VolumeId, req.NodeId)
+		if err != nil {
+			msg := fmt.Sprintf("Failed to get the pod vmuuid annotation from the k8sCloudOperator service when processing attach for volumeID: %s on node: %s. Error: %+v", req.VolumeId, req.NodeId, err)
+			log.Error(msg)
+			return nil, status.Errorf(codes.Internal, msg)
+		}
 
-	// Connect to VC
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	err = vc.Connect(ctx)
-	if err != nil {
-		msg := fmt.Sprintf("failed to connect to Virtual Center: %s", vc.Config.Host)
-		log.Error(msg)
-		return nil, status.Errorf(codes.Internal, msg)
-	}
+		vcdcMap, err := getDatacenterFromConfig(c.manager.CnsConfig)
+		if err != nil {
+			msg := fmt.Sprintf("failed to get datacenter from config with error: %+v", err)
+			log.Error(msg)
+			return nil, status.Errorf(codes.Internal, msg)
+		}
+		var vCenterHost, dcMorefValue string
+		for key, value := range vcdcMap {
+			vCenterHost = key
+			dcMorefValue = value
+		}
+		vc, err := c.manager.VcenterManager.GetVirtualCenter(ctx, vCenterHost)
+		if err != nil {
+			msg := fmt.Sprintf("Cannot get virtual center %s from virtualcentermanager while attaching disk with error %+v",
+				vc.Config.Host, err)
+			log.Error(msg)
+			return nil, status.Errorf(codes.Internal, msg)
+		}
 
-	podVM, err := getVMByInstanceUUIDInDatacenter(ctx, vc, dcMorefValue, vmuuid)
-	if err != nil {
-		msg := fmt.Sprintf("failed to the PodVM Moref from the PodVM UUID: %s in datacenter: %s with err: %+v", vmuuid, dcMorefValue, err)
-		log.Error(msg)
-		return nil, status.Errorf(codes.Internal, msg)
-	}
+		// Connect to VC
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		err = vc.Connect(ctx)
+		if err != nil {
+			msg := fmt.Sprintf("failed to connect to Virtual Center: %s", vc.Config.Host)
+			log.Error(msg)
+			return nil, status.Errorf(codes.Internal, msg)
+		}
 
-	// Attach the volume to the node
-	diskUUID, err := common.AttachVolumeUtil(ctx, c.manager, podVM, req.VolumeId)
-	if err != nil {
-		if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.FakeAttach) {
-			log.Infof("Volume attachment failed. Checking if it can be fake attached")
-			var capabilities []*csi.VolumeCapability
-			capabilities = append(capabilities, req.VolumeCapability)
-			if !common.IsFileVolumeRequest(ctx, capabilities) { //Block volume
-				allowed, err := commonco.ContainerOrchestratorUtility.IsFakeAttachAllowed(ctx, req.VolumeId, c.manager.VolumeManager)
-				if err != nil {
-					msg := fmt.Sprintf("failed to determine if volume: %s can be fake attached. Error: %+v", req.VolumeId, err)
-					log.Error(msg)
-					return nil, status.Errorf(codes.Internal, msg)
-				}
+		podVM, err := getVMByInstanceUUIDInDatacenter(ctx, vc, dcMorefValue, vmuuid)
+		if err != nil {
+			msg := fmt.Sprintf("failed to the PodVM Moref from the PodVM UUID: %s in datacenter: %s with err: %+v", vmuuid, dcMorefValue, err)
+			log.Error(msg)
+			return nil, status.Errorf(codes.Internal, msg)
+		}
 
-				if allowed {
-					// Mark the volume as fake attached before returning response
-					err := commonco.ContainerOrchestratorUtility.MarkFakeAttached(ctx, req.VolumeId)
+		// Attach the volume to the node
+		diskUUID, err := common.AttachVolumeUtil(ctx, c.manager, podVM, req.VolumeId)
+		if err != nil {
+			if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.FakeAttach) {
+				log.Infof("Volume attachment failed. Checking if it can be fake attached")
+				var capabilities []*csi.VolumeCapability
+				capabilities = append(capabilities, req.VolumeCapability)
+				if !common.IsFileVolumeRequest(ctx, capabilities) { //Block volume
+					allowed, err := commonco.ContainerOrchestratorUtility.IsFakeAttachAllowed(ctx, req.VolumeId, c.manager.VolumeManager)
 					if err != nil {
-						msg := fmt.Sprintf("failed to mark volume: %s as fake attached. Error: %+v", req.VolumeId, err)
+						msg := fmt.Sprintf("failed to determine if volume: %s can be fake attached. Error: %+v", req.VolumeId, err)
 						log.Error(msg)
 						return nil, status.Errorf(codes.Internal, msg)
 					}
 
-					publishInfo := make(map[string]string)
-					publishInfo[common.AttributeDiskType] = common.DiskTypeBlockVolume
-					publishInfo[common.AttributeFakeAttached] = "true"
-
-					resp := &csi.ControllerPublishVolumeResponse{
-						PublishContext: publishInfo,
+					if allowed {
+						// Mark the volume as fake attached before returning response
+						err := commonco.ContainerOrchestratorUtility.MarkFakeAttached(ctx, req.VolumeId)
+						if err != nil {
+							msg := fmt.Sprintf("failed to mark volume: %s as fake attached. Error: %+v", req.VolumeId, err)
+							log.Error(msg)
+							return nil, status.Errorf(codes.Internal, msg)
+						}
+
+						publishInfo := make(map[string]string)
+						publishInfo[common.AttributeDiskType] = common.DiskTypeBlockVolume
+						publishInfo[common.AttributeFakeAttached] = "true"
+
+						resp := &csi.ControllerPublishVolumeResponse{
+							PublishContext: publishInfo,
+						}
+						log.Infof("Volume %s has been fake attached", req.VolumeId)
+						return resp, nil
 					}
-					log.Infof("Volume %s has been fake attached", req.VolumeId)
-					return resp, nil
 				}
+
+				log.Infof("Volume %s is not eligible to be fake attached", req.VolumeId)
 			}
+			msg := fmt.Sprintf("failed to attach volume with volumeID: %s. Error: %+v", req.VolumeId, err)
+			log.Error(msg)
+			return nil, status.Errorf(codes.Internal, msg)
+		}
 
-			log.Infof("Volume %s is not eligible to be fake attached", req.VolumeId)
+		publishInfo := make(map[string]string)
+		publishInfo[common.AttributeDiskType] = common.DiskTypeBlockVolume
+		publishInfo[common.AttributeFirstClassDiskUUID] = common.FormatDiskUUID(diskUUID)
+		resp := &csi.ControllerPublishVolumeResponse{
+			PublishContext: publishInfo,
 		}
-		msg := fmt.Sprintf("failed to attach volume with volumeID: %s. Error: %+v", req.VolumeId, err)
-		log.Error(msg)
-		return nil, status.Errorf(codes.Internal, msg)
-	}
 
-	publishInfo := make(map[string]string)
-	publishInfo[common.AttributeDiskType] = common.DiskTypeBlockVolume
-	publishInfo[common.AttributeFirstClassDiskUUID] = common.FormatDiskUUID(diskUUID)
-	resp := &csi.ControllerPublishVolumeResponse{
-		PublishContext: publishInfo,
+		return resp, nil
 	}
-
-	return resp, nil
+	resp, err := controllerPublishVolumeInternal()
+	prometheus.RecordVolumeControlOp(volumeType, prometheus.PrometheusAttachVolumeOpType, start, err)
+	return resp, err
 }
 
 // ControllerUnpublishVolume detaches a volume from the Node VM.
 // volume id and node name is retrieved from ControllerUnpublishVolumeRequest
 func (c *controller) ControllerUnpublishVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest) (
 	*csi.ControllerUnpublishVolumeResponse, error) {
-	ctx = logger.NewContextWithLogger(ctx)
-	log := logger.GetLogger(ctx)
-	log.Infof("ControllerUnpublishVolume: called with args %+v", *req)
-	err := validateWCPControllerUnpublishVolumeRequest(ctx, req)
-	if err != nil {
-		msg := fmt.Sprintf("Validation for UnpublishVolume Request: %+v has failed. Error: %v", *req, err)
-		log.Error(msg)
-		return nil, err
-	}
-
-	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.FakeAttach) {
-		// Check if the volume was fake attached and unmark it as not fake attached
-		if err := commonco.ContainerOrchestratorUtility.ClearFakeAttached(ctx, req.VolumeId); err != nil {
-			msg := fmt.Sprintf("Failed to unmark volume as not fake attached. Error: %v", err)
+	start := time.Now()
+	prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusDetachVolumeOpType).Inc()
+	defer prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusDetachVolumeOpType).Dec()
+	volumeType := prometheus.PrometheusUnknownVolumeType
+	controllerUnpublishVolumeInternal := func() (
+		*csi.ControllerUnpublishVolumeResponse, error) {
+		ctx = logger.NewContextWithLogger(ctx)
+		log := logger.GetLogger(ctx)
+		log.Infof("ControllerUnpublishVolume: called with args %+v", protosanitizer.StripSecrets(req))
+		err := validateWCPControllerUnpublishVolumeRequest(ctx, req)
+		if err != nil {
+			msg := fmt.Sprintf("Validation for UnpublishVolume Request: %+v has failed. Error: %v", protosanitizer.StripSecrets(req), err)
 			log.Error(msg)
 			return nil, err
 		}
+
+		if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.FakeAttach) {
+			// Check if the volume was fake attached and unmark it as not fake attached
+			if err := commonco.ContainerOrchestratorUtility.ClearFakeAttached(ctx, req.VolumeId); err != nil {
+				msg := fmt.Sprintf("Failed to unmark volume as not fake attached. Error: %v", err)
+				log.Error(msg)
+				return nil, err
+			}
+		}
+		return &csi.ControllerUnpublishVolumeResponse{}, nil
 	}
-	return &csi.ControllerUnpublishVolumeResponse{}, nil
+	resp, err := controllerUnpublishVolumeInternal()
+	prometheus.RecordVolumeControlOp(volumeType, prometheus.PrometheusDetachVolumeOpType, start, err)
+	return resp, err
 }
 
 // ValidateVolumeCapabilities returns the capabilities of the volume.
@@ -667,7 +725,7 @@ func (c *controller) ValidateVolumeCapabilities(ctx context.Context, req *csi.Va
 	*csi.ValidateVolumeCapabilitiesResponse, error) {
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("ControllerGetCapabilities: called with args %+v", *req)
+	log.Infof("ControllerGetCapabilities: called with args %+v", protosanitizer.StripSecrets(req))
 	volCaps := req.GetVolumeCapabilities()
 	var confirmed *csi.ValidateVolumeCapabilitiesResponse_Confirmed
 	if err := common.IsValidVolumeCapabilities(ctx, volCaps); err == nil {
@@ -682,7 +740,7 @@ func (c *controller) ListVolumes(ctx context.Context, req *csi.ListVolumesReques
 	*csi.ListVolumesResponse, error) {
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("ListVolumes: called with args %+v", *req)
+	log.Infof("ListVolumes: called with args %+v", protosanitizer.StripSecrets(req))
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
@@ -690,7 +748,7 @@ func (c *controller) GetCapacity(ctx context.Context, req *csi.GetCapacityReques
 	*csi.GetCapacityResponse, error) {
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("GetCapacity: called with args %+v", *req)
+	log.Infof("GetCapacity: called with args %+v", protosanitizer.StripSecrets(req))
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
@@ -699,7 +757,7 @@ func (c *controller) ControllerGetCapabilities(ctx context.Context, req *csi.Con
 
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("ControllerGetCapabilities: called with args %+v", *req)
+	log.Infof("ControllerGetCapabilities: called with args %+v", protosanitizer.StripSecrets(req))
 	var caps []*csi.ControllerServiceCapability
 	for _, cap := range controllerCaps {
 		c := &csi.ControllerServiceCapability{
@@ -719,7 +777,7 @@ func (c *controller) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshot
 
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("CreateSnapshot: called with args %+v", *req)
+	log.Infof("CreateSnapshot: called with args %+v", protosanitizer.StripSecrets(req))
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
@@ -728,7 +786,7 @@ func (c *controller) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshot
 
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("DeleteSnapshot: called with args %+v", *req)
+	log.Infof("DeleteSnapshot: called with args %+v", protosanitizer.StripSecrets(req))
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
@@ -737,52 +795,64 @@ func (c *controller) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRe
 
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("ListSnapshots: called with args %+v", *req)
+	log.Infof("ListSnapshots: called with args %+v", protosanitizer.StripSecrets(req))
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
 // ControllerExpandVolume expands a volume.
 func (c *controller) ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest) (
 	*csi.ControllerExpandVolumeResponse, error) {
-	ctx = logger.NewContextWithLogger(ctx)
-	log := logger.GetLogger(ctx)
-	if !commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.VolumeExtend) {
-		msg := "ExpandVolume feature is disabled on the cluster"
-		log.Warn(msg)
-		return nil, status.Errorf(codes.Unimplemented, msg)
-	}
-	log.Infof("ControllerExpandVolume: called with args %+v", *req)
+	start := time.Now()
+	prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusExpandVolumeOpType).Inc()
+	defer prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusExpandVolumeOpType).Dec()
+	volumeType := prometheus.PrometheusUnknownVolumeType
+	controllerExpandVolumeInternal := func() (
+		*csi.ControllerExpandVolumeResponse, error) {
+		ctx = logger.NewContextWithLogger(ctx)
+		log := logger.GetLogger(ctx)
+		if !commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.VolumeExtend) {
+			msg := "ExpandVolume feature is disabled on the cluster"
+			log.Warn(msg)
+			return nil, status.Errorf(codes.Unimplemented, msg)
+		}
+		log.Infof("ControllerExpandVolume: called with args %+v", protosanitizer.StripSecrets(req))
+		// Only block volumes can be expanded
+		volumeType = prometheus.PrometheusBlockVolumeType
 
-	isOnlineExpansionEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.OnlineVolumeExtend)
-	err := validateWCPControllerExpandVolumeRequest(ctx, req, c.manager, isOnlineExpansionEnabled)
-	if err != nil {
-		log.Errorf("validation for ExpandVolume Request: %+v has failed. Error: %v", *req, err)
-		return nil, err
-	}
-	volumeID := req.GetVolumeId()
-	volSizeBytes := int64(req.GetCapacityRange().GetRequiredBytes())
-	volSizeMB := int64(common.RoundUpSize(volSizeBytes, common.MbInBytes))
+		isOnlineExpansionEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.OnlineVolumeExtend)
+		err := validateWCPControllerExpandVolumeRequest(ctx, req, c.manager, isOnlineExpansionEnabled)
+		if err != nil {
+			log.Errorf("validation for ExpandVolume Request: %+v has failed. Error: %v", protosanitizer.StripSecrets(req), err)
+			return nil, err
+		}
+		volumeID := req.GetVolumeId()
+		volSizeBytes := int64(req.GetCapacityRange().GetRequiredBytes())
+		volSizeMB := int64(common.RoundUpSize(volSizeBytes, common.MbInBytes))
 
-	err = common.ExpandVolumeUtil(ctx, c.manager, volumeID, volSizeMB)
-	if err != nil {
-		msg := fmt.Sprintf("failed to expand volume: %+q to size: %d err %+v", volumeID, volSizeMB, err)
-		log.Error(msg)
-		return nil, status.Errorf(codes.Internal, msg)
-	}
+		err = common.ExpandVolumeUtil(ctx, c.manager, volumeID, volSizeMB)
+		if err != nil {
+			msg := fmt.Sprintf("failed to expand volume: %+q to size: %d err %+v", volumeID, volSizeMB, err)
+			log.Error(msg)
+			return nil, status.Errorf(codes.Internal, msg)
+		}
 
-	// Always set nodeExpansionRequired to true, even if requested size is equal to current size.
-	// Volume expansion may succeed on CNS but external-resizer may fail to update API server.
-	// Requests are requeued in this case. Setting nodeExpandsionRequired to false marks PVC
-	// resize as finished which prevents kubelet from expanding the filesystem.
-	// Ref: https://github.com/kubernetes-csi/external-resizer/blob/master/pkg/controller/controller.go#L335
-	nodeExpansionRequired := true
-	// Set NodeExpansionRequired to false for raw block volumes
-	if _, ok := req.GetVolumeCapability().GetAccessType().(*csi.VolumeCapability_Block); ok {
-		nodeExpansionRequired = false
-	}
-	resp := &csi.ControllerExpandVolumeResponse{
-		CapacityBytes:         int64(units.FileSize(volSizeMB * common.MbInBytes)),
-		NodeExpansionRequired: nodeExpansionRequired,
+		// Always set nodeExpansionRequired to true, even if requested size is equal to current size.
+		// Volume expansion may succeed on CNS but external-resizer may fail to update API server.
+		// Requests are requeued in this case. Setting nodeExpandsionRequired to false marks PVC
+		// resize as finished which prevents kubelet from expanding the filesystem.
+		// Ref: https://github.com/kubernetes-csi/external-resizer/blob/master/pkg/controller/controller.go#L335
+		nodeExpansionRequired := true
+		// Set NodeExpansionRequired to false for raw block volumes
+		if _, ok := req.GetVolumeCapability().GetAccessType().(*csi.VolumeCapability_Block); ok {
+			nodeExpansionRequired = false
+		}
+		resp := &csi.ControllerExpandVolumeResponse{
+			CapacityBytes:         int64(units.FileSize(volSizeMB * common.MbInBytes)),
+			NodeExpansionRequired: nodeExpansionRequired,
+		}
+		return resp, nil
 	}
-	return resp, nil
+	resp, err := controllerExpandVolumeInternal()
+	prometheus.RecordVolumeControlOp(volumeType, prometheus.PrometheusExpandVolumeOpType, start, err)
+	return resp, err
 }
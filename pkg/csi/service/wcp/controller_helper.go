@@ -52,7 +52,8 @@ func validateCreateBlockReqParam(paramName, value string) bool {
 	return paramName == common.AttributeStoragePolicyID ||
 		paramName == common.AttributeFsType ||
 		paramName == common.AttributeStoragePool ||
-		(paramName == common.AttributeHostLocal && strings.EqualFold(value, "true"))
+		(paramName == common.AttributeHostLocal && strings.EqualFold(value, "true")) ||
+		common.IsCreateMetadataParam(paramName)
 }
 
 const (
@@ -65,7 +66,8 @@ const (
 // Returns true if the parameter name is valid, false otherwise
 func validateCreateFileReqParam(paramName, value string) bool {
 	return paramName == common.AttributeStoragePolicyID ||
-		paramName == common.AttributeFsType
+		paramName == common.AttributeFsType ||
+		common.IsCreateMetadataParam(paramName)
 }
 
 // ValidateCreateVolumeRequest is the helper function to validate
@@ -180,8 +182,10 @@ func validateWCPControllerExpandVolumeRequest(ctx context.Context, req *csi.Cont
 // getK8sCloudOperatorClientConnection is a helper function that creates a clientConnection to
 // k8sCloudOperator GRPC service running on syncer container
 func getK8sCloudOperatorClientConnection(ctx context.Context) (*grpc.ClientConn, error) {
-	var opts []grpc.DialOption
-	opts = append(opts, grpc.WithInsecure())
+	opts, err := k8scloudoperator.GetClientDialOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
 	port := common.GetK8sCloudOperatorServicePort(ctx)
 	k8sCloudOperatorServiceAddr := "127.0.0.1:" + strconv.Itoa(port)
 	// Connect to k8s cloud operator gRPC service
@@ -232,12 +236,18 @@ func getVMUUIDFromK8sCloudOperatorService(ctx context.Context, volumeID string,
 	// Create a client stub for k8s cloud operator gRPC service
 	client := k8scloudoperator.NewK8SCloudOperatorClient(conn)
 
-	// Call GetPodVMUUIDAnnotation method on the client stub
-	res, err := client.GetPodVMUUIDAnnotation(ctx,
+	// Call WatchPodVMUUIDAnnotation method on the client stub, the annotation is streamed as soon as it is set
+	stream, err := client.WatchPodVMUUIDAnnotation(ctx,
 		&k8scloudoperator.PodListenerRequest{
 			VolumeID: volumeID,
 			NodeName: nodeName,
 		})
+	if err != nil {
+		msg := fmt.Sprintf("Failed to watch the pod vmuuid annotation from the k8s cloud operator service. Error: %+v", err)
+		log.Error(msg)
+		return "", err
+	}
+	res, err := stream.Recv()
 	if err != nil {
 		msg := fmt.Sprintf("Failed to get the pod vmuuid annotation from the k8s cloud operator service. Error: %+v", err)
 		log.Error(msg)
@@ -31,6 +31,7 @@ import (
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/fsnotify/fsnotify"
+	"github.com/kubernetes-csi/csi-lib-utils/protosanitizer"
 	vmoperatortypes "github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc/codes"
@@ -175,6 +176,18 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 	return nil
 }
 
+// CheckReadiness verifies that the clients to the supervisor cluster are initialized
+// and the supervisor API server is reachable.
+func (c *controller) CheckReadiness(ctx context.Context) error {
+	if c.supervisorClient == nil || c.vmOperatorClient == nil || c.cnsOperatorClient == nil {
+		return fmt.Errorf("clients to the supervisor cluster are not initialized")
+	}
+	if _, err := c.supervisorClient.Discovery().ServerVersion(); err != nil {
+		return fmt.Errorf("failed to reach supervisor cluster API server. err: %v", err)
+	}
+	return nil
+}
+
 // ReloadConfiguration reloads configuration from the secret, and reset restClientConfig, supervisorClient
 // and re-create vmOperatorClient using new config
 func (c *controller) ReloadConfiguration() {
@@ -218,16 +231,18 @@ func (c *controller) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequ
 	*csi.CreateVolumeResponse, error) {
 
 	start := time.Now()
+	prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusCreateVolumeOpType).Inc()
+	defer prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusCreateVolumeOpType).Dec()
 	volumeType := prometheus.PrometheusUnknownVolumeType
 	createVolumeInternal := func() (
 		*csi.CreateVolumeResponse, error) {
 
 		ctx = logger.NewContextWithLogger(ctx)
 		log := logger.GetLogger(ctx)
-		log.Infof("CreateVolume: called with args %+v", *req)
+		log.Infof("CreateVolume: called with args %+v", protosanitizer.StripSecrets(req))
 		err := validateGuestClusterCreateVolumeRequest(ctx, req)
 		if err != nil {
-			msg := fmt.Sprintf("Validation for CreateVolume Request: %+v has failed. Error: %+v", *req, err)
+			msg := fmt.Sprintf("Validation for CreateVolume Request: %+v has failed. Error: %+v", protosanitizer.StripSecrets(req), err)
 			log.Error(msg)
 			return nil, err
 		}
@@ -298,13 +313,7 @@ func (c *controller) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequ
 		return resp, nil
 	}
 	resp, err := createVolumeInternal()
-	if err != nil {
-		prometheus.VolumeControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusCreateVolumeOpType,
-			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
-	} else {
-		prometheus.VolumeControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusCreateVolumeOpType,
-			prometheus.PrometheusPassStatus).Observe(time.Since(start).Seconds())
-	}
+	prometheus.RecordVolumeControlOp(volumeType, prometheus.PrometheusCreateVolumeOpType, start, err)
 	return resp, err
 }
 
@@ -313,17 +322,19 @@ func (c *controller) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequ
 	*csi.DeleteVolumeResponse, error) {
 
 	start := time.Now()
+	prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusDeleteVolumeOpType).Inc()
+	defer prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusDeleteVolumeOpType).Dec()
 	volumeType := prometheus.PrometheusUnknownVolumeType
 
 	deleteVolumeInternal := func() (
 		*csi.DeleteVolumeResponse, error) {
 		ctx = logger.NewContextWithLogger(ctx)
 		log := logger.GetLogger(ctx)
-		log.Infof("DeleteVolume: called with args: %+v", *req)
+		log.Infof("DeleteVolume: called with args: %+v", protosanitizer.StripSecrets(req))
 		var err error
 		err = validateGuestClusterDeleteVolumeRequest(ctx, req)
 		if err != nil {
-			msg := fmt.Sprintf("Validation for Delete Volume Request: %+v has failed. Error: %+v", *req, err)
+			msg := fmt.Sprintf("Validation for Delete Volume Request: %+v has failed. Error: %+v", protosanitizer.StripSecrets(req), err)
 			log.Error(msg)
 			return nil, err
 		}
@@ -350,7 +361,7 @@ func (c *controller) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequ
 				log.Debugf("PVC: %q not found in the Supervisor cluster. Assuming this volume to be deleted.", req.VolumeId)
 				return &csi.DeleteVolumeResponse{}, nil
 			}
-			msg := fmt.Sprintf("DeleteVolume Request: %+v has failed. Error: %+v", *req, err)
+			msg := fmt.Sprintf("DeleteVolume Request: %+v has failed. Error: %+v", protosanitizer.StripSecrets(req), err)
 			log.Error(msg)
 			return nil, status.Errorf(codes.Internal, msg)
 		}
@@ -358,13 +369,7 @@ func (c *controller) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequ
 		return &csi.DeleteVolumeResponse{}, nil
 	}
 	resp, err := deleteVolumeInternal()
-	if err != nil {
-		prometheus.VolumeControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusDeleteVolumeOpType,
-			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
-	} else {
-		prometheus.VolumeControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusDeleteVolumeOpType,
-			prometheus.PrometheusPassStatus).Observe(time.Since(start).Seconds())
-	}
+	prometheus.RecordVolumeControlOp(volumeType, prometheus.PrometheusDeleteVolumeOpType, start, err)
 	return resp, err
 }
 
@@ -372,31 +377,42 @@ func (c *controller) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequ
 // volume id and node name is retrieved from ControllerPublishVolumeRequest
 func (c *controller) ControllerPublishVolume(ctx context.Context, req *csi.ControllerPublishVolumeRequest) (
 	*csi.ControllerPublishVolumeResponse, error) {
+	start := time.Now()
+	prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusAttachVolumeOpType).Inc()
+	defer prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusAttachVolumeOpType).Dec()
+	volumeType := prometheus.PrometheusUnknownVolumeType
+	controllerPublishVolumeInternal := func() (
+		*csi.ControllerPublishVolumeResponse, error) {
+		ctx = logger.NewContextWithLogger(ctx)
+		log := logger.GetLogger(ctx)
+		log.Infof("ControllerPublishVolume: called with args %+v", protosanitizer.StripSecrets(req))
+		// Check whether the request is for a block or file volume
+		isFileVolumeRequest := common.IsFileVolumeRequest(ctx, []*csi.VolumeCapability{req.GetVolumeCapability()})
 
-	ctx = logger.NewContextWithLogger(ctx)
-	log := logger.GetLogger(ctx)
-	log.Infof("ControllerPublishVolume: called with args %+v", *req)
-	// Check whether the request is for a block or file volume
-	isFileVolumeRequest := common.IsFileVolumeRequest(ctx, []*csi.VolumeCapability{req.GetVolumeCapability()})
-
-	err := validateGuestClusterControllerPublishVolumeRequest(ctx, req)
-	if err != nil {
-		msg := fmt.Sprintf("Validation for PublishVolume Request: %+v has failed. Error: %v", *req, err)
-		log.Error(msg)
-		return nil, status.Errorf(codes.Internal, msg)
-	}
+		err := validateGuestClusterControllerPublishVolumeRequest(ctx, req)
+		if err != nil {
+			msg := fmt.Sprintf("Validation for PublishVolume Request: %+v has failed. Error: %v", protosanitizer.StripSecrets(req), err)
+			log.Error(msg)
+			return nil, status.Errorf(codes.Internal, msg)
+		}
 
-	// File volumes support
-	if isFileVolumeRequest {
-		// Check the feature state for file volume support
-		if !commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.FileVolume) {
-			// Feature is disabled on the cluster
-			return nil, status.Error(codes.InvalidArgument, "File volume not supported.")
+		// File volumes support
+		if isFileVolumeRequest {
+			volumeType = prometheus.PrometheusFileVolumeType
+			// Check the feature state for file volume support
+			if !commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.FileVolume) {
+				// Feature is disabled on the cluster
+				return nil, status.Error(codes.InvalidArgument, "File volume not supported.")
+			}
+			return controllerPublishForFileVolume(ctx, req, c)
 		}
-		return controllerPublishForFileVolume(ctx, req, c)
+		// Block volumes support
+		volumeType = prometheus.PrometheusBlockVolumeType
+		return controllerPublishForBlockVolume(ctx, req, c)
 	}
-	// Block volumes support
-	return controllerPublishForBlockVolume(ctx, req, c)
+	resp, err := controllerPublishVolumeInternal()
+	prometheus.RecordVolumeControlOp(volumeType, prometheus.PrometheusAttachVolumeOpType, start, err)
+	return resp, err
 }
 
 // controllerPublishForBlockVolume is a helper mthod for handling ControllerPublishVolume request for Block volumes
@@ -661,38 +677,49 @@ func controllerPublishForFileVolume(ctx context.Context, req *csi.ControllerPubl
 // volume id and node name is retrieved from ControllerUnpublishVolumeRequest
 func (c *controller) ControllerUnpublishVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest) (
 	*csi.ControllerUnpublishVolumeResponse, error) {
+	start := time.Now()
+	prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusDetachVolumeOpType).Inc()
+	defer prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusDetachVolumeOpType).Dec()
+	volumeType := prometheus.PrometheusUnknownVolumeType
+	controllerUnpublishVolumeInternal := func() (
+		*csi.ControllerUnpublishVolumeResponse, error) {
+		ctx = logger.NewContextWithLogger(ctx)
+		log := logger.GetLogger(ctx)
+		log.Infof("ControllerUnpublishVolume: called with args %+v", protosanitizer.StripSecrets(req))
+		err := validateGuestClusterControllerUnpublishVolumeRequest(ctx, req)
+		if err != nil {
+			msg := fmt.Sprintf("Validation for UnpublishVolume Request: %+v has failed. Error: %v", protosanitizer.StripSecrets(req), err)
+			log.Error(msg)
+			return nil, err
+		}
 
-	ctx = logger.NewContextWithLogger(ctx)
-	log := logger.GetLogger(ctx)
-	log.Infof("ControllerUnpublishVolume: called with args %+v", *req)
-	err := validateGuestClusterControllerUnpublishVolumeRequest(ctx, req)
-	if err != nil {
-		msg := fmt.Sprintf("Validation for UnpublishVolume Request: %+v has failed. Error: %v", *req, err)
-		log.Error(msg)
-		return nil, err
-	}
-
-	// Retrieve Supervisor PVC
-	svPVC, err := c.supervisorClient.CoreV1().PersistentVolumeClaims(c.supervisorNamespace).Get(ctx, req.VolumeId, metav1.GetOptions{})
-	if err != nil {
-		msg := fmt.Sprintf("failed to retrieve supervisor PVC %q in %q namespace. Error: %+v", req.VolumeId, c.supervisorNamespace, err)
-		log.Error(msg)
-		return nil, status.Error(codes.Internal, msg)
-	}
-	var isFileVolume bool
-	for _, accessMode := range svPVC.Spec.AccessModes {
-		if accessMode == corev1.ReadWriteMany || accessMode == corev1.ReadOnlyMany {
-			isFileVolume = true
+		// Retrieve Supervisor PVC
+		svPVC, err := c.supervisorClient.CoreV1().PersistentVolumeClaims(c.supervisorNamespace).Get(ctx, req.VolumeId, metav1.GetOptions{})
+		if err != nil {
+			msg := fmt.Sprintf("failed to retrieve supervisor PVC %q in %q namespace. Error: %+v", req.VolumeId, c.supervisorNamespace, err)
+			log.Error(msg)
+			return nil, status.Error(codes.Internal, msg)
 		}
-	}
-	if isFileVolume {
-		if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.FileVolume) {
-			return controllerUnpublishForFileVolume(ctx, req, c)
+		var isFileVolume bool
+		for _, accessMode := range svPVC.Spec.AccessModes {
+			if accessMode == corev1.ReadWriteMany || accessMode == corev1.ReadOnlyMany {
+				isFileVolume = true
+			}
 		}
-		// Feature is disabled on the cluster
-		return nil, status.Error(codes.InvalidArgument, "File volume not supported.")
+		if isFileVolume {
+			volumeType = prometheus.PrometheusFileVolumeType
+			if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.FileVolume) {
+				return controllerUnpublishForFileVolume(ctx, req, c)
+			}
+			// Feature is disabled on the cluster
+			return nil, status.Error(codes.InvalidArgument, "File volume not supported.")
+		}
+		volumeType = prometheus.PrometheusBlockVolumeType
+		return controllerUnpublishForBlockVolume(ctx, req, c)
 	}
-	return controllerUnpublishForBlockVolume(ctx, req, c)
+	resp, err := controllerUnpublishVolumeInternal()
+	prometheus.RecordVolumeControlOp(volumeType, prometheus.PrometheusDetachVolumeOpType, start, err)
+	return resp, err
 }
 
 // controllerUnpublishForBlockVolume is helper method to handle ControllerPublishVolume for Block volumes
@@ -883,113 +910,125 @@ func controllerUnpublishForFileVolume(ctx context.Context, req *csi.ControllerUn
 // volume id and size is retrieved from ControllerExpandVolumeRequest
 func (c *controller) ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest) (
 	*csi.ControllerExpandVolumeResponse, error) {
-	ctx = logger.NewContextWithLogger(ctx)
-	log := logger.GetLogger(ctx)
-	if !commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.VolumeExtend) {
-		msg := "ExpandVolume feature is disabled on the cluster."
-		log.Warn(msg)
-		return nil, status.Error(codes.Unimplemented, msg)
-	}
-	log.Infof("ControllerExpandVolume: called with args %+v", *req)
-
-	err := validateGuestClusterControllerExpandVolumeRequest(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-
-	volumeID := req.GetVolumeId()
-	volSizeBytes := int64(req.GetCapacityRange().GetRequiredBytes())
+	start := time.Now()
+	prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusExpandVolumeOpType).Inc()
+	defer prometheus.VolumeControlOpsInFlightGaugeVec.WithLabelValues(prometheus.PrometheusExpandVolumeOpType).Dec()
+	volumeType := prometheus.PrometheusUnknownVolumeType
+	controllerExpandVolumeInternal := func() (
+		*csi.ControllerExpandVolumeResponse, error) {
+		ctx = logger.NewContextWithLogger(ctx)
+		log := logger.GetLogger(ctx)
+		if !commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.VolumeExtend) {
+			msg := "ExpandVolume feature is disabled on the cluster."
+			log.Warn(msg)
+			return nil, status.Error(codes.Unimplemented, msg)
+		}
+		log.Infof("ControllerExpandVolume: called with args %+v", protosanitizer.StripSecrets(req))
+		// Only block volumes can be expanded
+		volumeType = prometheus.PrometheusBlockVolumeType
 
-	if !commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.OnlineVolumeExtend) {
-		vmList := &vmoperatortypes.VirtualMachineList{}
-		err = c.vmOperatorClient.List(ctx, vmList, client.InNamespace(c.supervisorNamespace))
+		err := validateGuestClusterControllerExpandVolumeRequest(ctx, req)
 		if err != nil {
-			msg := fmt.Sprintf("failed to list virtualmachines with error: %+v", err)
-			log.Error(msg)
-			return nil, status.Error(codes.Internal, msg)
+			return nil, err
 		}
 
-		for _, vmInstance := range vmList.Items {
-			for _, vmVolume := range vmInstance.Status.Volumes {
-				if vmVolume.Name == volumeID && vmVolume.Attached {
-					msg := fmt.Sprintf("failed to expand volume: %q. Volume is attached to pod. Only offline volume expansion is supported", volumeID)
-					log.Error(msg)
-					return nil, status.Error(codes.FailedPrecondition, msg)
+		volumeID := req.GetVolumeId()
+		volSizeBytes := int64(req.GetCapacityRange().GetRequiredBytes())
+
+		if !commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.OnlineVolumeExtend) {
+			vmList := &vmoperatortypes.VirtualMachineList{}
+			err = c.vmOperatorClient.List(ctx, vmList, client.InNamespace(c.supervisorNamespace))
+			if err != nil {
+				msg := fmt.Sprintf("failed to list virtualmachines with error: %+v", err)
+				log.Error(msg)
+				return nil, status.Error(codes.Internal, msg)
+			}
+
+			for _, vmInstance := range vmList.Items {
+				for _, vmVolume := range vmInstance.Status.Volumes {
+					if vmVolume.Name == volumeID && vmVolume.Attached {
+						msg := fmt.Sprintf("failed to expand volume: %q. Volume is attached to pod. Only offline volume expansion is supported", volumeID)
+						log.Error(msg)
+						return nil, status.Error(codes.FailedPrecondition, msg)
+					}
 				}
 			}
 		}
-	}
-
-	// Retrieve Supervisor PVC
-	svPVC, err := c.supervisorClient.CoreV1().PersistentVolumeClaims(c.supervisorNamespace).Get(ctx, volumeID, metav1.GetOptions{})
-	if err != nil {
-		msg := fmt.Sprintf("failed to retrieve supervisor PVC %q in %q namespace. Error: %+v", volumeID, c.supervisorNamespace, err)
-		log.Error(msg)
-		return nil, status.Error(codes.Internal, msg)
-	}
 
-	waitForSvPvcCondition := true
-	gcPvcRequestSize := resource.NewQuantity(volSizeBytes, resource.Format(resource.BinarySI))
-	svPvcRequestSize := svPVC.Spec.Resources.Requests[corev1.ResourceName(corev1.ResourceStorage)]
-	// Check if GC PVC request size is greater than SV PVC request size
-	switch (gcPvcRequestSize).Cmp(svPvcRequestSize) {
-	case 1:
-		// Update requested storage in SV PVC spec
-		svPvcClone := svPVC.DeepCopy()
-		svPvcClone.Spec.Resources.Requests[corev1.ResourceName(corev1.ResourceStorage)] = *gcPvcRequestSize
-
-		// Make an update call to SV API server
-		log.Infof("Increasing the size of supervisor PVC %s in namespace %s to %s", volumeID, c.supervisorNamespace, gcPvcRequestSize.String())
-		svPVC, err = c.supervisorClient.CoreV1().PersistentVolumeClaims(c.supervisorNamespace).Update(ctx, svPvcClone, metav1.UpdateOptions{})
+		// Retrieve Supervisor PVC
+		svPVC, err := c.supervisorClient.CoreV1().PersistentVolumeClaims(c.supervisorNamespace).Get(ctx, volumeID, metav1.GetOptions{})
 		if err != nil {
-			msg := fmt.Sprintf("failed to update supervisor PVC %q in %q namespace. Error: %+v", volumeID, c.supervisorNamespace, err)
+			msg := fmt.Sprintf("failed to retrieve supervisor PVC %q in %q namespace. Error: %+v", volumeID, c.supervisorNamespace, err)
 			log.Error(msg)
 			return nil, status.Error(codes.Internal, msg)
 		}
-	case 0:
-		// GC PVC request size is equal to SV PVC request size
-		log.Infof("Skipping resize call for supervisor PVC %s in namespace %s as it is already at the requested size", volumeID, c.supervisorNamespace)
 
-		// SV PVC is already in FileSystemResizePending condition indicates that SV PV has already been expanded to required size
-		if checkPVCCondition(ctx, svPVC, corev1.PersistentVolumeClaimFileSystemResizePending) {
-			waitForSvPvcCondition = false
-		} else {
-			// SV PVC is not in FileSystemResizePending condition and GC PVC request size is equal to SV PVC capacity
-			// indicates that SV PVC is already at required size
-			if (gcPvcRequestSize).Cmp(svPVC.Status.Capacity[corev1.ResourceName(corev1.ResourceStorage)]) == 0 {
+		waitForSvPvcCondition := true
+		gcPvcRequestSize := resource.NewQuantity(volSizeBytes, resource.Format(resource.BinarySI))
+		svPvcRequestSize := svPVC.Spec.Resources.Requests[corev1.ResourceName(corev1.ResourceStorage)]
+		// Check if GC PVC request size is greater than SV PVC request size
+		switch (gcPvcRequestSize).Cmp(svPvcRequestSize) {
+		case 1:
+			// Update requested storage in SV PVC spec
+			svPvcClone := svPVC.DeepCopy()
+			svPvcClone.Spec.Resources.Requests[corev1.ResourceName(corev1.ResourceStorage)] = *gcPvcRequestSize
+
+			// Make an update call to SV API server
+			log.Infof("Increasing the size of supervisor PVC %s in namespace %s to %s", volumeID, c.supervisorNamespace, gcPvcRequestSize.String())
+			svPVC, err = c.supervisorClient.CoreV1().PersistentVolumeClaims(c.supervisorNamespace).Update(ctx, svPvcClone, metav1.UpdateOptions{})
+			if err != nil {
+				msg := fmt.Sprintf("failed to update supervisor PVC %q in %q namespace. Error: %+v", volumeID, c.supervisorNamespace, err)
+				log.Error(msg)
+				return nil, status.Error(codes.Internal, msg)
+			}
+		case 0:
+			// GC PVC request size is equal to SV PVC request size
+			log.Infof("Skipping resize call for supervisor PVC %s in namespace %s as it is already at the requested size", volumeID, c.supervisorNamespace)
+
+			// SV PVC is already in FileSystemResizePending condition indicates that SV PV has already been expanded to required size
+			if checkPVCCondition(ctx, svPVC, corev1.PersistentVolumeClaimFileSystemResizePending) {
 				waitForSvPvcCondition = false
+			} else {
+				// SV PVC is not in FileSystemResizePending condition and GC PVC request size is equal to SV PVC capacity
+				// indicates that SV PVC is already at required size
+				if (gcPvcRequestSize).Cmp(svPVC.Status.Capacity[corev1.ResourceName(corev1.ResourceStorage)]) == 0 {
+					waitForSvPvcCondition = false
+				}
 			}
+		default:
+			// GC PVC request size is lesser than SV PVC request size
+			msg := fmt.Sprintf("the requested size of the Supervisor PVC %s in namespace %s is %s which is greater than the requested size of %s",
+				volumeID, c.supervisorNamespace, svPvcRequestSize.String(), gcPvcRequestSize.String())
+			log.Error(msg)
+			return nil, status.Error(codes.InvalidArgument, msg)
 		}
-	default:
-		// GC PVC request size is lesser than SV PVC request size
-		msg := fmt.Sprintf("the requested size of the Supervisor PVC %s in namespace %s is %s which is greater than the requested size of %s",
-			volumeID, c.supervisorNamespace, svPvcRequestSize.String(), gcPvcRequestSize.String())
-		log.Error(msg)
-		return nil, status.Error(codes.InvalidArgument, msg)
-	}
 
-	if waitForSvPvcCondition {
-		// Wait for Supervisor PVC to change status to FilesystemResizePending
-		err = checkForSupervisorPVCCondition(ctx, c.supervisorClient, svPVC,
-			corev1.PersistentVolumeClaimFileSystemResizePending, time.Duration(getResizeTimeoutInMin(ctx))*time.Minute)
-		if err != nil {
-			msg := fmt.Sprintf("failed to expand volume %s in namespace %s of supervisor cluster. Error: %+v", volumeID, c.supervisorNamespace, err)
-			log.Error(msg)
-			return nil, status.Error(codes.Internal, msg)
+		if waitForSvPvcCondition {
+			// Wait for Supervisor PVC to change status to FilesystemResizePending
+			err = checkForSupervisorPVCCondition(ctx, c.supervisorClient, svPVC,
+				corev1.PersistentVolumeClaimFileSystemResizePending, time.Duration(getResizeTimeoutInMin(ctx))*time.Minute)
+			if err != nil {
+				msg := fmt.Sprintf("failed to expand volume %s in namespace %s of supervisor cluster. Error: %+v", volumeID, c.supervisorNamespace, err)
+				log.Error(msg)
+				return nil, status.Error(codes.Internal, msg)
+			}
 		}
-	}
 
-	nodeExpansionRequired := true
-	// Set NodeExpansionRequired to false for raw block volumes
-	if _, ok := req.GetVolumeCapability().GetAccessType().(*csi.VolumeCapability_Block); ok {
-		log.Infof("Node Expansion not supported for raw block volume ID %q in namespace %s of supervisor", volumeID, c.supervisorNamespace)
-		nodeExpansionRequired = false
-	}
-	resp := &csi.ControllerExpandVolumeResponse{
-		CapacityBytes:         volSizeBytes,
-		NodeExpansionRequired: nodeExpansionRequired,
+		nodeExpansionRequired := true
+		// Set NodeExpansionRequired to false for raw block volumes
+		if _, ok := req.GetVolumeCapability().GetAccessType().(*csi.VolumeCapability_Block); ok {
+			log.Infof("Node Expansion not supported for raw block volume ID %q in namespace %s of supervisor", volumeID, c.supervisorNamespace)
+			nodeExpansionRequired = false
+		}
+		resp := &csi.ControllerExpandVolumeResponse{
+			CapacityBytes:         volSizeBytes,
+			NodeExpansionRequired: nodeExpansionRequired,
+		}
+		return resp, nil
 	}
-	return resp, nil
+	resp, err := controllerExpandVolumeInternal()
+	prometheus.RecordVolumeControlOp(volumeType, prometheus.PrometheusExpandVolumeOpType, start, err)
+	return resp, err
 }
 
 // ValidateVolumeCapabilities returns the capabilities of the volume.
@@ -997,7 +1036,7 @@ func (c *controller) ValidateVolumeCapabilities(ctx context.Context, req *csi.Va
 	*csi.ValidateVolumeCapabilitiesResponse, error) {
 
 	log := logger.GetLogger(ctx)
-	log.Infof("ValidateVolumeCapabilities: called with args %+v", *req)
+	log.Infof("ValidateVolumeCapabilities: called with args %+v", protosanitizer.StripSecrets(req))
 	volCaps := req.GetVolumeCapabilities()
 	var confirmed *csi.ValidateVolumeCapabilitiesResponse_Confirmed
 	if err := common.IsValidVolumeCapabilities(ctx, volCaps); err == nil {
@@ -1013,7 +1052,7 @@ func (c *controller) ListVolumes(ctx context.Context, req *csi.ListVolumesReques
 
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("ListVolumes: called with args %+v", *req)
+	log.Infof("ListVolumes: called with args %+v", protosanitizer.StripSecrets(req))
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
@@ -1022,7 +1061,7 @@ func (c *controller) GetCapacity(ctx context.Context, req *csi.GetCapacityReques
 
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("GetCapacity: called with args %+v", *req)
+	log.Infof("GetCapacity: called with args %+v", protosanitizer.StripSecrets(req))
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
@@ -1031,7 +1070,7 @@ func (c *controller) ControllerGetCapabilities(ctx context.Context, req *csi.Con
 
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("ControllerGetCapabilities: called with args %+v", *req)
+	log.Infof("ControllerGetCapabilities: called with args %+v", protosanitizer.StripSecrets(req))
 	var caps []*csi.ControllerServiceCapability
 	for _, cap := range controllerCaps {
 		c := &csi.ControllerServiceCapability{
@@ -1050,7 +1089,7 @@ func (c *controller) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshot
 	*csi.CreateSnapshotResponse, error) {
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("CreateSnapshot: called with args %+v", *req)
+	log.Infof("CreateSnapshot: called with args %+v", protosanitizer.StripSecrets(req))
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
@@ -1058,7 +1097,7 @@ func (c *controller) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshot
 	*csi.DeleteSnapshotResponse, error) {
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("DeleteSnapshot: called with args %+v", *req)
+	log.Infof("DeleteSnapshot: called with args %+v", protosanitizer.StripSecrets(req))
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
@@ -1067,6 +1106,6 @@ func (c *controller) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRe
 
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
-	log.Infof("ListSnapshots: called with args %+v", *req)
+	log.Infof("ListSnapshots: called with args %+v", protosanitizer.StripSecrets(req))
 	return nil, status.Error(codes.Unimplemented, "")
 }
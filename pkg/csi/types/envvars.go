@@ -31,4 +31,7 @@ const (
 
 	// EnvInClusterClientBurst is the Burst for all clients to the API server
 	EnvInClusterClientBurst = "INCLUSTER_CLIENT_BURST"
+
+	// EnvNodeMetricsAddress is the address on which the node plugin exposes Prometheus metrics
+	EnvNodeMetricsAddress = "NODE_METRICS_ADDRESS"
 )
@@ -17,6 +17,8 @@ limitations under the License.
 package types
 
 import (
+	"context"
+
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/common/config"
 )
@@ -26,4 +28,7 @@ import (
 type CnsController interface {
 	csi.ControllerServer
 	Init(config *config.Config, version string) error
+	// CheckReadiness returns an error describing why the controller is not
+	// able to serve requests, or nil if all its backend dependencies are ready.
+	CheckReadiness(ctx context.Context) error
 }
@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+)
+
+// NewRecorder returns an event recorder publishing the events of the given component
+// to the API server using the given client
+func NewRecorder(k8sClient clientset.Interface, component string) record.EventRecorder {
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(
+		&typedcorev1.EventSinkImpl{
+			Interface: k8sClient.CoreV1().Events(""),
+		},
+	)
+	return eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: component})
+}
+
+// EventRecorder records events on the PVCs and Nodes referenced by name in the CSI requests.
+// Events are best effort: they are dropped if the object cannot be retrieved, and a nil
+// EventRecorder drops all events.
+type EventRecorder struct {
+	k8sClient clientset.Interface
+	recorder  record.EventRecorder
+}
+
+// NewEventRecorder creates an EventRecorder for the given component using the in-cluster client
+func NewEventRecorder(ctx context.Context, component string) (*EventRecorder, error) {
+	log := logger.GetLogger(ctx)
+	k8sClient, err := NewClient(ctx)
+	if err != nil {
+		log.Errorf("failed to create Kubernetes client for the event recorder. Err: %v", err)
+		return nil, err
+	}
+	return &EventRecorder{
+		k8sClient: k8sClient,
+		recorder:  NewRecorder(k8sClient, component),
+	}, nil
+}
+
+// PVCEventf records an event on the PVC with the given namespace and name
+func (r *EventRecorder) PVCEventf(ctx context.Context, namespace string, name string,
+	eventtype, reason, messageFmt string, args ...interface{}) {
+	if r == nil || namespace == "" || name == "" {
+		return
+	}
+	log := logger.GetLogger(ctx)
+	pvc, err := r.k8sClient.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		log.Debugf("failed to get PVC %s/%s to record event %q. Err: %v", namespace, name, reason, err)
+		return
+	}
+	r.recorder.Eventf(pvc, eventtype, reason, messageFmt, args...)
+}
+
+// CreateVolumeEventf records an event on the PVC a CreateVolume request was issued for.
+// The PVC is identified by the parameters added by the external-provisioner started with
+// --extra-create-metadata, the event is dropped if they are missing.
+func (r *EventRecorder) CreateVolumeEventf(ctx context.Context, params map[string]string,
+	eventtype, reason, messageFmt string, args ...interface{}) {
+	r.PVCEventf(ctx, params[common.AttributePVCNamespace], params[common.AttributePVCName],
+		eventtype, reason, messageFmt, args...)
+}
+
+// NodeEventf records an event on the Node with the given name
+func (r *EventRecorder) NodeEventf(ctx context.Context, nodeName string,
+	eventtype, reason, messageFmt string, args ...interface{}) {
+	if r == nil || nodeName == "" {
+		return
+	}
+	log := logger.GetLogger(ctx)
+	node, err := r.k8sClient.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		log.Debugf("failed to get node %s to record event %q. Err: %v", nodeName, reason, err)
+		return
+	}
+	r.recorder.Eventf(node, eventtype, reason, messageFmt, args...)
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
+)
+
+func TestNewRecorder(t *testing.T) {
+	k8sClient := testclient.NewSimpleClientset()
+	createdEvents := make(chan *v1.Event, 1)
+	k8sClient.PrependReactor("create", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		event := action.(k8stesting.CreateAction).GetObject().(*v1.Event)
+		createdEvents <- event
+		return true, event, nil
+	})
+	pvc := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "pvc-1", Namespace: "test-ns"}}
+	NewRecorder(k8sClient, "vsphere-csi-controller").Event(pvc, v1.EventTypeWarning, "TestReason", "test message")
+
+	select {
+	case event := <-createdEvents:
+		if event.Reason != "TestReason" || event.Source.Component != "vsphere-csi-controller" ||
+			event.InvolvedObject.Name != "pvc-1" || event.Namespace != "test-ns" {
+			t.Errorf("unexpected event %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not published")
+	}
+}
+
+func TestEventRecorder(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := testclient.NewSimpleClientset(
+		&v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "pvc-1", Namespace: "test-ns"}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+	)
+	fakeRecorder := record.NewFakeRecorder(10)
+	r := &EventRecorder{k8sClient: k8sClient, recorder: fakeRecorder}
+
+	r.PVCEventf(ctx, "test-ns", "pvc-1", v1.EventTypeWarning, "PVCReason", "volume %s", "v1")
+	r.PVCEventf(ctx, "test-ns", "missing-pvc", v1.EventTypeWarning, "MissingPVC", "dropped")
+	r.PVCEventf(ctx, "", "pvc-1", v1.EventTypeWarning, "NoNamespace", "dropped")
+	r.CreateVolumeEventf(ctx, map[string]string{
+		common.AttributePVCNamespace: "test-ns",
+		common.AttributePVCName:      "pvc-1",
+	}, v1.EventTypeNormal, "CreateReason", "created")
+	r.CreateVolumeEventf(ctx, map[string]string{}, v1.EventTypeNormal, "NoPVCParams", "dropped")
+	r.NodeEventf(ctx, "node-1", v1.EventTypeWarning, "NodeReason", "node %d", 1)
+	r.NodeEventf(ctx, "missing-node", v1.EventTypeWarning, "MissingNode", "dropped")
+	var nilRecorder *EventRecorder
+	nilRecorder.PVCEventf(ctx, "test-ns", "pvc-1", v1.EventTypeWarning, "NilRecorder", "dropped")
+	nilRecorder.NodeEventf(ctx, "node-1", v1.EventTypeWarning, "NilRecorder", "dropped")
+
+	close(fakeRecorder.Events)
+	var events []string
+	for event := range fakeRecorder.Events {
+		events = append(events, event)
+	}
+	expected := []string{
+		"Warning PVCReason volume v1",
+		"Normal CreateReason created",
+		"Warning NodeReason node 1",
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected events %v, got %v", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("expected event %q, got %q", expected[i], events[i])
+		}
+	}
+}
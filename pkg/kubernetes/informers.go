@@ -25,6 +25,7 @@ import (
 	v1 "k8s.io/client-go/informers/core/v1"
 	clientset "k8s.io/client-go/kubernetes"
 	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/sample-controller/pkg/signals"
 )
@@ -150,6 +151,11 @@ func (im *InformerManager) GetPodLister() corelisters.PodLister {
 	return im.informerFactory.Core().V1().Pods().Lister()
 }
 
+// GetStorageClassLister returns StorageClass Lister for the calling informer manager
+func (im *InformerManager) GetStorageClassLister() storagelisters.StorageClassLister {
+	return im.informerFactory.Storage().V1().StorageClasses().Lister()
+}
+
 // Listen starts the Informers
 func (im *InformerManager) Listen() (stopCh <-chan struct{}) {
 	go im.informerFactory.Start(im.stopCh)
@@ -27,15 +27,20 @@ import (
 	"os"
 
 	"github.com/fsnotify/fsnotify"
+	cnstypes "github.com/vmware/govmomi/cns/types"
 	admissionv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/informers"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 
 	cnsconfig "sigs.k8s.io/vsphere-csi-driver/pkg/common/config"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/commonco"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+	k8s "sigs.k8s.io/vsphere-csi-driver/pkg/kubernetes"
 )
 
 type (
@@ -55,6 +60,12 @@ var (
 	// CO agnostic orchestrator in the admission handler package
 	COInitParams                 *interface{}
 	containerOrchestratorUtility commonco.COCommonInterface
+	// clusterFlavor is the flavor of the cluster the webhook is running in
+	clusterFlavor cnstypes.CnsClusterFlavor
+	// k8sClient is used to look up the objects referenced by the validated resources
+	k8sClient clientset.Interface
+	// volumeAttachmentIndexer caches the VolumeAttachments of the cluster, indexed by PV name
+	volumeAttachmentIndexer cache.Indexer
 )
 
 // watchConfigChange watches on the webhook configuration directory for changes like cert, key etc.
@@ -128,7 +139,7 @@ func StartWebhookServer(ctx context.Context) error {
 		log.Debugf("webhook config: %v", cfg)
 	}
 	if containerOrchestratorUtility == nil {
-		clusterFlavor, err := cnsconfig.GetClusterFlavor(ctx)
+		clusterFlavor, err = cnsconfig.GetClusterFlavor(ctx)
 		if err != nil {
 			log.Errorf("Failed retrieving cluster flavor. Error: %v", err)
 			return err
@@ -139,7 +150,23 @@ func StartWebhookServer(ctx context.Context) error {
 			return err
 		}
 	}
-	if containerOrchestratorUtility.IsFSSEnabled(ctx, common.CSIMigration) {
+	if k8sClient == nil {
+		k8sClient, err = k8s.NewClient(ctx)
+		if err != nil {
+			log.Errorf("failed to create k8s client. err: %v", err)
+			return err
+		}
+	}
+	if volumeAttachmentIndexer == nil && containerOrchestratorUtility.IsFSSEnabled(ctx, common.CSIPVCValidation) {
+		volumeAttachmentIndexer, err = startVolumeAttachmentInformer(ctx)
+		if err != nil {
+			log.Errorf("failed to start VolumeAttachment informer. err: %v", err)
+			return err
+		}
+	}
+	if containerOrchestratorUtility.IsFSSEnabled(ctx, common.CSIMigration) ||
+		containerOrchestratorUtility.IsFSSEnabled(ctx, common.CSIStorageClassValidation) ||
+		containerOrchestratorUtility.IsFSSEnabled(ctx, common.CSIPVCValidation) {
 		certs, err := tls.LoadX509KeyPair(cfg.WebHookConfig.CertFile, cfg.WebHookConfig.KeyFile)
 		if err != nil {
 			log.Errorf("failed to load key pair. certFile: %q, keyFile: %q err: %v", cfg.WebHookConfig.CertFile, cfg.WebHookConfig.KeyFile, err)
@@ -176,6 +203,21 @@ func StartWebhookServer(ctx context.Context) error {
 	return errors.New("can't start webhook. no features are enabled which requires webhook")
 }
 
+// startVolumeAttachmentInformer starts an informer on the VolumeAttachments of the cluster
+// and returns its indexer once it is synced
+func startVolumeAttachmentInformer(ctx context.Context) (cache.Indexer, error) {
+	informer := informers.NewSharedInformerFactory(k8sClient, 0).Storage().V1().VolumeAttachments().Informer()
+	err := informer.AddIndexers(cache.Indexers{volumeAttachmentPVNameIndex: volumeAttachmentPVName})
+	if err != nil {
+		return nil, err
+	}
+	go informer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return nil, errors.New("failed to sync VolumeAttachment informer")
+	}
+	return informer.GetIndexer(), nil
+}
+
 // restartWebhookServer stops the webhook server and start webhook using updated config
 func restartWebhookServer(ctx context.Context) error {
 	log := logger.GetLogger(ctx)
@@ -236,6 +278,8 @@ func validationHandler(w http.ResponseWriter, r *http.Request) {
 			switch ar.Request.Kind.Kind {
 			case "StorageClass":
 				admissionResponse = validateStorageClass(ctx, &ar)
+			case "PersistentVolumeClaim":
+				admissionResponse = validatePVC(ctx, &ar)
 			default:
 				log.Infof("Skipping validation for resource type: %q", ar.Request.Kind.Kind)
 				admissionResponse = &admissionv1.AdmissionResponse{
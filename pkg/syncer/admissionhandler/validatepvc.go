@@ -0,0 +1,218 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admissionhandler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/pkg/csi/types"
+)
+
+const (
+	fileVolumeExpandErrorMessage = "Expansion of file volumes is not supported"
+	onlineExpandErrorMessage     = "Expansion of attached volumes is not supported. Detach the volume from the node before expanding it"
+	rwxBlockErrorMessage         = "ReadWriteMany access mode is not supported for volumes in Block volumeMode"
+	rwxFileServiceErrorMessage   = "ReadWriteMany access mode requires vSAN file services to be enabled on the target datastores"
+
+	// volumeAttachmentPVNameIndex is the name of the index of VolumeAttachments by PV name
+	volumeAttachmentPVNameIndex = "pvName"
+)
+
+// validatePVC helps validate AdmissionReview requests for PersistentVolumeClaim
+func validatePVC(ctx context.Context, ar *admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+	if containerOrchestratorUtility != nil && !containerOrchestratorUtility.IsFSSEnabled(ctx, common.CSIPVCValidation) {
+		// if PVC validation is disabled and webhook is running
+		// skip validation for PersistentVolumeClaim
+		return &admissionv1.AdmissionResponse{
+			Allowed: true,
+		}
+	}
+	log := logger.GetLogger(ctx)
+	req := ar.Request
+	if req.Kind.Kind != "PersistentVolumeClaim" {
+		log.Errorf("Can't validate resource kind: %q using validatePVC function", req.Kind.Kind)
+		return &admissionv1.AdmissionResponse{
+			Allowed: false,
+		}
+	}
+	pvc := v1.PersistentVolumeClaim{}
+	if err := json.Unmarshal(req.Object.Raw, &pvc); err != nil {
+		log.Error("error deserializing PersistentVolumeClaim")
+		return &admissionv1.AdmissionResponse{
+			Result: &metav1.Status{
+				Message: err.Error(),
+			},
+		}
+	}
+	log.Infof("Validating PersistentVolumeClaim: %s/%s", pvc.Namespace, pvc.Name)
+	var reason string
+	switch req.Operation {
+	case admissionv1.Create:
+		reason = validatePVCAccessModes(ctx, &pvc)
+	case admissionv1.Update:
+		oldPVC := v1.PersistentVolumeClaim{}
+		if err := json.Unmarshal(req.OldObject.Raw, &oldPVC); err != nil {
+			log.Error("error deserializing old PersistentVolumeClaim")
+			return &admissionv1.AdmissionResponse{
+				Result: &metav1.Status{
+					Message: err.Error(),
+				},
+			}
+		}
+		reason = validatePVCResize(ctx, &oldPVC, &pvc)
+	}
+	if reason != "" {
+		log.Errorf("validation of PersistentVolumeClaim: %s/%s Failed. %s", pvc.Namespace, pvc.Name, reason)
+		return &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result: &metav1.Status{
+				Reason: metav1.StatusReason(reason),
+			},
+		}
+	}
+	log.Infof("Validation of PersistentVolumeClaim: %s/%s Passed", pvc.Namespace, pvc.Name)
+	return &admissionv1.AdmissionResponse{
+		Allowed: true,
+	}
+}
+
+// validatePVCResize returns the reason of the rejection of the update of the given PVC,
+// or an empty string if the requested size of the volume can be applied.
+// Shrinking is left to the apiserver, which already rejects it.
+func validatePVCResize(ctx context.Context, oldPVC *v1.PersistentVolumeClaim, newPVC *v1.PersistentVolumeClaim) string {
+	log := logger.GetLogger(ctx)
+	oldSize := oldPVC.Spec.Resources.Requests[v1.ResourceStorage]
+	newSize := newPVC.Spec.Resources.Requests[v1.ResourceStorage]
+	if newSize.Cmp(oldSize) <= 0 || newPVC.Spec.VolumeName == "" {
+		return ""
+	}
+	pv, err := k8sClient.CoreV1().PersistentVolumes().Get(ctx, newPVC.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		log.Warnf("skipping validation of the expansion of PVC %s/%s. failed to get PV %s. err: %v",
+			newPVC.Namespace, newPVC.Name, newPVC.Spec.VolumeName, err)
+		return ""
+	}
+	if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.Name {
+		return ""
+	}
+	if pv.Spec.CSI.VolumeAttributes[common.AttributeDiskType] == common.DiskTypeFileVolume {
+		return fileVolumeExpandErrorMessage
+	}
+	if containerOrchestratorUtility != nil && containerOrchestratorUtility.IsFSSEnabled(ctx, common.OnlineVolumeExtend) {
+		return ""
+	}
+	if volumeAttachmentIndexer == nil {
+		log.Warnf("skipping validation of the expansion of PVC %s/%s. VolumeAttachment informer is not started",
+			newPVC.Namespace, newPVC.Name)
+		return ""
+	}
+	volumeAttachments, err := volumeAttachmentIndexer.ByIndex(volumeAttachmentPVNameIndex, pv.Name)
+	if err != nil {
+		log.Warnf("skipping validation of the expansion of PVC %s/%s. failed to get the VolumeAttachments of PV %s. err: %v",
+			newPVC.Namespace, newPVC.Name, pv.Name, err)
+		return ""
+	}
+	for _, obj := range volumeAttachments {
+		if va, ok := obj.(*storagev1.VolumeAttachment); ok && va.Status.Attached {
+			return fmt.Sprintf("%s. Volume %s is attached to node %s", onlineExpandErrorMessage, pv.Name, va.Spec.NodeName)
+		}
+	}
+	return ""
+}
+
+// volumeAttachmentPVName is the index function of VolumeAttachments by the name of their PV
+func volumeAttachmentPVName(obj interface{}) ([]string, error) {
+	va, ok := obj.(*storagev1.VolumeAttachment)
+	if !ok || va.Spec.Source.PersistentVolumeName == nil {
+		return nil, nil
+	}
+	return []string{*va.Spec.Source.PersistentVolumeName}, nil
+}
+
+// validatePVCAccessModes returns the reason of the rejection of the creation of the given PVC,
+// or an empty string if a volume can be provisioned with the requested access modes
+func validatePVCAccessModes(ctx context.Context, pvc *v1.PersistentVolumeClaim) string {
+	log := logger.GetLogger(ctx)
+	if clusterFlavor != cnstypes.CnsClusterFlavorVanilla || pvc.Spec.StorageClassName == nil ||
+		*pvc.Spec.StorageClassName == "" {
+		return ""
+	}
+	rwx := false
+	for _, accessMode := range pvc.Spec.AccessModes {
+		if accessMode == v1.ReadWriteMany {
+			rwx = true
+		}
+	}
+	if !rwx {
+		return ""
+	}
+	sc, err := k8sClient.StorageV1().StorageClasses().Get(ctx, *pvc.Spec.StorageClassName, metav1.GetOptions{})
+	if err != nil {
+		log.Warnf("skipping validation of the access modes of PVC %s/%s. failed to get StorageClass %s. err: %v",
+			pvc.Namespace, pvc.Name, *pvc.Spec.StorageClassName, err)
+		return ""
+	}
+	if sc.Provisioner != csitypes.Name {
+		return ""
+	}
+	if pvc.Spec.VolumeMode != nil && *pvc.Spec.VolumeMode == v1.PersistentVolumeBlock {
+		return rwxBlockErrorMessage
+	}
+
+	vc, err := getVirtualCenter(ctx)
+	if err != nil {
+		log.Warnf("skipping validation of the access modes of PVC %s/%s. err: %v", pvc.Namespace, pvc.Name, err)
+		return ""
+	}
+	targetDatastoreURLs := vc.Config.TargetvSANFileShareDatastoreURLs
+	if len(targetDatastoreURLs) == 0 {
+		vsanDatastores, err := vc.GetVsanDatastores(ctx)
+		if err != nil {
+			log.Warnf("skipping validation of the access modes of PVC %s/%s. failed to get vSAN datastores. err: %v",
+				pvc.Namespace, pvc.Name, err)
+			return ""
+		}
+		for datastoreURL := range vsanDatastores {
+			targetDatastoreURLs = append(targetDatastoreURLs, datastoreURL)
+		}
+	}
+	if len(targetDatastoreURLs) == 0 {
+		return rwxFileServiceErrorMessage
+	}
+	dsToFileServiceEnabledMap, err := common.IsFileServiceEnabled(ctx, targetDatastoreURLs, vc)
+	if err != nil {
+		log.Warnf("skipping validation of the access modes of PVC %s/%s. file service enablement check failed. err: %v",
+			pvc.Namespace, pvc.Name, err)
+		return ""
+	}
+	for _, enabled := range dsToFileServiceEnabledMap {
+		if enabled {
+			return ""
+		}
+	}
+	return rwxFileServiceErrorMessage
+}
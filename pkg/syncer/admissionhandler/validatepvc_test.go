@@ -0,0 +1,164 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admissionhandler
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/pkg/csi/types"
+)
+
+func newTestPVC(volumeName string, size string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "default"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			VolumeName: volumeName,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+			},
+		},
+	}
+}
+
+func newTestPV(name string, diskType string) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{
+					Driver:           csitypes.Name,
+					VolumeHandle:     name,
+					VolumeAttributes: map[string]string{common.AttributeDiskType: diskType},
+				},
+			},
+		},
+	}
+}
+
+func newTestVolumeAttachment(name string, pvName string, attached bool) *storagev1.VolumeAttachment {
+	return &storagev1.VolumeAttachment{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: storagev1.VolumeAttachmentSpec{
+			Attacher: csitypes.Name,
+			NodeName: "node-1",
+			Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName},
+		},
+		Status: storagev1.VolumeAttachmentStatus{Attached: attached},
+	}
+}
+
+// TestValidatePVCResize is the unit test for validating the expansion of PVCs
+func TestValidatePVCResize(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	attachedPV := "attached-block-pv"
+	k8sClient = fake.NewSimpleClientset(
+		newTestPV("block-pv", common.DiskTypeBlockVolume),
+		newTestPV("file-pv", common.DiskTypeFileVolume),
+		newTestPV(attachedPV, common.DiskTypeBlockVolume),
+	)
+	defer func() { k8sClient = nil }()
+	volumeAttachmentIndexer = cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{volumeAttachmentPVNameIndex: volumeAttachmentPVName})
+	defer func() { volumeAttachmentIndexer = nil }()
+	for _, va := range []*storagev1.VolumeAttachment{
+		newTestVolumeAttachment("va-attached", attachedPV, true),
+		newTestVolumeAttachment("va-detached", "block-pv", false),
+	} {
+		if err := volumeAttachmentIndexer.Add(va); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		oldPVC   *v1.PersistentVolumeClaim
+		newPVC   *v1.PersistentVolumeClaim
+		expected string
+	}{
+		{newTestPVC("block-pv", "1Gi"), newTestPVC("block-pv", "1Gi"), ""},
+		{newTestPVC("block-pv", "1Gi"), newTestPVC("block-pv", "2Gi"), ""},
+		{newTestPVC("block-pv", "2Gi"), newTestPVC("block-pv", "1Gi"), ""},
+		{newTestPVC("file-pv", "1Gi"), newTestPVC("file-pv", "2Gi"), fileVolumeExpandErrorMessage},
+		{newTestPVC(attachedPV, "1Gi"), newTestPVC(attachedPV, "2Gi"), onlineExpandErrorMessage},
+	}
+	for _, test := range tests {
+		reason := validatePVCResize(ctx, test.oldPVC, test.newPVC)
+		if (test.expected == "") != (reason == "") || !strings.HasPrefix(reason, test.expected) {
+			t.Errorf("expected reason %q for resize of %s from %v to %v, got %q", test.expected, test.newPVC.Spec.VolumeName,
+				test.oldPVC.Spec.Resources.Requests, test.newPVC.Spec.Resources.Requests, reason)
+		}
+	}
+}
+
+// TestValidatePVCAccessModes is the unit test for validating the access modes of PVCs
+func TestValidatePVCAccessModes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vc, cleanup := newTestVirtualCenter(ctx, t)
+	defer cleanup()
+	defer func(f func(ctx context.Context) (*cnsvsphere.VirtualCenter, error)) { getVirtualCenter = f }(getVirtualCenter)
+	getVirtualCenter = func(ctx context.Context) (*cnsvsphere.VirtualCenter, error) {
+		return vc, nil
+	}
+	k8sClient = fake.NewSimpleClientset(
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "csi-sc"}, Provisioner: csitypes.Name},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "in-tree-sc"}, Provisioner: "kubernetes.io/vsphere-volume"},
+	)
+	defer func() { k8sClient = nil }()
+	defer func(flavor cnstypes.CnsClusterFlavor) { clusterFlavor = flavor }(clusterFlavor)
+
+	blockMode := v1.PersistentVolumeBlock
+	tests := []struct {
+		name             string
+		flavor           cnstypes.CnsClusterFlavor
+		storageClassName string
+		accessMode       v1.PersistentVolumeAccessMode
+		volumeMode       *v1.PersistentVolumeMode
+		expected         string
+	}{
+		{"RWO", cnstypes.CnsClusterFlavorVanilla, "csi-sc", v1.ReadWriteOnce, nil, ""},
+		{"RWX in supervisor cluster", cnstypes.CnsClusterFlavorWorkload, "csi-sc", v1.ReadWriteMany, nil, ""},
+		{"RWX with in-tree StorageClass", cnstypes.CnsClusterFlavorVanilla, "in-tree-sc", v1.ReadWriteMany, nil, ""},
+		{"RWX with unknown StorageClass", cnstypes.CnsClusterFlavorVanilla, "unknown-sc", v1.ReadWriteMany, nil, ""},
+		{"RWX block", cnstypes.CnsClusterFlavorVanilla, "csi-sc", v1.ReadWriteMany, &blockMode, rwxBlockErrorMessage},
+		// vcsim has no vSAN datastore
+		{"RWX without vSAN file services", cnstypes.CnsClusterFlavorVanilla, "csi-sc", v1.ReadWriteMany, nil,
+			rwxFileServiceErrorMessage},
+	}
+	for _, test := range tests {
+		clusterFlavor = test.flavor
+		pvc := newTestPVC("", "1Gi")
+		pvc.Spec.StorageClassName = &test.storageClassName
+		pvc.Spec.AccessModes = []v1.PersistentVolumeAccessMode{test.accessMode}
+		pvc.Spec.VolumeMode = test.volumeMode
+		if reason := validatePVCAccessModes(ctx, pvc); reason != test.expected {
+			t.Errorf("%s: expected reason %q, got %q", test.name, test.expected, reason)
+		}
+	}
+}
@@ -19,13 +19,22 @@ package admissionhandler
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	pbmtypes "github.com/vmware/govmomi/pbm/types"
 	admissionv1 "k8s.io/api/admission/v1"
 	stroagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/vsphere"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/syncer/types"
 )
 
 var (
@@ -39,17 +48,112 @@ var (
 		common.ObjectspacereservationMigrationParam: struct{}{},
 		common.IopslimitMigrationParam:              struct{}{},
 	}
+	supportedFsTypes = parameterSet{
+		common.Ext4FsType:  struct{}{},
+		ext3FsType:         struct{}{},
+		common.NfsV4FsType: struct{}{},
+		common.NfsFsType:   struct{}{},
+	}
+
+	// getVirtualCenter returns the vCenter the parameters of CSI StorageClasses are validated against
+	getVirtualCenter = func(ctx context.Context) (*cnsvsphere.VirtualCenter, error) {
+		cfg, err := common.GetConfig(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return types.GetVirtualCenterInstance(ctx, &types.ConfigInfo{Cfg: cfg}, false)
+	}
 )
 
 const (
 	volumeExpansionErrorMessage = "AllowVolumeExpansion can not be set to true on the in-tree vSphere StorageClass"
 	migrationParamErrorMessage  = "Invalid StorageClass Parameters. Migration specific parameters should not be used in the StorageClass"
+	invalidParamErrorMessage    = "Invalid StorageClass Parameters"
+
+	ext3FsType = "ext3"
+	// csiParamPrefix is the prefix of the StorageClass parameters reserved for the CSI sidecars,
+	// e.g. csi.storage.k8s.io/fstype or csi.storage.k8s.io/provisioner-secret-name
+	csiParamPrefix = "csi.storage.k8s.io/"
+	csiFsTypeParam = csiParamPrefix + "fstype"
+
+	// vCenterLookupTimeout bounds the vCenter lookups of an admission request. It is shorter than the
+	// timeoutSeconds of the webhook, so that the StorageClass is admitted rather than rejected by the
+	// failurePolicy when vCenter is slow or unreachable.
+	vCenterLookupTimeout = 5 * time.Second
+	// vCenterLookupCacheTTL is how long the storage policies and datastores found in vCenter are cached
+	vCenterLookupCacheTTL = 5 * time.Minute
 )
 
+// vCenterLookupCache caches the storage policies and the datastores accessible from the nodes found in
+// vCenter, so that admission requests don't query vCenter and every node VM each time. Only the lookups
+// which found a storage policy or an accessible datastore are served from the cache, others query vCenter
+// again so that a policy or a datastore which was just created is not rejected.
+type vCenterLookupCache struct {
+	lock sync.Mutex
+	// host is the vCenter the cached storage policies and datastores were found in
+	host string
+	// storagePolicies holds the names of the storage policies
+	storagePolicies           map[string]bool
+	storagePoliciesExpiration time.Time
+	// accessibleDatastores holds the URLs of the datastores accessible from at least one node
+	accessibleDatastores           map[string]bool
+	accessibleDatastoresExpiration time.Time
+}
+
+var lookupCache = &vCenterLookupCache{}
+
+// hasStoragePolicy returns true if the storage policy with the given name of the given vCenter is cached
+func (c *vCenterLookupCache) hasStoragePolicy(host string, storagePolicyName string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.host == host && time.Now().Before(c.storagePoliciesExpiration) && c.storagePolicies[storagePolicyName]
+}
+
+// resetIfHostChanged drops the cached lookups if they were made in another vCenter than the given one.
+// It must be called with the lock held.
+func (c *vCenterLookupCache) resetIfHostChanged(host string) {
+	if c.host == host {
+		return
+	}
+	c.host = host
+	c.storagePolicies, c.storagePoliciesExpiration = nil, time.Time{}
+	c.accessibleDatastores, c.accessibleDatastoresExpiration = nil, time.Time{}
+}
+
+// setStoragePolicies caches the names of the storage policies of the given vCenter
+func (c *vCenterLookupCache) setStoragePolicies(host string, storagePolicies map[string]bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.resetIfHostChanged(host)
+	c.storagePolicies = storagePolicies
+	c.storagePoliciesExpiration = time.Now().Add(vCenterLookupCacheTTL)
+}
+
+// isDatastoreAccessible returns true if the datastore with the given URL of the given vCenter is cached
+// as accessible from the nodes
+func (c *vCenterLookupCache) isDatastoreAccessible(host string, datastoreURL string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.host == host && time.Now().Before(c.accessibleDatastoresExpiration) && c.accessibleDatastores[datastoreURL]
+}
+
+// setAccessibleDatastores caches the URLs of the datastores of the given vCenter accessible from the nodes
+func (c *vCenterLookupCache) setAccessibleDatastores(host string, accessibleDatastores map[string]bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.resetIfHostChanged(host)
+	c.accessibleDatastores = accessibleDatastores
+	c.accessibleDatastoresExpiration = time.Now().Add(vCenterLookupCacheTTL)
+}
+
 // validateStorageClass helps validate AdmissionReview requests for StroageClass
 func validateStorageClass(ctx context.Context, ar *admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
-	if containerOrchestratorUtility != nil && !containerOrchestratorUtility.IsFSSEnabled(ctx, common.CSIMigration) {
-		// if CSI migration is disabled and webhook is running
+	migrationEnabled := containerOrchestratorUtility == nil ||
+		containerOrchestratorUtility.IsFSSEnabled(ctx, common.CSIMigration)
+	paramValidationEnabled := containerOrchestratorUtility == nil ||
+		containerOrchestratorUtility.IsFSSEnabled(ctx, common.CSIStorageClassValidation)
+	if !migrationEnabled && !paramValidationEnabled {
+		// if CSI migration and StorageClass validation are disabled and webhook is running
 		// skip validation for StorageClass
 		return &admissionv1.AdmissionResponse{
 			Allowed: true,
@@ -75,7 +179,7 @@ func validateStorageClass(ctx context.Context, ar *admissionv1.AdmissionReview)
 		log.Infof("Validating StorageClass: %q", sc.Name)
 		// AllowVolumeExpansion check for kubernetes.io/vsphere-volume provisioner
 		if sc.Provisioner == "kubernetes.io/vsphere-volume" {
-			if sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion {
+			if migrationEnabled && sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion {
 				allowed = false
 				result = &metav1.Status{
 					Reason: volumeExpansionErrorMessage,
@@ -83,13 +187,23 @@ func validateStorageClass(ctx context.Context, ar *admissionv1.AdmissionReview)
 			}
 		} else if sc.Provisioner == "csi.vsphere.vmware.com" {
 			// Migration parameters check for csi.vsphere.vmware.com provisioner
-			for param := range sc.Parameters {
-				if unSupportedParameters.Has(param) {
+			if migrationEnabled {
+				for param := range sc.Parameters {
+					if unSupportedParameters.Has(param) {
+						allowed = false
+						result = &metav1.Status{
+							Reason: migrationParamErrorMessage,
+						}
+						break
+					}
+				}
+			}
+			if allowed && paramValidationEnabled {
+				if reason := validateCSIStorageClassParams(ctx, sc.Parameters); reason != "" {
 					allowed = false
 					result = &metav1.Status{
-						Reason: migrationParamErrorMessage,
+						Reason: metav1.StatusReason(reason),
 					}
-					break
 				}
 			}
 		}
@@ -108,3 +222,165 @@ func validateStorageClass(ctx context.Context, ar *admissionv1.AdmissionReview)
 		Result:  result,
 	}
 }
+
+// validateCSIStorageClassParams validates the parameters of a csi.vsphere.vmware.com StorageClass
+// and returns the reason of the rejection, or an empty string if the parameters are valid.
+// The storage policy and the datastore are looked up in vCenter, the lookup is skipped if
+// vCenter or the nodes of the cluster can't be queried within vCenterLookupTimeout so that
+// StorageClasses can still be applied.
+func validateCSIStorageClassParams(ctx context.Context, params map[string]string) string {
+	log := logger.GetLogger(ctx)
+	var storagePolicyName, datastoreURL string
+	paramNames := make([]string, 0, len(params))
+	for param := range params {
+		paramNames = append(paramNames, param)
+	}
+	sort.Strings(paramNames)
+	for _, param := range paramNames {
+		value := params[param]
+		switch name := strings.ToLower(param); {
+		case name == common.AttributeStoragePolicyName:
+			storagePolicyName = value
+		case name == common.AttributeDatastoreURL:
+			datastoreURL = value
+		case name == common.AttributeFsType || name == csiFsTypeParam:
+			if !supportedFsTypes.Has(strings.ToLower(value)) {
+				return fmt.Sprintf("%s. %s %q is not supported. Supported values are %s, %s, %s and %s",
+					invalidParamErrorMessage, param, value, common.Ext4FsType, ext3FsType, common.NfsV4FsType, common.NfsFsType)
+			}
+		case strings.HasPrefix(name, csiParamPrefix):
+			// parameters reserved for the CSI sidecars
+		default:
+			return fmt.Sprintf("%s. Unknown parameter %q. Supported parameters are %s, %s and %s", invalidParamErrorMessage,
+				param, common.AttributeStoragePolicyName, common.AttributeDatastoreURL, csiFsTypeParam)
+		}
+	}
+	if storagePolicyName == "" && datastoreURL == "" {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, vCenterLookupTimeout)
+	defer cancel()
+	vc, err := getVirtualCenter(ctx)
+	if err != nil {
+		log.Warnf("skipping validation of StorageClass parameters against vCenter. err: %v", err)
+		return ""
+	}
+	if storagePolicyName != "" {
+		found, err := storagePolicyExists(ctx, vc, storagePolicyName)
+		if err != nil {
+			log.Warnf("skipping validation of storage policy %q. err: %v", storagePolicyName, err)
+		} else if !found {
+			log.Errorf("storage policy %q is not found", storagePolicyName)
+			return fmt.Sprintf("%s. Storage policy %q is not found in vCenter %s",
+				invalidParamErrorMessage, storagePolicyName, vc.Config.Host)
+		}
+	}
+	if datastoreURL != "" {
+		if lookupCache.isDatastoreAccessible(vc.Config.Host, datastoreURL) {
+			return ""
+		}
+		datacenters, err := vc.GetDatacenters(ctx)
+		if err != nil {
+			log.Warnf("skipping validation of datastore %q. failed to get datacenters. err: %v", datastoreURL, err)
+			return ""
+		}
+		found := false
+		for _, dc := range datacenters {
+			datastores, err := dc.GetAllDatastores(ctx)
+			if err != nil {
+				log.Warnf("skipping validation of datastore %q. failed to get datastores of datacenter %s. err: %v",
+					datastoreURL, dc.InventoryPath, err)
+				return ""
+			}
+			if _, found = datastores[datastoreURL]; found {
+				break
+			}
+		}
+		if !found {
+			log.Errorf("datastore %q is not found in the datacenters of the cluster", datastoreURL)
+			return fmt.Sprintf("%s. Datastore %q is not found in the datacenters of vCenter %s configured for the cluster",
+				invalidParamErrorMessage, datastoreURL, vc.Config.Host)
+		}
+		accessibleDatastores, err := getNodeAccessibleDatastores(ctx, datacenters)
+		if err != nil {
+			log.Warnf("skipping validation of the accessibility of datastore %q. err: %v", datastoreURL, err)
+			return ""
+		}
+		lookupCache.setAccessibleDatastores(vc.Config.Host, accessibleDatastores)
+		if !accessibleDatastores[datastoreURL] {
+			log.Errorf("datastore %q is not accessible from the nodes of the cluster", datastoreURL)
+			return fmt.Sprintf("%s. Datastore %q is not accessible from any node of the cluster",
+				invalidParamErrorMessage, datastoreURL)
+		}
+	}
+	return ""
+}
+
+// storagePolicyExists returns true if a storage policy with the given name exists in vCenter.
+// Unlike ProfileIDByName, a policy which is not found is told apart from a failed lookup.
+func storagePolicyExists(ctx context.Context, vc *cnsvsphere.VirtualCenter, storagePolicyName string) (bool, error) {
+	if lookupCache.hasStoragePolicy(vc.Config.Host, storagePolicyName) {
+		return true, nil
+	}
+	if err := vc.ConnectPbm(ctx); err != nil {
+		return false, err
+	}
+	profileIds, err := vc.PbmClient.QueryProfile(ctx, pbmtypes.PbmProfileResourceType{
+		ResourceType: string(pbmtypes.PbmProfileResourceTypeEnumSTORAGE),
+	}, string(pbmtypes.PbmProfileCategoryEnumREQUIREMENT))
+	if err != nil {
+		return false, err
+	}
+	profiles, err := vc.PbmClient.RetrieveContent(ctx, profileIds)
+	if err != nil {
+		return false, err
+	}
+	storagePolicies := make(map[string]bool)
+	for _, profile := range profiles {
+		storagePolicies[profile.GetPbmProfile().Name] = true
+	}
+	lookupCache.setStoragePolicies(vc.Config.Host, storagePolicies)
+	return storagePolicies[storagePolicyName], nil
+}
+
+// getNodeAccessibleDatastores returns the URLs of the datastores accessible from at least one node of the
+// cluster. A datastore only accessible from some nodes is accepted, as volumes provisioned with topology are
+// only placed on the datastores accessible from the nodes of a zone.
+// An error is returned if none of the nodes is found in the given datacenters.
+func getNodeAccessibleDatastores(ctx context.Context, datacenters []*cnsvsphere.Datacenter) (map[string]bool, error) {
+	if k8sClient == nil {
+		return nil, errors.New("kubernetes client is not initialized")
+	}
+	nodes, err := k8sClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	accessibleDatastores := make(map[string]bool)
+	nodeVMsFound := 0
+	for _, node := range nodes.Items {
+		nodeUUID := common.GetUUIDFromProviderID(node.Spec.ProviderID)
+		if nodeUUID == "" {
+			continue
+		}
+		for _, dc := range datacenters {
+			vm, err := dc.GetVirtualMachineByUUID(ctx, nodeUUID, false)
+			if err != nil {
+				continue
+			}
+			nodeVMsFound++
+			datastores, err := vm.GetAllAccessibleDatastores(ctx)
+			if err != nil {
+				return nil, err
+			}
+			for _, ds := range datastores {
+				accessibleDatastores[ds.Info.Url] = true
+			}
+			break
+		}
+	}
+	if nodeVMsFound == 0 {
+		return nil, fmt.Errorf("none of the %d nodes of the cluster is found in vCenter", len(nodes.Items))
+	}
+	return accessibleDatastores, nil
+}
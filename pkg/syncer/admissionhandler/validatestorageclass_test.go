@@ -18,12 +18,22 @@ package admissionhandler
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"strconv"
 	"strings"
 	"testing"
 
+	"github.com/vmware/govmomi/find"
+	pbmsim "github.com/vmware/govmomi/pbm/simulator"
+	"github.com/vmware/govmomi/simulator"
 	v1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/vsphere"
 )
 
 var admissionReview = v1.AdmissionReview{
@@ -78,3 +88,172 @@ func TestValidateStorageClassForValidStorageClass(t *testing.T) {
 	}
 	t.Log("TestValidateStorageClassForValidStorageClass Passed")
 }
+
+// TestValidateCSIStorageClassParams is the unit test for validating the parameters of CSI StorageClasses
+func TestValidateCSIStorageClassParams(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// vCenter is unreachable, the storage policy and datastore are not looked up
+	defer func(f func(ctx context.Context) (*cnsvsphere.VirtualCenter, error)) { getVirtualCenter = f }(getVirtualCenter)
+	getVirtualCenter = func(ctx context.Context) (*cnsvsphere.VirtualCenter, error) {
+		return nil, errors.New("vCenter is unreachable")
+	}
+	tests := []struct {
+		params  map[string]string
+		allowed bool
+	}{
+		{map[string]string{"storagepolicyname": "vSAN Default Storage Policy", "csi.storage.k8s.io/fstype": "ext4"}, true},
+		{map[string]string{"datastoreURL": "ds:///vmfs/volumes/vsan:52d8eb4842dbf493-41523be9cd4ff7b7/", "fstype": "NFS4"}, true},
+		{map[string]string{"csi.storage.k8s.io/provisioner-secret-name": "secret"}, true},
+		{map[string]string{"storagepolicy": "vSAN Default Storage Policy"}, false},
+		{map[string]string{"csi.storage.k8s.io/fstype": "xfs"}, false},
+		{map[string]string{"datastore-migrationparam": "vsanDatastore"}, false},
+	}
+	for _, test := range tests {
+		reason := validateCSIStorageClassParams(ctx, test.params)
+		if test.allowed != (reason == "") {
+			t.Errorf("unexpected validation result for parameters %v: %q", test.params, reason)
+		} else if !test.allowed && !strings.HasPrefix(reason, invalidParamErrorMessage) {
+			t.Errorf("unexpected rejection reason for parameters %v: %q", test.params, reason)
+		}
+	}
+}
+
+// TestValidateCSIStorageClassParamsWithVC is the unit test for validating the storage policy and the datastore
+// of CSI StorageClasses against vCenter
+func TestValidateCSIStorageClassParamsWithVC(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vc, cleanup := newTestVirtualCenter(ctx, t)
+	defer cleanup()
+	defer func(f func(ctx context.Context) (*cnsvsphere.VirtualCenter, error)) { getVirtualCenter = f }(getVirtualCenter)
+	getVirtualCenter = func(ctx context.Context) (*cnsvsphere.VirtualCenter, error) {
+		return vc, nil
+	}
+
+	// The node VM runs on a standalone host, the local datastore is only mounted on a host of the cluster
+	finder := find.NewFinder(vc.Client.Client, false)
+	dc, err := finder.DefaultDatacenter(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	finder.SetDatacenter(dc)
+	nodeVM, err := finder.VirtualMachine(ctx, "DC0_H0_VM0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeVMUUID := simulator.Map.Get(nodeVM.Reference()).(*simulator.VirtualMachine).Config.Uuid
+	sharedDatastore, err := finder.Datastore(ctx, "LocalDS_0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sharedDatastoreURL := simulator.Map.Get(sharedDatastore.Reference()).(*simulator.Datastore).Info.GetDatastoreInfo().Url
+	clusterHost, err := finder.HostSystem(ctx, "DC0_C0/DC0_C0_H0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostDatastoreSystem, err := clusterHost.ConfigManager().DatastoreSystem(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	localDatastorePath := t.TempDir()
+	if _, err = hostDatastoreSystem.CreateLocalDatastore(ctx, "local-ds", localDatastorePath); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		nodes   []runtime.Object
+		params  map[string]string
+		allowed bool
+	}{
+		{"existing policy and shared datastore", nodeObjects(nodeVMUUID),
+			map[string]string{"storagepolicyname": "vSAN Default Storage Policy", "datastoreurl": sharedDatastoreURL}, true},
+		{"unknown policy", nodeObjects(nodeVMUUID), map[string]string{"storagepolicyname": "unknown"}, false},
+		{"unknown datastore", nodeObjects(nodeVMUUID), map[string]string{"datastoreurl": "ds:///vmfs/volumes/unknown/"}, false},
+		{"datastore not accessible from the nodes", nodeObjects(nodeVMUUID),
+			map[string]string{"datastoreurl": localDatastorePath}, false},
+		{"node VMs not found", nodeObjects("00000000-0000-0000-0000-000000000000"),
+			map[string]string{"datastoreurl": localDatastorePath}, true},
+	}
+	defer func() { k8sClient = nil }()
+	defer func() { lookupCache = &vCenterLookupCache{} }()
+	for _, test := range tests {
+		lookupCache = &vCenterLookupCache{}
+		k8sClient = fake.NewSimpleClientset(test.nodes...)
+		reason := validateCSIStorageClassParams(ctx, test.params)
+		if test.allowed != (reason == "") {
+			t.Errorf("%s: unexpected validation result for parameters %v: %q", test.name, test.params, reason)
+		} else if !test.allowed && !strings.HasPrefix(reason, invalidParamErrorMessage) {
+			t.Errorf("%s: unexpected rejection reason for parameters %v: %q", test.name, test.params, reason)
+		}
+	}
+
+	// The storage policies and the datastores accessible from the nodes are cached, and served without
+	// querying vCenter or the nodes again
+	lookupCache = &vCenterLookupCache{}
+	k8sClient = fake.NewSimpleClientset(nodeObjects(nodeVMUUID)...)
+	params := map[string]string{"storagepolicyname": "vSAN Default Storage Policy", "datastoreurl": sharedDatastoreURL}
+	if reason := validateCSIStorageClassParams(ctx, params); reason != "" {
+		t.Fatalf("unexpected rejection of parameters %v: %q", params, reason)
+	}
+	if !lookupCache.hasStoragePolicy(vc.Config.Host, "vSAN Default Storage Policy") {
+		t.Errorf("storage policy %q is not cached", "vSAN Default Storage Policy")
+	}
+	if !lookupCache.isDatastoreAccessible(vc.Config.Host, sharedDatastoreURL) {
+		t.Errorf("datastore %q is not cached as accessible", sharedDatastoreURL)
+	}
+	if lookupCache.isDatastoreAccessible(vc.Config.Host, localDatastorePath) {
+		t.Errorf("datastore %q is cached as accessible", localDatastorePath)
+	}
+	if lookupCache.isDatastoreAccessible("other-vc", sharedDatastoreURL) {
+		t.Errorf("datastore %q is cached as accessible for another vCenter", sharedDatastoreURL)
+	}
+}
+
+// nodeObjects returns a k8s node for each of the given VM UUIDs
+func nodeObjects(vmUUIDs ...string) []runtime.Object {
+	var nodes []runtime.Object
+	for _, vmUUID := range vmUUIDs {
+		nodes = append(nodes, &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: vmUUID},
+			Spec:       corev1.NodeSpec{ProviderID: "vsphere://" + vmUUID},
+		})
+	}
+	return nodes
+}
+
+// newTestVirtualCenter returns a VirtualCenter connected to a vcsim instance with the PBM simulator
+// and a function to stop it
+func newTestVirtualCenter(ctx context.Context, t *testing.T) (*cnsvsphere.VirtualCenter, func()) {
+	model := simulator.VPX()
+	if err := model.Create(); err != nil {
+		t.Fatal(err)
+	}
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterSDK(pbmsim.New())
+	s := model.Service.NewServer()
+	cleanup := func() {
+		s.Close()
+		model.Remove()
+	}
+
+	password, _ := s.URL.User.Password()
+	port, err := strconv.Atoi(s.URL.Port())
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	vc := &cnsvsphere.VirtualCenter{Config: &cnsvsphere.VirtualCenterConfig{
+		Host:     s.URL.Hostname(),
+		Port:     port,
+		Username: s.URL.User.Username(),
+		Password: password,
+		Insecure: true,
+	}}
+	if err := vc.Connect(ctx); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	return vc, cleanup
+}
@@ -19,6 +19,7 @@ package syncer
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	cnstypes "github.com/vmware/govmomi/cns/types"
@@ -26,8 +27,10 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 
 	"sigs.k8s.io/vsphere-csi-driver/pkg/apis/migration"
+	volumes "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/volume"
 	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/vsphere"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/prometheus"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
 )
 
@@ -36,6 +39,15 @@ import (
 func csiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer) {
 	log := logger.GetLogger(ctx)
 	log.Infof("FullSync: start")
+	report := newFullSyncReport(isFullSyncDryRunEnabled(ctx))
+	defer publishFullSyncReport(ctx, metadataSyncer.fullSyncReportClient, report)
+	defer func() {
+		status := prometheus.PrometheusPassStatus
+		if report.status.Error != "" {
+			status = prometheus.PrometheusFailStatus
+		}
+		prometheus.FullSyncOpsHistVec.WithLabelValues(status).Observe(time.Since(report.status.StartTime.Time).Seconds())
+	}()
 	var migrationFeatureStateForFullSync bool
 	// Fetch CSI migration feature state once, before performing full sync operations
 	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla {
@@ -45,6 +57,7 @@ func csiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer) {
 	k8sPVs, err := getPVsInBoundAvailableOrReleased(ctx, metadataSyncer)
 	if err != nil {
 		log.Errorf("FullSync: Failed to get PVs from kubernetes. Err: %v", err)
+		report.recordError(err)
 		return
 	}
 
@@ -55,6 +68,7 @@ func csiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer) {
 		// In case if feature state switch is enabled after syncer is deployed, we need to initialize the volumeMigrationService
 		if err := initVolumeMigrationService(ctx, metadataSyncer); err != nil {
 			log.Errorf("FullSync: Failed to get migration service. Err: %v", err)
+			report.recordError(err)
 			return
 		}
 	}
@@ -68,11 +82,15 @@ func csiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer) {
 		} else if migrationFeatureStateForFullSync && pv.Spec.VsphereVolume != nil {
 			// For vSphere volumes, the migration service will register volumes in CNS.
 			migrationVolumeSpec := &migration.VolumeSpec{VolumePath: pv.Spec.VsphereVolume.VolumePath, StoragePolicyName: pv.Spec.VsphereVolume.StoragePolicyName}
-			volumeHandle, err := volumeMigrationService.GetVolumeID(ctx, migrationVolumeSpec)
+			volumeHandle, err := fullSyncGetMigratedVolumeID(ctx, migrationVolumeSpec, report.status.DryRun)
 			if err != nil {
 				log.Errorf("FullSync: Failed to get VolumeID from volumeMigrationService for migration VolumeSpec: %v with error %+v", migrationVolumeSpec, err)
+				report.recordError(err)
 				return
 			}
+			if volumeHandle == "" {
+				continue
+			}
 			k8sPVMap[volumeHandle] = ""
 		}
 	}
@@ -81,47 +99,49 @@ func csiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer) {
 	pvToPVCMap, pvcToPodMap, err := buildPVCMapPodMap(ctx, k8sPVs, metadataSyncer)
 	if err != nil {
 		log.Errorf("FullSync: Failed to build PVCMap and PodMap. Err: %v", err)
+		report.recordError(err)
 		return
 	}
 	log.Debugf("FullSync: pvToPVCMap %v", pvToPVCMap)
 	log.Debugf("FullSync: pvcToPodMap %v", pvcToPodMap)
 
-	//Call CNS QueryAll to get container volumes by cluster ID
-	queryFilter := cnstypes.CnsQueryFilter{
-		ContainerClusterIds: []string{
-			metadataSyncer.configInfo.Cfg.Global.ClusterID,
-		},
-	}
-	querySelection := cnstypes.CnsQuerySelection{}
-	queryAllResult, err := metadataSyncer.volumeManager.QueryAllVolume(ctx, queryFilter, querySelection)
+	volumeToK8sEntityMetadataMap, err := fullSyncGetK8sEntityMetadata(ctx, k8sPVs, pvToPVCMap, pvcToPodMap, metadataSyncer, migrationFeatureStateForFullSync, report.status.DryRun)
 	if err != nil {
-		log.Errorf("FullSync: failed to queryAllVolume with err %+v", err)
+		log.Errorf("FullSync: fullSyncGetK8sEntityMetadata failed with err %+v", err)
+		report.recordError(err)
 		return
 	}
-	// get map of "volume to EntityMetadata" in CNS and "volume to EntityMetadata" in kubernetes
-	volumeToCnsEntityMetadataMap, volumeToK8sEntityMetadataMap, err := fullSyncGetEntityMetadata(ctx, k8sPVs, queryAllResult.Volumes, pvToPVCMap, pvcToPodMap, metadataSyncer, migrationFeatureStateForFullSync)
+	// Page through container volumes of this cluster in CNS
+	cnsVolumeIds, volumeToCnsEntityMetadataMap, err := fullSyncGetCnsEntityMetadata(ctx, volumeToK8sEntityMetadataMap, metadataSyncer)
 	if err != nil {
-		log.Errorf("FullSync: fullSyncGetEntityMetadata failed with err %+v", err)
+		log.Errorf("FullSync: fullSyncGetCnsEntityMetadata failed with err %+v", err)
+		report.recordError(err)
 		return
 	}
 	log.Debugf("FullSync: pvToCnsEntityMetadataMap %+v \n pvToK8sEntityMetadataMap: %+v \n", spew.Sdump(volumeToCnsEntityMetadataMap), spew.Sdump(volumeToK8sEntityMetadataMap))
 	// Get specs for create and update volume calls
 	containerCluster := cnsvsphere.GetContainerCluster(metadataSyncer.configInfo.Cfg.Global.ClusterID, metadataSyncer.configInfo.Cfg.VirtualCenter[metadataSyncer.host].User, metadataSyncer.clusterFlavor, metadataSyncer.configInfo.Cfg.Global.ClusterDistribution)
-	createSpecArray, updateSpecArray := fullSyncGetVolumeSpecs(ctx, k8sPVs, volumeToCnsEntityMetadataMap, volumeToK8sEntityMetadataMap, containerCluster, metadataSyncer, migrationFeatureStateForFullSync)
-	volToBeDeleted, err := getVolumesToBeDeleted(ctx, queryAllResult.Volumes, k8sPVMap, metadataSyncer, migrationFeatureStateForFullSync)
+	createSpecArray, updateSpecArray := fullSyncGetVolumeSpecs(ctx, k8sPVs, volumeToCnsEntityMetadataMap, volumeToK8sEntityMetadataMap, containerCluster, metadataSyncer, migrationFeatureStateForFullSync, report.status.DryRun)
+	volToBeDeleted, err := getVolumesToBeDeleted(ctx, cnsVolumeIds, k8sPVMap, metadataSyncer, migrationFeatureStateForFullSync, report.status.DryRun)
 	if err != nil {
 		log.Errorf("FullSync: failed to get list of volumes to be deleted with err %+v", err)
+		report.recordError(err)
 		return
 	}
-	wg := sync.WaitGroup{}
-	wg.Add(3)
-	// Perform operations
-	go fullSyncCreateVolumes(ctx, createSpecArray, metadataSyncer, &wg, migrationFeatureStateForFullSync)
-	go fullSyncUpdateVolumes(ctx, updateSpecArray, metadataSyncer, &wg)
-	go fullSyncDeleteVolumes(ctx, volToBeDeleted, metadataSyncer, &wg, migrationFeatureStateForFullSync)
-	wg.Wait()
-
-	cleanupCnsMaps(k8sPVMap)
+	report.recordOperations(createSpecArray, updateSpecArray, volToBeDeleted)
+	if report.status.DryRun {
+		log.Infof("FullSync: dry run, skipping %d create, %d update and %d delete operations",
+			len(createSpecArray), len(updateSpecArray), len(volToBeDeleted))
+	} else {
+		wg := sync.WaitGroup{}
+		wg.Add(3)
+		// Perform operations
+		go fullSyncCreateVolumes(ctx, createSpecArray, metadataSyncer, &wg, migrationFeatureStateForFullSync, report)
+		go fullSyncUpdateVolumes(ctx, updateSpecArray, metadataSyncer, &wg, report)
+		go fullSyncDeleteVolumes(ctx, volToBeDeleted, metadataSyncer, &wg, migrationFeatureStateForFullSync, report)
+		wg.Wait()
+		cleanupCnsMaps(k8sPVMap)
+	}
 	log.Debugf("FullSync: cnsDeletionMap at end of cycle: %v", cnsDeletionMap)
 	log.Debugf("FullSync: cnsCreationMap at end of cycle: %v", cnsCreationMap)
 	log.Infof("FullSync: end")
@@ -130,10 +150,10 @@ func csiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer) {
 // fullSyncCreateVolumes create volumes with given array of createSpec
 // Before creating a volume, all current K8s volumes are retrieved
 // If the volume is successfully created, it is removed from cnsCreationMap
-func fullSyncCreateVolumes(ctx context.Context, createSpecArray []cnstypes.CnsVolumeCreateSpec, metadataSyncer *metadataSyncInformer, wg *sync.WaitGroup, migrationFeatureStateForFullSync bool) {
+func fullSyncCreateVolumes(ctx context.Context, createSpecArray []cnstypes.CnsVolumeCreateSpec, metadataSyncer *metadataSyncInformer, wg *sync.WaitGroup, migrationFeatureStateForFullSync bool, report *fullSyncReport) {
 	log := logger.GetLogger(ctx)
 	defer wg.Done()
-	currentK8sPVMap := make(map[string]bool)
+	currentK8sPVMap := make(map[string]*v1.PersistentVolume)
 	volumeOperationsLock.Lock()
 	defer volumeOperationsLock.Unlock()
 	// Get all K8s PVs
@@ -146,7 +166,7 @@ func fullSyncCreateVolumes(ctx context.Context, createSpecArray []cnstypes.CnsVo
 	// Create map for easy lookup
 	for _, pv := range currentK8sPV {
 		if pv.Spec.CSI != nil {
-			currentK8sPVMap[pv.Spec.CSI.VolumeHandle] = true
+			currentK8sPVMap[pv.Spec.CSI.VolumeHandle] = pv
 		} else if migrationFeatureStateForFullSync && pv.Spec.VsphereVolume != nil {
 			migrationVolumeSpec := &migration.VolumeSpec{VolumePath: pv.Spec.VsphereVolume.VolumePath, StoragePolicyName: pv.Spec.VsphereVolume.StoragePolicyName}
 			volumeHandle, err := volumeMigrationService.GetVolumeID(ctx, migrationVolumeSpec)
@@ -154,27 +174,30 @@ func fullSyncCreateVolumes(ctx context.Context, createSpecArray []cnstypes.CnsVo
 				log.Errorf("FullSync: Failed to get VolumeID from volumeMigrationService for migration VolumeSpec: %v with error %+v", migrationVolumeSpec, err)
 				return
 			}
-			currentK8sPVMap[volumeHandle] = true
+			currentK8sPVMap[volumeHandle] = pv
 		}
 	}
 	for _, createSpec := range createSpecArray {
 		// Create volume if present in currentK8sPVMap
-		var volumeID string
-		if createSpec.VolumeType == common.BlockVolumeType && createSpec.BackingObjectDetails != nil && createSpec.BackingObjectDetails.(*cnstypes.CnsBlockBackingDetails) != nil {
-			volumeID = createSpec.BackingObjectDetails.(*cnstypes.CnsBlockBackingDetails).BackingDiskId
-		} else if createSpec.VolumeType == common.FileVolumeType && createSpec.BackingObjectDetails != nil && createSpec.BackingObjectDetails.(*cnstypes.CnsVsanFileShareBackingDetails) != nil {
-			volumeID = createSpec.BackingObjectDetails.(*cnstypes.CnsVsanFileShareBackingDetails).BackingFileId
-		} else {
+		volumeID := getVolumeIDFromCreateSpec(createSpec)
+		if volumeID == "" {
 			log.Warnf("Skipping createSpec: %+v as VolumeType is not known or BackingObjectDetails is either nil or not typecastable  ", spew.Sdump(createSpec))
 			continue
 		}
-		if _, existsInK8s := currentK8sPVMap[volumeID]; existsInK8s {
+		if pv, existsInK8s := currentK8sPVMap[volumeID]; existsInK8s {
 			log.Debugf("FullSync: Calling CreateVolume for volume id: %q with createSpec %+v", volumeID, spew.Sdump(createSpec))
 			_, err := metadataSyncer.volumeManager.CreateVolume(ctx, &createSpec)
 			if err != nil {
 				log.Warnf("FullSync: Failed to create volume with the spec: %+v. Err: %+v", spew.Sdump(createSpec), err)
+				report.recordFailure(fullSyncOperationCreate, volumeID, err)
+				prometheus.FullSyncVolumeOpsCounterVec.WithLabelValues(prometheus.PrometheusFullSyncCreateOpType,
+					prometheus.PrometheusFailStatus).Inc()
 				continue
 			}
+			prometheus.FullSyncVolumeOpsCounterVec.WithLabelValues(prometheus.PrometheusFullSyncCreateOpType,
+				prometheus.PrometheusPassStatus).Inc()
+			metadataSyncer.eventRecorder.Eventf(pv, v1.EventTypeNormal, "VolumeRecreatedByFullSync",
+				"Volume %s was missing in CNS and has been re-created by full sync", volumeID)
 		} else {
 			log.Debugf("FullSync: volumeID %s does not exist in Kubernetes, no need to create volume in CNS", volumeID)
 		}
@@ -186,7 +209,7 @@ func fullSyncCreateVolumes(ctx context.Context, createSpecArray []cnstypes.CnsVo
 // fullSyncDeleteVolumes delete volumes with given array of volumeId
 // Before deleting a volume, all current K8s volumes are retrieved
 // If the volume is successfully deleted, it is removed from cnsDeletionMap
-func fullSyncDeleteVolumes(ctx context.Context, volumeIDDeleteArray []cnstypes.CnsVolumeId, metadataSyncer *metadataSyncInformer, wg *sync.WaitGroup, migrationFeatureStateForFullSync bool) {
+func fullSyncDeleteVolumes(ctx context.Context, volumeIDDeleteArray []cnstypes.CnsVolumeId, metadataSyncer *metadataSyncInformer, wg *sync.WaitGroup, migrationFeatureStateForFullSync bool, report *fullSyncReport) {
 	defer wg.Done()
 	log := logger.GetLogger(ctx)
 	deleteDisk := false
@@ -228,14 +251,15 @@ func fullSyncDeleteVolumes(ctx context.Context, volumeIDDeleteArray []cnstypes.C
 		log.Info("FullSync: fullSyncDeleteVolumes could not find any volume which is not present in k8s and needs to be checked for volume deletion.")
 		return
 	}
-	allQueryResults, err := fullSyncGetQueryResults(ctx, queryVolumeIds, "", metadataSyncer.volumeManager)
-	if err != nil {
-		log.Errorf("FullSync: fullSyncGetQueryResults failed to query volume metadata from vc. Err: %v", err)
-		return
-	}
-	// Verify if Volume is not in use by any other Cluster before removing CNS tag
-	for _, queryResult := range allQueryResults {
-		for _, volume := range queryResult.Volumes {
+	queryFilter := cnstypes.CnsQueryFilter{
+		VolumeIds: queryVolumeIds,
+	}
+	// Verify if Volume is not in use by any other Cluster before removing CNS tag.
+	// Volumes are deleted once all the pages are retrieved, as deleting them while paging
+	// shrinks the query result under the offset of the next page and skips volumes.
+	var unusedVolumeIds []string
+	err = volumes.ForEachVolumePage(ctx, metadataSyncer.volumeManager, queryFilter, queryVolumeLimit, func(cnsVolumes []cnstypes.CnsVolume) error {
+		for _, volume := range cnsVolumes {
 			inUsebyOtherK8SCluster := false
 			for _, metadata := range volume.Metadata.EntityMetadata {
 				if metadata.(*cnstypes.CnsKubernetesEntityMetadata).ClusterID != metadataSyncer.configInfo.Cfg.Global.ClusterID {
@@ -244,38 +268,60 @@ func fullSyncDeleteVolumes(ctx context.Context, volumeIDDeleteArray []cnstypes.C
 					break
 				}
 			}
-			if !inUsebyOtherK8SCluster {
-				log.Infof("FullSync: fullSyncDeleteVolumes: Calling DeleteVolume for volume %v with delete disk %v", volume.VolumeId.Id, deleteDisk)
-				err := metadataSyncer.volumeManager.DeleteVolume(ctx, volume.VolumeId.Id, deleteDisk)
-				if err != nil {
-					log.Warnf("FullSync: fullSyncDeleteVolumes: Failed to delete volume %s with error %+v", volume.VolumeId.Id, err)
-					continue
-				}
-				if migrationFeatureStateForFullSync {
-					err = volumeMigrationService.DeleteVolumeInfo(ctx, volume.VolumeId.Id)
-					// For non-migrated volumes DeleteVolumeInfo will not return error and
-					// So, the volume id will be deleted from cnsDeletionMap
-					if err != nil {
-						log.Warnf("FullSync: fullSyncDeleteVolumes: Failed to delete volume mapping CR for %s with error %+v", volume.VolumeId.Id, err)
-						continue
-					}
-				}
+			if inUsebyOtherK8SCluster {
+				delete(cnsDeletionMap, volume.VolumeId.Id)
+				continue
 			}
-			// delete volume from cnsDeletionMap which is successfully deleted from CNS
-			delete(cnsDeletionMap, volume.VolumeId.Id)
+			unusedVolumeIds = append(unusedVolumeIds, volume.VolumeId.Id)
 		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("FullSync: failed to query volume metadata from vc. Err: %v", err)
+		return
+	}
+	for _, volumeID := range unusedVolumeIds {
+		log.Infof("FullSync: fullSyncDeleteVolumes: Calling DeleteVolume for volume %v with delete disk %v", volumeID, deleteDisk)
+		err := metadataSyncer.volumeManager.DeleteVolume(ctx, volumeID, deleteDisk)
+		if err != nil {
+			log.Warnf("FullSync: fullSyncDeleteVolumes: Failed to delete volume %s with error %+v", volumeID, err)
+			report.recordFailure(fullSyncOperationDelete, volumeID, err)
+			prometheus.FullSyncVolumeOpsCounterVec.WithLabelValues(prometheus.PrometheusFullSyncDeleteOpType,
+				prometheus.PrometheusFailStatus).Inc()
+			continue
+		}
+		prometheus.FullSyncVolumeOpsCounterVec.WithLabelValues(prometheus.PrometheusFullSyncDeleteOpType,
+			prometheus.PrometheusPassStatus).Inc()
+		if migrationFeatureStateForFullSync {
+			err = volumeMigrationService.DeleteVolumeInfo(ctx, volumeID)
+			// For non-migrated volumes DeleteVolumeInfo will not return error and
+			// So, the volume id will be deleted from cnsDeletionMap
+			if err != nil {
+				log.Warnf("FullSync: fullSyncDeleteVolumes: Failed to delete volume mapping CR for %s with error %+v", volumeID, err)
+				report.recordFailure(fullSyncOperationDelete, volumeID, err)
+				continue
+			}
+		}
+		// delete volume from cnsDeletionMap which is successfully deleted from CNS
+		delete(cnsDeletionMap, volumeID)
 	}
 }
 
 // fullSyncUpdateVolumes update metadata for volumes with given array of createSpec
-func fullSyncUpdateVolumes(ctx context.Context, updateSpecArray []cnstypes.CnsVolumeMetadataUpdateSpec, metadataSyncer *metadataSyncInformer, wg *sync.WaitGroup) {
+func fullSyncUpdateVolumes(ctx context.Context, updateSpecArray []cnstypes.CnsVolumeMetadataUpdateSpec, metadataSyncer *metadataSyncInformer, wg *sync.WaitGroup, report *fullSyncReport) {
 	defer wg.Done()
 	log := logger.GetLogger(ctx)
 	for _, updateSpec := range updateSpecArray {
 		log.Debugf("FullSync: Calling UpdateVolumeMetadata for volume %s with updateSpec: %+v", updateSpec.VolumeId.Id, spew.Sdump(updateSpec))
 		if err := metadataSyncer.volumeManager.UpdateVolumeMetadata(ctx, &updateSpec); err != nil {
 			log.Warnf("FullSync:UpdateVolumeMetadata failed with err %v", err)
+			report.recordFailure(fullSyncOperationUpdate, updateSpec.VolumeId.Id, err)
+			prometheus.FullSyncVolumeOpsCounterVec.WithLabelValues(prometheus.PrometheusFullSyncUpdateOpType,
+				prometheus.PrometheusFailStatus).Inc()
+			continue
 		}
+		prometheus.FullSyncVolumeOpsCounterVec.WithLabelValues(prometheus.PrometheusFullSyncUpdateOpType,
+			prometheus.PrometheusPassStatus).Inc()
 	}
 }
 
@@ -307,19 +353,11 @@ func buildCnsMetadataList(ctx context.Context, pv *v1.PersistentVolume, pvToPVCM
 	return metadataList
 }
 
-// fullSyncGetEntityMetadata builds and return map of volume to EntityMetadata in CNS (volumeToCnsEntityMetadataMap)
-// and map of volume to EntityMetadata in kubernetes (volumeToK8sEntityMetadataMap)
-func fullSyncGetEntityMetadata(ctx context.Context, pvList []*v1.PersistentVolume, cnsVolumeList []cnstypes.CnsVolume, pvToPVCMap pvcMap, pvcToPodMap podMap, metadataSyncer *metadataSyncInformer, migrationFeatureStateForFullSync bool) (map[string][]cnstypes.BaseCnsEntityMetadata, map[string][]cnstypes.BaseCnsEntityMetadata, error) {
+// fullSyncGetK8sEntityMetadata builds and returns map of volume to EntityMetadata in kubernetes
+func fullSyncGetK8sEntityMetadata(ctx context.Context, pvList []*v1.PersistentVolume, pvToPVCMap pvcMap, pvcToPodMap podMap, metadataSyncer *metadataSyncInformer, migrationFeatureStateForFullSync bool, dryRun bool) (map[string][]cnstypes.BaseCnsEntityMetadata, error) {
 	log := logger.GetLogger(ctx)
-	volumeToCnsEntityMetadataMap := make(map[string][]cnstypes.BaseCnsEntityMetadata)
 	volumeToK8sEntityMetadataMap := make(map[string][]cnstypes.BaseCnsEntityMetadata)
-	cnsVolumeMap := make(map[string]bool)
-
-	for _, vol := range cnsVolumeList {
-		cnsVolumeMap[vol.VolumeId.Id] = true
-	}
 	var err error
-	var queryVolumeIds []cnstypes.CnsVolumeId
 	for _, pv := range pvList {
 		k8sMetadata := buildCnsMetadataList(ctx, pv, pvToPVCMap, pvcToPodMap, metadataSyncer.configInfo.Cfg.Global.ClusterID)
 		var volumeHandle string
@@ -327,10 +365,13 @@ func fullSyncGetEntityMetadata(ctx context.Context, pvList []*v1.PersistentVolum
 			volumeHandle = pv.Spec.CSI.VolumeHandle
 		} else if migrationFeatureStateForFullSync && pv.Spec.VsphereVolume != nil {
 			migrationVolumeSpec := &migration.VolumeSpec{VolumePath: pv.Spec.VsphereVolume.VolumePath, StoragePolicyName: pv.Spec.VsphereVolume.StoragePolicyName}
-			volumeHandle, err = volumeMigrationService.GetVolumeID(ctx, migrationVolumeSpec)
+			volumeHandle, err = fullSyncGetMigratedVolumeID(ctx, migrationVolumeSpec, dryRun)
 			if err != nil {
 				log.Errorf("FullSync: Failed to get VolumeID from volumeMigrationService for migration VolumeSpec: %v with error %+v", migrationVolumeSpec, err)
-				return nil, nil, err
+				return nil, err
+			}
+			if volumeHandle == "" {
+				continue
 			}
 		} else {
 			// Do nothing for other cases
@@ -341,42 +382,50 @@ func fullSyncGetEntityMetadata(ctx context.Context, pvList []*v1.PersistentVolum
 		} else {
 			volumeToK8sEntityMetadataMap[volumeHandle] = k8sMetadata
 		}
-		if cnsVolumeMap[volumeHandle] {
-			// PV exist in both K8S and CNS cache, add to queryVolumeIds list to check if metadata has been
-			// changed or not
-			queryVolumeIds = append(queryVolumeIds, cnstypes.CnsVolumeId{Id: volumeHandle})
-		}
-	}
-	// this check is needed to prevent querying all CNS volumes when queryFilter.VolumeIds does not have any volumes.
-	// volumes in the queryFilter.VolumeIds should be one which is present in both k8s and in CNS.
-	if len(queryVolumeIds) == 0 {
-		log.Warn("could not find any volume which is present in both k8s and in CNS")
-		return volumeToCnsEntityMetadataMap, volumeToK8sEntityMetadataMap, nil
-	}
-	allQueryResults, err := fullSyncGetQueryResults(ctx, queryVolumeIds, metadataSyncer.configInfo.Cfg.Global.ClusterID, metadataSyncer.volumeManager)
-	if err != nil {
-		log.Errorf("FullSync: fullSyncGetQueryResults failed to query volume metadata from vc. Err: %v", err)
-		return nil, nil, err
 	}
+	return volumeToK8sEntityMetadataMap, nil
+}
 
-	for _, queryResult := range allQueryResults {
-		for _, volume := range queryResult.Volumes {
+// fullSyncGetCnsEntityMetadata pages through the container volumes of this cluster in CNS and returns
+// the IDs of all these volumes along with a map of volume to EntityMetadata in CNS for the volumes
+// present in volumeToK8sEntityMetadataMap. Metadata of volumes not present in kubernetes is not retained,
+// which keeps memory usage bounded for very large inventories.
+func fullSyncGetCnsEntityMetadata(ctx context.Context, volumeToK8sEntityMetadataMap map[string][]cnstypes.BaseCnsEntityMetadata, metadataSyncer *metadataSyncInformer) ([]cnstypes.CnsVolumeId, map[string][]cnstypes.BaseCnsEntityMetadata, error) {
+	log := logger.GetLogger(ctx)
+	clusterID := metadataSyncer.configInfo.Cfg.Global.ClusterID
+	volumeToCnsEntityMetadataMap := make(map[string][]cnstypes.BaseCnsEntityMetadata)
+	var cnsVolumeIds []cnstypes.CnsVolumeId
+	queryFilter := cnstypes.CnsQueryFilter{
+		ContainerClusterIds: []string{clusterID},
+	}
+	err := volumes.ForEachVolumePage(ctx, metadataSyncer.volumeManager, queryFilter, queryVolumeLimit, func(cnsVolumes []cnstypes.CnsVolume) error {
+		for _, volume := range cnsVolumes {
+			cnsVolumeIds = append(cnsVolumeIds, volume.VolumeId)
+			if _, presentInK8S := volumeToK8sEntityMetadataMap[volume.VolumeId.Id]; !presentInK8S {
+				continue
+			}
+			// PV exist in both K8S and CNS, retain CNS metadata to check if metadata has been changed or not
 			var cnsMetadata []cnstypes.BaseCnsEntityMetadata
-			allEntityMetadata := volume.Metadata.EntityMetadata
-			for _, metadata := range allEntityMetadata {
-				if metadata.(*cnstypes.CnsKubernetesEntityMetadata).ClusterID == metadataSyncer.configInfo.Cfg.Global.ClusterID {
+			for _, metadata := range volume.Metadata.EntityMetadata {
+				if metadata.(*cnstypes.CnsKubernetesEntityMetadata).ClusterID == clusterID {
 					cnsMetadata = append(cnsMetadata, metadata)
 				}
 			}
 			volumeToCnsEntityMetadataMap[volume.VolumeId.Id] = cnsMetadata
 		}
+		log.Debugf("FullSync: retrieved %d volumes from CNS so far", len(cnsVolumeIds))
+		return nil
+	})
+	if err != nil {
+		log.Errorf("FullSync: failed to query volumes from CNS for cluster %q. Err: %v", clusterID, err)
+		return nil, nil, err
 	}
-	return volumeToCnsEntityMetadataMap, volumeToK8sEntityMetadataMap, nil
+	return cnsVolumeIds, volumeToCnsEntityMetadataMap, nil
 }
 
 // fullSyncGetVolumeSpecs return list of CnsVolumeCreateSpec for volumes which needs to be created in CNS and a list of
 // CnsVolumeMetadataUpdateSpec for volumes which needs to be updated in CNS.
-func fullSyncGetVolumeSpecs(ctx context.Context, pvList []*v1.PersistentVolume, volumeToCnsEntityMetadataMap map[string][]cnstypes.BaseCnsEntityMetadata, volumeToK8sEntityMetadataMap map[string][]cnstypes.BaseCnsEntityMetadata, containerCluster cnstypes.CnsContainerCluster, metadataSyncer *metadataSyncInformer, migrationFeatureStateForFullSync bool) ([]cnstypes.CnsVolumeCreateSpec, []cnstypes.CnsVolumeMetadataUpdateSpec) {
+func fullSyncGetVolumeSpecs(ctx context.Context, pvList []*v1.PersistentVolume, volumeToCnsEntityMetadataMap map[string][]cnstypes.BaseCnsEntityMetadata, volumeToK8sEntityMetadataMap map[string][]cnstypes.BaseCnsEntityMetadata, containerCluster cnstypes.CnsContainerCluster, metadataSyncer *metadataSyncInformer, migrationFeatureStateForFullSync bool, dryRun bool) ([]cnstypes.CnsVolumeCreateSpec, []cnstypes.CnsVolumeMetadataUpdateSpec) {
 	log := logger.GetLogger(ctx)
 	var createSpecArray []cnstypes.CnsVolumeCreateSpec
 	var updateSpecArray []cnstypes.CnsVolumeMetadataUpdateSpec
@@ -387,11 +436,14 @@ func fullSyncGetVolumeSpecs(ctx context.Context, pvList []*v1.PersistentVolume,
 		if pv.Spec.VsphereVolume != nil && migrationFeatureStateForFullSync {
 			var err error
 			migrationVolumeSpec := &migration.VolumeSpec{VolumePath: pv.Spec.VsphereVolume.VolumePath, StoragePolicyName: pv.Spec.VsphereVolume.StoragePolicyName}
-			volumeHandle, err = volumeMigrationService.GetVolumeID(ctx, migrationVolumeSpec)
+			volumeHandle, err = fullSyncGetMigratedVolumeID(ctx, migrationVolumeSpec, dryRun)
 			if err != nil {
 				log.Warnf("FullSync: Failed to get VolumeID from volumeMigrationService for migration VolumeSpec: %v with error %+v", migrationVolumeSpec, err)
 				continue
 			}
+			if volumeHandle == "" {
+				continue
+			}
 		} else {
 			volumeHandle = pv.Spec.CSI.VolumeHandle
 		}
@@ -407,6 +459,8 @@ func fullSyncGetVolumeSpecs(ctx context.Context, pvList []*v1.PersistentVolume,
 			if _, existsInCnsCreationMap := cnsCreationMap[volumeHandle]; existsInCnsCreationMap {
 				log.Infof("FullSync: create is required for volume: %q, as volume was present in cnsCreationMap across two full-sync cycles", volumeHandle)
 				operationType = "createVolume"
+			} else if dryRun {
+				log.Infof("FullSync: dry run, volume with id: %q and name: %q is not added to cnsCreationMap", volumeHandle, pv.Name)
 			} else {
 				log.Infof("FullSync: Volume with id: %q and name: %q is added to cnsCreationMap", volumeHandle, pv.Name)
 				cnsCreationMap[volumeHandle] = true
@@ -492,38 +546,41 @@ func fullSyncGetVolumeSpecs(ctx context.Context, pvList []*v1.PersistentVolume,
 
 // getVolumesToBeDeleted return list of volumeIds that need to be deleted
 // A volumeId is added to this list only if it was present in cnsDeletionMap across two cycles of full sync
-func getVolumesToBeDeleted(ctx context.Context, cnsVolumeList []cnstypes.CnsVolume, k8sPVMap map[string]string, metadataSyncer *metadataSyncInformer, migrationFeatureStateForFullSync bool) ([]cnstypes.CnsVolumeId, error) {
+// In dry run, cnsDeletionMap is left unchanged
+func getVolumesToBeDeleted(ctx context.Context, cnsVolumeIds []cnstypes.CnsVolumeId, k8sPVMap map[string]string, metadataSyncer *metadataSyncInformer, migrationFeatureStateForFullSync bool, dryRun bool) ([]cnstypes.CnsVolumeId, error) {
 	log := logger.GetLogger(ctx)
 	var volToBeDeleted []cnstypes.CnsVolumeId
 	// inlineVolumeMap holds the volume path information for migrated volumes which are used by Pods
 	inlineVolumeMap := make(map[string]string)
 	var err error
 	if migrationFeatureStateForFullSync {
-		inlineVolumeMap, err = fullSyncGetInlineMigratedVolumesInfo(ctx, metadataSyncer, migrationFeatureStateForFullSync)
+		inlineVolumeMap, err = fullSyncGetInlineMigratedVolumesInfo(ctx, metadataSyncer, migrationFeatureStateForFullSync, dryRun)
 		if err != nil {
 			log.Errorf("FullSync: Failed to get inline migrated volumes. Err: %v", err)
 			return volToBeDeleted, err
 		}
 	}
-	for _, vol := range cnsVolumeList {
-		if _, existsInK8s := k8sPVMap[vol.VolumeId.Id]; !existsInK8s {
-			if _, existsInCnsDeletionMap := cnsDeletionMap[vol.VolumeId.Id]; existsInCnsDeletionMap {
+	for _, volumeID := range cnsVolumeIds {
+		if _, existsInK8s := k8sPVMap[volumeID.Id]; !existsInK8s {
+			if _, existsInCnsDeletionMap := cnsDeletionMap[volumeID.Id]; existsInCnsDeletionMap {
 				// Volume does not exist in K8s across two fullsync cycles - add to delete list
-				log.Debugf("FullSync: Volume with id %s added to delete list as it was present in cnsDeletionMap across two fullsync cycles", vol.VolumeId.Id)
-				volToBeDeleted = append(volToBeDeleted, vol.VolumeId)
+				log.Debugf("FullSync: Volume with id %s added to delete list as it was present in cnsDeletionMap across two fullsync cycles", volumeID.Id)
+				volToBeDeleted = append(volToBeDeleted, volumeID)
+			} else if dryRun {
+				log.Debugf("FullSync: dry run, volume with id %s is not added to cnsDeletionMap", volumeID.Id)
 			} else {
 				// Add to cnsDeletionMap
 				if migrationFeatureStateForFullSync {
 					// If migration is ON, verify if the volume is present in inlineVolumeMap
-					if _, existsInInlineVolumeMap := inlineVolumeMap[vol.VolumeId.Id]; !existsInInlineVolumeMap {
-						log.Infof("FullSync: Volume with id %q added to cnsDeletionMap", vol.VolumeId.Id)
-						cnsDeletionMap[vol.VolumeId.Id] = true
+					if _, existsInInlineVolumeMap := inlineVolumeMap[volumeID.Id]; !existsInInlineVolumeMap {
+						log.Infof("FullSync: Volume with id %q added to cnsDeletionMap", volumeID.Id)
+						cnsDeletionMap[volumeID.Id] = true
 					} else {
-						log.Debugf("FullSync: Inline migrated volume with id %s is in use. Skipping for deletion", vol.VolumeId.Id)
+						log.Debugf("FullSync: Inline migrated volume with id %s is in use. Skipping for deletion", volumeID.Id)
 					}
 				} else {
-					log.Debugf("FullSync: Volume with id %s added to cnsDeletionMap", vol.VolumeId.Id)
-					cnsDeletionMap[vol.VolumeId.Id] = true
+					log.Debugf("FullSync: Volume with id %s added to cnsDeletionMap", volumeID.Id)
+					cnsDeletionMap[volumeID.Id] = true
 				}
 			}
 		}
@@ -531,6 +588,16 @@ func getVolumesToBeDeleted(ctx context.Context, cnsVolumeList []cnstypes.CnsVolu
 	return volToBeDeleted, nil
 }
 
+// fullSyncGetMigratedVolumeID returns the VolumeID of the given in-tree vSphere volume, registering it with CNS
+// if needed. In dry run, the volume is not registered and an empty VolumeID is returned if it is not registered yet.
+func fullSyncGetMigratedVolumeID(ctx context.Context, volumeSpec *migration.VolumeSpec, dryRun bool) (string, error) {
+	if dryRun {
+		volumeID, _ := volumeMigrationService.GetRegisteredVolumeID(ctx, volumeSpec.VolumePath)
+		return volumeID, nil
+	}
+	return volumeMigrationService.GetVolumeID(ctx, volumeSpec)
+}
+
 // buildPVCMapPodMap build two maps to help
 //  1. find PVC for given PV
 //  2. find POD mounted to given PVC
@@ -0,0 +1,200 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	fullsyncreportv1alpha1 "sigs.k8s.io/vsphere-csi-driver/pkg/apis/fullsyncreport/v1alpha1"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+	k8s "sigs.k8s.io/vsphere-csi-driver/pkg/kubernetes"
+)
+
+const (
+	// fullSyncReportCRDName represents the name of fullsyncreports CRD
+	fullSyncReportCRDName = "fullsyncreports.cns.vmware.com"
+	// fullSyncReportCRDSingular represents the singular name of fullsyncreports CRD
+	fullSyncReportCRDSingular = "fullsyncreport"
+	// fullSyncReportCRDPlural represents the plural name of fullsyncreports CRD
+	fullSyncReportCRDPlural = "fullsyncreports"
+	// maxFullSyncReports is the number of FullSyncReport instances retained in the cluster
+	maxFullSyncReports = 10
+	// maxFullSyncReportVolumeIDs caps the number of volume IDs recorded per operation to bound the CR size
+	maxFullSyncReportVolumeIDs = 1000
+
+	fullSyncOperationCreate = "create"
+	fullSyncOperationUpdate = "update"
+	fullSyncOperationDelete = "delete"
+)
+
+// fullSyncReport collects the outcome of a full sync cycle. All methods are safe
+// to call on a nil report, in which case nothing is recorded.
+type fullSyncReport struct {
+	lock   sync.Mutex
+	status fullsyncreportv1alpha1.FullSyncReportStatus
+}
+
+// newFullSyncReport returns a report for a full sync cycle starting now
+func newFullSyncReport(dryRun bool) *fullSyncReport {
+	return &fullSyncReport{
+		status: fullsyncreportv1alpha1.FullSyncReportStatus{
+			DryRun:    dryRun,
+			StartTime: metav1.Now(),
+		},
+	}
+}
+
+// recordError records the error which aborted the full sync cycle
+func (r *fullSyncReport) recordError(err error) {
+	if r == nil || err == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.status.Error = err.Error()
+}
+
+// recordOperations records the operations computed by the full sync cycle
+func (r *fullSyncReport) recordOperations(createSpecArray []cnstypes.CnsVolumeCreateSpec,
+	updateSpecArray []cnstypes.CnsVolumeMetadataUpdateSpec, volToBeDeleted []cnstypes.CnsVolumeId) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.status.CreateCount = len(createSpecArray)
+	r.status.UpdateCount = len(updateSpecArray)
+	r.status.DeleteCount = len(volToBeDeleted)
+	for _, createSpec := range createSpecArray {
+		if len(r.status.VolumesToCreate) < maxFullSyncReportVolumeIDs {
+			r.status.VolumesToCreate = append(r.status.VolumesToCreate, getVolumeIDFromCreateSpec(createSpec))
+		}
+	}
+	for _, updateSpec := range updateSpecArray {
+		if len(r.status.VolumesToUpdate) < maxFullSyncReportVolumeIDs {
+			r.status.VolumesToUpdate = append(r.status.VolumesToUpdate, updateSpec.VolumeId.Id)
+		}
+	}
+	for _, volumeID := range volToBeDeleted {
+		if len(r.status.VolumesToDelete) < maxFullSyncReportVolumeIDs {
+			r.status.VolumesToDelete = append(r.status.VolumesToDelete, volumeID.Id)
+		}
+	}
+}
+
+// recordFailure records a failed operation on the volume with the given ID
+func (r *fullSyncReport) recordFailure(operation string, volumeID string, err error) {
+	if r == nil || err == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if len(r.status.Failures) < maxFullSyncReportVolumeIDs {
+		r.status.Failures = append(r.status.Failures, fullsyncreportv1alpha1.FullSyncFailure{
+			Operation: operation,
+			VolumeID:  volumeID,
+			Error:     err.Error(),
+		})
+	}
+}
+
+// getVolumeIDFromCreateSpec returns the ID of the volume to be created with the given createSpec,
+// or an empty string if the VolumeType is not known or BackingObjectDetails is not set
+func getVolumeIDFromCreateSpec(createSpec cnstypes.CnsVolumeCreateSpec) string {
+	switch createSpec.VolumeType {
+	case common.BlockVolumeType:
+		if backingDetails, ok := createSpec.BackingObjectDetails.(*cnstypes.CnsBlockBackingDetails); ok && backingDetails != nil {
+			return backingDetails.BackingDiskId
+		}
+	case common.FileVolumeType:
+		if backingDetails, ok := createSpec.BackingObjectDetails.(*cnstypes.CnsVsanFileShareBackingDetails); ok && backingDetails != nil {
+			return backingDetails.BackingFileId
+		}
+	}
+	return ""
+}
+
+// initFullSyncReportClient creates the fullsyncreports CRD and returns a client to manage its instances
+func initFullSyncReportClient(ctx context.Context) (client.Client, error) {
+	log := logger.GetLogger(ctx)
+	err := k8s.CreateCustomResourceDefinitionFromSpec(ctx, fullSyncReportCRDName, fullSyncReportCRDSingular, fullSyncReportCRDPlural,
+		reflect.TypeOf(fullsyncreportv1alpha1.FullSyncReport{}).Name(), fullsyncreportv1alpha1.SchemeGroupVersion.Group,
+		fullsyncreportv1alpha1.SchemeGroupVersion.Version, apiextensionsv1beta1.ClusterScoped)
+	if err != nil {
+		log.Errorf("failed to create %q CRD. Err: %+v", fullSyncReportCRDName, err)
+		return nil, err
+	}
+	config, err := k8s.GetKubeConfig(ctx)
+	if err != nil {
+		log.Errorf("failed to get kubeconfig. Err: %+v", err)
+		return nil, err
+	}
+	return k8s.NewClientForGroup(ctx, config, fullsyncreportv1alpha1.SchemeGroupVersion.Group)
+}
+
+// publishFullSyncReport creates a FullSyncReport instance for the given report and removes
+// the oldest instances beyond maxFullSyncReports. Nothing is published if k8sClient is nil.
+func publishFullSyncReport(ctx context.Context, k8sClient client.Client, report *fullSyncReport) {
+	log := logger.GetLogger(ctx)
+	if k8sClient == nil || report == nil {
+		return
+	}
+	report.lock.Lock()
+	report.status.EndTime = metav1.Now()
+	fullSyncReport := &fullsyncreportv1alpha1.FullSyncReport{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fmt.Sprintf("fullsync-%d", report.status.StartTime.Unix()),
+		},
+		Status: *report.status.DeepCopy(),
+	}
+	report.lock.Unlock()
+	log.Infof("FullSync: dryRun: %t, volumes to create: %d, update: %d, delete: %d, failures: %d",
+		fullSyncReport.Status.DryRun, fullSyncReport.Status.CreateCount, fullSyncReport.Status.UpdateCount,
+		fullSyncReport.Status.DeleteCount, len(fullSyncReport.Status.Failures))
+	if err := k8sClient.Create(ctx, fullSyncReport); err != nil {
+		log.Errorf("FullSync: failed to create FullSyncReport %q. Err: %+v", fullSyncReport.Name, err)
+		return
+	}
+
+	fullSyncReportList := &fullsyncreportv1alpha1.FullSyncReportList{}
+	if err := k8sClient.List(ctx, fullSyncReportList); err != nil {
+		log.Warnf("FullSync: failed to list FullSyncReports. Err: %+v", err)
+		return
+	}
+	if len(fullSyncReportList.Items) <= maxFullSyncReports {
+		return
+	}
+	sort.Slice(fullSyncReportList.Items, func(i, j int) bool {
+		return fullSyncReportList.Items[i].Status.StartTime.Before(&fullSyncReportList.Items[j].Status.StartTime)
+	})
+	for i := 0; i < len(fullSyncReportList.Items)-maxFullSyncReports; i++ {
+		staleReport := &fullSyncReportList.Items[i]
+		if err := k8sClient.Delete(ctx, staleReport); err != nil {
+			log.Warnf("FullSync: failed to delete FullSyncReport %q. Err: %+v", staleReport.Name, err)
+		}
+	}
+}
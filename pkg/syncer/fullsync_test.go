@@ -0,0 +1,180 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	volumes "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/volume"
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/common/unittestcommon"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/pkg/csi/types"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/syncer/types"
+)
+
+// fakeVolumeManager is a Manager holding the volumes of a single cluster in memory. QueryVolume pages
+// through the volumes with the query cursor and DeleteVolume removes them, like CNS does.
+type fakeVolumeManager struct {
+	volumes.Manager
+	volumeIds      []string
+	deletedVolumes []string
+	// queryAllResult is returned by QueryAllVolume for queries which are not filtered by volume ID
+	queryAllResult *cnstypes.CnsQueryResult
+	// healthStatus is the health status of the volumes returned by QueryAllVolume by volume ID
+	healthStatus    map[string]string
+	queryAllFilters []cnstypes.CnsQueryFilter
+}
+
+func (m *fakeVolumeManager) QueryAllVolume(ctx context.Context, queryFilter cnstypes.CnsQueryFilter, querySelection cnstypes.CnsQuerySelection) (*cnstypes.CnsQueryResult, error) {
+	m.queryAllFilters = append(m.queryAllFilters, queryFilter)
+	if len(queryFilter.VolumeIds) == 0 {
+		return m.queryAllResult, nil
+	}
+	res := &cnstypes.CnsQueryResult{}
+	for _, volumeID := range queryFilter.VolumeIds {
+		res.Volumes = append(res.Volumes, cnstypes.CnsVolume{VolumeId: volumeID, HealthStatus: m.healthStatus[volumeID.Id]})
+	}
+	return res, nil
+}
+
+func (m *fakeVolumeManager) QueryVolume(ctx context.Context, queryFilter cnstypes.CnsQueryFilter) (*cnstypes.CnsQueryResult, error) {
+	var matching []string
+	for _, volumeID := range m.volumeIds {
+		for _, filterID := range queryFilter.VolumeIds {
+			if filterID.Id == volumeID {
+				matching = append(matching, volumeID)
+			}
+		}
+	}
+	res := &cnstypes.CnsQueryResult{}
+	offset := queryFilter.Cursor.Offset
+	for i := offset; i < int64(len(matching)) && i < offset+queryFilter.Cursor.Limit; i++ {
+		res.Volumes = append(res.Volumes, cnstypes.CnsVolume{
+			VolumeId: cnstypes.CnsVolumeId{Id: matching[i]},
+			Metadata: cnstypes.CnsVolumeMetadata{EntityMetadata: []cnstypes.BaseCnsEntityMetadata{
+				&cnstypes.CnsKubernetesEntityMetadata{CnsEntityMetadata: cnstypes.CnsEntityMetadata{ClusterID: testClusterName}},
+			}},
+		})
+	}
+	res.Cursor = cnstypes.CnsCursor{
+		Offset:       offset + int64(len(res.Volumes)),
+		Limit:        queryFilter.Cursor.Limit,
+		TotalRecords: int64(len(matching)),
+	}
+	return res, nil
+}
+
+func (m *fakeVolumeManager) DeleteVolume(ctx context.Context, volumeID string, deleteDisk bool) error {
+	for i, id := range m.volumeIds {
+		if id == volumeID {
+			m.volumeIds = append(m.volumeIds[:i], m.volumeIds[i+1:]...)
+			m.deletedVolumes = append(m.deletedVolumes, volumeID)
+			return nil
+		}
+	}
+	return fmt.Errorf("volume %q not found", volumeID)
+}
+
+// TestFullSyncDeleteVolumesAcrossPages verifies that all the stale volumes are deleted
+// when they span several pages of the CNS query.
+func TestFullSyncDeleteVolumesAcrossPages(t *testing.T) {
+	ctx := context.Background()
+	coCommonInterface, err := unittestcommon.GetFakeContainerOrchestratorInterface(common.Kubernetes)
+	if err != nil {
+		t.Fatalf("failed to create co agnostic interface. err: %v", err)
+	}
+	volumeManager := &fakeVolumeManager{}
+	if cnsDeletionMap == nil {
+		cnsDeletionMap = make(map[string]bool)
+	}
+	var volumeIDDeleteArray []cnstypes.CnsVolumeId
+	totalVolumes := int(2*queryVolumeLimit + 10)
+	for i := 0; i < totalVolumes; i++ {
+		volumeID := fmt.Sprintf("stale-volume-%d", i)
+		volumeManager.volumeIds = append(volumeManager.volumeIds, volumeID)
+		volumeIDDeleteArray = append(volumeIDDeleteArray, cnstypes.CnsVolumeId{Id: volumeID})
+		cnsDeletionMap[volumeID] = true
+	}
+	cfg := &cnsconfig.Config{}
+	cfg.Global.ClusterID = testClusterName
+	metadataSyncer := &metadataSyncInformer{
+		volumeManager:     volumeManager,
+		configInfo:        &types.ConfigInfo{Cfg: cfg},
+		pvLister:          corelisters.NewPersistentVolumeLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+		coCommonInterface: coCommonInterface,
+	}
+	report := newFullSyncReport(false)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	fullSyncDeleteVolumes(ctx, volumeIDDeleteArray, metadataSyncer, &wg, false, report)
+
+	if len(volumeManager.deletedVolumes) != totalVolumes {
+		t.Errorf("expected %d volumes to be deleted, got %d", totalVolumes, len(volumeManager.deletedVolumes))
+	}
+	if len(volumeManager.volumeIds) != 0 {
+		t.Errorf("expected no volume left in CNS, got %v", volumeManager.volumeIds)
+	}
+	for _, volumeID := range volumeIDDeleteArray {
+		if cnsDeletionMap[volumeID.Id] {
+			t.Errorf("expected volume %q to be removed from cnsDeletionMap", volumeID.Id)
+		}
+	}
+	if len(report.status.Failures) != 0 {
+		t.Errorf("unexpected failures: %v", report.status.Failures)
+	}
+}
+
+// TestFullSyncDryRunLeavesCnsMapsUnchanged verifies that a dry run does not record volumes in
+// cnsCreationMap and cnsDeletionMap, so that the next cycle still waits for two cycles.
+func TestFullSyncDryRunLeavesCnsMapsUnchanged(t *testing.T) {
+	ctx := context.Background()
+	cnsCreationMap = make(map[string]bool)
+	cnsDeletionMap = make(map[string]bool)
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-missing-in-cns"},
+		Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+			CSI: &v1.CSIPersistentVolumeSource{Driver: csitypes.Name, VolumeHandle: "volume-missing-in-cns"}}},
+	}
+	volumeToK8sEntityMetadataMap := map[string][]cnstypes.BaseCnsEntityMetadata{"volume-missing-in-cns": nil}
+	cnsVolumeIds := []cnstypes.CnsVolumeId{{Id: "volume-missing-in-k8s"}}
+
+	for _, dryRun := range []bool{true, false} {
+		createSpecArray, _ := fullSyncGetVolumeSpecs(ctx, []*v1.PersistentVolume{pv}, nil, volumeToK8sEntityMetadataMap,
+			cnstypes.CnsContainerCluster{}, nil, false, dryRun)
+		volToBeDeleted, err := getVolumesToBeDeleted(ctx, cnsVolumeIds, map[string]string{}, nil, false, dryRun)
+		if err != nil {
+			t.Fatalf("getVolumesToBeDeleted failed: %v", err)
+		}
+		if len(createSpecArray) != 0 || len(volToBeDeleted) != 0 {
+			t.Errorf("expected no operation in the first cycle, got %d create and %d delete",
+				len(createSpecArray), len(volToBeDeleted))
+		}
+		if cnsCreationMap["volume-missing-in-cns"] == dryRun || cnsDeletionMap["volume-missing-in-k8s"] == dryRun {
+			t.Errorf("unexpected cnsCreationMap %v and cnsDeletionMap %v with dry run %v", cnsCreationMap, cnsDeletionMap, dryRun)
+		}
+	}
+}
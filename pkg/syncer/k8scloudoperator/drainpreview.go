@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8scloudoperator
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
+
+	spv1alpha1 "sigs.k8s.io/vsphere-csi-driver/pkg/apis/storagepool/cns/v1alpha1"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+)
+
+// GetDrainPreview computes the storage vMotion plan of the given StoragePool like GetSVMotionPlan without
+// modifying any PVC. Unlike GetSVMotionPlan, it does not fail if some volumes cannot be placed, they are
+// reported in the UnplaceableVolumes of the preview.
+func GetDrainPreview(ctx context.Context, client kubernetes.Interface, storagePoolName string,
+	maintenanceMode string) (*spv1alpha1.DrainPreview, error) {
+	log := logger.GetLogger(ctx)
+	preview := &spv1alpha1.DrainPreview{
+		MaintenanceMode:    maintenanceMode,
+		GeneratedAt:        metav1.Now(),
+		ProjectedFreeSpace: make(map[string]int64),
+	}
+	volumeInfoList, spList, allPVCList, accessibleNodes, err := getSVMotionPlanInputs(ctx, client, storagePoolName)
+	if err != nil {
+		return nil, err
+	}
+	// The PVCs and StoragePools are copies local to the planner, the plan is not persisted.
+	// Mark the copy of the source StoragePool as under disk decommission so that it is not
+	// selected as a target, as it would be during the actual decommission.
+	for idx := range spList {
+		if spList[idx].GetName() == storagePoolName {
+			err = unstructured.SetNestedField(spList[idx].Object, maintenanceMode, "spec", "parameters", diskDecommissionModeField)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	freeSpaceBeforeMigration := make(map[string]int64)
+	for _, sp := range spList {
+		spType, _, _ := unstructured.NestedString(sp.Object, "metadata", "labels", spTypeLabelKey)
+		if spType != vsanDirect {
+			continue
+		}
+		freeSpace, found, err := unstructured.NestedInt64(sp.Object, "status", "capacity", "freeSpace")
+		if found && err == nil {
+			freeSpaceBeforeMigration[sp.GetName()] = freeSpace
+		}
+	}
+	planner := newMigrationPlanner(ctx, volumeInfoList, spList, allPVCList, accessibleNodes)
+	volumesToSPMap, unplaceableVolumes, err := planner.getPartialMigrationPlan(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	for spName, freeSpace := range freeSpaceBeforeMigration {
+		preview.ProjectedFreeSpace[spName] = freeSpace
+	}
+	for _, vol := range volumeInfoList {
+		if targetSPName, ok := volumesToSPMap[vol.PVName]; ok {
+			if _, ok := preview.ProjectedFreeSpace[storagePoolName]; ok {
+				preview.ProjectedFreeSpace[storagePoolName] += vol.SizeInBytes
+			}
+			if _, ok := preview.ProjectedFreeSpace[targetSPName]; ok {
+				preview.ProjectedFreeSpace[targetSPName] -= vol.SizeInBytes
+			}
+			preview.Migrations = append(preview.Migrations, spv1alpha1.PlannedMigration{
+				PVName:            vol.PVName,
+				PVCName:           vol.PVC.Name,
+				PVCNamespace:      vol.PVC.Namespace,
+				SizeInBytes:       vol.SizeInBytes,
+				TargetStoragePool: targetSPName,
+			})
+		} else if err, ok := unplaceableVolumes[vol.PVName]; ok {
+			preview.UnplaceableVolumes = append(preview.UnplaceableVolumes, spv1alpha1.UnplaceableVolume{
+				PVName:       vol.PVName,
+				PVCName:      vol.PVC.Name,
+				PVCNamespace: vol.PVC.Namespace,
+				SizeInBytes:  vol.SizeInBytes,
+				Reason:       err.Error(),
+			})
+		}
+	}
+
+	log.Infof("Drain preview of StoragePool %v with MM %v: %d volume(s) to migrate, %d volume(s) unplaceable",
+		storagePoolName, maintenanceMode, len(preview.Migrations), len(preview.UnplaceableVolumes))
+	return preview, nil
+}
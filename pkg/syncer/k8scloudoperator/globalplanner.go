@@ -0,0 +1,302 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8scloudoperator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
+
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+)
+
+const (
+	// envMigrationPlanner selects the planner computing the storage vMotion plans of disk decommission
+	envMigrationPlanner = "SVMOTION_PLANNER"
+	// relaxedFitPlanner places the volumes one by one, largest first, on the StoragePool with the most free space
+	relaxedFitPlanner = "relaxed-fit"
+	// globalPlanner searches the assignment of all the volumes placing the most volumes
+	globalPlanner = "global"
+	// defaultMaxPlannerSearchSteps bounds the number of partial assignments explored by the global planner
+	defaultMaxPlannerSearchSteps = 100000
+)
+
+// newMigrationPlanner returns the migration planner selected by the SVMOTION_PLANNER environment variable.
+// The relaxed fit planner is used by default.
+func newMigrationPlanner(ctx context.Context, volumeList []VolumeInfo, spList []unstructured.Unstructured,
+	allPVCList []v1.PersistentVolumeClaim, accessibleNodeNames []string) migrationPlanner {
+	log := logger.GetLogger(ctx)
+	switch planner := os.Getenv(envMigrationPlanner); planner {
+	case globalPlanner:
+		return newGlobalMigrationPlanner(volumeList, spList, allPVCList, accessibleNodeNames)
+	case "", relaxedFitPlanner:
+	default:
+		log.Warnf("Unknown migration planner %q, using %q", planner, relaxedFitPlanner)
+	}
+	return newRelaxedFitMigrationPlanner(volumeList, spList, allPVCList, accessibleNodeNames)
+}
+
+// globalMigrationPlanner searches the assignment of the volumes to the storage pools globally instead of placing
+// them one at a time. Among the assignments placing the most volumes, it selects the one moving the fewest bytes.
+// The search explores the volumes largest first and the storage pools with the most free space first, so its first
+// candidate is the relaxed fit plan, and it is bounded by maxSearchSteps.
+type globalMigrationPlanner struct {
+	volumeList      []VolumeInfo
+	storagePoolList []unstructured.Unstructured
+	pvcList         []v1.PersistentVolumeClaim
+	sourceHostNames []string
+	maxSearchSteps  int
+}
+
+func newGlobalMigrationPlanner(volumeList []VolumeInfo, spList []unstructured.Unstructured,
+	allPVCList []v1.PersistentVolumeClaim, accessibleNodeNames []string) migrationPlanner {
+	return globalMigrationPlanner{
+		volumeList:      volumeList,
+		storagePoolList: spList,
+		pvcList:         allPVCList,
+		sourceHostNames: accessibleNodeNames,
+		maxSearchSteps:  defaultMaxPlannerSearchSteps,
+	}
+}
+
+// getMigrationPlan returns the plan of the global planner, it fails if any volume cannot be placed
+func (g globalMigrationPlanner) getMigrationPlan(ctx context.Context, client kubernetes.Interface) (map[string]string, error) {
+	volumeToSPMap, strandedVolumes, err := g.getPartialMigrationPlan(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	if len(strandedVolumes) != 0 {
+		var pvNames []string
+		for pvName := range strandedVolumes {
+			pvNames = append(pvNames, pvName)
+		}
+		sort.Strings(pvNames)
+		return nil, fmt.Errorf("PV(s) %v could not be migrated due to volume placement constraints or lack of free capacity in accessible datastores", pvNames)
+	}
+	return volumeToSPMap, nil
+}
+
+// getPartialMigrationPlan returns the plan of the volumes which can be placed and the reason the others cannot
+func (g globalMigrationPlanner) getPartialMigrationPlan(ctx context.Context,
+	client kubernetes.Interface) (map[string]string, map[string]error, error) {
+	log := logger.GetLogger(ctx)
+	volumes, pools, strandedVolumes := g.buildPlacementProblem(ctx)
+	volumeToSPMap := solveMigrationPlan(volumes, pools, g.maxSearchSteps)
+	for _, vol := range volumes {
+		if _, ok := volumeToSPMap[vol.pvName]; !ok {
+			strandedVolumes[vol.pvName] = fmt.Errorf("fail to find any compatible StoragePool with enough free capacity")
+		}
+	}
+	log.Debugf("volumeToSPMap: %v, strandedVolumes: %v", volumeToSPMap, strandedVolumes)
+	return volumeToSPMap, strandedVolumes, nil
+}
+
+// buildPlacementProblem computes the candidate storage pools of each volume and the free capacity of the storage
+// pools taking the pending PVCs into account. The volumes without any candidate are returned as stranded.
+func (g globalMigrationPlanner) buildPlacementProblem(ctx context.Context) ([]planVolume, map[string]*planPool, map[string]error) {
+	log := logger.GetLogger(ctx)
+	strandedVolumes := make(map[string]error)
+	pools := make(map[string]*planPool)
+
+	// the capacity of the pending PVCs is not accounted in the free space of the storage pools yet
+	pendingBytes := make(map[string]int64)
+	for _, pvc := range g.pvcList {
+		if spName, ok := pvc.Annotations[StoragePoolAnnotationKey]; ok && pvc.Status.Phase == v1.ClaimPending {
+			capacity := pvc.Spec.Resources.Requests[v1.ResourceName(v1.ResourceStorage)]
+			pendingBytes[spName] += capacity.Value()
+		}
+	}
+
+	var volumes []planVolume
+	for _, vol := range g.volumeList {
+		scName, err := GetSCNameFromPVC(&vol.PVC)
+		if err != nil {
+			strandedVolumes[vol.PVName] = err
+			continue
+		}
+		spList, err := preFilterSPList(ctx, g.storagePoolList, scName, g.sourceHostNames, vol.SizeInBytes)
+		if err != nil {
+			strandedVolumes[vol.PVName] = err
+			continue
+		}
+		planVol := planVolume{
+			pvName:          vol.PVName,
+			sizeInBytes:     vol.SizeInBytes,
+			antiAffinityKey: antiAffinityKey(vol.PVC.Namespace, vol.PVC.Annotations),
+		}
+		for _, sp := range spList {
+			if _, ok := pools[sp.Name]; !ok {
+				pools[sp.Name] = &planPool{
+					freeInBytes:      sp.FreeCapInBytes - pendingBytes[sp.Name],
+					antiAffinityKeys: make(map[string]bool),
+				}
+			}
+			planVol.candidates = append(planVol.candidates, sp.Name)
+		}
+		if len(planVol.candidates) == 0 {
+			strandedVolumes[vol.PVName] = fmt.Errorf("fail to find a StoragePool passing all criteria")
+			continue
+		}
+		volumes = append(volumes, planVol)
+	}
+
+	// volumes with the same required anti-affinity cannot be placed on the storage pools already used by one of them
+	for _, pvc := range g.pvcList {
+		spName, ok := pvc.Annotations[StoragePoolAnnotationKey]
+		if !ok {
+			continue
+		}
+		if pool, ok := pools[spName]; ok {
+			if key := antiAffinityKey(pvc.Namespace, pvc.Annotations); key != "" {
+				pool.antiAffinityKeys[key] = true
+			}
+		}
+	}
+	log.Infof("Global migration planner: %d volume(s) to place on %d StoragePool(s), %d volume(s) without candidate",
+		len(volumes), len(pools), len(strandedVolumes))
+	return volumes, pools, strandedVolumes
+}
+
+// antiAffinityKey returns the key identifying the PVCs which must not share a storage pool with a PVC of the given
+// namespace and annotations, or an empty string if the PVC does not require anti-affinity
+func antiAffinityKey(namespace string, annotations map[string]string) string {
+	if value, ok := annotations[spPolicyAntiRequired]; ok {
+		return strings.Join([]string{namespace, value}, "/")
+	}
+	return ""
+}
+
+// planVolume is a volume to be placed by solveMigrationPlan
+type planVolume struct {
+	pvName      string
+	sizeInBytes int64
+	// antiAffinityKey identifies the volumes which must not share a storage pool, empty if none
+	antiAffinityKey string
+	// candidates are the storage pools the volume is compatible with
+	candidates []string
+}
+
+// planPool is a storage pool the volumes can be placed on by solveMigrationPlan
+type planPool struct {
+	freeInBytes int64
+	// antiAffinityKeys are the anti-affinity keys of the volumes placed on the storage pool
+	antiAffinityKeys map[string]bool
+}
+
+// planSearch holds the state of the branch and bound search of solveMigrationPlan
+type planSearch struct {
+	volumes        []planVolume
+	pools          map[string]*planPool
+	assignment     []string
+	best           []string
+	bestPlaced     int
+	bestBytes      int64
+	found          bool
+	steps          int
+	maxSearchSteps int
+}
+
+// solveMigrationPlan assigns the volumes to their candidate pools, maximizing the number of volumes placed then
+// minimizing the bytes moved. A volume fits on a pool if the free space of the pool exceeds the size of the volume
+// by bufferDiskSize. The best assignment found within maxSearchSteps is returned as a map of the PV names to the
+// names of their target pool, the volumes which could not be placed are not in the map.
+func solveMigrationPlan(volumes []planVolume, pools map[string]*planPool, maxSearchSteps int) map[string]string {
+	sorted := make([]planVolume, len(volumes))
+	copy(sorted, volumes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].sizeInBytes > sorted[j].sizeInBytes
+	})
+	s := &planSearch{
+		volumes:        sorted,
+		pools:          pools,
+		assignment:     make([]string, len(sorted)),
+		best:           make([]string, len(sorted)),
+		maxSearchSteps: maxSearchSteps,
+	}
+	s.search(0, 0, 0)
+
+	volumeToSPMap := make(map[string]string)
+	for i, spName := range s.best {
+		if spName != "" {
+			volumeToSPMap[sorted[i].pvName] = spName
+		}
+	}
+	return volumeToSPMap
+}
+
+// search explores the assignments of the volumes from index i, placed volumes moving placedBytes being already assigned
+func (s *planSearch) search(i int, placed int, placedBytes int64) {
+	if s.steps >= s.maxSearchSteps {
+		return
+	}
+	s.steps++
+	// bound: the remaining volumes cannot improve on the best assignment
+	maxPlaced := placed + len(s.volumes) - i
+	if s.found && (maxPlaced < s.bestPlaced || (maxPlaced == s.bestPlaced && placedBytes >= s.bestBytes)) {
+		return
+	}
+	if i == len(s.volumes) {
+		if !s.found || placed > s.bestPlaced || (placed == s.bestPlaced && placedBytes < s.bestBytes) {
+			copy(s.best, s.assignment)
+			s.bestPlaced = placed
+			s.bestBytes = placedBytes
+			s.found = true
+		}
+		return
+	}
+
+	vol := s.volumes[i]
+	candidates := make([]string, 0, len(vol.candidates))
+	for _, spName := range vol.candidates {
+		pool, ok := s.pools[spName]
+		if !ok || pool.freeInBytes <= vol.sizeInBytes+bufferDiskSize {
+			continue
+		}
+		if vol.antiAffinityKey != "" && pool.antiAffinityKeys[vol.antiAffinityKey] {
+			continue
+		}
+		candidates = append(candidates, spName)
+	}
+	sort.Slice(candidates, func(a, b int) bool {
+		freeA, freeB := s.pools[candidates[a]].freeInBytes, s.pools[candidates[b]].freeInBytes
+		return freeA > freeB || (freeA == freeB && candidates[a] < candidates[b])
+	})
+
+	for _, spName := range candidates {
+		pool := s.pools[spName]
+		pool.freeInBytes -= vol.sizeInBytes
+		addedKey := vol.antiAffinityKey != "" && !pool.antiAffinityKeys[vol.antiAffinityKey]
+		if addedKey {
+			pool.antiAffinityKeys[vol.antiAffinityKey] = true
+		}
+		s.assignment[i] = spName
+		s.search(i+1, placed+1, placedBytes+vol.sizeInBytes)
+		s.assignment[i] = ""
+		if addedKey {
+			delete(pool.antiAffinityKeys, vol.antiAffinityKey)
+		}
+		pool.freeInBytes += vol.sizeInBytes
+	}
+	// leave the volume stranded
+	s.search(i+1, placed, placedBytes)
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8scloudoperator
+
+import (
+	"reflect"
+	"testing"
+)
+
+const gib = int64(1024 * 1024 * 1024)
+
+// newTestPools returns pools on which volumes of exactly the given number of GiB fit
+func newTestPools(freeGiB map[string]int64) map[string]*planPool {
+	pools := make(map[string]*planPool)
+	for name, free := range freeGiB {
+		pools[name] = &planPool{
+			freeInBytes:      free*gib + bufferDiskSize + 1,
+			antiAffinityKeys: make(map[string]bool),
+		}
+	}
+	return pools
+}
+
+func TestSolveMigrationPlan(t *testing.T) {
+	tests := []struct {
+		name     string
+		pools    map[string]int64
+		volumes  []planVolume
+		expected map[string]string
+	}{
+		{
+			name:  "all volumes fit",
+			pools: map[string]int64{"sp-1": 10, "sp-2": 5},
+			volumes: []planVolume{
+				{pvName: "pv-1", sizeInBytes: 4 * gib, candidates: []string{"sp-1", "sp-2"}},
+				{pvName: "pv-2", sizeInBytes: 2 * gib, candidates: []string{"sp-1", "sp-2"}},
+			},
+			expected: map[string]string{"pv-1": "sp-1", "pv-2": "sp-1"},
+		},
+		{
+			name:  "relaxed fit strands a volume that the global plan places",
+			pools: map[string]int64{"sp-1": 6, "sp-2": 5},
+			volumes: []planVolume{
+				{pvName: "pv-1", sizeInBytes: 4 * gib, candidates: []string{"sp-1", "sp-2"}},
+				{pvName: "pv-2", sizeInBytes: 3 * gib, candidates: []string{"sp-1", "sp-2"}},
+				{pvName: "pv-3", sizeInBytes: 3 * gib, candidates: []string{"sp-1", "sp-2"}},
+			},
+			expected: map[string]string{"pv-1": "sp-2", "pv-2": "sp-1", "pv-3": "sp-1"},
+		},
+		{
+			name:  "partial plan strands the volumes which cannot fit",
+			pools: map[string]int64{"sp-1": 5},
+			volumes: []planVolume{
+				{pvName: "pv-1", sizeInBytes: 8 * gib, candidates: []string{"sp-1"}},
+				{pvName: "pv-2", sizeInBytes: 2 * gib, candidates: []string{"sp-1"}},
+			},
+			expected: map[string]string{"pv-2": "sp-1"},
+		},
+		{
+			name:  "fewest bytes moved among the plans placing the most volumes",
+			pools: map[string]int64{"sp-1": 5},
+			volumes: []planVolume{
+				{pvName: "pv-1", sizeInBytes: 4 * gib, candidates: []string{"sp-1"}},
+				{pvName: "pv-2", sizeInBytes: 3 * gib, candidates: []string{"sp-1"}},
+			},
+			expected: map[string]string{"pv-2": "sp-1"},
+		},
+		{
+			name:  "volumes with required anti-affinity do not share a pool",
+			pools: map[string]int64{"sp-1": 10, "sp-2": 10},
+			volumes: []planVolume{
+				{pvName: "pv-1", sizeInBytes: 2 * gib, antiAffinityKey: "ns/db", candidates: []string{"sp-1", "sp-2"}},
+				{pvName: "pv-2", sizeInBytes: 2 * gib, antiAffinityKey: "ns/db", candidates: []string{"sp-1", "sp-2"}},
+				{pvName: "pv-3", sizeInBytes: 2 * gib, antiAffinityKey: "ns/db", candidates: []string{"sp-1", "sp-2"}},
+			},
+			expected: map[string]string{"pv-1": "sp-1", "pv-2": "sp-2"},
+		},
+		{
+			name:  "volumes are only placed on their candidate pools",
+			pools: map[string]int64{"sp-1": 10, "sp-2": 3},
+			volumes: []planVolume{
+				{pvName: "pv-1", sizeInBytes: 3 * gib, candidates: []string{"sp-1"}},
+				{pvName: "pv-2", sizeInBytes: 3 * gib, candidates: []string{"sp-2"}},
+				{pvName: "pv-3", sizeInBytes: 2 * gib, candidates: []string{"sp-2"}},
+			},
+			expected: map[string]string{"pv-1": "sp-1", "pv-3": "sp-2"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plan := solveMigrationPlan(test.volumes, newTestPools(test.pools), defaultMaxPlannerSearchSteps)
+			if !reflect.DeepEqual(plan, test.expected) {
+				t.Errorf("expected plan %v, got %v", test.expected, plan)
+			}
+		})
+	}
+}
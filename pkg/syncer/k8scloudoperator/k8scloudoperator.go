@@ -21,19 +21,19 @@ import (
 	"flag"
 	"fmt"
 	"net"
-	"os"
-	"strconv"
 	"strings"
-	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 	v1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
-	"k8s.io/apimachinery/pkg/util/wait"
 	clientset "k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	api "k8s.io/kubernetes/pkg/apis/core"
 
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
@@ -45,7 +45,6 @@ import (
 
 const (
 	vmUUIDLabel                 = "vmware-system-vm-uuid"
-	defaultPodPollIntervalInSec = 2
 	spTypePrefix                = "cns.vmware.com/"
 	spTypeAnnotationKey         = spTypePrefix + "StoragePoolTypeHint"
 	nodeAffinityAnnotationKey   = "failure-domain.beta.vmware.com/node" // PVC annotation key to specify the node to which PV should be affinitized.
@@ -53,10 +52,15 @@ const (
 	vsanSnaType                 = spTypePrefix + "vsan-sna"
 	spTypeLabelKey              = spTypePrefix + "StoragePoolType"
 	diskDecommissionModeField   = "decommMode"
+	k8sCloudOperatorServiceName = "k8scloudoperator.K8sCloudOperator"
 )
 
 type k8sCloudOperator struct {
 	k8sClient clientset.Interface
+	// podLister and podWaiters are backed by the pod informer, so that waiting for the vmuuid annotation
+	// of pods does not poll the API server
+	podLister  corelisters.PodLister
+	podWaiters *podAnnotationWaiters
 }
 
 // initK8sCloudOperatorType initializes the k8sCloudOperator struct
@@ -71,6 +75,13 @@ func initK8sCloudOperatorType(ctx context.Context) (*k8sCloudOperator, error) {
 		log.Errorf("Creating Kubernetes client failed. Err: %v", err)
 		return nil, err
 	}
+	k8sCloudOperator.podWaiters = newPodAnnotationWaiters()
+	informerManager := k8s.NewInformer(k8sCloudOperator.k8sClient)
+	informerManager.AddPodListener(k8sCloudOperator.podWaiters.podAdded, k8sCloudOperator.podWaiters.podUpdated,
+		k8sCloudOperator.podWaiters.podDeleted)
+	k8sCloudOperator.podLister = informerManager.GetPodLister()
+	storageClassLister = informerManager.GetStorageClassLister()
+	informerManager.Listen()
 	return &k8sCloudOperator, nil
 }
 
@@ -86,14 +97,25 @@ func InitK8sCloudOperatorService(ctx context.Context) error {
 		log.Errorf("failed to listen. Err: %v", err)
 		return err
 	}
-	grpcServer := grpc.NewServer()
+	serverOptions, err := getServerOptions(ctx)
+	if err != nil {
+		log.Errorf("Failed to get the k8s Cloud Operator gRPC server options. Err: %v", err)
+		return err
+	}
+	grpcServer := grpc.NewServer(serverOptions...)
 	server, err := initK8sCloudOperatorType(ctx)
 	if err != nil {
 		return err
 	}
 	RegisterK8SCloudOperatorServer(grpcServer, server)
+	// health and reflection services allow probing and inspecting the service with standard gRPC tools
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	reflection.Register(grpcServer)
+	healthServer.SetServingStatus(k8sCloudOperatorServiceName, healthpb.HealthCheckResponse_SERVING)
 	err = grpcServer.Serve(lis)
 	if err != nil {
+		healthServer.Shutdown()
 		log.Errorf("Failed to accept incoming connections on k8s Cloud Operator gRPC server. Err: %+v", err)
 		return err
 	}
@@ -105,78 +127,63 @@ func InitK8sCloudOperatorService(ctx context.Context) error {
  * GetPodVMUUIDAnnotation provide the implementation the GetPodVMUUIDAnnotation interface method
  */
 func (k8sCloudOperator *k8sCloudOperator) GetPodVMUUIDAnnotation(ctx context.Context, req *PodListenerRequest) (*PodListenerResponse, error) {
-	var (
-		vmuuid   string
-		err      error
-		timeout  = 5 * time.Minute
-		pollTime = time.Duration(getPodPollIntervalInSecs(ctx)) * time.Second
-		volumeID = req.VolumeID
-		nodeName = req.NodeName
-	)
+	log := logger.GetLogger(ctx)
+	vmuuid, err := k8sCloudOperator.getPodVMUUIDAnnotation(ctx, req.VolumeID, req.NodeName)
+	if err != nil {
+		return nil, err
+	}
+	response := PodListenerResponse{VmuuidAnnotation: vmuuid}
+	log.Debugf("Returning the %s annotation: %s for VolumeID: %s", vmUUIDLabel, vmuuid, req.VolumeID)
+	return &response, nil
+}
+
+/*
+ * WatchPodVMUUIDAnnotation provide the implementation the WatchPodVMUUIDAnnotation interface method
+ */
+func (k8sCloudOperator *k8sCloudOperator) WatchPodVMUUIDAnnotation(req *PodListenerRequest,
+	stream K8SCloudOperator_WatchPodVMUUIDAnnotationServer) error {
+	ctx := stream.Context()
+	log := logger.GetLogger(ctx)
+	vmuuid, err := k8sCloudOperator.getPodVMUUIDAnnotation(ctx, req.VolumeID, req.NodeName)
+	if err != nil {
+		return err
+	}
+	err = stream.Send(&PodListenerResponse{VmuuidAnnotation: vmuuid})
+	if err != nil {
+		log.Errorf("Failed to send the %s annotation for VolumeID: %s. Error: %+v", vmUUIDLabel, req.VolumeID, err)
+		return err
+	}
+	return nil
+}
 
+// getPodVMUUIDAnnotation finds the pod using the PVC of the given volume on the given node
+// and waits for its vmuuid annotation
+func (k8sCloudOperator *k8sCloudOperator) getPodVMUUIDAnnotation(ctx context.Context, volumeID string,
+	nodeName string) (string, error) {
 	log := logger.GetLogger(ctx)
 	pv, err := k8sCloudOperator.getPVWithVolumeID(ctx, volumeID)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	if pv.Spec.ClaimRef == nil {
 		errMsg := fmt.Sprintf("No Claim ref found for this PV with volumeID: %s", volumeID)
 		log.Errorf(errMsg)
-		return nil, fmt.Errorf(errMsg)
+		return "", fmt.Errorf(errMsg)
 	}
 	podResult, err := k8sCloudOperator.getPod(ctx, pv.Spec.ClaimRef.Name, pv.Spec.ClaimRef.Namespace, nodeName)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	podName := podResult.Name
 	podNamespace := podResult.Namespace
-	err = wait.Poll(pollTime, timeout, func() (bool, error) {
-		var exists bool
-		pod, err := k8sCloudOperator.k8sClient.CoreV1().Pods(podNamespace).Get(ctx, podName, metav1.GetOptions{})
-		if err != nil {
-			log.Errorf("Failed to get the pod with name: %s on namespace: %s using K8s Cloud Operator informer. Error: %+v", podName, podNamespace, err)
-			return false, err
-		}
-		annotations := pod.Annotations
-		vmuuid, exists = annotations[vmUUIDLabel]
-		if !exists {
-			log.Debugf("Waiting for %s annotation in Pod: %s", vmUUIDLabel, spew.Sdump(pod))
-			return false, nil
-		}
-		log.Debugf("%s annotation with value: %s found in Pod: %s", vmUUIDLabel, vmuuid, spew.Sdump(pod))
-		return true, nil
-	})
+	vmuuid, err := k8sCloudOperator.waitForPodVMUUIDAnnotation(ctx, podNamespace, podName)
 	if err != nil {
-		errMsg := fmt.Sprintf("Unable to find pod with name: %s and annotation: %s on namespace: %s in timeout: %d period. Error: %+v", podName, vmUUIDLabel, podNamespace, timeout, err)
-		log.Errorf(errMsg)
-		return nil, fmt.Errorf(errMsg)
+		log.Errorf("Unable to get the %s annotation of pod with name: %s on namespace: %s. Error: %+v",
+			vmUUIDLabel, podName, podNamespace, err)
+		return "", err
 	}
 	log.Infof("Found the %s: %s annotation on Pod: %s referring to VolumeID: %s running on node: %s", vmUUIDLabel, vmuuid, podName, volumeID, nodeName)
-	response := PodListenerResponse{VmuuidAnnotation: vmuuid}
-	return &response, nil
-}
-
-// getPodPollIntervalInSecs return the Poll interval in secs to query the
-// Pod Info from API server.
-// If environment variable POD_POLL_INTERVAL_SECONDS is set and valid,
-// return the interval value read from environment variable
-// otherwise, use the default value 30 minutes
-func getPodPollIntervalInSecs(ctx context.Context) int {
-	log := logger.GetLogger(ctx)
-	podPollIntervalInSec := defaultPodPollIntervalInSec
-	if v := os.Getenv("POD_POLL_INTERVAL_SECONDS"); v != "" {
-		if value, err := strconv.Atoi(v); err == nil {
-			if value <= 0 {
-				log.Warnf("Poll Interval to query the Pod Info from API server set in env variable POD_POLL_INTERVAL_SECONDS %s is equal or less than 0, will use the default interval %d", v, defaultPodPollIntervalInSec)
-			} else {
-				podPollIntervalInSec = value
-				log.Debugf("Poll Interval to query the Pod Info from API server is set to %d seconds", podPollIntervalInSec)
-			}
-		} else {
-			log.Warnf("Poll Interval to query the Pod Info from API server set in env variable PODVM_POLL_INTERVAL_SECONDS %s is invalid, will use the default interval", v)
-		}
-	}
-	return podPollIntervalInSec
+	return vmuuid, nil
 }
 
 /*
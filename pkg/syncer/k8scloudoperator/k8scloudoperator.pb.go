@@ -405,45 +405,46 @@ func init() {
 func init() { proto.RegisterFile("k8scloudoperator.proto", fileDescriptor_acda4807ad521f9a) }
 
 var fileDescriptor_acda4807ad521f9a = []byte{
-	// 605 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x54, 0xdd, 0x4e, 0xdb, 0x4c,
-	0x10, 0x25, 0xe4, 0xfb, 0x2a, 0x18, 0x68, 0x09, 0x0b, 0x44, 0xd4, 0xf4, 0x02, 0x59, 0xb4, 0x4d,
-	0x91, 0x08, 0x2a, 0x55, 0x25, 0xc4, 0x4d, 0x45, 0xa1, 0xa2, 0x08, 0x68, 0xad, 0x50, 0x22, 0xb5,
-	0x37, 0xe9, 0xb2, 0x9e, 0x86, 0x15, 0xeb, 0x1d, 0xe3, 0x5d, 0x47, 0xca, 0xf3, 0xf4, 0x31, 0x7a,
-	0xd9, 0x17, 0xab, 0xec, 0x38, 0x89, 0x1d, 0x5b, 0xe2, 0x2a, 0x9e, 0x33, 0x7f, 0x67, 0x32, 0x67,
-	0x16, 0x9a, 0xf7, 0x87, 0x46, 0x28, 0x8a, 0x7d, 0x0a, 0x31, 0xe2, 0x96, 0xa2, 0x76, 0x18, 0x91,
-	0x25, 0xd6, 0x98, 0xc5, 0x9d, 0xf7, 0x7d, 0x69, 0xef, 0xe2, 0xdb, 0xb6, 0xa0, 0x60, 0x5f, 0x90,
-	0xb6, 0x5c, 0x6a, 0x8c, 0xf6, 0x8c, 0xa5, 0x88, 0xf7, 0x71, 0x4f, 0x6a, 0x8b, 0xd1, 0x2f, 0x2e,
-	0x70, 0xdf, 0x84, 0x28, 0xf6, 0x85, 0x91, 0xa3, 0x42, 0xee, 0x25, 0x30, 0x8f, 0xfc, 0x4b, 0x69,
-	0x2c, 0x6a, 0x8c, 0x3a, 0xf8, 0x10, 0xa3, 0xb1, 0xcc, 0x81, 0x85, 0x01, 0xa9, 0x38, 0xc0, 0xf3,
-	0xd3, 0xcd, 0xda, 0x76, 0xad, 0xb5, 0xd8, 0x99, 0xd8, 0x89, 0x4f, 0x93, 0x8f, 0x5f, 0x78, 0x80,
-	0x9b, 0xf3, 0x23, 0xdf, 0xd8, 0x76, 0x8f, 0x61, 0xad, 0x50, 0xcd, 0x84, 0xa4, 0x0d, 0xb2, 0x5d,
-	0x68, 0x0c, 0x82, 0x38, 0x96, 0xfe, 0xb1, 0xd6, 0x64, 0xb9, 0x95, 0xa4, 0xb3, 0xb2, 0x25, 0xdc,
-	0xfd, 0x0e, 0x1b, 0x9f, 0xc9, 0xd8, 0x29, 0x92, 0xe3, 0x74, 0x47, 0xc6, 0xa6, 0x7d, 0x33, 0x4e,
-	0x63, 0x9b, 0xed, 0xc0, 0x53, 0x3e, 0x49, 0xb8, 0xc0, 0x61, 0x46, 0xac, 0x08, 0xba, 0x1f, 0xa1,
-	0x39, 0x5b, 0x3a, 0x23, 0xd8, 0x82, 0x95, 0x69, 0x68, 0x97, 0xab, 0x78, 0x3c, 0xda, 0x2c, 0xec,
-	0xfe, 0xae, 0xc1, 0x9a, 0xd7, 0x3d, 0xf1, 0x14, 0x17, 0x18, 0xa0, 0xb6, 0x63, 0x76, 0x0c, 0xfe,
-	0xd3, 0x53, 0x66, 0xe9, 0x37, 0x7b, 0x01, 0x8b, 0xc9, 0xaf, 0x09, 0xb9, 0x18, 0xd7, 0x9b, 0x02,
-	0xec, 0x07, 0x38, 0x5c, 0x08, 0x34, 0x46, 0xde, 0x4a, 0x25, 0xed, 0xb0, 0x17, 0xe1, 0x43, 0x2c,
-	0xa3, 0xb4, 0xaa, 0xd9, 0xac, 0x6f, 0xd7, 0x5a, 0x4b, 0x07, 0x5b, 0xed, 0x64, 0x53, 0x83, 0xb7,
-	0xed, 0x6f, 0x14, 0x92, 0xa2, 0xfe, 0xb0, 0x33, 0x8d, 0xe9, 0x3c, 0x2f, 0xa4, 0xe7, 0x3c, 0xc6,
-	0x3d, 0x82, 0xf5, 0x22, 0xc9, 0x6c, 0x4e, 0x17, 0x96, 0xc3, 0x04, 0xbc, 0x8e, 0xd3, 0xd4, 0x94,
-	0xed, 0x42, 0xa7, 0x80, 0xb9, 0x1a, 0x36, 0xae, 0x47, 0xaa, 0xe9, 0x5e, 0x51, 0x7e, 0x01, 0xbb,
-	0xb0, 0x9a, 0xc9, 0xa9, 0x17, 0x12, 0xa9, 0x5e, 0x6e, 0xde, 0x95, 0xcc, 0xe1, 0x11, 0xa9, 0x74,
-	0x21, 0x6f, 0xa0, 0x11, 0xf0, 0x44, 0x74, 0x9a, 0x6b, 0x81, 0xbd, 0x80, 0xfc, 0xc9, 0x3f, 0x9a,
-	0xc3, 0xaf, 0xc8, 0x47, 0xf7, 0x4f, 0x0d, 0x9a, 0xb3, 0x0d, 0x33, 0xba, 0x3f, 0xe1, 0x99, 0x19,
-	0xf4, 0x82, 0x14, 0xec, 0x85, 0x8a, 0x27, 0xaa, 0xa9, 0xb7, 0x96, 0x0e, 0x8e, 0xda, 0xa5, 0xb3,
-	0xa8, 0xae, 0xd0, 0xbe, 0x1e, 0x8c, 0x00, 0x4f, 0x71, 0xfd, 0x49, 0xdb, 0x68, 0xd8, 0x59, 0x36,
-	0x39, 0xc8, 0xf9, 0x00, 0xab, 0xa5, 0x10, 0xd6, 0x80, 0xfa, 0x3d, 0x0e, 0xb3, 0xd1, 0x92, 0x4f,
-	0xb6, 0x0e, 0xff, 0x0f, 0x72, 0xaa, 0x18, 0x19, 0x47, 0xf3, 0x87, 0xb5, 0x83, 0xbf, 0x75, 0x68,
-	0x5c, 0x1c, 0x9a, 0x93, 0x84, 0xcc, 0xd7, 0x8c, 0x0c, 0xeb, 0x43, 0xf3, 0x0c, 0xad, 0x47, 0x7e,
-	0xf7, 0xea, 0xe6, 0xe6, 0xfc, 0x74, 0x2a, 0x38, 0xb6, 0x53, 0x66, 0x5e, 0x3e, 0x3f, 0xe7, 0xe5,
-	0x23, 0x51, 0xa3, 0xe1, 0xdc, 0x39, 0x76, 0x07, 0xab, 0x67, 0x68, 0x8b, 0xa2, 0x66, 0xaf, 0xcb,
-	0xd9, 0x95, 0x17, 0xe5, 0xb4, 0x1e, 0x0f, 0x9c, 0x74, 0xd2, 0xb0, 0x95, 0xca, 0xc9, 0xc3, 0xc8,
-	0xa4, 0x3c, 0x04, 0x76, 0xd3, 0x17, 0xe1, 0x44, 0x71, 0x19, 0xb0, 0x2a, 0xc6, 0xe5, 0x2b, 0x71,
-	0x5e, 0x3d, 0x16, 0x36, 0xe9, 0xa7, 0x60, 0xe3, 0x0c, 0x6d, 0x71, 0xab, 0xc9, 0x86, 0xaa, 0xa6,
-	0xab, 0x94, 0x6b, 0xd5, 0x74, 0xd5, 0x22, 0x71, 0xe7, 0x6e, 0x9f, 0xa4, 0x8f, 0xe1, 0xbb, 0x7f,
-	0x01, 0x00, 0x00, 0xff, 0xff, 0x9b, 0xaf, 0x7c, 0xd6, 0x6f, 0x05, 0x00, 0x00,
+	// 613 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x54, 0x5f, 0x4f, 0xd3, 0x50,
+	0x14, 0xb7, 0x80, 0x06, 0x0e, 0x28, 0xe3, 0x02, 0xcb, 0x2c, 0x3e, 0x90, 0x06, 0x75, 0x92, 0x30,
+	0x14, 0x63, 0x42, 0x78, 0x31, 0x08, 0x06, 0x09, 0xa0, 0xcd, 0x90, 0x19, 0x7d, 0x99, 0x97, 0xdb,
+	0xe3, 0xd6, 0x70, 0x7b, 0x4f, 0xe9, 0xbd, 0x5d, 0xb2, 0xcf, 0xe3, 0x17, 0xf0, 0xdd, 0x2f, 0x67,
+	0xda, 0x75, 0x5b, 0xbb, 0x2e, 0xd9, 0x93, 0x4f, 0xeb, 0xf9, 0x9d, 0x7f, 0xbf, 0xb3, 0xf3, 0x3b,
+	0x17, 0xaa, 0x77, 0x87, 0x5a, 0x48, 0x8a, 0x3d, 0x0a, 0x31, 0xe2, 0x86, 0xa2, 0x46, 0x18, 0x91,
+	0x21, 0x56, 0x99, 0xc4, 0xed, 0x77, 0x1d, 0xdf, 0x74, 0xe3, 0xdb, 0x86, 0xa0, 0x60, 0x5f, 0x90,
+	0x32, 0xdc, 0x57, 0x18, 0xed, 0x69, 0x43, 0x11, 0xef, 0xe0, 0x9e, 0xaf, 0x0c, 0x46, 0xbf, 0xb8,
+	0xc0, 0x7d, 0x1d, 0xa2, 0xd8, 0x17, 0xda, 0x1f, 0x14, 0x72, 0x2e, 0x81, 0xb9, 0xe4, 0x5d, 0xfa,
+	0xda, 0xa0, 0xc2, 0xa8, 0x89, 0xf7, 0x31, 0x6a, 0xc3, 0x6c, 0x58, 0xec, 0x91, 0x8c, 0x03, 0x3c,
+	0x3f, 0xad, 0x59, 0xdb, 0x56, 0x7d, 0xa9, 0x39, 0xb2, 0x13, 0x9f, 0x22, 0x0f, 0x3f, 0xf3, 0x00,
+	0x6b, 0x73, 0x03, 0xdf, 0xd0, 0x76, 0x8e, 0x61, 0xbd, 0x50, 0x4d, 0x87, 0xa4, 0x34, 0xb2, 0x5d,
+	0xa8, 0xf4, 0x82, 0x38, 0xf6, 0xbd, 0x63, 0xa5, 0xc8, 0x70, 0xe3, 0x93, 0xca, 0xca, 0x96, 0x70,
+	0xe7, 0x3b, 0x6c, 0x7e, 0x22, 0x6d, 0xc6, 0x48, 0x8e, 0x53, 0x97, 0xb4, 0x49, 0xfb, 0x66, 0x9c,
+	0x86, 0x36, 0xdb, 0x81, 0xc7, 0x7c, 0x94, 0x70, 0x81, 0xfd, 0x8c, 0x58, 0x11, 0x74, 0x3e, 0x40,
+	0x75, 0xb2, 0x74, 0x46, 0xb0, 0x0e, 0xab, 0xe3, 0xd0, 0x16, 0x97, 0xf1, 0x70, 0xb4, 0x49, 0xd8,
+	0xf9, 0x6d, 0xc1, 0xba, 0xdb, 0x3a, 0x71, 0x25, 0x17, 0x18, 0xa0, 0x32, 0x43, 0x76, 0x0c, 0x16,
+	0xd4, 0x98, 0x59, 0xfa, 0xcd, 0x9e, 0xc1, 0x52, 0xf2, 0xab, 0x43, 0x2e, 0x86, 0xf5, 0xc6, 0x00,
+	0xfb, 0x01, 0x36, 0x17, 0x02, 0xb5, 0xf6, 0x6f, 0x7d, 0xe9, 0x9b, 0x7e, 0x3b, 0xc2, 0xfb, 0xd8,
+	0x8f, 0xd2, 0xaa, 0xba, 0x36, 0xbf, 0x6d, 0xd5, 0x97, 0x0f, 0xb6, 0x1a, 0xc9, 0xa6, 0x7a, 0x6f,
+	0x1a, 0x5f, 0x29, 0x24, 0x49, 0x9d, 0x7e, 0x73, 0x1c, 0xd3, 0x7c, 0x5a, 0x48, 0xcf, 0x79, 0xb4,
+	0x73, 0x04, 0x1b, 0x45, 0x92, 0xd9, 0x9c, 0x0e, 0xac, 0x84, 0x09, 0x78, 0x1d, 0xa7, 0xa9, 0x29,
+	0xdb, 0xc5, 0x66, 0x01, 0x73, 0x14, 0x6c, 0x5e, 0x0f, 0x54, 0xd3, 0xba, 0xa2, 0xfc, 0x02, 0x76,
+	0x61, 0x2d, 0x93, 0x53, 0x3b, 0x24, 0x92, 0xed, 0xdc, 0xbc, 0xab, 0x99, 0xc3, 0x25, 0x92, 0xe9,
+	0x42, 0x5e, 0x41, 0x25, 0xe0, 0x89, 0xe8, 0x14, 0x57, 0x02, 0xdb, 0x01, 0x79, 0xa3, 0x7f, 0x34,
+	0x87, 0x5f, 0x91, 0x87, 0xce, 0x5f, 0x0b, 0xaa, 0x93, 0x0d, 0x33, 0xba, 0x3f, 0xe1, 0x89, 0xee,
+	0xb5, 0x83, 0x14, 0x6c, 0x87, 0x92, 0x27, 0xaa, 0x99, 0xaf, 0x2f, 0x1f, 0x1c, 0x35, 0x4a, 0x67,
+	0x31, 0xbd, 0x42, 0xe3, 0xba, 0x37, 0x00, 0x5c, 0xc9, 0xd5, 0x47, 0x65, 0xa2, 0x7e, 0x73, 0x45,
+	0xe7, 0x20, 0xfb, 0x3d, 0xac, 0x95, 0x42, 0x58, 0x05, 0xe6, 0xef, 0xb0, 0x9f, 0x8d, 0x96, 0x7c,
+	0xb2, 0x0d, 0x78, 0xd8, 0xcb, 0xa9, 0x62, 0x60, 0x1c, 0xcd, 0x1d, 0x5a, 0x07, 0x7f, 0x16, 0xa0,
+	0x72, 0x71, 0xa8, 0x4f, 0x12, 0x32, 0x5f, 0x32, 0x32, 0xac, 0x03, 0xd5, 0x33, 0x34, 0x2e, 0x79,
+	0xad, 0xab, 0x9b, 0x9b, 0xf3, 0xd3, 0xb1, 0xe0, 0xd8, 0x4e, 0x99, 0x79, 0xf9, 0xfc, 0xec, 0xe7,
+	0x33, 0xa2, 0x06, 0xc3, 0x39, 0x0f, 0x58, 0x17, 0xd6, 0xce, 0xd0, 0x14, 0x45, 0xcd, 0x5e, 0x96,
+	0xb3, 0xa7, 0x5e, 0x94, 0x5d, 0x9f, 0x1d, 0x38, 0xea, 0xa4, 0x60, 0x2b, 0x95, 0x93, 0x8b, 0x91,
+	0x4e, 0x79, 0x08, 0x6c, 0xa5, 0x2f, 0xc2, 0x89, 0xe4, 0x7e, 0xc0, 0xa6, 0x31, 0x2e, 0x5f, 0x89,
+	0xfd, 0x62, 0x56, 0xd8, 0xa8, 0x9f, 0x84, 0xcd, 0x33, 0x34, 0xc5, 0xad, 0x26, 0x1b, 0x9a, 0x36,
+	0xdd, 0x54, 0xb9, 0xda, 0xf5, 0xd9, 0x81, 0xa3, 0x6e, 0x77, 0x50, 0xfb, 0xc6, 0x8d, 0xe8, 0xfe,
+	0xff, 0x95, 0xbd, 0xb6, 0x6e, 0x1f, 0xa5, 0x2f, 0xef, 0xdb, 0x7f, 0x03, 0x00, 0xb1, 0x23, 0x07,
+	0x76, 0xdc, 0x05, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -468,7 +469,7 @@ type K8SCloudOperatorClient interface {
 	// 3. Pod has a volume with name "pvcName" associated with it
 	//
 	// The annotation might not be available immediately when the Pod is created.
-	// So polling the Pod periodically every pollInterval seconds to check if annotation is available.
+	// So the Pod is watched until the annotation is available.
 	// If annotation is not available after a timeout period, the function errors out.
 	GetPodVMUUIDAnnotation(ctx context.Context, in *PodListenerRequest, opts ...grpc.CallOption) (*PodListenerResponse, error)
 	//
@@ -492,6 +493,11 @@ type K8SCloudOperatorClient interface {
 	// 2. Some (or all) of the PSP PVs cannot be mapped (may be due to lack of capacity). We return with
 	//    appropriate error and return vMotion plan as nil.
 	GetStorageVMotionPlan(ctx context.Context, in *StorageVMotionRequest, opts ...grpc.CallOption) (*StorageVMotionResponse, error)
+	//
+	// WatchPodVMUUIDAnnotation streams the vmuuid annotation of the pod found like in GetPodVMUUIDAnnotation.
+	// The pod is watched and the annotation is sent as soon as it is set on the pod, after which the stream ends.
+	// The stream fails if the pod is deleted or the annotation is not set after a timeout period.
+	WatchPodVMUUIDAnnotation(ctx context.Context, in *PodListenerRequest, opts ...grpc.CallOption) (K8SCloudOperator_WatchPodVMUUIDAnnotationClient, error)
 }
 
 type k8SCloudOperatorClient struct {
@@ -538,6 +544,38 @@ func (c *k8SCloudOperatorClient) GetStorageVMotionPlan(ctx context.Context, in *
 	return out, nil
 }
 
+func (c *k8SCloudOperatorClient) WatchPodVMUUIDAnnotation(ctx context.Context, in *PodListenerRequest, opts ...grpc.CallOption) (K8SCloudOperator_WatchPodVMUUIDAnnotationClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_K8SCloudOperator_serviceDesc.Streams[0], "/k8scloudoperator.K8sCloudOperator/WatchPodVMUUIDAnnotation", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &k8SCloudOperatorWatchPodVMUUIDAnnotationClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type K8SCloudOperator_WatchPodVMUUIDAnnotationClient interface {
+	Recv() (*PodListenerResponse, error)
+	grpc.ClientStream
+}
+
+type k8SCloudOperatorWatchPodVMUUIDAnnotationClient struct {
+	grpc.ClientStream
+}
+
+func (x *k8SCloudOperatorWatchPodVMUUIDAnnotationClient) Recv() (*PodListenerResponse, error) {
+	m := new(PodListenerResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // K8SCloudOperatorServer is the server API for K8SCloudOperator service.
 type K8SCloudOperatorServer interface {
 	//
@@ -550,7 +588,7 @@ type K8SCloudOperatorServer interface {
 	// 3. Pod has a volume with name "pvcName" associated with it
 	//
 	// The annotation might not be available immediately when the Pod is created.
-	// So polling the Pod periodically every pollInterval seconds to check if annotation is available.
+	// So the Pod is watched until the annotation is available.
 	// If annotation is not available after a timeout period, the function errors out.
 	GetPodVMUUIDAnnotation(context.Context, *PodListenerRequest) (*PodListenerResponse, error)
 	//
@@ -574,6 +612,11 @@ type K8SCloudOperatorServer interface {
 	// 2. Some (or all) of the PSP PVs cannot be mapped (may be due to lack of capacity). We return with
 	//    appropriate error and return vMotion plan as nil.
 	GetStorageVMotionPlan(context.Context, *StorageVMotionRequest) (*StorageVMotionResponse, error)
+	//
+	// WatchPodVMUUIDAnnotation streams the vmuuid annotation of the pod found like in GetPodVMUUIDAnnotation.
+	// The pod is watched and the annotation is sent as soon as it is set on the pod, after which the stream ends.
+	// The stream fails if the pod is deleted or the annotation is not set after a timeout period.
+	WatchPodVMUUIDAnnotation(*PodListenerRequest, K8SCloudOperator_WatchPodVMUUIDAnnotationServer) error
 }
 
 func RegisterK8SCloudOperatorServer(s *grpc.Server, srv K8SCloudOperatorServer) {
@@ -652,6 +695,27 @@ func _K8SCloudOperator_GetStorageVMotionPlan_Handler(srv interface{}, ctx contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _K8SCloudOperator_WatchPodVMUUIDAnnotation_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PodListenerRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(K8SCloudOperatorServer).WatchPodVMUUIDAnnotation(m, &k8SCloudOperatorWatchPodVMUUIDAnnotationServer{stream})
+}
+
+type K8SCloudOperator_WatchPodVMUUIDAnnotationServer interface {
+	Send(*PodListenerResponse) error
+	grpc.ServerStream
+}
+
+type k8SCloudOperatorWatchPodVMUUIDAnnotationServer struct {
+	grpc.ServerStream
+}
+
+func (x *k8SCloudOperatorWatchPodVMUUIDAnnotationServer) Send(m *PodListenerResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _K8SCloudOperator_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "k8scloudoperator.K8sCloudOperator",
 	HandlerType: (*K8SCloudOperatorServer)(nil),
@@ -673,6 +737,12 @@ var _K8SCloudOperator_serviceDesc = grpc.ServiceDesc{
 			Handler:    _K8SCloudOperator_GetStorageVMotionPlan_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchPodVMUUIDAnnotation",
+			Handler:       _K8SCloudOperator_WatchPodVMUUIDAnnotation_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "k8scloudoperator.proto",
 }
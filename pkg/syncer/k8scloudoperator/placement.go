@@ -68,8 +68,10 @@ const (
 // StoragePoolInfo is abstraction of a storage pool list
 // XXX Change all usage of this into a map
 type StoragePoolInfo struct {
-	Name           string
-	FreeCapInBytes int64
+	Name            string
+	FreeCapInBytes  int64
+	TotalCapInBytes int64
+	AccessibleNodes []string
 }
 
 // byCombination uses several different property to rank storage pools
@@ -104,7 +106,11 @@ type VolumeInfo struct {
 }
 
 type migrationPlanner interface {
+	// getMigrationPlan maps the PV names to the name of their target storage pool. It fails if any volume cannot be placed.
 	getMigrationPlan(ctx context.Context, client kubernetes.Interface) (map[string]string, error)
+	// getPartialMigrationPlan maps the PV names of the volumes which can be placed to the name of their target
+	// storage pool and the PV names of the others to the reason they cannot be placed
+	getPartialMigrationPlan(ctx context.Context, client kubernetes.Interface) (map[string]string, map[string]error, error)
 }
 
 // relaxed fit decreasing also called as WFD offers appreciable approximation guarantees (measures closeness to optimal soln)
@@ -130,8 +136,24 @@ func newRelaxedFitMigrationPlanner(volumeList []VolumeInfo, spList []unstructure
 // Uses Relaxed Fit Decreasing (also called WFD) bin packaging algorithm to assign for each pvc a target storage-pool for storage vMotion
 // It tries to place a volume into a disk with maximum free space. Hence it tries to decrease the free space variance after migration.
 func (b relaxedFitMigrationPlanner) getMigrationPlan(ctx context.Context, client kubernetes.Interface) (map[string]string, error) {
+	volumeToSPMap, _, err := b.placeVolumes(ctx, client, true)
+	return volumeToSPMap, err
+}
+
+// getPartialMigrationPlan returns the relaxed fit plan of the volumes which can be placed and the reason the others cannot
+func (b relaxedFitMigrationPlanner) getPartialMigrationPlan(ctx context.Context,
+	client kubernetes.Interface) (map[string]string, map[string]error, error) {
+	return b.placeVolumes(ctx, client, false)
+}
+
+// placeVolumes assigns a target storage-pool to each volume and updates the free space of the storage pools in
+// storagePoolList accordingly. If stopOnFailure is false, the volumes which cannot be placed are returned along with
+// the reason instead of failing the whole plan.
+func (b relaxedFitMigrationPlanner) placeVolumes(ctx context.Context, client kubernetes.Interface,
+	stopOnFailure bool) (map[string]string, map[string]error, error) {
 	log := logger.GetLogger(ctx)
 	volumeToSPMap := make(map[string]string)
+	unplaceableVolumes := make(map[string]error)
 	// sort volumes in decreasing order of size
 	sort.Slice(b.volumeList, func(i, j int) bool {
 		return b.volumeList[i].SizeInBytes > b.volumeList[j].SizeInBytes
@@ -140,11 +162,15 @@ func (b relaxedFitMigrationPlanner) getMigrationPlan(ctx context.Context, client
 	for _, vol := range b.volumeList {
 		pvcName := vol.PVC.Name
 
-		assignedSp, err := getSPForPVCPlacement(ctx, client, &vol.PVC, vol.SizeInBytes, b.storagePoolList,
+		assignedSp, _, err := getSPForPVCPlacement(ctx, client, &vol.PVC, vol.SizeInBytes, b.storagePoolList,
 			b.sourceHostNames, b.pvcList, vsanDirectType, false)
 		if err != nil {
 			log.Errorf("Failed to assign SP to PVC %v. Error: %v", pvcName, err)
-			return nil, fmt.Errorf("PVC %v could not be migrated due to volume placement constraints or lack of free capacity in accessible datastores", pvcName)
+			if !stopOnFailure {
+				unplaceableVolumes[vol.PVName] = err
+				continue
+			}
+			return nil, nil, fmt.Errorf("PVC %v could not be migrated due to volume placement constraints or lack of free capacity in accessible datastores", pvcName)
 		}
 
 		// map the volume with SP with highest free space. Update free space in assigned SP for more accurate placement.
@@ -176,7 +202,7 @@ func (b relaxedFitMigrationPlanner) getMigrationPlan(ctx context.Context, client
 		}
 	}
 	log.Debugf("volumeToSPMap: %v", volumeToSPMap)
-	return volumeToSPMap, nil
+	return volumeToSPMap, unplaceableVolumes, nil
 }
 
 // isSPInList checks if a name already lies in the given list
@@ -243,12 +269,36 @@ func GetSVMotionPlan(ctx context.Context, client kubernetes.Interface, storagePo
 	log := logger.GetLogger(ctx)
 	volumesToSPMap := make(map[string]string)
 
+	volumeInfoList, spList, allPVCList, accessibleNodes, err := getSVMotionPlanInputs(ctx, client, storagePoolName)
+	if err != nil {
+		return nil, err
+	}
+	if len(volumeInfoList) == 0 {
+		log.Infof("No volume present in storagePool %v to migrate. ", storagePoolName)
+		return volumesToSPMap, nil
+	}
+
+	// for each volume assign a target sp for storage vMotion
+	planner := newMigrationPlanner(ctx, volumeInfoList, spList, allPVCList, accessibleNodes)
+	volumesToSPMap, err = planner.getMigrationPlan(ctx, client)
+
+	if err != nil {
+		return nil, err
+	}
+	return volumesToSPMap, nil
+}
+
+// getSVMotionPlanInputs returns the volumes present on the given vSAN Direct StoragePool, all the StoragePools,
+// all the PVCs and the nodes accessing the given StoragePool
+func getSVMotionPlanInputs(ctx context.Context, client kubernetes.Interface, storagePoolName string) ([]VolumeInfo,
+	[]unstructured.Unstructured, []v1.PersistentVolumeClaim, []string, error) {
+	log := logger.GetLogger(ctx)
 	spList, err := getStoragePoolList(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get StoragePools list. Error: %v", err)
+		return nil, nil, nil, nil, fmt.Errorf("failed to get StoragePools list. Error: %v", err)
 	}
 	if len(spList.Items) == 0 {
-		return nil, fmt.Errorf("could not find any StoragePool to migrate volumes")
+		return nil, nil, nil, nil, fmt.Errorf("could not find any StoragePool to migrate volumes")
 	}
 
 	var sourceSP unstructured.Unstructured
@@ -259,44 +309,32 @@ func GetSVMotionPlan(ctx context.Context, client kubernetes.Interface, storagePo
 		}
 	}
 	if sourceSP.GetName() == "" {
-		return nil, fmt.Errorf("failed to find source vSAN Direct StoragePool with name %v", storagePoolName)
+		return nil, nil, nil, nil, fmt.Errorf("failed to find source vSAN Direct StoragePool with name %v", storagePoolName)
 	}
 
 	spType, found, err := unstructured.NestedString(sourceSP.Object, "metadata", "labels", spTypeLabelKey)
 	if !found || err != nil || spType != vsanDirect {
-		return nil, fmt.Errorf("given StoragePool is not a vSAN Direct Datastore")
+		return nil, nil, nil, nil, fmt.Errorf("given StoragePool is not a vSAN Direct Datastore")
 	}
 
 	accessibleNodes, found, err := unstructured.NestedStringSlice(sourceSP.Object, "status", "accessibleNodes")
 	if !found || err != nil {
 		log.Errorf("Could not get accessible host from storage pool %v. Err: %v", storagePoolName, err)
-		return nil, fmt.Errorf("could not get accessible host information from StoragePool %v", storagePoolName)
+		return nil, nil, nil, nil, fmt.Errorf("could not get accessible host information from StoragePool %v", storagePoolName)
 	}
 	if len(accessibleNodes) != 1 {
 		log.Warnf("Unexpected number of accessible nodes found for storage pool %v. Expected 1 found %v", storagePoolName, len(accessibleNodes))
 		if len(accessibleNodes) == 0 {
-			return nil, fmt.Errorf("the given datastore/StoragePool is not accessible from any host. Maybe its unmounted or host is under maintainence mode")
+			return nil, nil, nil, nil, fmt.Errorf("the given datastore/StoragePool is not accessible from any host. Maybe its unmounted or host is under maintainence mode")
 		}
 		// if datastore is accessible from multiple host, ignore the error.
 	}
 
 	volumeInfoList, allPVCList, err := GetVolumesOnStoragePool(ctx, client, storagePoolName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get the list of volumes to be migrated")
+		return nil, nil, nil, nil, fmt.Errorf("failed to get the list of volumes to be migrated")
 	}
-	if len(volumeInfoList) == 0 {
-		log.Infof("No volume present in storagePool %v to migrate. ", storagePoolName)
-		return volumesToSPMap, nil
-	}
-
-	// for each volume assign a target sp for storage vMotion
-	rfMigrationPlanner := newRelaxedFitMigrationPlanner(volumeInfoList, spList.Items, allPVCList, accessibleNodes)
-	volumesToSPMap, err = rfMigrationPlanner.getMigrationPlan(ctx, client)
-
-	if err != nil {
-		return nil, err
-	}
-	return volumesToSPMap, nil
+	return volumeInfoList, spList.Items, allPVCList, accessibleNodes, nil
 }
 
 func getSPForPVCPlacement(ctx context.Context,
@@ -307,7 +345,7 @@ func getSPForPVCPlacement(ctx context.Context,
 	hostNames []string,
 	pvcList []v1.PersistentVolumeClaim,
 	spType string,
-	onlinePlacement bool) (StoragePoolInfo, error) {
+	onlinePlacement bool) (StoragePoolInfo, *placementDecision, error) {
 	log := logger.GetLogger(ctx)
 	assignedSP := StoragePoolInfo{}
 
@@ -319,7 +357,7 @@ func getSPForPVCPlacement(ctx context.Context,
 		if onlinePlacement {
 			stampPVCWithError(ctx, client, curPVC, invalidParamsErr)
 		}
-		return assignedSP, err
+		return assignedSP, nil, err
 	}
 
 	spList, err := preFilterSPList(ctx, sps, scName, hostNames, volSizeBytes)
@@ -328,14 +366,14 @@ func getSPForPVCPlacement(ctx context.Context,
 		if onlinePlacement {
 			stampPVCWithError(ctx, client, curPVC, genericErr)
 		}
-		return assignedSP, err
+		return assignedSP, nil, err
 	}
 	if len(spList) == 0 {
 		log.Infof("Did not find any matching storage pools for %s", curPVC.Name)
 		if onlinePlacement {
 			stampPVCWithError(ctx, client, curPVC, notEnoughResErr)
 		}
-		return assignedSP, fmt.Errorf("fail to find a StoragePool passing all criteria")
+		return assignedSP, nil, fmt.Errorf("fail to find a StoragePool passing all criteria")
 	}
 
 	xCapPendingSet := make(map[string]bool)
@@ -360,7 +398,9 @@ func getSPForPVCPlacement(ctx context.Context,
 	}
 	log.Infof("%d StoragePool(s) removed because of lack of capacity after considering usage based on any unbound PVCs: %v", len(xCapPendingSet), xCapPendingSet)
 
-	sort.Sort(byCOMBINATION(spList))
+	policy := getPlacementPolicy(ctx, client, scName)
+	spList = policy.filterByHeadroom(ctx, spList, volSizeBytes)
+	scores := policy.rank(spList, sps, pvcList)
 
 	spList = handleUsedStoragePools(ctx, curPVC, volSizeBytes, spList, pvcList, spType)
 
@@ -369,11 +409,12 @@ func getSPForPVCPlacement(ctx context.Context,
 		if onlinePlacement {
 			stampPVCWithError(ctx, client, curPVC, notEnoughResErr)
 		}
-		return assignedSP, fmt.Errorf("fail to find any compatible StoragePool due to volume placement constraints")
+		return assignedSP, nil, fmt.Errorf("fail to find any compatible StoragePool due to volume placement constraints")
 	}
 
 	assignedSP = spList[0]
-	return assignedSP, nil
+	log.Infof("Ranked StoragePools for PVC %s with scorers %v: %v", curPVC.Name, policy.Scorers, scores)
+	return assignedSP, &placementDecision{placementPolicy: policy, Selected: assignedSP.Name, Scores: scores}, nil
 }
 
 func stampPVCWithError(ctx context.Context, client kubernetes.Interface, curPVC *v1.PersistentVolumeClaim, errAnnotation string) {
@@ -435,7 +476,7 @@ func PlacePVConStoragePool(ctx context.Context, client kubernetes.Interface, top
 		return err
 	}
 
-	assignedSP, err := getSPForPVCPlacement(ctx, client, curPVC, volSizeBytes, sps.Items, hostNames, pvcList.Items, spType, true)
+	assignedSP, decision, err := getSPForPVCPlacement(ctx, client, curPVC, volSizeBytes, sps.Items, hostNames, pvcList.Items, spType, true)
 	if err != nil {
 		log.Errorf("Failed to find any SP to place PVC %v. Error :%v", curPVC.Name, err)
 		// we have already stamped PVC with corresponding error.
@@ -447,6 +488,7 @@ func PlacePVConStoragePool(ctx context.Context, client kubernetes.Interface, top
 		log.Errorf("setPVCAnnotation failed with %+v", err)
 		return err
 	}
+	setPVCPlacementDecision(ctx, client, curPVC, decision)
 
 	return nil
 }
@@ -544,9 +586,13 @@ func preFilterSPList(ctx context.Context, sps []unstructured.Unstructured, stora
 		}
 
 		if spSize > volSizeBytes+bufferDiskSize { //filter by capacity
+			spTotal, _, _ := unstructured.NestedInt64(sp.Object, "status", "capacity", "total")
+			nodes, _, _ := unstructured.NestedStringSlice(sp.Object, "status", "accessibleNodes")
 			spList = append(spList, StoragePoolInfo{
-				Name:           spName,
-				FreeCapInBytes: spSize,
+				Name:            spName,
+				FreeCapInBytes:  spSize,
+				TotalCapInBytes: spTotal,
+				AccessibleNodes: nodes,
 			})
 		} else {
 			notEnoughCapacity++
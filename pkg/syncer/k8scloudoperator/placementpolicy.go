@@ -0,0 +1,287 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8scloudoperator
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+)
+
+const (
+	// spPolicyScorers is a StorageClass annotation listing the comma separated scorers used to rank
+	// the StoragePools on which the PVCs of the StorageClass are placed
+	spPolicyScorers = "placement.beta.vmware.com/storagepool_scorers"
+	// spPolicyReservedHeadroom is a StorageClass annotation specifying the percentage of the capacity
+	// of the StoragePools that must remain free after placing the PVCs of the StorageClass
+	spPolicyReservedHeadroom = "placement.beta.vmware.com/storagepool_reservedHeadroomPercent"
+	// spPlacementDecisionAnnotationKey is a PVC annotation recording the placement policy, the selected
+	// StoragePool and the scores of the candidate StoragePools of the last placement of the PVC
+	spPlacementDecisionAnnotationKey = "placement.beta.vmware.com/storagepool_placementDecision"
+
+	// scorerFreeSpace prefers the StoragePools with the most free space. It is the default scorer.
+	scorerFreeSpace = "free-space"
+	// scorerSpreadHosts prefers the StoragePools accessible from the hosts with the fewest volumes
+	scorerSpreadHosts = "spread-hosts"
+	// scorerBinPack prefers the most used StoragePools so that whole disks are kept free
+	scorerBinPack = "binpack"
+	// scorerVolumeCount prefers the StoragePools with the fewest volumes
+	scorerVolumeCount = "balance-volume-count"
+)
+
+// placementScorer scores a candidate StoragePool for the placement of a volume.
+// Scores range from 0 to 1, the StoragePool with the highest score is preferred.
+type placementScorer interface {
+	score(sp StoragePoolInfo, state *placementState) float64
+}
+
+// placementState holds the usage of the StoragePools and hosts the scorers rank the candidates with
+type placementState struct {
+	// maxFreeCapInBytes is the largest free capacity of the candidate StoragePools
+	maxFreeCapInBytes int64
+	// spVolumeCount maps the StoragePool names to the number of volumes placed on them
+	spVolumeCount map[string]int
+	// nodeVolumeCount maps the node names to the number of volumes placed on the StoragePools they access
+	nodeVolumeCount map[string]int
+}
+
+type freeSpaceScorer struct{}
+
+func (freeSpaceScorer) score(sp StoragePoolInfo, state *placementState) float64 {
+	if state.maxFreeCapInBytes <= 0 {
+		return 0
+	}
+	return float64(sp.FreeCapInBytes) / float64(state.maxFreeCapInBytes)
+}
+
+type spreadHostsScorer struct{}
+
+func (spreadHostsScorer) score(sp StoragePoolInfo, state *placementState) float64 {
+	if len(sp.AccessibleNodes) == 0 {
+		return 0
+	}
+	// a StoragePool shared by several hosts is as loaded as its least loaded host
+	minCount := -1
+	for _, node := range sp.AccessibleNodes {
+		if count := state.nodeVolumeCount[node]; minCount < 0 || count < minCount {
+			minCount = count
+		}
+	}
+	return 1 / float64(1+minCount)
+}
+
+type binPackScorer struct{}
+
+func (binPackScorer) score(sp StoragePoolInfo, state *placementState) float64 {
+	if sp.TotalCapInBytes <= 0 {
+		return 0
+	}
+	return 1 - float64(sp.FreeCapInBytes)/float64(sp.TotalCapInBytes)
+}
+
+type volumeCountScorer struct{}
+
+func (volumeCountScorer) score(sp StoragePoolInfo, state *placementState) float64 {
+	return 1 / float64(1+state.spVolumeCount[sp.Name])
+}
+
+// storageClassLister is backed by the StorageClass informer so that the placement policy of
+// each placement is not fetched from the API server
+var storageClassLister storagelisters.StorageClassLister
+
+var placementScorers = map[string]placementScorer{
+	scorerFreeSpace:   freeSpaceScorer{},
+	scorerSpreadHosts: spreadHostsScorer{},
+	scorerBinPack:     binPackScorer{},
+	scorerVolumeCount: volumeCountScorer{},
+}
+
+// placementPolicy is the placement policy of the PVCs of a StorageClass
+type placementPolicy struct {
+	// Scorers are the names of the scorers ranking the StoragePools, their scores are averaged
+	Scorers []string `json:"scorers"`
+	// ReservedHeadroomPercent is the percentage of the capacity of the StoragePools that must remain free
+	ReservedHeadroomPercent int64 `json:"reservedHeadroomPercent,omitempty"`
+}
+
+// placementDecision records the placement of a PVC
+type placementDecision struct {
+	placementPolicy
+	// Selected is the name of the StoragePool selected for the PVC
+	Selected string `json:"selected"`
+	// Scores maps the names of the candidate StoragePools to their score
+	Scores map[string]float64 `json:"scores"`
+}
+
+// getPlacementPolicy returns the placement policy specified by the annotations of the given StorageClass.
+// Unknown scorers and invalid headroom percentages are ignored, if no valid scorer is specified the
+// StoragePools are ranked by free space.
+func getPlacementPolicy(ctx context.Context, client kubernetes.Interface, scName string) placementPolicy {
+	log := logger.GetLogger(ctx)
+	policy := placementPolicy{}
+	sc, err := getStorageClass(ctx, client, scName)
+	if err != nil {
+		log.Warnf("Failed to get StorageClass %s, using the default placement policy. Error: %v", scName, err)
+	} else {
+		for _, name := range strings.Split(sc.Annotations[spPolicyScorers], ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if _, ok := placementScorers[name]; !ok {
+				log.Warnf("Ignoring unknown StoragePool scorer %q of StorageClass %s", name, scName)
+				continue
+			}
+			policy.Scorers = append(policy.Scorers, name)
+		}
+		if value, ok := sc.Annotations[spPolicyReservedHeadroom]; ok {
+			headroom, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || headroom < 0 || headroom >= 100 {
+				log.Warnf("Ignoring invalid reserved headroom percentage %q of StorageClass %s", value, scName)
+			} else {
+				policy.ReservedHeadroomPercent = headroom
+			}
+		}
+	}
+	if len(policy.Scorers) == 0 {
+		policy.Scorers = []string{scorerFreeSpace}
+	}
+	return policy
+}
+
+// getStorageClass returns the StorageClass with the given name from the informer cache, falling back to
+// the API server if it is not cached yet
+func getStorageClass(ctx context.Context, client kubernetes.Interface, scName string) (*storagev1.StorageClass, error) {
+	if storageClassLister != nil {
+		sc, err := storageClassLister.Get(scName)
+		if !apierrors.IsNotFound(err) {
+			return sc, err
+		}
+	}
+	return client.StorageV1().StorageClasses().Get(ctx, scName, metav1.GetOptions{})
+}
+
+// filterByHeadroom removes the StoragePools that would not keep the reserved headroom free after
+// placing a volume of the given size
+func (policy placementPolicy) filterByHeadroom(ctx context.Context, spList []StoragePoolInfo,
+	volSizeBytes int64) []StoragePoolInfo {
+	log := logger.GetLogger(ctx)
+	if policy.ReservedHeadroomPercent == 0 {
+		return spList
+	}
+	filtered := []StoragePoolInfo{}
+	for _, sp := range spList {
+		reserved := sp.TotalCapInBytes * policy.ReservedHeadroomPercent / 100
+		if sp.FreeCapInBytes-volSizeBytes >= reserved {
+			filtered = append(filtered, sp)
+		}
+	}
+	log.Infof("%d StoragePool(s) removed to keep %d%% of their capacity free", len(spList)-len(filtered),
+		policy.ReservedHeadroomPercent)
+	return filtered
+}
+
+// rank sorts the given StoragePools by decreasing score and returns the score of each StoragePool.
+// StoragePools with the same score are ranked by free space, then by name.
+func (policy placementPolicy) rank(spList []StoragePoolInfo, sps []unstructured.Unstructured,
+	pvcList []v1.PersistentVolumeClaim) map[string]float64 {
+	state := newPlacementState(spList, sps, pvcList)
+	scores := make(map[string]float64, len(spList))
+	for _, sp := range spList {
+		total := 0.0
+		for _, name := range policy.Scorers {
+			total += placementScorers[name].score(sp, state)
+		}
+		scores[sp.Name] = total / float64(len(policy.Scorers))
+	}
+	sort.Sort(byCOMBINATION(spList))
+	sort.SliceStable(spList, func(i, j int) bool {
+		return scores[spList[i].Name] > scores[spList[j].Name]
+	})
+	return scores
+}
+
+// newPlacementState computes the usage of the StoragePools and hosts from the StoragePool annotation of the PVCs
+func newPlacementState(spList []StoragePoolInfo, sps []unstructured.Unstructured,
+	pvcList []v1.PersistentVolumeClaim) *placementState {
+	state := &placementState{
+		spVolumeCount:   make(map[string]int),
+		nodeVolumeCount: make(map[string]int),
+	}
+	for _, sp := range spList {
+		if sp.FreeCapInBytes > state.maxFreeCapInBytes {
+			state.maxFreeCapInBytes = sp.FreeCapInBytes
+		}
+	}
+	for _, pvc := range pvcList {
+		if spName, ok := pvc.Annotations[StoragePoolAnnotationKey]; ok {
+			state.spVolumeCount[spName]++
+		}
+	}
+	for _, sp := range sps {
+		count := state.spVolumeCount[sp.GetName()]
+		if count == 0 {
+			continue
+		}
+		nodes, _, _ := unstructured.NestedStringSlice(sp.Object, "status", "accessibleNodes")
+		for _, node := range nodes {
+			state.nodeVolumeCount[node] += count
+		}
+	}
+	return state
+}
+
+// setPVCPlacementDecision records the given placement decision on the PVC
+func setPVCPlacementDecision(ctx context.Context, client kubernetes.Interface, curPVC *v1.PersistentVolumeClaim,
+	decision *placementDecision) {
+	log := logger.GetLogger(ctx)
+	decisionBytes, err := json.Marshal(decision)
+	if err != nil {
+		log.Errorf("Fail to marshal placement decision: %+v", err)
+		return
+	}
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				spPlacementDecisionAnnotationKey: string(decisionBytes),
+			},
+		},
+	}
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		log.Errorf("Fail to marshal patch: %+v", err)
+		return
+	}
+	_, err = client.CoreV1().PersistentVolumeClaims(curPVC.Namespace).Patch(ctx, curPVC.Name, k8stypes.MergePatchType,
+		patchBytes, metav1.PatchOptions{})
+	if err != nil {
+		log.Errorf("Fail to record placement decision on PVC %s/%s: %+v", curPVC.Namespace, curPVC.Name, err)
+	}
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8scloudoperator
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestStorageClass(name string, annotations map[string]string) *storagev1.StorageClass {
+	return &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+}
+
+func TestGetPlacementPolicy(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		newTestStorageClass("default", nil),
+		newTestStorageClass("binpack", map[string]string{
+			spPolicyScorers:          "binpack, unknown,,spread-hosts",
+			spPolicyReservedHeadroom: "20",
+		}),
+		newTestStorageClass("invalid", map[string]string{
+			spPolicyScorers:          "unknown",
+			spPolicyReservedHeadroom: "100",
+		}),
+	)
+	tests := []struct {
+		scName   string
+		expected placementPolicy
+	}{
+		{"default", placementPolicy{Scorers: []string{scorerFreeSpace}}},
+		{"binpack", placementPolicy{Scorers: []string{scorerBinPack, scorerSpreadHosts}, ReservedHeadroomPercent: 20}},
+		{"invalid", placementPolicy{Scorers: []string{scorerFreeSpace}}},
+		{"missing", placementPolicy{Scorers: []string{scorerFreeSpace}}},
+	}
+	for _, test := range tests {
+		if policy := getPlacementPolicy(ctx, client, test.scName); !reflect.DeepEqual(policy, test.expected) {
+			t.Errorf("StorageClass %s: expected policy %+v, got %+v", test.scName, test.expected, policy)
+		}
+	}
+}
+
+// TestGetPlacementPolicyFromLister verifies that the StorageClasses are read from the informer cache
+// and only fetched from the API server if they are not cached yet
+func TestGetPlacementPolicyFromLister(t *testing.T) {
+	ctx := context.Background()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := indexer.Add(newTestStorageClass("cached", map[string]string{spPolicyScorers: scorerVolumeCount})); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { storageClassLister = nil }()
+	storageClassLister = storagelisters.NewStorageClassLister(indexer)
+	client := fake.NewSimpleClientset(
+		newTestStorageClass("cached", map[string]string{spPolicyScorers: scorerBinPack}),
+		newTestStorageClass("uncached", map[string]string{spPolicyScorers: scorerSpreadHosts}),
+	)
+
+	if policy := getPlacementPolicy(ctx, client, "cached"); !reflect.DeepEqual(policy.Scorers, []string{scorerVolumeCount}) {
+		t.Errorf("expected the scorers of the cached StorageClass, got %v", policy.Scorers)
+	}
+	if len(client.Actions()) != 0 {
+		t.Errorf("expected no request to the API server, got %v", client.Actions())
+	}
+	if policy := getPlacementPolicy(ctx, client, "uncached"); !reflect.DeepEqual(policy.Scorers, []string{scorerSpreadHosts}) {
+		t.Errorf("expected the scorers of the uncached StorageClass, got %v", policy.Scorers)
+	}
+}
+
+func TestFilterByHeadroom(t *testing.T) {
+	ctx := context.Background()
+	spList := []StoragePoolInfo{
+		{Name: "sp-1", FreeCapInBytes: 50 * gib, TotalCapInBytes: 100 * gib},
+		{Name: "sp-2", FreeCapInBytes: 30 * gib, TotalCapInBytes: 100 * gib},
+		{Name: "sp-3", FreeCapInBytes: 25 * gib, TotalCapInBytes: 50 * gib},
+	}
+	tests := []struct {
+		headroom int64
+		volSize  int64
+		expected []string
+	}{
+		{0, 40 * gib, []string{"sp-1", "sp-2", "sp-3"}},
+		{20, 10 * gib, []string{"sp-1", "sp-2", "sp-3"}},
+		{20, 15 * gib, []string{"sp-1", "sp-3"}},
+		{20, 30 * gib, []string{"sp-1"}},
+		{50, 1 * gib, []string{}},
+	}
+	for _, test := range tests {
+		policy := placementPolicy{Scorers: []string{scorerFreeSpace}, ReservedHeadroomPercent: test.headroom}
+		names := []string{}
+		for _, sp := range policy.filterByHeadroom(ctx, spList, test.volSize) {
+			names = append(names, sp.Name)
+		}
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("headroom %d%%, volume of %d bytes: expected %v, got %v", test.headroom, test.volSize,
+				test.expected, names)
+		}
+	}
+}
+
+func TestPlacementScorers(t *testing.T) {
+	state := &placementState{
+		maxFreeCapInBytes: 80 * gib,
+		spVolumeCount:     map[string]int{"sp-1": 3},
+		nodeVolumeCount:   map[string]int{"node-1": 3, "node-2": 1},
+	}
+	tests := []struct {
+		scorer   string
+		sp       StoragePoolInfo
+		expected float64
+	}{
+		{scorerFreeSpace, StoragePoolInfo{Name: "sp-1", FreeCapInBytes: 20 * gib}, 0.25},
+		{scorerFreeSpace, StoragePoolInfo{Name: "sp-2", FreeCapInBytes: 80 * gib}, 1},
+		{scorerBinPack, StoragePoolInfo{Name: "sp-1", FreeCapInBytes: 20 * gib, TotalCapInBytes: 80 * gib}, 0.75},
+		{scorerBinPack, StoragePoolInfo{Name: "sp-2"}, 0},
+		{scorerVolumeCount, StoragePoolInfo{Name: "sp-1"}, 0.25},
+		{scorerVolumeCount, StoragePoolInfo{Name: "sp-2"}, 1},
+		{scorerSpreadHosts, StoragePoolInfo{Name: "sp-1", AccessibleNodes: []string{"node-1"}}, 0.25},
+		{scorerSpreadHosts, StoragePoolInfo{Name: "sp-2", AccessibleNodes: []string{"node-1", "node-2"}}, 0.5},
+		{scorerSpreadHosts, StoragePoolInfo{Name: "sp-3"}, 0},
+	}
+	for _, test := range tests {
+		if score := placementScorers[test.scorer].score(test.sp, state); math.Abs(score-test.expected) > 1e-9 {
+			t.Errorf("scorer %s, StoragePool %+v: expected score %v, got %v", test.scorer, test.sp, test.expected, score)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	sps := []unstructured.Unstructured{}
+	for name, nodes := range map[string][]interface{}{
+		"sp-1": {"node-1"},
+		"sp-2": {"node-2"},
+		"sp-3": {"node-3"},
+	} {
+		sp := unstructured.Unstructured{Object: map[string]interface{}{
+			"status": map[string]interface{}{"accessibleNodes": nodes},
+		}}
+		sp.SetName(name)
+		sps = append(sps, sp)
+	}
+	pvcList := []v1.PersistentVolumeClaim{}
+	for _, spName := range []string{"sp-1", "sp-1", "sp-2"} {
+		pvcList = append(pvcList, v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{StoragePoolAnnotationKey: spName},
+		}})
+	}
+	newSPList := func() []StoragePoolInfo {
+		return []StoragePoolInfo{
+			{Name: "sp-1", FreeCapInBytes: 60 * gib, TotalCapInBytes: 100 * gib, AccessibleNodes: []string{"node-1"}},
+			{Name: "sp-2", FreeCapInBytes: 20 * gib, TotalCapInBytes: 100 * gib, AccessibleNodes: []string{"node-2"}},
+			{Name: "sp-3", FreeCapInBytes: 20 * gib, TotalCapInBytes: 40 * gib, AccessibleNodes: []string{"node-3"}},
+		}
+	}
+	tests := []struct {
+		scorers  []string
+		expected []string
+	}{
+		{[]string{scorerFreeSpace}, []string{"sp-1", "sp-2", "sp-3"}},
+		{[]string{scorerBinPack}, []string{"sp-2", "sp-3", "sp-1"}},
+		{[]string{scorerVolumeCount}, []string{"sp-3", "sp-2", "sp-1"}},
+		{[]string{scorerSpreadHosts}, []string{"sp-3", "sp-2", "sp-1"}},
+		// free space: 1, 1/3, 1/3 and binpack: 0.4, 0.8, 0.5
+		{[]string{scorerFreeSpace, scorerBinPack}, []string{"sp-1", "sp-2", "sp-3"}},
+	}
+	for _, test := range tests {
+		spList := newSPList()
+		scores := placementPolicy{Scorers: test.scorers}.rank(spList, sps, pvcList)
+		names := []string{}
+		for _, sp := range spList {
+			names = append(names, sp.Name)
+		}
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("scorers %v: expected ranking %v, got %v with scores %v", test.scorers, test.expected, names, scores)
+		}
+		if len(scores) != len(spList) {
+			t.Errorf("scorers %v: expected a score for each StoragePool, got %v", test.scorers, scores)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8scloudoperator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+)
+
+// podAnnotationTimeout is the time after which waiting for the vmuuid annotation of a pod errors out
+const podAnnotationTimeout = 5 * time.Minute
+
+// podEvent is the notification of a waiter of a pod, pod is nil if the pod is deleted
+type podEvent struct {
+	pod *v1.Pod
+}
+
+// podAnnotationWaiters notifies the callers waiting for the vmuuid annotation of a pod from the events
+// of the pod informer
+type podAnnotationWaiters struct {
+	lock sync.Mutex
+	// waiters are the channels of the callers waiting for each pod by namespace/name
+	waiters map[string]map[chan podEvent]bool
+}
+
+func newPodAnnotationWaiters() *podAnnotationWaiters {
+	return &podAnnotationWaiters{waiters: make(map[string]map[chan podEvent]bool)}
+}
+
+// add registers a waiter for the given pod. The returned function unregisters it.
+func (w *podAnnotationWaiters) add(podNamespace, podName string) (chan podEvent, func()) {
+	key := podNamespace + "/" + podName
+	// buffered so that notifying never blocks the informer, only the latest event matters
+	ch := make(chan podEvent, 1)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.waiters[key] == nil {
+		w.waiters[key] = make(map[chan podEvent]bool)
+	}
+	w.waiters[key][ch] = true
+	return ch, func() {
+		w.lock.Lock()
+		defer w.lock.Unlock()
+		delete(w.waiters[key], ch)
+		if len(w.waiters[key]) == 0 {
+			delete(w.waiters, key)
+		}
+	}
+}
+
+// notify sends the given event to the waiters of the given pod
+func (w *podAnnotationWaiters) notify(podNamespace, podName string, event podEvent) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for ch := range w.waiters[podNamespace+"/"+podName] {
+		// drop the pending event, if any, in favor of the latest one
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- event
+	}
+}
+
+func (w *podAnnotationWaiters) podAdded(obj interface{}) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok || pod == nil {
+		return
+	}
+	if _, exists := pod.Annotations[vmUUIDLabel]; exists {
+		w.notify(pod.Namespace, pod.Name, podEvent{pod: pod})
+	}
+}
+
+func (w *podAnnotationWaiters) podUpdated(oldObj, newObj interface{}) {
+	w.podAdded(newObj)
+}
+
+func (w *podAnnotationWaiters) podDeleted(obj interface{}) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		if pod, ok = tombstone.Obj.(*v1.Pod); !ok {
+			return
+		}
+	}
+	w.notify(pod.Namespace, pod.Name, podEvent{})
+}
+
+// waitForPodVMUUIDAnnotation waits until the vmuuid annotation is set on the given pod and returns it.
+// It errors out if the pod is deleted, the annotation is not set within podAnnotationTimeout or ctx is done.
+func (k8sCloudOperator *k8sCloudOperator) waitForPodVMUUIDAnnotation(ctx context.Context, podNamespace string,
+	podName string) (string, error) {
+	log := logger.GetLogger(ctx)
+	// the waiter is registered before looking up the pod, so that an annotation set in between is not missed
+	events, done := k8sCloudOperator.podWaiters.add(podNamespace, podName)
+	defer done()
+
+	pod, err := k8sCloudOperator.podLister.Pods(podNamespace).Get(podName)
+	if apierrors.IsNotFound(err) {
+		// the informer cache may lag behind the pod found by the caller
+		pod, err = k8sCloudOperator.k8sClient.CoreV1().Pods(podNamespace).Get(ctx, podName, metav1.GetOptions{})
+	}
+	if err != nil {
+		log.Errorf("Failed to get the pod with name: %s on namespace: %s. Error: %+v", podName, podNamespace, err)
+		return "", err
+	}
+	if vmuuid, exists := pod.Annotations[vmUUIDLabel]; exists {
+		return vmuuid, nil
+	}
+	log.Debugf("Waiting for %s annotation in Pod: %s on namespace: %s", vmUUIDLabel, podName, podNamespace)
+
+	timer := time.NewTimer(podAnnotationTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case event := <-events:
+			if event.pod == nil {
+				return "", fmt.Errorf("pod with name: %s on namespace: %s was deleted before the %s annotation was set",
+					podName, podNamespace, vmUUIDLabel)
+			}
+			if vmuuid, exists := event.pod.Annotations[vmUUIDLabel]; exists {
+				return vmuuid, nil
+			}
+		case <-timer.C:
+			return "", fmt.Errorf("unable to find pod with name: %s and annotation: %s on namespace: %s in timeout: %v period",
+				podName, vmUUIDLabel, podNamespace, podAnnotationTimeout)
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
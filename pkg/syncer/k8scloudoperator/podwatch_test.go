@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8scloudoperator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestWaitForPodVMUUIDAnnotation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "ns"}}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := indexer.Add(pod); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	operator := &k8sCloudOperator{
+		k8sClient:  fake.NewSimpleClientset(pod),
+		podLister:  corelisters.NewPodLister(indexer),
+		podWaiters: newPodAnnotationWaiters(),
+	}
+
+	type result struct {
+		vmuuid string
+		err    error
+	}
+	wait := func() chan result {
+		results := make(chan result, 1)
+		go func() {
+			vmuuid, err := operator.waitForPodVMUUIDAnnotation(ctx, pod.Namespace, pod.Name)
+			results <- result{vmuuid, err}
+		}()
+		// let the waiter register before sending the pod events
+		for {
+			operator.podWaiters.lock.Lock()
+			registered := len(operator.podWaiters.waiters) > 0
+			operator.podWaiters.lock.Unlock()
+			if registered {
+				return results
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// the annotation is returned as soon as the pod is updated with it
+	results := wait()
+	operator.podWaiters.podUpdated(pod, pod.DeepCopy())
+	annotatedPod := pod.DeepCopy()
+	annotatedPod.Annotations = map[string]string{vmUUIDLabel: "vm-uuid-1"}
+	operator.podWaiters.podUpdated(pod, annotatedPod)
+	if res := <-results; res.err != nil || res.vmuuid != "vm-uuid-1" {
+		t.Errorf("expected vmuuid vm-uuid-1, got %q with error %v", res.vmuuid, res.err)
+	}
+	if len(operator.podWaiters.waiters) != 0 {
+		t.Errorf("expected the waiter to be unregistered, got %v", operator.podWaiters.waiters)
+	}
+
+	// the wait errors out when the pod is deleted
+	results = wait()
+	operator.podWaiters.podDeleted(cache.DeletedFinalStateUnknown{Key: "ns/pod-1", Obj: pod})
+	if res := <-results; res.err == nil {
+		t.Errorf("expected an error for a deleted pod, got vmuuid %q", res.vmuuid)
+	}
+
+	// the annotation already in the cache is returned immediately
+	if err := indexer.Update(annotatedPod); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	vmuuid, err := operator.waitForPodVMUUIDAnnotation(ctx, pod.Namespace, pod.Name)
+	if err != nil || vmuuid != "vm-uuid-1" {
+		t.Errorf("expected vmuuid vm-uuid-1, got %q with error %v", vmuuid, err)
+	}
+}
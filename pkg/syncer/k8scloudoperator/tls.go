@@ -0,0 +1,316 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8scloudoperator
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+	k8s "sigs.k8s.io/vsphere-csi-driver/pkg/kubernetes"
+)
+
+const (
+	// tlsSecretEnv is the environment variable with the <namespace>/<name> of the Secret holding the certificates
+	// of the K8s Cloud Operator gRPC service. The Secret has the tls.crt and tls.key keys of a kubernetes.io/tls
+	// Secret and the ca.crt key with the CA used to verify the certificates of the peers.
+	// If not set, the service is served and dialed without TLS.
+	tlsSecretEnv = "K8S_CLOUD_OPERATOR_TLS_SECRET"
+	// tlsServerNameEnv is the environment variable with the name the certificate of the service is verified
+	// against by its clients. If not set, "localhost" is used.
+	tlsServerNameEnv = "K8S_CLOUD_OPERATOR_TLS_SERVER_NAME"
+	// rpcAllowListEnv is the environment variable with the client identities allowed to call each RPC, in the
+	// format <method>=<identity>,<identity>;<method>=<identity>. The identity of a client is the common name or a
+	// DNS name of its certificate, and the method "*" applies to all the RPCs.
+	// If not set, all the clients with a certificate signed by the CA are allowed to call all the RPCs.
+	rpcAllowListEnv = "K8S_CLOUD_OPERATOR_RPC_ALLOW_LIST"
+
+	defaultTLSServerName = "localhost"
+	caCertKey            = "ca.crt"
+	allRPCs              = "*"
+	// healthServicePrefix is the prefix of the methods of the gRPC health service. They can be called without a
+	// client certificate so that probes can check the health of the service.
+	healthServicePrefix = "/grpc.health.v1.Health/"
+)
+
+var (
+	clientCertReloader     *certReloader
+	clientCertReloaderLock sync.Mutex
+)
+
+// certReloader keeps the certificates of the K8s Cloud Operator gRPC service up to date with their Secret
+type certReloader struct {
+	lock   sync.RWMutex
+	cert   *tls.Certificate
+	caPool *x509.CertPool
+}
+
+// newCertReloader loads the certificates from the given Secret and watches it to reload them when it changes
+func newCertReloader(ctx context.Context, secretNamespacedName string) (*certReloader, error) {
+	log := logger.GetLogger(ctx)
+	parts := strings.Split(secretNamespacedName, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid value %q of %s, expected <namespace>/<name>", secretNamespacedName, tlsSecretEnv)
+	}
+	namespace, name := parts[0], parts[1]
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		log.Errorf("Creating Kubernetes client failed. Err: %v", err)
+		return nil, err
+	}
+	secret, err := k8sClient.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		log.Errorf("Failed to get the TLS Secret %s. Err: %v", secretNamespacedName, err)
+		return nil, err
+	}
+	reloader := &certReloader{}
+	if err = reloader.load(secret); err != nil {
+		log.Errorf("Failed to load the certificates from the TLS Secret %s. Err: %v", secretNamespacedName, err)
+		return nil, err
+	}
+
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(k8sClient, 0, informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}))
+	secretInformer := informerFactory.Core().V1().Secrets().Informer()
+	reload := func(obj interface{}) {
+		secret, ok := obj.(*v1.Secret)
+		if !ok {
+			return
+		}
+		// keep serving with the previous certificates if the new ones are invalid
+		if err := reloader.load(secret); err != nil {
+			log.Errorf("Failed to reload the certificates from the TLS Secret %s. Err: %v", secretNamespacedName, err)
+			return
+		}
+		log.Infof("Reloaded the certificates from the TLS Secret %s", secretNamespacedName)
+	}
+	secretInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    reload,
+		UpdateFunc: func(oldObj, newObj interface{}) { reload(newObj) },
+	})
+	go informerFactory.Start(ctx.Done())
+	return reloader, nil
+}
+
+// load parses the certificates of the given Secret and replaces the current ones
+func (r *certReloader) load(secret *v1.Secret) error {
+	cert, err := tls.X509KeyPair(secret.Data[v1.TLSCertKey], secret.Data[v1.TLSPrivateKeyKey])
+	if err != nil {
+		return err
+	}
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(secret.Data[caCertKey]) {
+		return fmt.Errorf("no CA certificate found in key %s", caCertKey)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.cert = &cert
+	r.caPool = caPool
+	return nil
+}
+
+func (r *certReloader) get() (*tls.Certificate, *x509.CertPool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.cert, r.caPool
+}
+
+// serverTLSConfig returns a TLS config verifying the client certificates with the current certificates at each
+// handshake. The handshake succeeds without a client certificate so that the health service can be probed, the
+// other RPCs are rejected by authorize if the client did not present a verified certificate.
+func (r *certReloader) serverTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, caPool := r.get()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				ClientAuth:   tls.VerifyClientCertIfGiven,
+				ClientCAs:    caPool,
+			}, nil
+		},
+	}
+}
+
+// clientTLSConfig returns a TLS config presenting the current client certificate and verifying the certificate
+// of the service
+func (r *certReloader) clientTLSConfig(serverName string) *tls.Config {
+	cert, caPool := r.get()
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*cert},
+		RootCAs:      caPool,
+		ServerName:   serverName,
+	}
+}
+
+// rpcAllowList is the client identities allowed to call each RPC of the K8s Cloud Operator gRPC service
+type rpcAllowList map[string]map[string]bool
+
+// parseRPCAllowList parses an allow list in the format of K8S_CLOUD_OPERATOR_RPC_ALLOW_LIST.
+// An empty allow list allows all the clients.
+func parseRPCAllowList(value string) (rpcAllowList, error) {
+	allowList := make(rpcAllowList)
+	for _, rule := range strings.Split(value, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		parts := strings.SplitN(rule, "=", 2)
+		method := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || method == "" {
+			return nil, fmt.Errorf("invalid rule %q in %s, expected <method>=<identity>,<identity>", rule, rpcAllowListEnv)
+		}
+		if allowList[method] == nil {
+			allowList[method] = make(map[string]bool)
+		}
+		for _, identity := range strings.Split(parts[1], ",") {
+			if identity = strings.TrimSpace(identity); identity != "" {
+				allowList[method][identity] = true
+			}
+		}
+	}
+	return allowList, nil
+}
+
+// isAllowed tells whether a client with the given identities is allowed to call the given method.
+// fullMethod is in the format /<service>/<method>.
+func (allowList rpcAllowList) isAllowed(fullMethod string, identities []string) bool {
+	if len(allowList) == 0 {
+		return true
+	}
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	for _, identity := range identities {
+		if allowList[method][identity] || allowList[allRPCs][identity] {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize checks that the client of the given call presented a verified certificate and is allowed to call the
+// given method. The methods of the health service are not authorized.
+func (allowList rpcAllowList) authorize(ctx context.Context, fullMethod string) error {
+	log := logger.GetLogger(ctx)
+	if strings.HasPrefix(fullMethod, healthServicePrefix) {
+		return nil
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Errorf(codes.Unauthenticated, "no peer found for %s", fullMethod)
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return status.Errorf(codes.Unauthenticated, "no verified client certificate for %s", fullMethod)
+	}
+	clientCert := tlsInfo.State.VerifiedChains[0][0]
+	identities := append([]string{clientCert.Subject.CommonName}, clientCert.DNSNames...)
+	if !allowList.isAllowed(fullMethod, identities) {
+		log.Warnf("Denied call of %s by client %v", fullMethod, identities)
+		return status.Errorf(codes.PermissionDenied, "client %v is not allowed to call %s", identities, fullMethod)
+	}
+	return nil
+}
+
+func (allowList rpcAllowList) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	if err := allowList.authorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (allowList rpcAllowList) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	if err := allowList.authorize(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// getServerOptions returns the options of the K8s Cloud Operator gRPC server. If the TLS Secret is set, the server
+// requires mutual TLS for all the RPCs but the ones of the health service and authorizes the calls with the RPC
+// allow list.
+func getServerOptions(ctx context.Context) ([]grpc.ServerOption, error) {
+	log := logger.GetLogger(ctx)
+	secretNamespacedName := os.Getenv(tlsSecretEnv)
+	if secretNamespacedName == "" {
+		log.Warnf("%s is not set, K8s Cloud Operator gRPC service will be served without TLS", tlsSecretEnv)
+		return nil, nil
+	}
+	allowList, err := parseRPCAllowList(os.Getenv(rpcAllowListEnv))
+	if err != nil {
+		log.Errorf("Failed to parse the RPC allow list. Err: %v", err)
+		return nil, err
+	}
+	reloader, err := newCertReloader(ctx, secretNamespacedName)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("K8s Cloud Operator gRPC service will be served with mutual TLS using Secret %s", secretNamespacedName)
+	return []grpc.ServerOption{
+		grpc.Creds(credentials.NewTLS(reloader.serverTLSConfig())),
+		grpc.UnaryInterceptor(allowList.unaryInterceptor),
+		grpc.StreamInterceptor(allowList.streamInterceptor),
+	}, nil
+}
+
+// GetClientDialOptions returns the options to dial the K8s Cloud Operator gRPC service. If the TLS Secret is set,
+// the client presents the certificate of the Secret and verifies the certificate of the service with its CA.
+// The Secret is watched from the first call on, so that each new connection uses the latest certificates.
+func GetClientDialOptions(ctx context.Context) ([]grpc.DialOption, error) {
+	secretNamespacedName := os.Getenv(tlsSecretEnv)
+	if secretNamespacedName == "" {
+		return []grpc.DialOption{grpc.WithInsecure()}, nil
+	}
+	clientCertReloaderLock.Lock()
+	defer clientCertReloaderLock.Unlock()
+	if clientCertReloader == nil {
+		// the Secret is watched for the lifetime of the process, independently of the context of this call
+		reloader, err := newCertReloader(logger.NewContextWithLogger(context.Background()), secretNamespacedName)
+		if err != nil {
+			return nil, err
+		}
+		clientCertReloader = reloader
+	}
+	serverName := os.Getenv(tlsServerNameEnv)
+	if serverName == "" {
+		serverName = defaultTLSServerName
+	}
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(credentials.NewTLS(clientCertReloader.clientTLSConfig(serverName))),
+	}, nil
+}
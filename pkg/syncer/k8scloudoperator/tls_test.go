@@ -0,0 +1,228 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8scloudoperator
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestRPCAllowList(t *testing.T) {
+	allowList, err := parseRPCAllowList("GetPodVMUUIDAnnotation = csi-controller, csi-attacher ; *=admin;GetStorageVMotionPlan=")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		method     string
+		identities []string
+		expected   bool
+	}{
+		{"/k8scloudoperator.K8sCloudOperator/GetPodVMUUIDAnnotation", []string{"csi-controller"}, true},
+		{"/k8scloudoperator.K8sCloudOperator/GetPodVMUUIDAnnotation", []string{"unknown", "csi-attacher"}, true},
+		{"/k8scloudoperator.K8sCloudOperator/GetPodVMUUIDAnnotation", []string{"unknown"}, false},
+		{"/k8scloudoperator.K8sCloudOperator/PlacePersistenceVolumeClaim", []string{"csi-controller"}, false},
+		{"/k8scloudoperator.K8sCloudOperator/PlacePersistenceVolumeClaim", []string{"admin"}, true},
+		{"/k8scloudoperator.K8sCloudOperator/GetStorageVMotionPlan", []string{"csi-controller"}, false},
+	}
+	for _, test := range tests {
+		if allowed := allowList.isAllowed(test.method, test.identities); allowed != test.expected {
+			t.Errorf("expected %s allowed for %v to be %v, got %v", test.method, test.identities, test.expected, allowed)
+		}
+	}
+
+	emptyAllowList, err := parseRPCAllowList("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !emptyAllowList.isAllowed("/k8scloudoperator.K8sCloudOperator/GetHostAnnotation", []string{"anyone"}) {
+		t.Errorf("expected an empty allow list to allow all the clients")
+	}
+
+	if _, err = parseRPCAllowList("GetHostAnnotation"); err == nil {
+		t.Errorf("expected an error for a rule without identities")
+	}
+}
+
+// testCA signs the certificates of the mutual TLS tests
+type testCA struct {
+	cert    *x509.Certificate
+	certPEM []byte
+	key     *ecdsa.PrivateKey
+	serial  int64
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), key: key, serial: 1}
+}
+
+// newSecret returns a TLS Secret with a certificate signed by the CA for the given common name and DNS names
+func (ca *testCA) newSecret(t *testing.T, commonName string, dnsNames ...string) *v1.Secret {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.serial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(ca.serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &v1.Secret{Data: map[string][]byte{
+		v1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		v1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		caCertKey:           ca.certPEM,
+	}}
+}
+
+func newTestCertReloader(t *testing.T, secret *v1.Secret) *certReloader {
+	reloader := &certReloader{}
+	if err := reloader.load(secret); err != nil {
+		t.Fatal(err)
+	}
+	return reloader
+}
+
+// testK8sCloudOperatorServer only implements GetHostAnnotation
+type testK8sCloudOperatorServer struct {
+	K8SCloudOperatorServer
+}
+
+func (s *testK8sCloudOperatorServer) GetHostAnnotation(ctx context.Context, req *HostAnnotationRequest) (*HostAnnotationResponse, error) {
+	return &HostAnnotationResponse{AnnotationValue: "value"}, nil
+}
+
+// TestMutualTLS serves the K8s Cloud Operator service with mutual TLS and the given allow list, and checks the
+// handshake and authorization of clients with various certificates
+func TestMutualTLS(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	ca := newTestCA(t)
+	serverReloader := newTestCertReloader(t, ca.newSecret(t, "k8s-cloud-operator", defaultTLSServerName))
+	allowList, err := parseRPCAllowList("GetHostAnnotation=csi-controller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(serverReloader.serverTLSConfig())),
+		grpc.UnaryInterceptor(allowList.unaryInterceptor),
+		grpc.StreamInterceptor(allowList.streamInterceptor),
+	)
+	RegisterK8SCloudOperatorServer(grpcServer, &testK8sCloudOperatorServer{})
+	healthpb.RegisterHealthServer(grpcServer, health.NewServer())
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = grpcServer.Serve(listener) }()
+	defer grpcServer.Stop()
+
+	// dial returns a connection to the service verifying its certificate with the CA of the given Secret and
+	// presenting the client certificate of the given Secret, if any
+	dial := func(caSecret *v1.Secret, clientSecret *v1.Secret) *grpc.ClientConn {
+		tlsConfig := newTestCertReloader(t, caSecret).clientTLSConfig(defaultTLSServerName)
+		tlsConfig.Certificates = nil
+		if clientSecret != nil {
+			tlsConfig.Certificates = newTestCertReloader(t, clientSecret).clientTLSConfig(defaultTLSServerName).Certificates
+		}
+		conn, err := grpc.DialContext(ctx, listener.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	otherCA := newTestCA(t)
+	serverCASecret := ca.newSecret(t, "client")
+	tests := []struct {
+		name           string
+		caSecret       *v1.Secret
+		clientSecret   *v1.Secret
+		expectedCode   codes.Code
+		expectedHealth codes.Code
+	}{
+		{"allowed common name", serverCASecret, ca.newSecret(t, "csi-controller"), codes.OK, codes.OK},
+		{"allowed DNS name", serverCASecret, ca.newSecret(t, "other", "csi-controller"), codes.OK, codes.OK},
+		{"client not in the allow list", serverCASecret, ca.newSecret(t, "other"), codes.PermissionDenied, codes.OK},
+		// the health service can be probed without a client certificate, the other RPCs cannot
+		{"no client certificate", serverCASecret, nil, codes.Unauthenticated, codes.OK},
+		// the handshake fails as the service does not trust the CA of the client
+		{"client certificate of another CA", serverCASecret, otherCA.newSecret(t, "csi-controller"), codes.Unavailable, codes.Unavailable},
+		// the handshake fails as the client does not trust the CA of the service
+		{"service certificate of another CA", otherCA.newSecret(t, "client"), ca.newSecret(t, "csi-controller"), codes.Unavailable, codes.Unavailable},
+	}
+	for _, test := range tests {
+		conn := dial(test.caSecret, test.clientSecret)
+		_, err := NewK8SCloudOperatorClient(conn).GetHostAnnotation(ctx, &HostAnnotationRequest{HostName: "host"})
+		if code := status.Code(err); code != test.expectedCode {
+			t.Errorf("%s: expected GetHostAnnotation to return %v, got %v", test.name, test.expectedCode, err)
+		}
+		_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		if code := status.Code(err); code != test.expectedHealth {
+			t.Errorf("%s: expected the health check to return %v, got %v", test.name, test.expectedHealth, err)
+		}
+		conn.Close()
+	}
+}
@@ -44,7 +44,9 @@ import (
 	cnsconfig "sigs.k8s.io/vsphere-csi-driver/pkg/common/config"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/prometheus"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/tracing"
 	csitypes "sigs.k8s.io/vsphere-csi-driver/pkg/csi/types"
 	k8s "sigs.k8s.io/vsphere-csi-driver/pkg/kubernetes"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/syncer/storagepool"
@@ -89,12 +91,16 @@ func getFullSyncIntervalInMin(ctx context.Context) int {
 }
 
 // getVolumeHealthIntervalInMin returns the VolumeHealthInterval
-// If environment variable VOLUME_HEALTH_STATUS_INTERVAL_MINUTES is set and valid,
-// return the interval value read from environment variable
+// If environment variable VOLUME_HEALTH_INTERVAL_MINUTES is set and valid,
+// return the interval value read from environment variable,
+// otherwise the interval configured for the cluster in the vsphere config secret, if any,
 // otherwise, use the default value 5 minutes
-func getVolumeHealthIntervalInMin(ctx context.Context) int {
+func getVolumeHealthIntervalInMin(ctx context.Context, configuredIntervalInMin int) int {
 	log := logger.GetLogger(ctx)
 	volumeHealthIntervalInMin := defaultVolumeHealthIntervalInMin
+	if configuredIntervalInMin > 0 {
+		volumeHealthIntervalInMin = configuredIntervalInMin
+	}
 	if v := os.Getenv("VOLUME_HEALTH_INTERVAL_MINUTES"); v != "" {
 		if value, err := strconv.Atoi(v); err == nil {
 			if value <= 0 {
@@ -110,6 +116,58 @@ func getVolumeHealthIntervalInMin(ctx context.Context) int {
 	return volumeHealthIntervalInMin
 }
 
+// isFullSyncDryRunEnabled returns true if environment variable FULL_SYNC_DRY_RUN is set to true,
+// in which case full sync computes the create, update and delete operations without performing them
+func isFullSyncDryRunEnabled(ctx context.Context) bool {
+	log := logger.GetLogger(ctx)
+	if v := os.Getenv("FULL_SYNC_DRY_RUN"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			log.Warnf("FullSync: value set in env variable FULL_SYNC_DRY_RUN %s is invalid, dry run is disabled", v)
+			return false
+		}
+		return dryRun
+	}
+	return false
+}
+
+// getStoragePolicyComplianceIntervalInMin returns the interval for storage policy compliance monitoring
+// If environment variable STORAGE_POLICY_COMPLIANCE_INTERVAL_MINUTES is set and valid,
+// return the interval value read from environment variable
+// otherwise, use the default value 30 minutes
+func getStoragePolicyComplianceIntervalInMin(ctx context.Context) int {
+	log := logger.GetLogger(ctx)
+	complianceIntervalInMin := defaultStoragePolicyComplianceIntervalInMin
+	if v := os.Getenv("STORAGE_POLICY_COMPLIANCE_INTERVAL_MINUTES"); v != "" {
+		if value, err := strconv.Atoi(v); err == nil {
+			if value <= 0 {
+				log.Warnf("StoragePolicyCompliance: interval set in env variable STORAGE_POLICY_COMPLIANCE_INTERVAL_MINUTES %s is equal or less than 0, will use the default interval", v)
+			} else {
+				complianceIntervalInMin = value
+				log.Infof("StoragePolicyCompliance: interval is set to %d minutes", complianceIntervalInMin)
+			}
+		} else {
+			log.Warnf("StoragePolicyCompliance: interval set in env variable STORAGE_POLICY_COMPLIANCE_INTERVAL_MINUTES %s is invalid, will use the default interval", v)
+		}
+	}
+	return complianceIntervalInMin
+}
+
+// isStoragePolicyReapplyEnabled returns true if environment variable STORAGE_POLICY_COMPLIANCE_REAPPLY
+// is set to true, in which case the StorageClass policy is reapplied to non-compliant volumes
+func isStoragePolicyReapplyEnabled(ctx context.Context) bool {
+	log := logger.GetLogger(ctx)
+	if v := os.Getenv("STORAGE_POLICY_COMPLIANCE_REAPPLY"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			log.Warnf("StoragePolicyCompliance: value set in env variable STORAGE_POLICY_COMPLIANCE_REAPPLY %s is invalid, policies will not be reapplied", v)
+			return false
+		}
+		return enabled
+	}
+	return false
+}
+
 // InitMetadataSyncer initializes the Metadata Sync Informer
 func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFlavor, configInfo *types.ConfigInfo) error {
 	log := logger.GetLogger(ctx)
@@ -124,6 +182,7 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 		log.Errorf("Creating Kubernetes client failed. Err: %v", err)
 		return err
 	}
+	metadataSyncer.k8sClient = k8sClient
 
 	// Initialize the k8s orchestrator interface
 	metadataSyncer.coCommonInterface, err = commonco.GetContainerOrchestratorInterface(ctx, common.Kubernetes, clusterFlavor, COInitParams)
@@ -132,6 +191,10 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 		return err
 	}
 	metadataSyncer.clusterFlavor = clusterFlavor
+
+	// eventRecorder records events on PVCs, PVs and Nodes
+	metadataSyncer.eventRecorder = k8s.NewRecorder(k8sClient, csitypes.Name)
+
 	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorGuest {
 		// Initialize client to supervisor cluster
 		// if metadata syncer is being initialized for guest clusters
@@ -156,7 +219,14 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 			return err
 		}
 		metadataSyncer.host = vCenter.Config.Host
-		metadataSyncer.volumeManager = volumes.GetManager(ctx, vCenter)
+		metadataSyncer.volumeManager = volumes.GetManagerWithQueryCache(ctx, vCenter,
+			time.Duration(configInfo.Cfg.Global.QueryVolumeCacheTTLInSec)*time.Second)
+		// FullSyncReport instances are best effort, full sync proceeds without them
+		metadataSyncer.fullSyncReportClient, err = initFullSyncReportClient(ctx)
+		if err != nil {
+			log.Warnf("Failed to initialize FullSyncReport client, full sync reports will not be published. Err: %+v", err)
+			metadataSyncer.fullSyncReportClient = nil
+		}
 	}
 
 	// Initialize cnsDeletionMap used by Full Sync
@@ -213,6 +283,11 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 		}
 	}
 
+	// Storage policy changes requested on PVCs are applied by a fixed number of workers
+	metadataSyncer.storagePolicyChangeQueue = workqueue.NewNamedRateLimitingQueue(
+		workqueue.DefaultControllerRateLimiter(), "storage-policy-change-pvc")
+	defer metadataSyncer.storagePolicyChangeQueue.ShutDown()
+
 	// Set up kubernetes resource listeners for metadata syncer
 	metadataSyncer.k8sInformerManager = k8s.NewInformer(k8sClient)
 	metadataSyncer.k8sInformerManager.AddPVCListener(
@@ -255,26 +330,31 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 	// Trigger full sync
 	go func() {
 		for ; true; <-fullSyncTicker.C {
-			ctx, log = logger.GetNewContextWithLogger()
+			fullSyncCtx, span := tracing.StartSpan(context.Background(), "syncer.FullSync")
+			ctx = logger.NewContextWithLogger(fullSyncCtx)
+			log = logger.GetLogger(ctx)
 			log.Infof("fullSync is triggered")
 			if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorGuest {
 				pvcsiFullSync(ctx, metadataSyncer)
 			} else {
 				csiFullSync(ctx, metadataSyncer)
 			}
+			span.End()
 		}
 	}()
 
-	volumeHealthTicker := time.NewTicker(time.Duration(getVolumeHealthIntervalInMin(ctx)) * time.Minute)
+	volumeHealthTicker := time.NewTicker(time.Duration(getVolumeHealthIntervalInMin(ctx,
+		metadataSyncer.configInfo.Cfg.Global.VolumeHealthIntervalInMin)) * time.Minute)
 	defer volumeHealthTicker.Stop()
 
 	// Trigger get volume health status
-	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorWorkload {
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorWorkload ||
+		metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla {
 		go func() {
 			for ; true; <-volumeHealthTicker.C {
 				ctx, log = logger.GetNewContextWithLogger()
 				if !metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.VolumeHealth) {
-					log.Warnf("VolumeHealth feature is disabled on the cluster")
+					log.Debugf("VolumeHealth feature is disabled on the cluster")
 				} else {
 					log.Infof("getVolumeHealthStatus is triggered")
 					csiGetVolumeHealthStatus(ctx, k8sClient, metadataSyncer)
@@ -282,6 +362,25 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 			}
 		}()
 	}
+	if metadataSyncer.clusterFlavor != cnstypes.CnsClusterFlavorGuest {
+		storagePolicyComplianceTicker := time.NewTicker(time.Duration(getStoragePolicyComplianceIntervalInMin(ctx)) * time.Minute)
+		defer storagePolicyComplianceTicker.Stop()
+		// Trigger storage policy compliance monitoring
+		go func() {
+			for ; true; <-storagePolicyComplianceTicker.C {
+				ctx, log = logger.GetNewContextWithLogger()
+				if !metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.StoragePolicyCompliance) {
+					log.Debugf("StoragePolicyCompliance feature is disabled on the cluster")
+				} else {
+					log.Infof("csiGetVolumeComplianceStatus is triggered")
+					csiGetVolumeComplianceStatus(ctx, k8sClient, metadataSyncer)
+				}
+			}
+		}()
+		for i := 0; i < storagePolicyChangeWorkers; i++ {
+			go wait.Until(func() { processStoragePolicyChange(metadataSyncer) }, 0, stopCh)
+		}
+	}
 	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorGuest {
 		volumeHealthEnablementTicker := time.NewTicker(common.DefaultFeatureEnablementCheckInterval)
 		defer volumeHealthEnablementTicker.Stop()
@@ -391,8 +490,10 @@ func ReloadConfiguration(ctx context.Context, metadataSyncer *metadataSyncInform
 				vcenter.Config = newVCConfig
 			}
 			metadataSyncer.volumeManager.ResetManager(ctx, vcenter)
-			metadataSyncer.volumeManager = volumes.GetManager(ctx, vcenter)
-			if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorWorkload {
+			metadataSyncer.volumeManager = volumes.GetManagerWithQueryCache(ctx, vcenter,
+				time.Duration(cfg.Global.QueryVolumeCacheTTLInSec)*time.Second)
+			if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorWorkload ||
+				metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla {
 				storagepool.ResetVC(ctx, vcenter)
 			}
 		}
@@ -447,6 +548,12 @@ func pvcUpdated(oldObj, newObj interface{}, metadataSyncer *metadataSyncInformer
 		}
 		log.Debugf("PVCUpdated: Found Persistent Volume %s from API server", newPvc.Spec.VolumeName)
 	}
+	if metadataSyncer.clusterFlavor != cnstypes.CnsClusterFlavorGuest && pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.Name &&
+		isStoragePolicyChangeRequested(newPvc) && metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.StoragePolicyChange) {
+		if key, err := getPVCKey(ctx, newPvc); err == nil {
+			metadataSyncer.storagePolicyChangeQueue.AddRateLimited(key)
+		}
+	}
 	migrationEnabled := metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.CSIMigration)
 	// Verify if csi migration is ON and check if there is any label update or migrated-to annotation was received for the PVC
 	if migrationEnabled && pv.Spec.VsphereVolume != nil {
@@ -794,9 +901,13 @@ func csiPVCUpdated(ctx context.Context, pvc *v1.PersistentVolumeClaim, pv *v1.Pe
 	}
 
 	log.Debugf("PVCUpdated: Calling UpdateVolumeMetadata with updateSpec: %+v", spew.Sdump(updateSpec))
-	if err := metadataSyncer.volumeManager.UpdateVolumeMetadata(ctx, updateSpec); err != nil {
+	err = metadataSyncer.volumeManager.UpdateVolumeMetadata(ctx, updateSpec)
+	if err != nil {
 		log.Errorf("PVCUpdated: UpdateVolumeMetadata failed with err %v", err)
+		metadataSyncer.eventRecorder.Eventf(pvc, v1.EventTypeWarning, "MetadataUpdateFailed",
+			"Failed to update metadata of volume %s in CNS: %v", updateSpec.VolumeId.Id, err)
 	}
+	recordMetadataSyncOp(prometheus.PrometheusPVCUpdatedHandler, err)
 }
 
 // csiPVCDeleted deletes volume metadata on VC when volume has been deleted on Vanilla k8s and supervisor cluster
@@ -843,9 +954,11 @@ func csiPVCDeleted(ctx context.Context, pvc *v1.PersistentVolumeClaim, pv *v1.Pe
 	}
 
 	log.Debugf("PVCDeleted: Calling UpdateVolumeMetadata for volume %s with updateSpec: %+v", updateSpec.VolumeId.Id, spew.Sdump(updateSpec))
-	if err := metadataSyncer.volumeManager.UpdateVolumeMetadata(ctx, updateSpec); err != nil {
+	err = metadataSyncer.volumeManager.UpdateVolumeMetadata(ctx, updateSpec)
+	if err != nil {
 		log.Errorf("PVCDeleted: UpdateVolumeMetadata failed with err %v", err)
 	}
+	recordMetadataSyncOp(prometheus.PrometheusPVCDeletedHandler, err)
 }
 
 // csiPVUpdated updates volume metadata on VC when volume labels on Vanilla k8s and supervisor cluster have been updated
@@ -954,8 +1067,12 @@ func csiPVUpdated(ctx context.Context, newPv *v1.PersistentVolume, oldPv *v1.Per
 	}
 
 	log.Debugf("PVUpdated: Calling UpdateVolumeMetadata for volume %q with updateSpec: %+v", updateSpec.VolumeId.Id, spew.Sdump(updateSpec))
-	if err := metadataSyncer.volumeManager.UpdateVolumeMetadata(ctx, updateSpec); err != nil {
+	err = metadataSyncer.volumeManager.UpdateVolumeMetadata(ctx, updateSpec)
+	recordMetadataSyncOp(prometheus.PrometheusPVUpdatedHandler, err)
+	if err != nil {
 		log.Errorf("PVUpdated: UpdateVolumeMetadata failed with err %v", err)
+		metadataSyncer.eventRecorder.Eventf(newPv, v1.EventTypeWarning, "MetadataUpdateFailed",
+			"Failed to update metadata of volume %s in CNS: %v", updateSpec.VolumeId.Id, err)
 		return
 	}
 	log.Debugf("PVUpdated: UpdateVolumeMetadata succeed for the volume %q with updateSpec: %+v", updateSpec.VolumeId.Id, spew.Sdump(updateSpec))
@@ -991,7 +1108,9 @@ func csiPVDeleted(ctx context.Context, pv *v1.PersistentVolume, metadataSyncer *
 		}
 
 		log.Debugf("PVDeleted: Calling UpdateVolumeMetadata for volume %s with updateSpec: %+v", updateSpec.VolumeId.Id, spew.Sdump(updateSpec))
-		if err := metadataSyncer.volumeManager.UpdateVolumeMetadata(ctx, updateSpec); err != nil {
+		err := metadataSyncer.volumeManager.UpdateVolumeMetadata(ctx, updateSpec)
+		recordMetadataSyncOp(prometheus.PrometheusPVDeletedHandler, err)
+		if err != nil {
 			log.Errorf("PVDeleted: UpdateVolumeMetadata failed with err %v", err)
 			return
 		}
@@ -1039,9 +1158,11 @@ func csiPVDeleted(ctx context.Context, pv *v1.PersistentVolume, metadataSyncer *
 
 		log.Debugf("PVDeleted: vSphere CSI Driver is deleting volume %v", pv)
 
-		if err := metadataSyncer.volumeManager.DeleteVolume(ctx, volumeHandle, false); err != nil {
+		err = metadataSyncer.volumeManager.DeleteVolume(ctx, volumeHandle, false)
+		if err != nil {
 			log.Errorf("PVDeleted: Failed to delete disk %s with error %+v", volumeHandle, err)
 		}
+		recordMetadataSyncOp(prometheus.PrometheusPVDeletedHandler, err)
 		if migrationFeatureEnabled && pv.Spec.VsphereVolume != nil {
 			// Delete the cnsvspherevolumemigration crd instance when PV is deleted
 			err = volumeMigrationService.DeleteVolumeInfo(ctx, volumeHandle)
@@ -1138,9 +1259,13 @@ func csiUpdatePod(ctx context.Context, pod *v1.Pod, metadataSyncer *metadataSync
 		}
 
 		log.Debugf("Calling UpdateVolumeMetadata for volume %s with updateSpec: %+v", updateSpec.VolumeId.Id, spew.Sdump(updateSpec))
-		if err := metadataSyncer.volumeManager.UpdateVolumeMetadata(ctx, updateSpec); err != nil {
+		err := metadataSyncer.volumeManager.UpdateVolumeMetadata(ctx, updateSpec)
+		if err != nil {
 			log.Errorf("UpdateVolumeMetadata failed for volume %s with err: %v", volume.Name, err)
+			metadataSyncer.eventRecorder.Eventf(pod, v1.EventTypeWarning, "MetadataUpdateFailed",
+				"Failed to update metadata of volume %s in CNS: %v", volumeHandle, err)
 		}
+		recordMetadataSyncOp(prometheus.PrometheusPodUpdatedHandler, err)
 
 	}
 }
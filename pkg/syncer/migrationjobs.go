@@ -0,0 +1,173 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"sigs.k8s.io/vsphere-csi-driver/pkg/apis/migration"
+	volumes "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/volume"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+	k8s "sigs.k8s.io/vsphere-csi-driver/pkg/kubernetes"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/syncer/types"
+)
+
+// This file holds the one-shot CSI migration jobs the syncer runs instead of its controllers: the rollback of
+// migrated volumes to the in-tree plugin and the pre-registration of in-tree volumes with CNS.
+
+// initMigrationJob returns the volume migration service and the k8s client used by the CSI migration jobs
+func initMigrationJob(ctx context.Context) (migration.VolumeMigrationService, clientset.Interface, error) {
+	log := logger.GetLogger(ctx)
+	configInfo, err := types.InitConfigInfo(ctx)
+	if err != nil {
+		log.Errorf("failed to initialize the configInfo. Err: %+v", err)
+		return nil, nil, err
+	}
+	vCenter, err := types.GetVirtualCenterInstance(ctx, configInfo, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	volumeManager := volumes.GetManager(ctx, vCenter)
+	volumeMigrationService, err := migration.GetVolumeMigrationService(ctx, &volumeManager, configInfo.Cfg, false)
+	if err != nil {
+		log.Errorf("failed to get migration service. Err: %v", err)
+		return nil, nil, err
+	}
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		log.Errorf("Creating Kubernetes client failed. Err: %v", err)
+		return nil, nil, err
+	}
+	return volumeMigrationService, k8sClient, nil
+}
+
+// writeMigrationJobReport writes the given per-volume outcomes of a CSI migration job as JSON to stdout
+func writeMigrationJobReport(results interface{}) error {
+	report, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(os.Stdout, string(report))
+	return err
+}
+
+// RollbackVolumeMigration rolls back the volumes of a Vanilla cluster migrated from the in-tree vSphere plugin to
+// the CSI driver, and writes the outcome for each volume as a JSON report to stdout.
+// It refuses to run while the csi-migration feature is enabled, as the volumes would be registered again on their
+// next use. The CSIMigrationvSphere feature gate of the cluster must be disabled as well.
+// It returns an error if the rollback of any volume failed.
+func RollbackVolumeMigration(ctx context.Context, clusterFlavor cnstypes.CnsClusterFlavor, coInitParams *interface{},
+	dryRun bool) error {
+	log := logger.GetLogger(ctx)
+	if clusterFlavor != cnstypes.CnsClusterFlavorVanilla {
+		return fmt.Errorf("CSI migration rollback is not supported for cluster flavor %q", clusterFlavor)
+	}
+	coCommonInterface, err := commonco.GetContainerOrchestratorInterface(ctx, common.Kubernetes, clusterFlavor, *coInitParams)
+	if err != nil {
+		log.Errorf("Failed to create CO agnostic interface. Error: %v", err)
+		return err
+	}
+	if coCommonInterface.IsFSSEnabled(ctx, common.CSIMigration) {
+		return fmt.Errorf("%s feature must be disabled before rolling back the migrated volumes", common.CSIMigration)
+	}
+	volumeMigrationService, k8sClient, err := initMigrationJob(ctx)
+	if err != nil {
+		return err
+	}
+	results, err := volumeMigrationService.RollbackMigratedVolumes(ctx, k8sClient, dryRun)
+	if err != nil {
+		log.Errorf("failed to roll back the migrated volumes. Err: %v", err)
+		return err
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Status == migration.RollbackStatusFailed {
+			failed++
+			log.Errorf("Rollback of volume %q with path %q of PV %q failed: %s", result.VolumeID, result.VolumePath,
+				result.PVName, result.Message)
+		} else {
+			log.Infof("Rollback of volume %q with path %q of PV %q: %s %s", result.VolumeID, result.VolumePath,
+				result.PVName, result.Status, result.Message)
+		}
+	}
+	if err = writeMigrationJobReport(results); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("rollback of %d out of %d migrated volume(s) failed", failed, len(results))
+	}
+	log.Infof("Rolled back %d migrated volume(s), dry run: %v", len(results), dryRun)
+	return nil
+}
+
+// PreRegisterInTreeVolumes registers the in-tree vSphere volumes of a Vanilla cluster with CNS, at most concurrency
+// at a time, and writes the outcome for each volume as a JSON report to stdout. It is meant to run before enabling
+// the csi-migration feature, so that the volumes are not all registered on their first use after it is enabled.
+// It returns an error if the registration of any volume failed.
+func PreRegisterInTreeVolumes(ctx context.Context, clusterFlavor cnstypes.CnsClusterFlavor, coInitParams *interface{},
+	concurrency int, dryRun bool) error {
+	log := logger.GetLogger(ctx)
+	if clusterFlavor != cnstypes.CnsClusterFlavorVanilla {
+		return fmt.Errorf("in-tree volume pre-registration is not supported for cluster flavor %q", clusterFlavor)
+	}
+	coCommonInterface, err := commonco.GetContainerOrchestratorInterface(ctx, common.Kubernetes, clusterFlavor, *coInitParams)
+	if err != nil {
+		log.Errorf("Failed to create CO agnostic interface. Error: %v", err)
+		return err
+	}
+	if coCommonInterface.IsFSSEnabled(ctx, common.CSIMigration) {
+		log.Warnf("%s feature is already enabled, volumes may be registered concurrently on their first use",
+			common.CSIMigration)
+	}
+	volumeMigrationService, k8sClient, err := initMigrationJob(ctx)
+	if err != nil {
+		return err
+	}
+	results, err := volumeMigrationService.PreRegisterVolumes(ctx, k8sClient, concurrency, dryRun)
+	if err != nil {
+		log.Errorf("failed to pre-register the in-tree volumes. Err: %v", err)
+		return err
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Status == migration.RegistrationStatusFailed {
+			failed++
+			log.Errorf("Registration of volume %q of PV %q failed: %s", result.VolumePath, result.PVName, result.Message)
+		} else {
+			log.Infof("Registration of volume %q of PV %q: %s %s", result.VolumePath, result.PVName, result.Status,
+				result.VolumeID)
+		}
+	}
+	if err = writeMigrationJobReport(results); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("registration of %d out of %d in-tree volume(s) failed", failed, len(results))
+	}
+	log.Infof("Pre-registered %d in-tree volume(s), dry run: %v", len(results), dryRun)
+	return nil
+}
@@ -87,11 +87,18 @@ func pvcsiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer) {
 		supervisorObjectsMap[object.Name] = &supervisorCnsVolumeMetadataList.Items[index]
 	}
 
+	// In dry run, the objects to create, update or delete are only logged
+	dryRun := isFullSyncDryRunEnabled(ctx)
+
 	// Identify cnsvolumemetadata objects that need to be updated or created
 	// on the supervisor cluster API server.
 	for _, guestObject := range guestCnsVolumeMetadataList.Items {
 		if supervisorObject, exists := supervisorObjectsMap[guestObject.Name]; !exists {
 			// Create objects that do not exist
+			if dryRun {
+				log.Infof("FullSync: dry run, skipping creation of CnsVolumeMetadata %v for entity type %q", guestObject.Name, guestObject.Spec.EntityType)
+				continue
+			}
 			log.Infof("FullSync: Creating CnsVolumeMetadata %v on the supervisor cluster for entity type %q", guestObject.Name, guestObject.Spec.EntityType)
 			guestObject.Namespace = supervisorNamespace
 			if err := metadataSyncer.cnsOperatorClient.Create(ctx, &guestObject); err != nil {
@@ -102,6 +109,10 @@ func pvcsiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer) {
 			// Update the supervisor cluster API server if an object is stale.
 			if guestObject.Spec.EntityType != cnsvolumemetadatav1alpha1.CnsOperatorEntityTypePOD &&
 				!compareCnsVolumeMetadatas(&guestObject.Spec, &supervisorObject.Spec) {
+				if dryRun {
+					log.Infof("FullSync: dry run, skipping update of CnsVolumeMetadata %v", guestObject.Name)
+					continue
+				}
 				log.Infof("FullSync: Updating CnsVolumeMetadata %v on the supervisor cluster", guestObject.Name)
 				if err := metadataSyncer.cnsOperatorClient.Update(ctx, supervisorObject); err != nil {
 					log.Warnf("FullSync: Failed to update CnsVolumeMetadata %v. Err: %v", supervisorObject.Name, err)
@@ -114,6 +125,10 @@ func pvcsiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer) {
 	// the supervisor cluster API server that shouldn't exist.
 	for _, supervisorObject := range supervisorCnsVolumeMetadataList.Items {
 		if _, exists := guestObjectsMap[supervisorObject.Name]; !exists {
+			if dryRun {
+				log.Infof("FullSync: dry run, skipping deletion of CnsVolumeMetadata %v for entity type %q", supervisorObject.Name, supervisorObject.Spec.EntityType)
+				continue
+			}
 			log.Infof("FullSync: Deleting CnsVolumeMetadata %v on the supervisor cluster for entity type %q", supervisorObject.Name, supervisorObject.Spec.EntityType)
 			if err := metadataSyncer.cnsOperatorClient.Delete(ctx, &supervisorObject); err != nil {
 				log.Warnf("FullSync: Failed to delete CnsVolumeMetadata %v. Err: %v", supervisorObject.Name, err)
@@ -36,6 +36,7 @@ import (
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/prometheus"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
 )
 
@@ -178,8 +179,10 @@ func (rc *resizeReconciler) syncPVCs(ctx context.Context) {
 	defer rc.claimQueue.Done(key)
 
 	if err := rc.syncPVC(ctx, key.(string)); err != nil {
+		prometheus.ResizeReconcileOpsCounterVec.WithLabelValues(prometheus.PrometheusFailStatus).Inc()
 		rc.claimQueue.AddRateLimited(key)
 	} else {
+		prometheus.ResizeReconcileOpsCounterVec.WithLabelValues(prometheus.PrometheusPassStatus).Inc()
 		rc.claimQueue.Forget(key)
 	}
 }
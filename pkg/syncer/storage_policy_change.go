@@ -0,0 +1,271 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	v1helper "k8s.io/kubernetes/pkg/apis/core/v1/helper"
+
+	volumes "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/volume"
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/pkg/csi/types"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/syncer/types"
+)
+
+const (
+	// storagePolicyChangeSucceeded is the change-status annotation value when the requested policy is applied
+	storagePolicyChangeSucceeded = "Succeeded"
+	// storagePolicyChangeFailed is the change-status annotation value when the requested policy could not be applied
+	storagePolicyChangeFailed = "Failed"
+)
+
+// storagePolicyChangeInProgress holds the IDs of volumes whose storage policy is being changed
+var storagePolicyChangeInProgress sync.Map
+
+// isStoragePolicyChangeRequested returns true if the PVC requests a storage policy which has not
+// yet been processed by the syncer
+func isStoragePolicyChangeRequested(pvc *v1.PersistentVolumeClaim) bool {
+	requested := pvc.Annotations[annStoragePolicyRequested]
+	return requested != "" && requested != pvc.Annotations[annStoragePolicyObserved]
+}
+
+// processStoragePolicyChange applies the storage policy change requested by the next PVC of the storage policy
+// change queue. The PVC is read from the API server, as the informer cache may not have the result of a change
+// which was just applied. Failures are reported on the PVC and are not retried until another policy is requested.
+func processStoragePolicyChange(metadataSyncer *metadataSyncInformer) {
+	key, quit := metadataSyncer.storagePolicyChangeQueue.Get()
+	if quit {
+		return
+	}
+	defer metadataSyncer.storagePolicyChangeQueue.Done(key)
+	defer metadataSyncer.storagePolicyChangeQueue.Forget(key)
+	ctx, log := logger.GetNewContextWithLogger()
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key.(string))
+	if err != nil {
+		log.Errorf("PVCStoragePolicyChanged: failed to split key %q. Err: %v", key, err)
+		return
+	}
+	pvc, err := metadataSyncer.k8sClient.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Errorf("PVCStoragePolicyChanged: failed to get pvc %s. Err: %v", key, err)
+		}
+		return
+	}
+	if !isStoragePolicyChangeRequested(pvc) {
+		log.Debugf("PVCStoragePolicyChanged: storage policy change of pvc %s is already processed", key)
+		return
+	}
+	pv, err := metadataSyncer.pvLister.Get(pvc.Spec.VolumeName)
+	if err != nil {
+		log.Errorf("PVCStoragePolicyChanged: failed to get pv %q of pvc %s. Err: %v", pvc.Spec.VolumeName, key, err)
+		return
+	}
+	if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.Name {
+		return
+	}
+	csiPVCStoragePolicyChanged(ctx, pvc, pv.DeepCopy(), metadataSyncer)
+}
+
+// csiPVCStoragePolicyChanged changes the storage policy of the volume of the given PV bound to the given PVC
+// to the storage policy requested in the PVC annotation and reports the result on the PVC.
+// The StorageClass of the PVC is left unchanged.
+func csiPVCStoragePolicyChanged(ctx context.Context, pvc *v1.PersistentVolumeClaim, pv *v1.PersistentVolume,
+	metadataSyncer *metadataSyncInformer) {
+	log := logger.GetLogger(ctx)
+
+	volumeID := pv.Spec.CSI.VolumeHandle
+	if _, inProgress := storagePolicyChangeInProgress.LoadOrStore(volumeID, true); inProgress {
+		log.Debugf("PVCStoragePolicyChanged: storage policy change is already in progress for volume %q", volumeID)
+		return
+	}
+	defer storagePolicyChangeInProgress.Delete(volumeID)
+
+	requested := pvc.Annotations[annStoragePolicyRequested]
+	log.Infof("PVCStoragePolicyChanged: changing storage policy of volume %q bound to pvc %s/%s to %q",
+		volumeID, pvc.Namespace, pvc.Name, requested)
+	var status, message string
+	relocated, err := changeVolumeStoragePolicy(ctx, pv, requested, metadataSyncer)
+	if err != nil {
+		log.Errorf("PVCStoragePolicyChanged: failed to change storage policy of volume %q to %q. Err: %v", volumeID, requested, err)
+		status = storagePolicyChangeFailed
+		message = fmt.Sprintf("Failed to change storage policy to %q: %v", requested, err)
+		metadataSyncer.eventRecorder.Event(pvc, v1.EventTypeWarning, "StoragePolicyChangeFailed", message)
+	} else {
+		status = storagePolicyChangeSucceeded
+		message = fmt.Sprintf("Storage policy changed to %q", requested)
+		if relocated {
+			message = fmt.Sprintf("Storage policy changed to %q, volume relocated to a compatible datastore", requested)
+		}
+		metadataSyncer.eventRecorder.Event(pvc, v1.EventTypeNormal, "StoragePolicyChanged", message)
+	}
+	updatePVCStoragePolicyChangeStatus(ctx, metadataSyncer.k8sClient, pvc, requested, status, message)
+}
+
+// changeVolumeStoragePolicy applies the storage policy with the given name to the volume of the given PV, relocating
+// it if its current datastore is not compatible with the policy. It returns true if the volume was relocated.
+func changeVolumeStoragePolicy(ctx context.Context, pv *v1.PersistentVolume, storagePolicyName string,
+	metadataSyncer *metadataSyncInformer) (bool, error) {
+	log := logger.GetLogger(ctx)
+	volumeID := pv.Spec.CSI.VolumeHandle
+	vc, err := types.GetVirtualCenterInstance(ctx, metadataSyncer.configInfo, false)
+	if err != nil {
+		log.Errorf("failed to get virtual center instance. Err: %v", err)
+		return false, err
+	}
+	storagePolicyID, err := vc.GetStoragePolicyIDByName(ctx, storagePolicyName)
+	if err != nil {
+		log.Errorf("failed to get storage policy ID for storage policy name %q. Err: %v", storagePolicyName, err)
+		return false, err
+	}
+	queryFilter := cnstypes.CnsQueryFilter{
+		VolumeIds: []cnstypes.CnsVolumeId{{Id: volumeID}},
+	}
+	queryResult, err := metadataSyncer.volumeManager.QueryVolume(volumes.WithConsistentRead(ctx), queryFilter)
+	if err != nil {
+		log.Errorf("failed to query volume %q. Err: %v", volumeID, err)
+		return false, err
+	}
+	if len(queryResult.Volumes) == 0 {
+		return false, fmt.Errorf("volume %q not found in CNS", volumeID)
+	}
+	volume := queryResult.Volumes[0]
+	if volume.VolumeType != string(cnstypes.CnsVolumeTypeBlock) {
+		return false, fmt.Errorf("changing the storage policy of %s volume %q is not supported", volume.VolumeType, volumeID)
+	}
+	candidateDatastoreURLs, err := getNodeAccessibleDatastoreURLs(ctx, vc, pv, metadataSyncer)
+	if err != nil {
+		log.Errorf("failed to get the datastores accessible to the nodes of volume %q. Err: %v", volumeID, err)
+		return false, err
+	}
+	datastoreURL, err := common.ChangeVolumeStoragePolicy(ctx, vc, metadataSyncer.volumeManager, volumeID,
+		volume.DatastoreUrl, storagePolicyID, candidateDatastoreURLs)
+	if err != nil {
+		return false, err
+	}
+	return datastoreURL != volume.DatastoreUrl, nil
+}
+
+// getNodeAccessibleDatastoreURLs returns the URLs of the datastores accessible to all the k8s nodes the volume of
+// the given PV can be attached to, i.e. the nodes matching the node affinity of the PV, or all the nodes if it has
+// none. The volume is only relocated to one of these datastores so that it remains attachable.
+func getNodeAccessibleDatastoreURLs(ctx context.Context, vc *cnsvsphere.VirtualCenter, pv *v1.PersistentVolume,
+	metadataSyncer *metadataSyncInformer) (map[string]bool, error) {
+	log := logger.GetLogger(ctx)
+	nodeList, err := metadataSyncer.k8sClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("failed to list k8s nodes. Err: %v", err)
+		return nil, err
+	}
+	dcs, err := vc.GetDatacenters(ctx)
+	if err != nil {
+		log.Errorf("failed to get datacenters from VC. Err: %v", err)
+		return nil, err
+	}
+	var datastoreURLs map[string]bool
+	for _, node := range filterNodesByAffinity(nodeList.Items, pv.Spec.NodeAffinity) {
+		nodeUUID := common.GetUUIDFromProviderID(node.Spec.ProviderID)
+		if nodeUUID == "" {
+			log.Debugf("Ignoring node %s without provider ID", node.Name)
+			continue
+		}
+		var nodeVM *cnsvsphere.VirtualMachine
+		for _, dc := range dcs {
+			if nodeVM, err = dc.GetVirtualMachineByUUID(ctx, nodeUUID, false); err == nil {
+				break
+			}
+		}
+		if nodeVM == nil {
+			return nil, fmt.Errorf("failed to find the VM of node %s with UUID %s", node.Name, nodeUUID)
+		}
+		accessibleDatastores, err := nodeVM.GetAllAccessibleDatastores(ctx)
+		if err != nil {
+			log.Errorf("failed to get the datastores accessible to node %s. Err: %v", node.Name, err)
+			return nil, err
+		}
+		nodeDatastoreURLs := make(map[string]bool)
+		for _, ds := range accessibleDatastores {
+			if datastoreURLs == nil || datastoreURLs[ds.Info.Url] {
+				nodeDatastoreURLs[ds.Info.Url] = true
+			}
+		}
+		datastoreURLs = nodeDatastoreURLs
+	}
+	if datastoreURLs == nil {
+		return nil, fmt.Errorf("no node found for the node affinity of pv %s", pv.Name)
+	}
+	return datastoreURLs, nil
+}
+
+// filterNodesByAffinity returns the nodes matching the given volume node affinity, or all the nodes if it is nil
+func filterNodesByAffinity(nodes []v1.Node, nodeAffinity *v1.VolumeNodeAffinity) []v1.Node {
+	if nodeAffinity == nil || nodeAffinity.Required == nil {
+		return nodes
+	}
+	var matchingNodes []v1.Node
+	for _, node := range nodes {
+		if v1helper.MatchNodeSelectorTerms(nodeAffinity.Required.NodeSelectorTerms, labels.Set(node.Labels), nil) {
+			matchingNodes = append(matchingNodes, node)
+		}
+	}
+	return matchingNodes
+}
+
+// updatePVCStoragePolicyChangeStatus records the result of the storage policy change for the given
+// requested storage policy name in the PVC annotations
+func updatePVCStoragePolicyChangeStatus(ctx context.Context, k8sClient clientset.Interface, pvc *v1.PersistentVolumeClaim,
+	requested string, status string, message string) {
+	log := logger.GetLogger(ctx)
+	newPvc := pvc
+	for attempt := 0; attempt < 2; attempt++ {
+		newPvc = newPvc.DeepCopy()
+		metav1.SetMetaDataAnnotation(&newPvc.ObjectMeta, annStoragePolicyObserved, requested)
+		metav1.SetMetaDataAnnotation(&newPvc.ObjectMeta, annStoragePolicyChangeStatus, status)
+		metav1.SetMetaDataAnnotation(&newPvc.ObjectMeta, annStoragePolicyChangeMessage, message)
+		_, err := k8sClient.CoreV1().PersistentVolumeClaims(newPvc.Namespace).Update(ctx, newPvc, metav1.UpdateOptions{})
+		if err == nil {
+			return
+		}
+		if !apierrors.IsConflict(err) {
+			log.Errorf("PVCStoragePolicyChanged: Failed to update pvc %s/%s with err:%+v", pvc.Namespace, pvc.Name, err)
+			return
+		}
+		log.Debugf("PVCStoragePolicyChanged: Failed to update pvc %s/%s with err:%+v, will retry the update",
+			pvc.Namespace, pvc.Name, err)
+		// pvc may be stale, try to get updated pvc from API server
+		newPvc, err = k8sClient.CoreV1().PersistentVolumeClaims(pvc.Namespace).Get(ctx, pvc.Name, metav1.GetOptions{})
+		if err != nil {
+			log.Errorf("PVCStoragePolicyChanged: storage policy change status for pvc %s/%s is not updated because "+
+				"failed to get pvc from API server. err=%+v", pvc.Namespace, pvc.Name, err)
+			return
+		}
+	}
+	log.Errorf("PVCStoragePolicyChanged: Failed to update storage policy change status for pvc %s/%s", pvc.Namespace, pvc.Name)
+}
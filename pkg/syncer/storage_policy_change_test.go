@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestFilterNodesByAffinity(t *testing.T) {
+	newNode := func(name, zone string) v1.Node {
+		return v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{v1.LabelZoneFailureDomain: zone}}}
+	}
+	nodes := []v1.Node{newNode("node-a1", "zone-a"), newNode("node-a2", "zone-a"), newNode("node-b1", "zone-b")}
+	zoneAffinity := func(zones ...string) *v1.VolumeNodeAffinity {
+		return &v1.VolumeNodeAffinity{Required: &v1.NodeSelector{NodeSelectorTerms: []v1.NodeSelectorTerm{{
+			MatchExpressions: []v1.NodeSelectorRequirement{{
+				Key: v1.LabelZoneFailureDomain, Operator: v1.NodeSelectorOpIn, Values: zones,
+			}},
+		}}}}
+	}
+	tests := []struct {
+		name         string
+		nodeAffinity *v1.VolumeNodeAffinity
+		expected     []string
+	}{
+		{"no node affinity", nil, []string{"node-a1", "node-a2", "node-b1"}},
+		{"no required node affinity", &v1.VolumeNodeAffinity{}, []string{"node-a1", "node-a2", "node-b1"}},
+		{"single zone", zoneAffinity("zone-a"), []string{"node-a1", "node-a2"}},
+		{"several zones", zoneAffinity("zone-a", "zone-b"), []string{"node-a1", "node-a2", "node-b1"}},
+		{"unknown zone", zoneAffinity("zone-c"), nil},
+	}
+	for _, test := range tests {
+		var names []string
+		for _, node := range filterNodesByAffinity(nodes, test.nodeAffinity) {
+			names = append(names, node.Name)
+		}
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("%s: expected nodes %v, got %v", test.name, test.expected, names)
+		}
+	}
+}
+
+func TestProcessStoragePolicyChangeSkipsProcessedPVCs(t *testing.T) {
+	pvc := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pvc-1",
+		Annotations: map[string]string{annStoragePolicyRequested: "gold", annStoragePolicyObserved: "gold"}}}
+	metadataSyncer := &metadataSyncInformer{
+		k8sClient: k8sfake.NewSimpleClientset(pvc),
+		storagePolicyChangeQueue: workqueue.NewNamedRateLimitingQueue(
+			workqueue.DefaultControllerRateLimiter(), "storage-policy-change-pvc-test"),
+	}
+	defer metadataSyncer.storagePolicyChangeQueue.ShutDown()
+
+	// neither the processed PVC nor the deleted one reach the vCenter, which is not set up
+	keys := []string{"default/pvc-1", "default/deleted-pvc"}
+	for _, key := range keys {
+		metadataSyncer.storagePolicyChangeQueue.Add(key)
+	}
+	for range keys {
+		processStoragePolicyChange(metadataSyncer)
+	}
+	if length := metadataSyncer.storagePolicyChangeQueue.Len(); length != 0 {
+		t.Errorf("expected an empty storage policy change queue, got %d key(s)", length)
+	}
+	for _, key := range keys {
+		if requeues := metadataSyncer.storagePolicyChangeQueue.NumRequeues(key); requeues != 0 {
+			t.Errorf("expected %s not to be requeued, got %d requeue(s)", key, requeues)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	pbmtypes "github.com/vmware/govmomi/pbm/types"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/syncer/types"
+)
+
+// csiGetVolumeComplianceStatus queries the storage policy compliance status of all container volumes
+// in the cluster, records it on the bound PVCs and, if enabled, reapplies the StorageClass policy, or the
+// policy requested through the PVC annotation, on non-compliant block volumes.
+func csiGetVolumeComplianceStatus(ctx context.Context, k8sclient clientset.Interface, metadataSyncer *metadataSyncInformer) {
+	log := logger.GetLogger(ctx)
+	log.Infof("csiGetVolumeComplianceStatus: start")
+
+	// Call CNS QueryAll to get container volumes by cluster ID
+	queryFilter := cnstypes.CnsQueryFilter{
+		ContainerClusterIds: []string{
+			metadataSyncer.configInfo.Cfg.Global.ClusterID,
+		},
+	}
+	querySelection := cnstypes.CnsQuerySelection{
+		Names: []string{
+			string(cnstypes.QuerySelectionNameTypeVolumeType),
+			string(cnstypes.QuerySelectionNameTypeComplianceStatus),
+		},
+	}
+	queryAllResult, err := metadataSyncer.volumeManager.QueryAllVolume(ctx, queryFilter, querySelection)
+	if err != nil {
+		log.Errorf("csiGetVolumeComplianceStatus: failed to queryAllVolume with err %+v", err)
+		return
+	}
+
+	// Get K8s PVs in State "Bound"
+	k8sPVs, err := getBoundPVs(ctx, metadataSyncer)
+	if err != nil {
+		log.Errorf("csiGetVolumeComplianceStatus: Failed to get PVs from kubernetes. Err: %+v", err)
+		return
+	}
+	volumeHandleToPVMap := make(map[string]*v1.PersistentVolume, len(k8sPVs))
+	volumeHandleToPvcMap := make(volumeHandlePVCMap, len(k8sPVs))
+	for _, pv := range k8sPVs {
+		if pv.Spec.ClaimRef == nil {
+			continue
+		}
+		pvc, err := metadataSyncer.pvcLister.PersistentVolumeClaims(pv.Spec.ClaimRef.Namespace).Get(pv.Spec.ClaimRef.Name)
+		if err != nil {
+			log.Warnf("csiGetVolumeComplianceStatus: Failed to get pvc for namespace %s and name %s. err=%+v",
+				pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name, err)
+			continue
+		}
+		volumeHandleToPVMap[pv.Spec.CSI.VolumeHandle] = pv
+		volumeHandleToPvcMap[pv.Spec.CSI.VolumeHandle] = pvc
+	}
+
+	reapplyEnabled := isStoragePolicyReapplyEnabled(ctx)
+	complianceCount := map[string]float64{
+		string(pbmtypes.PbmComplianceStatusCompliant):     0,
+		string(pbmtypes.PbmComplianceStatusNonCompliant):  0,
+		string(pbmtypes.PbmComplianceStatusUnknown):       0,
+		string(pbmtypes.PbmComplianceStatusNotApplicable): 0,
+		string(pbmtypes.PbmComplianceStatusOutOfDate):     0,
+	}
+	for _, vol := range queryAllResult.Volumes {
+		complianceStatus := vol.ComplianceStatus
+		if complianceStatus == "" {
+			complianceStatus = string(pbmtypes.PbmComplianceStatusUnknown)
+		}
+		complianceCount[complianceStatus]++
+		log.Debugf("Volume %q Compliance Status %q", vol.VolumeId.Id, complianceStatus)
+
+		pvc, ok := volumeHandleToPvcMap[vol.VolumeId.Id]
+		if !ok {
+			continue
+		}
+		updateVolumeComplianceAnnotation(ctx, k8sclient, metadataSyncer, pvc, complianceStatus)
+		if reapplyEnabled && isNonCompliant(complianceStatus) && vol.VolumeType == string(cnstypes.CnsVolumeTypeBlock) {
+			requestedPolicy, reapplicable := getReapplicableStoragePolicy(pvc)
+			if _, inProgress := storagePolicyChangeInProgress.Load(vol.VolumeId.Id); inProgress || !reapplicable {
+				log.Infof("csiGetVolumeComplianceStatus: not reapplying storage policy on volume %q, "+
+					"the storage policy change requested on pvc %s/%s is pending or failed", vol.VolumeId.Id, pvc.Namespace, pvc.Name)
+				continue
+			}
+			if err := reapplyStoragePolicy(ctx, k8sclient, metadataSyncer, volumeHandleToPVMap[vol.VolumeId.Id],
+				requestedPolicy); err != nil {
+				log.Errorf("csiGetVolumeComplianceStatus: failed to reapply storage policy on volume %q. Err: %+v", vol.VolumeId.Id, err)
+				prometheus.StoragePolicyReapplyOpsCounterVec.WithLabelValues(prometheus.PrometheusFailStatus).Inc()
+				metadataSyncer.eventRecorder.Eventf(pvc, v1.EventTypeWarning, "StoragePolicyReapplyFailed",
+					"Failed to reapply storage policy on volume %s: %v", vol.VolumeId.Id, err)
+			} else {
+				prometheus.StoragePolicyReapplyOpsCounterVec.WithLabelValues(prometheus.PrometheusPassStatus).Inc()
+				metadataSyncer.eventRecorder.Eventf(pvc, v1.EventTypeNormal, "StoragePolicyReapplied",
+					"Reapplied storage policy on volume %s", vol.VolumeId.Id)
+			}
+		}
+	}
+	for status, count := range complianceCount {
+		prometheus.VolumeComplianceGaugeVec.WithLabelValues(status).Set(count)
+	}
+	log.Infof("csiGetVolumeComplianceStatus: end")
+}
+
+// isNonCompliant returns true if the volume does not satisfy its storage policy
+func isNonCompliant(complianceStatus string) bool {
+	return complianceStatus == string(pbmtypes.PbmComplianceStatusNonCompliant) ||
+		complianceStatus == string(pbmtypes.PbmComplianceStatusOutOfDate)
+}
+
+// updateVolumeComplianceAnnotation sets the compliance status annotation on the given pvc and emits
+// an event when the volume becomes non-compliant or compliant again
+func updateVolumeComplianceAnnotation(ctx context.Context, k8sclient clientset.Interface, metadataSyncer *metadataSyncInformer,
+	pvc *v1.PersistentVolumeClaim, complianceStatus string) {
+	log := logger.GetLogger(ctx)
+	val, found := pvc.Annotations[annStoragePolicyCompliance]
+	if found && val == complianceStatus {
+		return
+	}
+	log.Debugf("csiGetVolumeComplianceStatus: update compliance annotation for pvc %s/%s from old value %s to new value %s",
+		pvc.Namespace, pvc.Name, val, complianceStatus)
+	if isNonCompliant(complianceStatus) {
+		metadataSyncer.eventRecorder.Eventf(pvc, v1.EventTypeWarning, "StoragePolicyNonCompliant",
+			"Volume is not compliant with its storage policy, compliance status: %s", complianceStatus)
+	} else if found && isNonCompliant(val) && complianceStatus == string(pbmtypes.PbmComplianceStatusCompliant) {
+		metadataSyncer.eventRecorder.Event(pvc, v1.EventTypeNormal, "StoragePolicyCompliant",
+			"Volume is compliant with its storage policy")
+	}
+	newPvc := pvc.DeepCopy()
+	metav1.SetMetaDataAnnotation(&newPvc.ObjectMeta, annStoragePolicyCompliance, complianceStatus)
+	_, err := k8sclient.CoreV1().PersistentVolumeClaims(newPvc.Namespace).Update(ctx, newPvc, metav1.UpdateOptions{})
+	if err != nil {
+		if !apierrors.IsConflict(err) {
+			log.Errorf("csiGetVolumeComplianceStatus: Failed to update pvc %s/%s with err:%+v", pvc.Namespace, pvc.Name, err)
+			return
+		}
+		log.Debugf("csiGetVolumeComplianceStatus: Failed to update pvc %s/%s with err:%+v, will retry the update",
+			pvc.Namespace, pvc.Name, err)
+		// pvc get from pvcLister may be stale, try to get updated pvc from API server
+		newPvc, err = k8sclient.CoreV1().PersistentVolumeClaims(pvc.Namespace).Get(ctx, pvc.Name, metav1.GetOptions{})
+		if err != nil {
+			log.Errorf("csiGetVolumeComplianceStatus: compliance annotation for pvc %s/%s is not updated because "+
+				"failed to get pvc from API server. err=%+v", pvc.Namespace, pvc.Name, err)
+			return
+		}
+		metav1.SetMetaDataAnnotation(&newPvc.ObjectMeta, annStoragePolicyCompliance, complianceStatus)
+		_, err = k8sclient.CoreV1().PersistentVolumeClaims(newPvc.Namespace).Update(ctx, newPvc, metav1.UpdateOptions{})
+		if err != nil {
+			log.Errorf("csiGetVolumeComplianceStatus: Failed to update pvc %s/%s with err:%+v", newPvc.Namespace, newPvc.Name, err)
+		}
+	}
+}
+
+// getReapplicableStoragePolicy returns the name of the storage policy requested through the annotation of the
+// given pvc which must be reapplied instead of the StorageClass policy, or an empty string if the volume still has
+// the StorageClass policy. reapplicable is false while the requested storage policy change is pending or if it
+// failed, as the storage policy the volume is expected to have is then unknown.
+func getReapplicableStoragePolicy(pvc *v1.PersistentVolumeClaim) (storagePolicyName string, reapplicable bool) {
+	requested := pvc.Annotations[annStoragePolicyRequested]
+	if requested == "" {
+		return "", true
+	}
+	if isStoragePolicyChangeRequested(pvc) || pvc.Annotations[annStoragePolicyChangeStatus] != storagePolicyChangeSucceeded {
+		return "", false
+	}
+	return requested, true
+}
+
+// reapplyStoragePolicy reapplies the storage policy with the given name on the volume of the given pv, or the
+// storage policy of the StorageClass used to provision it if storagePolicyName is empty
+func reapplyStoragePolicy(ctx context.Context, k8sclient clientset.Interface, metadataSyncer *metadataSyncInformer,
+	pv *v1.PersistentVolume, storagePolicyName string) error {
+	log := logger.GetLogger(ctx)
+	if storagePolicyName != "" {
+		vc, err := types.GetVirtualCenterInstance(ctx, metadataSyncer.configInfo, false)
+		if err != nil {
+			log.Errorf("failed to get virtual center instance. Err: %v", err)
+			return err
+		}
+		storagePolicyID, err := vc.GetStoragePolicyIDByName(ctx, storagePolicyName)
+		if err != nil {
+			log.Errorf("failed to get storage policy ID for storage policy name %s. Err: %v", storagePolicyName, err)
+			return err
+		}
+		return applyVolumeStoragePolicy(ctx, vc, metadataSyncer, pv, storagePolicyID)
+	}
+	if pv.Spec.StorageClassName == "" {
+		return fmt.Errorf("pv %s has no storage class", pv.Name)
+	}
+	sc, err := k8sclient.StorageV1().StorageClasses().Get(ctx, pv.Spec.StorageClassName, metav1.GetOptions{})
+	if err != nil {
+		log.Errorf("failed to get storage class %s for pv %s. Err: %v", pv.Spec.StorageClassName, pv.Name, err)
+		return err
+	}
+	vc, err := types.GetVirtualCenterInstance(ctx, metadataSyncer.configInfo, false)
+	if err != nil {
+		log.Errorf("failed to get virtual center instance. Err: %v", err)
+		return err
+	}
+	var storagePolicyID string
+	for param, value := range sc.Parameters {
+		switch strings.ToLower(param) {
+		case common.AttributeStoragePolicyID:
+			storagePolicyID = value
+		case common.AttributeStoragePolicyName:
+			storagePolicyID, err = vc.GetStoragePolicyIDByName(ctx, value)
+			if err != nil {
+				log.Errorf("failed to get storage policy ID for storage policy name %s. Err: %v", value, err)
+				return err
+			}
+		}
+	}
+	if storagePolicyID == "" {
+		return fmt.Errorf("storage class %s does not specify a storage policy", sc.Name)
+	}
+	return applyVolumeStoragePolicy(ctx, vc, metadataSyncer, pv, storagePolicyID)
+}
+
+// applyVolumeStoragePolicy applies the storage policy with the given ID on the volume of the given pv
+// in its current datastore
+func applyVolumeStoragePolicy(ctx context.Context, vc *cnsvsphere.VirtualCenter, metadataSyncer *metadataSyncInformer,
+	pv *v1.PersistentVolume, storagePolicyID string) error {
+	log := logger.GetLogger(ctx)
+	volumeID := pv.Spec.CSI.VolumeHandle
+	queryResult, err := metadataSyncer.volumeManager.QueryVolume(ctx, cnstypes.CnsQueryFilter{
+		VolumeIds: []cnstypes.CnsVolumeId{{Id: volumeID}},
+	})
+	if err != nil {
+		log.Errorf("failed to query volume %s. Err: %v", volumeID, err)
+		return err
+	}
+	if len(queryResult.Volumes) == 0 {
+		return fmt.Errorf("volume %s not found in CNS", volumeID)
+	}
+	log.Infof("Reapplying storage policy %s on volume %s", storagePolicyID, volumeID)
+	return common.ApplyVolumeStoragePolicy(ctx, vc, metadataSyncer.volumeManager, volumeID,
+		queryResult.Volumes[0].DatastoreUrl, storagePolicyID)
+}
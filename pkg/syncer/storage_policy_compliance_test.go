@@ -0,0 +1,166 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	pbmtypes "github.com/vmware/govmomi/pbm/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/pkg/common/config"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/pkg/csi/types"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/syncer/types"
+)
+
+// newCompliancePVAndPVC returns a bound PV without StorageClass and its PVC
+func newCompliancePVAndPVC(name string) (*v1.PersistentVolume, *v1.PersistentVolumeClaim) {
+	pvc := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace}}
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{Driver: csitypes.Name, VolumeHandle: name},
+			},
+			ClaimRef: &v1.ObjectReference{Namespace: testNamespace, Name: name},
+		},
+		Status: v1.PersistentVolumeStatus{Phase: v1.VolumeBound},
+	}
+	return pv, pvc
+}
+
+// TestCsiGetVolumeComplianceStatus verifies that the compliance status is recorded on the PVCs and that
+// the storage policy is reapplied only on non-compliant block volumes when the reapply is enabled.
+func TestCsiGetVolumeComplianceStatus(t *testing.T) {
+	ctx := context.Background()
+	volumes := map[string]struct {
+		volumeType       cnstypes.CnsVolumeType
+		complianceStatus pbmtypes.PbmComplianceStatus
+		annotations      map[string]string
+		reapply          bool
+	}{
+		"non-compliant-block": {cnstypes.CnsVolumeTypeBlock, pbmtypes.PbmComplianceStatusNonCompliant, nil, true},
+		"out-of-date-block":   {cnstypes.CnsVolumeTypeBlock, pbmtypes.PbmComplianceStatusOutOfDate, nil, true},
+		"compliant-block":     {cnstypes.CnsVolumeTypeBlock, pbmtypes.PbmComplianceStatusCompliant, nil, false},
+		"non-compliant-file":  {cnstypes.CnsVolumeTypeFile, pbmtypes.PbmComplianceStatusNonCompliant, nil, false},
+		"pending-change-block": {cnstypes.CnsVolumeTypeBlock, pbmtypes.PbmComplianceStatusNonCompliant,
+			map[string]string{annStoragePolicyRequested: "gold"}, false},
+		"failed-change-block": {cnstypes.CnsVolumeTypeBlock, pbmtypes.PbmComplianceStatusNonCompliant,
+			map[string]string{annStoragePolicyRequested: "gold", annStoragePolicyObserved: "gold",
+				annStoragePolicyChangeStatus: storagePolicyChangeFailed}, false},
+	}
+	k8sClient := testclient.NewSimpleClientset()
+	pvIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	pvcIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	queryAllResult := &cnstypes.CnsQueryResult{}
+	for name, volume := range volumes {
+		pv, pvc := newCompliancePVAndPVC(name)
+		pvc.Annotations = volume.annotations
+		if err := pvIndexer.Add(pv); err != nil {
+			t.Fatal(err)
+		}
+		if err := pvcIndexer.Add(pvc); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := k8sClient.CoreV1().PersistentVolumeClaims(testNamespace).Create(ctx, pvc, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		queryAllResult.Volumes = append(queryAllResult.Volumes, cnstypes.CnsVolume{
+			VolumeId:         cnstypes.CnsVolumeId{Id: name},
+			VolumeType:       string(volume.volumeType),
+			ComplianceStatus: string(volume.complianceStatus),
+		})
+	}
+	cfg := &cnsconfig.Config{}
+	cfg.Global.ClusterID = testClusterName
+	eventRecorder := record.NewFakeRecorder(100)
+	metadataSyncer := &metadataSyncInformer{
+		volumeManager: &fakeVolumeManager{queryAllResult: queryAllResult},
+		configInfo:    &types.ConfigInfo{Cfg: cfg},
+		pvLister:      corelisters.NewPersistentVolumeLister(pvIndexer),
+		pvcLister:     corelisters.NewPersistentVolumeClaimLister(pvcIndexer),
+		eventRecorder: eventRecorder,
+	}
+
+	os.Setenv("STORAGE_POLICY_COMPLIANCE_REAPPLY", "true")
+	defer os.Unsetenv("STORAGE_POLICY_COMPLIANCE_REAPPLY")
+	csiGetVolumeComplianceStatus(ctx, k8sClient, metadataSyncer)
+
+	for name, volume := range volumes {
+		pvc, err := k8sClient.CoreV1().PersistentVolumeClaims(testNamespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pvc.Annotations[annStoragePolicyCompliance] != string(volume.complianceStatus) {
+			t.Errorf("expected compliance annotation %q on pvc %s, got %q", volume.complianceStatus, name,
+				pvc.Annotations[annStoragePolicyCompliance])
+		}
+	}
+	// The PVs have no StorageClass, so every reapply attempt fails and is reported in an event
+	reapplied := make(map[string]bool)
+	close(eventRecorder.Events)
+	for event := range eventRecorder.Events {
+		if !strings.Contains(event, "StoragePolicyReapplyFailed") {
+			continue
+		}
+		for name := range volumes {
+			if strings.Contains(event, "volume "+name+":") {
+				reapplied[name] = true
+			}
+		}
+	}
+	for name, volume := range volumes {
+		if reapplied[name] != volume.reapply {
+			t.Errorf("expected reapply %v for volume %s, got %v", volume.reapply, name, reapplied[name])
+		}
+	}
+}
+
+func TestGetReapplicableStoragePolicy(t *testing.T) {
+	tests := []struct {
+		name              string
+		annotations       map[string]string
+		storagePolicyName string
+		reapplicable      bool
+	}{
+		{"no change requested", nil, "", true},
+		{"change pending", map[string]string{annStoragePolicyRequested: "gold"}, "", false},
+		{"change pending after a previous change", map[string]string{annStoragePolicyRequested: "gold",
+			annStoragePolicyObserved: "silver", annStoragePolicyChangeStatus: storagePolicyChangeSucceeded}, "", false},
+		{"change failed", map[string]string{annStoragePolicyRequested: "gold", annStoragePolicyObserved: "gold",
+			annStoragePolicyChangeStatus: storagePolicyChangeFailed}, "", false},
+		{"change succeeded", map[string]string{annStoragePolicyRequested: "gold", annStoragePolicyObserved: "gold",
+			annStoragePolicyChangeStatus: storagePolicyChangeSucceeded}, "gold", true},
+	}
+	for _, test := range tests {
+		pvc := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Annotations: test.annotations}}
+		storagePolicyName, reapplicable := getReapplicableStoragePolicy(pvc)
+		if storagePolicyName != test.storagePolicyName || reapplicable != test.reapplicable {
+			t.Errorf("%s: expected (%q, %v), got (%q, %v)", test.name, test.storagePolicyName, test.reapplicable,
+				storagePolicyName, reapplicable)
+		}
+	}
+}
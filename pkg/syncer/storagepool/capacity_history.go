@@ -0,0 +1,144 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storagepool
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/vsphere-csi-driver/pkg/apis/storagepool/cns/v1alpha1"
+)
+
+const (
+	// capacityHistoryWindow is the duration of the capacity history kept for each StoragePool
+	capacityHistoryWindow = 7 * 24 * time.Hour
+	// capacitySampleInterval is the minimum interval between two samples of the capacity history,
+	// a newer sample within the interval replaces the last one
+	capacitySampleInterval = 10 * time.Minute
+	// minForecastSpan is the minimum duration the capacity history must span to forecast the fill rate
+	minForecastSpan = time.Hour
+	// defaultCapacityWarningDays is the default number of days until a StoragePool is full below which
+	// its CapacityExhaustion condition is raised
+	defaultCapacityWarningDays = 14
+
+	conditionTrue  = "True"
+	conditionFalse = "False"
+
+	capacityReasonInsufficientHistory = "InsufficientHistory"
+	capacityReasonNotFilling          = "NotFilling"
+	capacityReasonAboveThreshold      = "ForecastAboveThreshold"
+	capacityReasonBelowThreshold      = "ForecastBelowThreshold"
+)
+
+// capacitySample is the capacity of a StoragePool at a point in time
+type capacitySample struct {
+	time      time.Time
+	capacity  int64
+	freeSpace int64
+}
+
+// capacityHistory is the rolling capacity time series of a StoragePool. The history is only kept in memory, so it
+// is reset when the syncer restarts or loses leadership, and the forecast reports InsufficientHistory until the new
+// history spans minForecastSpan again.
+type capacityHistory struct {
+	lock    sync.Mutex
+	samples []capacitySample
+}
+
+// capacityForecast is the exhaustion forecast of the capacity of a StoragePool
+type capacityForecast struct {
+	// fillRatePerDay is the growth of the used space in bytes per day, nil if the history is too short
+	fillRatePerDay *int64
+	// daysToFull is the number of days until the StoragePool is full, nil if it is not filling up
+	daysToFull *int64
+}
+
+// add records the given sample and drops the samples older than capacityHistoryWindow
+func (h *capacityHistory) add(sample capacitySample) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	// the last sample is always the latest one, the previous ones are at least capacitySampleInterval apart
+	if n := len(h.samples); n > 1 && sample.time.Sub(h.samples[n-2].time) <= capacitySampleInterval {
+		h.samples[n-1] = sample
+		return
+	}
+	h.samples = append(h.samples, sample)
+	first := 0
+	for first < len(h.samples) && sample.time.Sub(h.samples[first].time) > capacityHistoryWindow {
+		first++
+	}
+	h.samples = h.samples[first:]
+}
+
+// forecast computes the fill rate of the StoragePool as the least squares slope of its used space over time
+func (h *capacityHistory) forecast() capacityForecast {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	n := len(h.samples)
+	if n < 2 || h.samples[n-1].time.Sub(h.samples[0].time) < minForecastSpan {
+		return capacityForecast{}
+	}
+	origin := h.samples[0].time
+	var sumX, sumY, sumXY, sumXX float64
+	for _, sample := range h.samples {
+		x := sample.time.Sub(origin).Hours() / 24
+		y := float64(sample.capacity - sample.freeSpace)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	denominator := float64(n)*sumXX - sumX*sumX
+	if denominator == 0 {
+		return capacityForecast{}
+	}
+	slope := (float64(n)*sumXY - sumX*sumY) / denominator
+	fillRate := int64(math.Round(slope))
+	forecast := capacityForecast{fillRatePerDay: &fillRate}
+	if fillRate > 0 {
+		daysToFull := h.samples[n-1].freeSpace / fillRate
+		forecast.daysToFull = &daysToFull
+	}
+	return forecast
+}
+
+// capacityExhaustionCondition returns the CapacityExhaustion condition of a StoragePool with the given forecast
+func capacityExhaustionCondition(forecast capacityForecast, warningDays int64) v1alpha1.StoragePoolCondition {
+	condition := v1alpha1.StoragePoolCondition{
+		Type:   v1alpha1.ConditionCapacityExhaustion,
+		Status: conditionFalse,
+	}
+	switch {
+	case forecast.fillRatePerDay == nil:
+		condition.Reason = capacityReasonInsufficientHistory
+		condition.Message = fmt.Sprintf("Capacity history shorter than %v", minForecastSpan)
+	case forecast.daysToFull == nil:
+		condition.Reason = capacityReasonNotFilling
+		condition.Message = "Used space is not growing"
+	case *forecast.daysToFull < warningDays:
+		condition.Status = conditionTrue
+		condition.Reason = capacityReasonBelowThreshold
+		condition.Message = fmt.Sprintf("Forecast to be full in %d day(s), below the threshold of %d day(s)",
+			*forecast.daysToFull, warningDays)
+	default:
+		condition.Reason = capacityReasonAboveThreshold
+		condition.Message = fmt.Sprintf("Forecast to be full in %d day(s)", *forecast.daysToFull)
+	}
+	return condition
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storagepool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/types"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+const gib = int64(1024 * 1024 * 1024)
+
+func TestCapacityForecast(t *testing.T) {
+	start := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name               string
+		usedGiBPerDay      int64
+		days               int
+		expectedFillRate   *int64
+		expectedDaysToFull *int64
+		expectedStatus     string
+		expectedReason     string
+	}{
+		{
+			name:           "history too short",
+			usedGiBPerDay:  10,
+			days:           0,
+			expectedStatus: conditionFalse,
+			expectedReason: capacityReasonInsufficientHistory,
+		},
+		{
+			name:               "filling up below the threshold",
+			usedGiBPerDay:      10,
+			days:               3,
+			expectedFillRate:   int64Ptr(10 * gib),
+			expectedDaysToFull: int64Ptr(7),
+			expectedStatus:     conditionTrue,
+			expectedReason:     capacityReasonBelowThreshold,
+		},
+		{
+			name:               "filling up above the threshold",
+			usedGiBPerDay:      1,
+			days:               3,
+			expectedFillRate:   int64Ptr(gib),
+			expectedDaysToFull: int64Ptr(97),
+			expectedStatus:     conditionFalse,
+			expectedReason:     capacityReasonAboveThreshold,
+		},
+		{
+			name:             "not filling up",
+			usedGiBPerDay:    -1,
+			days:             3,
+			expectedFillRate: int64Ptr(-gib),
+			expectedStatus:   conditionFalse,
+			expectedReason:   capacityReasonNotFilling,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			history := &capacityHistory{}
+			capacity := 200 * gib
+			used := 100 * gib
+			// one sample per hour, the sample interval and rolling window only keep some of them
+			for hour := 0; hour <= test.days*24; hour++ {
+				history.add(capacitySample{
+					time:      start.Add(time.Duration(hour) * time.Hour),
+					capacity:  capacity,
+					freeSpace: capacity - used - test.usedGiBPerDay*gib*int64(hour)/24,
+				})
+			}
+			forecast := history.forecast()
+			if !equalInt64Ptr(forecast.fillRatePerDay, test.expectedFillRate) {
+				t.Errorf("expected fill rate %v, got %v", formatInt64Ptr(test.expectedFillRate), formatInt64Ptr(forecast.fillRatePerDay))
+			}
+			if !equalInt64Ptr(forecast.daysToFull, test.expectedDaysToFull) {
+				t.Errorf("expected days to full %v, got %v", formatInt64Ptr(test.expectedDaysToFull), formatInt64Ptr(forecast.daysToFull))
+			}
+			condition := capacityExhaustionCondition(forecast, defaultCapacityWarningDays)
+			if condition.Status != test.expectedStatus || condition.Reason != test.expectedReason {
+				t.Errorf("expected condition %v/%v, got %v/%v", test.expectedStatus, test.expectedReason,
+					condition.Status, condition.Reason)
+			}
+		})
+	}
+}
+
+func TestCapacityHistoryWindow(t *testing.T) {
+	start := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	history := &capacityHistory{}
+	for minute := 0; minute <= 10*24*60; minute++ {
+		history.add(capacitySample{time: start.Add(time.Duration(minute) * time.Minute), capacity: gib, freeSpace: gib})
+	}
+	first, last := history.samples[0].time, history.samples[len(history.samples)-1].time
+	if last.Sub(first) > capacityHistoryWindow {
+		t.Errorf("expected history to span at most %v, got %v", capacityHistoryWindow, last.Sub(first))
+	}
+	if max := int(capacityHistoryWindow/capacitySampleInterval) + 2; len(history.samples) > max {
+		t.Errorf("expected at most %d samples, got %d", max, len(history.samples))
+	}
+	if !last.Equal(start.Add(10 * 24 * time.Hour)) {
+		t.Errorf("expected the last sample to be the latest one, got %v", last)
+	}
+}
+
+// TestUpdateIntendedStateRecordsCapacity checks that the capacity history of a vSAN StoragePool is fed by every
+// datastore update, while its capacity is only updated on significant changes
+func TestUpdateIntendedStateRecordsCapacity(t *testing.T) {
+	ctx := context.Background()
+	spName := makeStoragePoolName("vsanDatastore")
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newTestStoragePool(spName, "", "50Gi"))
+	savedGetSPClient := getSPClient
+	getSPClient = func(ctx context.Context) (dynamic.Interface, *schema.GroupVersionResource, error) {
+		return client, &testSPResource, nil
+	}
+	defer func() { getSPClient = savedGetSPClient }()
+
+	c := &SpController{capacityWarningDays: defaultCapacityWarningDays}
+	c.intendedStateMap.Store("datastore-1", &intendedState{
+		dsMoid:     "datastore-1",
+		dsType:     vsanDsType,
+		spName:     spName,
+		capacity:   resource.NewQuantity(100*gib, resource.DecimalSI),
+		freeSpace:  resource.NewQuantity(50*gib, resource.DecimalSI),
+		accessible: true,
+	})
+	summary := types.DatastoreSummary{
+		Name:            "vsanDatastore",
+		Type:            "vsan",
+		Capacity:        100 * gib,
+		FreeSpace:       50*gib - gib/2,
+		Accessible:      true,
+		MaintenanceMode: string(types.DatastoreSummaryMaintenanceModeStateNormal),
+	}
+	if err := c.updateIntendedState(ctx, "datastore-1", summary, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	value, _ := c.intendedStateMap.Load("datastore-1")
+	if freeSpace := value.(*intendedState).freeSpace.Value(); freeSpace != 50*gib {
+		t.Errorf("expected the insignificant change not to update freeSpace, got %d", freeSpace)
+	}
+	value, ok := c.capacityHistoryMap.Load(spName)
+	if !ok {
+		t.Fatalf("no capacity history recorded for StoragePool %s", spName)
+	}
+	samples := value.(*capacityHistory).samples
+	if len(samples) != 1 || samples[0].capacity != summary.Capacity || samples[0].freeSpace != summary.FreeSpace {
+		t.Errorf("expected a sample with capacity %d and freeSpace %d, got %+v", summary.Capacity, summary.FreeSpace, samples)
+	}
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func equalInt64Ptr(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func formatInt64Ptr(v *int64) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
@@ -19,6 +19,8 @@ package storagepool
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/vmware/govmomi/object"
 	vimtypes "github.com/vmware/govmomi/vim25/types"
@@ -26,10 +28,12 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
 
+	spv1alpha1 "sigs.k8s.io/vsphere-csi-driver/pkg/apis/storagepool/cns/v1alpha1"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/volume"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/vsphere"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
@@ -50,6 +54,11 @@ const (
 	fullDataEvacuationMM  = "evacuateAll"
 	targetSPAnnotationKey = spTypePrefix + "migrate-to-storagepool"
 	vmUUIDAnnotationKey   = "vmware-system-vm-uuid"
+
+	drainPreviewStatusField = "drainPreview"
+	// drainPreviewRefreshInterval is the interval at which the drain previews are recomputed to account
+	// for the changes of the PVCs, which are not watched
+	drainPreviewRefreshInterval = 10 * time.Minute
 )
 
 // DiskDecommController is responsible for watching and processing disk decommission request
@@ -65,6 +74,17 @@ type DiskDecommController struct {
 	diskDecommMode map[string]string
 	// 1 weighted semaphore to make sure only one disk decomm request is being executed
 	execSemaphore *semaphore.Weighted
+	// stores the disk decommission mode of the drain preview requested for a SP.
+	// Keys are SP name and values are disk decomm mode. Guarded by drainPreviewLock.
+	drainPreviewMode map[string]string
+	// stores the state of each SP the drain previews depend on, to recompute them when it changes.
+	// Keys are SP name. Guarded by drainPreviewLock.
+	drainPreviewInputs map[string]string
+	drainPreviewLock   sync.Mutex
+	// drainPreviewTrigger requests the drain previews to be recomputed
+	drainPreviewTrigger chan struct{}
+	// getDrainPreview computes the drain preview of a SP
+	getDrainPreview func(ctx context.Context, storagePoolName string, maintenanceMode string) (*spv1alpha1.DrainPreview, error)
 }
 
 // detachVolumes detaches all the volumes present in the specified StoragePool from corresponding PodVM
@@ -171,6 +191,9 @@ func (w *DiskDecommController) DecommissionDisk(ctx context.Context, storagePool
 		_, found, _ := getDrainMode(ctx, storagePoolName)
 		if !found {
 			log.Infof("Disk decommission of StoragePool %v has been aborted/ terminated", storagePoolName)
+			if err := clearVolumeMigrationStatuses(ctx, storagePoolName); err != nil {
+				log.Errorf("Failed to clear migration statuses of StoragePool %v. Error: %v", storagePoolName, err)
+			}
 			return
 		}
 
@@ -260,29 +283,39 @@ func initDiskDecommController(ctx context.Context, migrationCntlr *migrationCont
 	w.pvcResource = &schema.GroupVersionResource{Group: "", Version: "v1", Resource: "persistentvolumeclaims"}
 	w.diskDecommMode = make(map[string]string)
 	w.execSemaphore = semaphore.NewWeighted(1)
+	w.drainPreviewMode = make(map[string]string)
+	w.drainPreviewInputs = make(map[string]string)
+	w.drainPreviewTrigger = make(chan struct{}, 1)
+	w.getDrainPreview = getDrainPreview
+
+	// start StoragePool watch to look for events putting SP under disk decommission
+	err = w.renewStoragePoolWatch(ctx)
+	if err != nil {
+		return w, err
+	}
+	go w.watchStoragePool(ctx)
 
 	// get all the pvc resource for which targetSPAnnotationKey annotations exists.
-	// This will give us list of all pending migrations.
+	// This will give us list of all pending migrations, e.g. those interrupted by a syncer leader failover.
 	pvcList, err := w.k8sDynamicClient.Resource(*w.pvcResource).Namespace(v1.NamespaceAll).List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return w, err
 	}
 
 	pvcToMigrate := make([]*unstructured.Unstructured, 0)
-	for _, pvc := range pvcList.Items {
+	for idx := range pvcList.Items {
+		pvc := &pvcList.Items[idx]
 		targetSPName, _, _ := unstructured.NestedString(pvc.Object, "metadata", "annotations", targetSPAnnotationKey)
 		if targetSPName != "" {
-			pvcToMigrate = append(pvcToMigrate, &pvc)
+			pvcToMigrate = append(pvcToMigrate, pvc)
 		}
 	}
-	w.migrationCntlr.MigrateVolumes(ctx, pvcToMigrate, false)
-
-	// start StoragePool watch to look for events putting SP under disk decommission
-	err = w.renewStoragePoolWatch(ctx)
-	if err != nil {
-		return w, err
+	// The pending migrations are retried with a backoff, resume them in the background so that they
+	// don't delay the handling of new disk decommission requests.
+	if len(pvcToMigrate) > 0 {
+		log.Infof("Resuming migration of %d pending volume(s)", len(pvcToMigrate))
+		go w.migrationCntlr.MigrateVolumes(ctx, pvcToMigrate, false)
 	}
-	go w.watchStoragePool(ctx)
 
 	// get all sp resource
 	spList, err := w.k8sDynamicClient.Resource(*w.spResource).List(ctx, metav1.ListOptions{})
@@ -296,7 +329,11 @@ func initDiskDecommController(ctx context.Context, migrationCntlr *migrationCont
 			maintenanceMode := w.diskDecommMode[spName]
 			go w.DecommissionDisk(ctx, spName, maintenanceMode)
 		}
+		if w.shouldRefreshDrainPreviews(&sp, false) {
+			w.triggerDrainPreviews()
+		}
 	}
+	go w.runDrainPreviews(ctx)
 	return w, nil
 }
 
@@ -355,6 +392,10 @@ func (w *DiskDecommController) watchStoragePool(ctx context.Context) {
 				log.Infof("Got enter disk decommission request for StoragePool %v with MM %v", spName, maintenanceMode)
 				go w.DecommissionDisk(ctx, spName, maintenanceMode)
 			}
+			if w.shouldRefreshDrainPreviews(sp, e.Type == watch.Deleted) {
+				log.Debugf("StoragePool %v changed, refreshing drain previews", spName)
+				w.triggerDrainPreviews()
+			}
 		}
 	}
 	log.Info("watchStoragePool ends")
@@ -375,6 +416,15 @@ func (w *DiskDecommController) shouldEnterDiskDecommission(ctx context.Context,
 	}
 	defer func() {
 		if !found {
+			// clear the migration statuses left by a disk decommission which has been cancelled after it ended
+			migrations, _, _ := unstructured.NestedMap(sp.Object, "status", "diskDecomm", migrationsStatusField)
+			if len(migrations) != 0 {
+				go func() {
+					if err := clearVolumeMigrationStatuses(ctx, spName); err != nil {
+						log.Errorf("Failed to clear migration statuses of StoragePool %v. Error: %v", spName, err)
+					}
+				}()
+			}
 			delete(w.diskDecommMode, spName)
 		} else {
 			w.diskDecommMode[spName] = drainMode
@@ -389,3 +439,130 @@ func (w *DiskDecommController) shouldEnterDiskDecommission(ctx context.Context,
 	}
 	return false
 }
+
+// shouldRefreshDrainPreviews records the drain preview mode and the state the drain previews depend on
+// of the given SP. It returns true if a drain preview has been requested for the SP, or if the state of
+// the SP changed while drain previews are requested, as the migrations may target any vSAN Direct SP.
+func (w *DiskDecommController) shouldRefreshDrainPreviews(sp *unstructured.Unstructured, deleted bool) bool {
+	spName := sp.GetName()
+	driver, _, _ := unstructured.NestedString(sp.Object, "spec", "driver")
+	if driver != csitypes.Name || spName == "" {
+		return false
+	}
+	w.drainPreviewLock.Lock()
+	defer w.drainPreviewLock.Unlock()
+	previewMode, _, _ := unstructured.NestedString(sp.Object, "spec", "parameters", spv1alpha1.ParameterDrainPreviewMode)
+	if deleted || (previewMode != fullDataEvacuationMM && previewMode != ensureAccessibilityMM) {
+		delete(w.drainPreviewMode, spName)
+	} else {
+		w.drainPreviewMode[spName] = previewMode
+	}
+	inputs := ""
+	if !deleted {
+		inputs = getDrainPreviewInputs(sp)
+	}
+	inputsChanged := inputs != w.drainPreviewInputs[spName]
+	if inputs == "" {
+		delete(w.drainPreviewInputs, spName)
+	} else {
+		w.drainPreviewInputs[spName] = inputs
+	}
+	return inputsChanged && len(w.drainPreviewMode) > 0
+}
+
+// getDrainPreviewInputs returns the state of the given SP the drain previews depend on. The drain preview
+// status is left out so that reporting a drain preview does not trigger another one.
+func getDrainPreviewInputs(sp *unstructured.Unstructured) string {
+	previewMode, _, _ := unstructured.NestedString(sp.Object, "spec", "parameters", spv1alpha1.ParameterDrainPreviewMode)
+	drainMode, _, _ := unstructured.NestedString(sp.Object, "spec", "parameters", drainModeField)
+	capacity, _, _ := unstructured.NestedMap(sp.Object, "status", "capacity")
+	accessibleNodes, _, _ := unstructured.NestedStringSlice(sp.Object, "status", "accessibleNodes")
+	errState, _, _ := unstructured.NestedString(sp.Object, "status", "error", "state")
+	return fmt.Sprintf("%s/%s/%v/%v/%s/%v", previewMode, drainMode, capacity["total"], capacity["freeSpace"],
+		errState, accessibleNodes)
+}
+
+// triggerDrainPreviews requests the drain previews to be recomputed. Requests made while the
+// previews are computed are coalesced.
+func (w *DiskDecommController) triggerDrainPreviews() {
+	select {
+	case w.drainPreviewTrigger <- struct{}{}:
+	default:
+	}
+}
+
+// runDrainPreviews recomputes the drain previews of the SPs when triggered and periodically
+func (w *DiskDecommController) runDrainPreviews(ctx context.Context) {
+	ticker := time.NewTicker(drainPreviewRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.drainPreviewTrigger:
+		case <-ticker.C:
+		}
+		w.drainPreviewLock.Lock()
+		previewModes := make(map[string]string, len(w.drainPreviewMode))
+		for spName, previewMode := range w.drainPreviewMode {
+			previewModes[spName] = previewMode
+		}
+		w.drainPreviewLock.Unlock()
+		for spName, previewMode := range previewModes {
+			w.previewDrain(ctx, spName, previewMode)
+		}
+	}
+}
+
+// getDrainPreview computes the drain preview of the given SP with the K8s cloud operator placement engine
+func getDrainPreview(ctx context.Context, storagePoolName string, maintenanceMode string) (*spv1alpha1.DrainPreview, error) {
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return k8scloudoperator.GetDrainPreview(ctx, k8sClient, storagePoolName, maintenanceMode)
+}
+
+// previewDrain computes the storage vMotion plan of the given SP and reports it in the drainPreview
+// status field of the SP. No PVC is modified.
+func (w *DiskDecommController) previewDrain(ctx context.Context, storagePoolName string, maintenanceMode string) {
+	log := logger.GetLogger(ctx)
+	preview, err := w.getDrainPreview(ctx, storagePoolName, maintenanceMode)
+	if err != nil {
+		log.Errorf("Failed to compute drain preview of StoragePool %v. Error: %v", storagePoolName, err)
+		preview = &spv1alpha1.DrainPreview{
+			MaintenanceMode: maintenanceMode,
+			GeneratedAt:     metav1.Now(),
+			Error:           err.Error(),
+		}
+	}
+	previewObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(preview)
+	if err != nil {
+		log.Errorf("Failed to convert drain preview of StoragePool %v. Error: %v", storagePoolName, err)
+		return
+	}
+	task := func() (done bool, err error) {
+		sp, err := w.k8sDynamicClient.Resource(*w.spResource).Get(ctx, storagePoolName, metav1.GetOptions{})
+		if err != nil {
+			log.Errorf("Could not get StoragePool with name %v. Error: %v", storagePoolName, err)
+			return false, err
+		}
+		err = unstructured.SetNestedField(sp.Object, previewObj, "status", drainPreviewStatusField)
+		if err != nil {
+			return false, err
+		}
+		_, err = w.k8sDynamicClient.Resource(*w.spResource).Update(ctx, sp, metav1.UpdateOptions{})
+		if err != nil {
+			log.Errorf("Failed to update drain preview of StoragePool %v. Error: %v", storagePoolName, err)
+			return false, err
+		}
+		log.Infof("Successfully updated drain preview of StoragePool %v", storagePoolName)
+		return true, nil
+	}
+	baseDuration := time.Duration(100) * time.Millisecond
+	thresholdDuration := time.Duration(10) * time.Second
+	_, err = ExponentialBackoff(task, baseDuration, thresholdDuration, 1.5, 5)
+	if err != nil {
+		log.Errorf("Failed to update drain preview of StoragePool %v. Error: %v", storagePoolName, err)
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storagepool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	spv1alpha1 "sigs.k8s.io/vsphere-csi-driver/pkg/apis/storagepool/cns/v1alpha1"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/pkg/csi/types"
+)
+
+// newTestStoragePool returns a StoragePool of the CSI driver with the given drain preview mode, if any
+func newTestStoragePool(name string, previewMode string, freeSpace string) *unstructured.Unstructured {
+	sp := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": spv1alpha1.SchemeGroupVersion.String(),
+		"kind":       "StoragePool",
+		"spec": map[string]interface{}{
+			"driver":     csitypes.Name,
+			"parameters": map[string]interface{}{},
+		},
+		"status": map[string]interface{}{
+			"capacity": map[string]interface{}{"total": "100Gi", "freeSpace": freeSpace},
+		},
+	}}
+	sp.SetName(name)
+	if previewMode != "" {
+		_ = unstructured.SetNestedField(sp.Object, previewMode, "spec", "parameters", spv1alpha1.ParameterDrainPreviewMode)
+	}
+	return sp
+}
+
+func newTestDiskDecommController(objects ...runtime.Object) *DiskDecommController {
+	spResource := spv1alpha1.SchemeGroupVersion.WithResource("storagepools")
+	return &DiskDecommController{
+		k8sDynamicClient:    dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objects...),
+		spResource:          &spResource,
+		drainPreviewMode:    make(map[string]string),
+		drainPreviewInputs:  make(map[string]string),
+		drainPreviewTrigger: make(chan struct{}, 1),
+	}
+}
+
+func TestPreviewDrain(t *testing.T) {
+	ctx := context.Background()
+	w := newTestDiskDecommController(newTestStoragePool("sp-1", ensureAccessibilityMM, "50Gi"))
+	getPreviewedSP := func() *spv1alpha1.StoragePool {
+		obj, err := w.k8sDynamicClient.Resource(*w.spResource).Get(ctx, "sp-1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sp := &spv1alpha1.StoragePool{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, sp); err != nil {
+			t.Fatal(err)
+		}
+		return sp
+	}
+
+	w.getDrainPreview = func(ctx context.Context, storagePoolName string, maintenanceMode string) (*spv1alpha1.DrainPreview, error) {
+		return &spv1alpha1.DrainPreview{
+			MaintenanceMode: maintenanceMode,
+			GeneratedAt:     metav1.Now(),
+			Migrations: []spv1alpha1.PlannedMigration{
+				{PVName: "pv-1", PVCName: "pvc-1", PVCNamespace: "ns", SizeInBytes: gib, TargetStoragePool: "sp-2"},
+			},
+			UnplaceableVolumes: []spv1alpha1.UnplaceableVolume{
+				{PVName: "pv-2", PVCName: "pvc-2", PVCNamespace: "ns", SizeInBytes: gib, Reason: "no StoragePool"},
+			},
+			ProjectedFreeSpace: map[string]int64{"sp-1": 51 * gib, "sp-2": 9 * gib},
+		}, nil
+	}
+	w.previewDrain(ctx, "sp-1", ensureAccessibilityMM)
+	preview := getPreviewedSP().Status.DrainPreview
+	if preview == nil || preview.MaintenanceMode != ensureAccessibilityMM || preview.Error != "" ||
+		len(preview.Migrations) != 1 || preview.Migrations[0].TargetStoragePool != "sp-2" ||
+		len(preview.UnplaceableVolumes) != 1 || preview.ProjectedFreeSpace["sp-2"] != 9*gib {
+		t.Errorf("unexpected drain preview %+v", preview)
+	}
+
+	w.getDrainPreview = func(ctx context.Context, storagePoolName string, maintenanceMode string) (*spv1alpha1.DrainPreview, error) {
+		return nil, errors.New("placement failed")
+	}
+	w.previewDrain(ctx, "sp-1", fullDataEvacuationMM)
+	preview = getPreviewedSP().Status.DrainPreview
+	if preview == nil || preview.MaintenanceMode != fullDataEvacuationMM || preview.Error != "placement failed" ||
+		len(preview.Migrations) != 0 {
+		t.Errorf("expected the drain preview to report the error, got %+v", preview)
+	}
+}
+
+func TestShouldRefreshDrainPreviews(t *testing.T) {
+	w := newTestDiskDecommController()
+	previewedSP := newTestStoragePool("sp-1", fullDataEvacuationMM, "50Gi")
+	reportedSP := previewedSP.DeepCopy()
+	_ = unstructured.SetNestedField(reportedSP.Object, map[string]interface{}{"maintenanceMode": fullDataEvacuationMM},
+		"status", drainPreviewStatusField)
+	cancelledSP := newTestStoragePool("sp-1", "", "50Gi")
+	tests := []struct {
+		name     string
+		sp       *unstructured.Unstructured
+		deleted  bool
+		expected bool
+	}{
+		{"no drain preview requested", newTestStoragePool("sp-2", "", "20Gi"), false, false},
+		{"invalid drain preview mode", newTestStoragePool("sp-1", noMigrationMM, "50Gi"), false, false},
+		{"drain preview requested", previewedSP, false, true},
+		{"drain preview reported", reportedSP, false, false},
+		{"capacity of another StoragePool changed", newTestStoragePool("sp-2", "", "10Gi"), false, true},
+		{"other StoragePool unchanged", newTestStoragePool("sp-2", "", "10Gi"), false, false},
+		{"other StoragePool deleted", newTestStoragePool("sp-2", "", "10Gi"), true, true},
+		{"drain preview cancelled", cancelledSP, false, false},
+		{"capacity changed without drain preview", newTestStoragePool("sp-1", "", "40Gi"), false, false},
+	}
+	for _, test := range tests {
+		if refresh := w.shouldRefreshDrainPreviews(test.sp, test.deleted); refresh != test.expected {
+			t.Errorf("%s: expected refresh %v, got %v", test.name, test.expected, refresh)
+		}
+	}
+	if len(w.drainPreviewMode) != 0 {
+		t.Errorf("expected no drain preview mode left, got %v", w.drainPreviewMode)
+	}
+}
+
+func TestRunDrainPreviews(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := newTestDiskDecommController(newTestStoragePool("sp-1", ensureAccessibilityMM, "50Gi"))
+	previewed := make(chan string, 10)
+	w.getDrainPreview = func(ctx context.Context, storagePoolName string, maintenanceMode string) (*spv1alpha1.DrainPreview, error) {
+		previewed <- storagePoolName + "/" + maintenanceMode
+		return &spv1alpha1.DrainPreview{MaintenanceMode: maintenanceMode, GeneratedAt: metav1.Now()}, nil
+	}
+	if !w.shouldRefreshDrainPreviews(newTestStoragePool("sp-1", ensureAccessibilityMM, "50Gi"), false) {
+		t.Fatal("expected the drain preview request to trigger a refresh")
+	}
+	w.triggerDrainPreviews()
+	// triggers are coalesced
+	w.triggerDrainPreviews()
+	go w.runDrainPreviews(ctx)
+	select {
+	case preview := <-previewed:
+		if preview != "sp-1/"+ensureAccessibilityMM {
+			t.Errorf("unexpected drain preview %s", preview)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("drain preview not computed")
+	}
+	select {
+	case preview := <-previewed:
+		t.Errorf("unexpected drain preview %s", preview)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
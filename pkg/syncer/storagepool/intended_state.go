@@ -22,7 +22,9 @@ import (
 	"math"
 	"strings"
 	"sync"
+	"time"
 
+	cnstypes "github.com/vmware/govmomi/cns/types"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/mo"
@@ -31,9 +33,11 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 
 	"sigs.k8s.io/vsphere-csi-driver/pkg/apis/storagepool/cns/v1alpha1"
 	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/prometheus"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
 	csitypes "sigs.k8s.io/vsphere-csi-driver/pkg/csi/types"
 )
@@ -68,6 +72,10 @@ type intendedState struct {
 	compatSC []string
 	// is a remote vSAN Datastore mounted into this cluster - HCI Mesh feature
 	isRemoteVsan bool
+	// exhaustion forecast computed from the capacity history of the StoragePool
+	forecast capacityForecast
+	// CapacityExhaustion condition derived from the forecast
+	capacityCondition v1alpha1.StoragePoolCondition
 }
 
 // SpController holds the intended state updated by property collector listener and has methods to apply intended state
@@ -75,15 +83,70 @@ type intendedState struct {
 type SpController struct {
 	vc        *cnsvsphere.VirtualCenter
 	clusterID string
+	// clusterFlavor is Workload for Supervisor clusters, where the k8s nodes are ESX hosts, and Vanilla for clusters
+	// whose k8s nodes are VMs
+	clusterFlavor cnstypes.CnsClusterFlavor
+	// hostMoIDToNodeNames maps the host moids to the names of the k8s node VMs running on them in Vanilla clusters.
+	// It is refreshed by ReconcileAllStoragePools.
+	hostMoIDToNodeNames map[string][]string
 	// intendedStateMap stores the datastoreMoid -> IntendedState for each datastore. Underlying map type map[string]*intendedState
 	intendedStateMap sync.Map
+	// capacityHistoryMap stores the spName -> capacity history of each StoragePool, in memory only. Underlying map type map[string]*capacityHistory
+	capacityHistoryMap sync.Map
+	// capacityWarningDays is the number of days until a StoragePool is full below which its CapacityExhaustion
+	// condition is raised
+	capacityWarningDays int64
 }
 
-func newSPController(vc *cnsvsphere.VirtualCenter, clusterID string) (*SpController, error) {
-	return &SpController{
-		vc:        vc,
-		clusterID: clusterID,
-	}, nil
+func newSPController(vc *cnsvsphere.VirtualCenter, clusterID string, clusterFlavor cnstypes.CnsClusterFlavor,
+	capacityWarningDays int) (*SpController, error) {
+	spController := &SpController{
+		vc:                  vc,
+		clusterID:           clusterID,
+		clusterFlavor:       clusterFlavor,
+		capacityWarningDays: defaultCapacityWarningDays,
+	}
+	if capacityWarningDays > 0 {
+		spController.capacityWarningDays = int64(capacityWarningDays)
+	}
+	return spController, nil
+}
+
+// recordCapacity adds the given capacity and freeSpace of a StoragePool to its history
+func (c *SpController) recordCapacity(spName string, capacity int64, freeSpace int64) {
+	value, _ := c.capacityHistoryMap.LoadOrStore(spName, &capacityHistory{})
+	value.(*capacityHistory).add(capacitySample{
+		time:      time.Now(),
+		capacity:  capacity,
+		freeSpace: freeSpace,
+	})
+}
+
+// updateCapacityForecast updates the exhaustion forecast of the intended state from the capacity history of its
+// StoragePool, and the corresponding metrics
+func (c *SpController) updateCapacityForecast(ctx context.Context, state *intendedState) {
+	log := logger.GetLogger(ctx)
+	value, _ := c.capacityHistoryMap.LoadOrStore(state.spName, &capacityHistory{})
+	state.forecast = value.(*capacityHistory).forecast()
+	state.capacityCondition = capacityExhaustionCondition(state.forecast, c.capacityWarningDays)
+	if state.forecast.fillRatePerDay != nil {
+		prometheus.StoragePoolFillRateGaugeVec.WithLabelValues(state.spName).Set(float64(*state.forecast.fillRatePerDay))
+	}
+	if state.forecast.daysToFull != nil {
+		prometheus.StoragePoolDaysToFullGaugeVec.WithLabelValues(state.spName).Set(float64(*state.forecast.daysToFull))
+	} else {
+		prometheus.StoragePoolDaysToFullGaugeVec.DeleteLabelValues(state.spName)
+	}
+	if state.capacityCondition.Status == conditionTrue {
+		log.Warnf("StoragePool %s: %s", state.spName, state.capacityCondition.Message)
+	}
+}
+
+// deleteCapacityHistory drops the capacity history and metrics of the given StoragePool
+func (c *SpController) deleteCapacityHistory(spName string) {
+	c.capacityHistoryMap.Delete(spName)
+	prometheus.StoragePoolFillRateGaugeVec.DeleteLabelValues(spName)
+	prometheus.StoragePoolDaysToFullGaugeVec.DeleteLabelValues(spName)
 }
 
 // newIntendedState creates a new IntendedState for a StoragePool
@@ -111,7 +174,13 @@ func newIntendedState(ctx context.Context, ds *cnsvsphere.DatastoreInfo,
 		return nil, err
 	}
 
-	nodesMap, err := findAccessibleNodes(ctx, ds.Datastore.Datastore, clusterID, vcClient.Client)
+	isVanilla := scWatchCntlr.spController.clusterFlavor == cnstypes.CnsClusterFlavorVanilla
+	var nodesMap map[string]bool
+	if isVanilla {
+		nodesMap, err = findAccessibleNodeVMs(ctx, ds.Datastore.Datastore, scWatchCntlr.spController.hostMoIDToNodeNames)
+	} else {
+		nodesMap, err = findAccessibleNodes(ctx, ds.Datastore.Datastore, clusterID, vcClient.Client)
+	}
 	if err != nil {
 		log.Errorf("Error finding accessible nodes of datastore %v. Err: %+v", ds, err)
 		return nil, err
@@ -144,10 +213,14 @@ func newIntendedState(ctx context.Context, ds *cnsvsphere.DatastoreInfo,
 		log.Infof("Failed to get compatible policies for %s", ds.Reference().Value)
 	}
 
-	remoteVsan, err := isRemoteVsan(ctx, dsProps, clusterID, vcClient.Client)
-	if err != nil {
-		log.Errorf("Not able to determine whether Datastore is vSAN Remote. %+v", err)
-		return nil, err
+	// the vSAN cluster of Vanilla clusters is not known, so their vSAN Datastores are deemed local
+	remoteVsan := false
+	if !isVanilla {
+		remoteVsan, err = isRemoteVsan(ctx, dsProps, clusterID, vcClient.Client)
+		if err != nil {
+			log.Errorf("Not able to determine whether Datastore is vSAN Remote. %+v", err)
+			return nil, err
+		}
 	}
 
 	return &intendedState{
@@ -276,6 +349,7 @@ func (c *SpController) applyIntendedState(ctx context.Context, state *intendedSt
 	if err != nil {
 		return err
 	}
+	c.updateCapacityForecast(ctx, state)
 	// Get StoragePool with spName and Update if already present, otherwise Create resource
 	sp, err := spClient.Resource(*spResource).Get(ctx, state.spName, metav1.GetOptions{})
 	if err != nil {
@@ -303,11 +377,14 @@ func (c *SpController) applyIntendedState(ctx context.Context, state *intendedSt
 		log.Debugf("Successfully updated StoragePool %v", newSp)
 	}
 
-	// update the underlying dsType in the all the compatible storage classes for reverse mapping
-	for _, scName := range state.compatSC {
-		if err := updateSPTypeInSC(ctx, scName, state.dsType); err != nil {
-			log.Errorf("Failed to update compatibleSPTypes in storage class %s. Err: %+v", scName, err)
-			continue
+	// update the underlying dsType in the all the compatible storage classes for reverse mapping.
+	// It is only used by the placement of Supervisor clusters.
+	if c.clusterFlavor != cnstypes.CnsClusterFlavorVanilla {
+		for _, scName := range state.compatSC {
+			if err := updateSPTypeInSC(ctx, scName, state.dsType); err != nil {
+				log.Errorf("Failed to update compatibleSPTypes in storage class %s. Err: %+v", scName, err)
+				continue
+			}
 		}
 	}
 	c.intendedStateMap.Store(state.dsMoid, state)
@@ -352,6 +429,9 @@ func (c *SpController) updateIntendedState(ctx context.Context, dsMoid string, d
 	intendedState.spName = intendedSpName
 	intendedState.url = dsSummary.Url
 	intendedState.datastoreInMM = dsSummary.MaintenanceMode != string(types.DatastoreSummaryMaintenanceModeStateNormal)
+	// The capacity history is fed by every update, the StoragePool capacity of a vSAN datastore is only updated on
+	// significant changes but the forecast needs the actual fill rate.
+	c.recordCapacity(intendedSpName, dsSummary.Capacity, dsSummary.FreeSpace)
 	// update StoragePool as per intendedState
 	if err := c.applyIntendedState(ctx, intendedState); err != nil {
 		return err
@@ -366,6 +446,7 @@ func (c *SpController) updateIntendedState(ctx context.Context, dsMoid string, d
 	}
 	if intendedSpName != oldSpName {
 		// need to also delete the older StoragePool
+		c.deleteCapacityHistory(oldSpName)
 		return deleteStoragePool(ctx, oldSpName)
 	}
 	return nil
@@ -403,6 +484,7 @@ func (c *SpController) updateVsanSnaIntendedState(ctx context.Context, vsanState
 			continue
 		}
 		validStoragePoolNames[intendedSNAState.spName] = true
+		c.recordCapacity(intendedSNAState.spName, intendedSNAState.capacity.Value(), intendedSNAState.freeSpace.Value())
 		err = c.applyIntendedState(ctx, intendedSNAState)
 		if err != nil {
 			log.Errorf("Error applying intended state of StoragePool %s. Err: %v", intendedSNAState.spName, err)
@@ -414,6 +496,7 @@ func (c *SpController) updateVsanSnaIntendedState(ctx context.Context, vsanState
 
 func (c *SpController) deleteIntendedState(ctx context.Context, spName string) (deleted bool) {
 	deleted = false
+	c.deleteCapacityHistory(spName)
 	c.intendedStateMap.Range(func(key, value interface{}) bool {
 		state, ok := value.(*intendedState)
 		if ok && state.spName == spName {
@@ -498,6 +581,7 @@ func (state *intendedState) createUnstructuredStoragePool(ctx context.Context) *
 		setNestedField(ctx, sp.Object, spErr.State, "status", "error", "state")
 		setNestedField(ctx, sp.Object, spErr.Message, "status", "error", "message")
 	}
+	state.setCapacityForecast(ctx, sp)
 	return sp
 }
 
@@ -522,9 +606,55 @@ func (state *intendedState) updateUnstructuredStoragePool(ctx context.Context, s
 	} else {
 		unstructured.RemoveNestedField(sp.Object, "status", "error")
 	}
+	state.setCapacityForecast(ctx, sp)
 	return sp
 }
 
+// setCapacityForecast sets the fill rate, days to full and CapacityExhaustion condition of the given StoragePool.
+// The last transition time of the condition is kept unless its status changes.
+func (state *intendedState) setCapacityForecast(ctx context.Context, sp *unstructured.Unstructured) {
+	log := logger.GetLogger(ctx)
+	if state.forecast.fillRatePerDay != nil {
+		setNestedField(ctx, sp.Object, *state.forecast.fillRatePerDay, "status", "capacity", "fillRatePerDay")
+	} else {
+		unstructured.RemoveNestedField(sp.Object, "status", "capacity", "fillRatePerDay")
+	}
+	if state.forecast.daysToFull != nil {
+		setNestedField(ctx, sp.Object, *state.forecast.daysToFull, "status", "capacity", "daysToFull")
+	} else {
+		unstructured.RemoveNestedField(sp.Object, "status", "capacity", "daysToFull")
+	}
+
+	condition := state.capacityCondition
+	if condition.Type == "" {
+		return
+	}
+	condition.LastTransitionTime = metav1.Now()
+	conditions, _, _ := unstructured.NestedSlice(sp.Object, "status", "conditions")
+	newConditions := make([]interface{}, 0, len(conditions)+1)
+	for _, obj := range conditions {
+		existing, ok := obj.(map[string]interface{})
+		if !ok || existing["type"] != condition.Type {
+			newConditions = append(newConditions, obj)
+			continue
+		}
+		if existing["status"] == condition.Status {
+			if lastTransitionTime, ok := existing["lastTransitionTime"].(string); ok {
+				if err := condition.LastTransitionTime.UnmarshalQueryParameter(lastTransitionTime); err != nil {
+					log.Debugf("Ignoring invalid last transition time %q of StoragePool %s", lastTransitionTime, state.spName)
+				}
+			}
+		}
+	}
+	conditionObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&condition)
+	if err != nil {
+		log.Errorf("err: %v", err)
+		return
+	}
+	newConditions = append(newConditions, conditionObj)
+	setNestedField(ctx, sp.Object, newConditions, "status", "conditions")
+}
+
 func setNestedField(ctx context.Context, obj map[string]interface{}, value interface{}, fields ...string) {
 	log := logger.GetLogger(ctx)
 	if err := unstructured.SetNestedField(obj, value, fields...); err != nil {
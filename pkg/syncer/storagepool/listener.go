@@ -22,12 +22,14 @@ import (
 	"sync"
 	"time"
 
+	cnstypes "github.com/vmware/govmomi/cns/types"
 	"github.com/vmware/govmomi/property"
 	"github.com/vmware/govmomi/vim25/types"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/prometheus"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
 	csitypes "sigs.k8s.io/vsphere-csi-driver/pkg/csi/types"
 )
@@ -121,9 +123,15 @@ func initListener(ctx context.Context, scWatchCntlr *StorageClassWatch, spContro
 						}
 						// Datastore summary property can be updated immediately into the StoragePool
 						log.Debugf("Starting update for single StoragePool %s", ds.Value)
+						start := time.Now()
 						err := spController.updateIntendedState(ctx, ds.Value, summary, scWatchCntlr)
 						if err != nil {
 							log.Errorf("Error updating StoragePool for datastore %v. Err: %v", ds, err)
+							prometheus.StoragePoolReconcileHistVec.WithLabelValues(prometheus.PrometheusStoragePoolUpdateOpType,
+								prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
+						} else {
+							prometheus.StoragePoolReconcileHistVec.WithLabelValues(prometheus.PrometheusStoragePoolUpdateOpType,
+								prometheus.PrometheusPassStatus).Observe(time.Since(start).Seconds())
 						}
 					} else {
 						// Handle changes in "hosts in cluster", "hosts inMaintenanceMode state" and "Datastores mounted on hosts" by
@@ -177,11 +185,16 @@ func scheduleReconcileAllStoragePools(ctx context.Context, freq time.Duration, n
 			select {
 			case <-tick.C:
 				log.Debugf("[%s] Starting reconcile for all StoragePool instances", iterID)
+				start := time.Now()
 				err := ReconcileAllStoragePools(ctx, scWatchCntrl, spController)
 				if err != nil {
 					log.Errorf("[%s] Error reconciling StoragePool instances in HostMount listener. Err: %+v", iterID, err)
+					prometheus.StoragePoolReconcileHistVec.WithLabelValues(prometheus.PrometheusStoragePoolReconcileAllOpType,
+						prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
 				} else {
 					log.Debugf("[%s] Successfully reconciled all StoragePool instances", iterID)
+					prometheus.StoragePoolReconcileHistVec.WithLabelValues(prometheus.PrometheusStoragePoolReconcileAllOpType,
+						prometheus.PrometheusPassStatus).Observe(time.Since(start).Seconds())
 				}
 			case <-ctx.Done():
 				log.Debugf("[%s] Done reconcile all loop for StoragePools", iterID)
@@ -210,12 +223,21 @@ func ReconcileAllStoragePools(ctx context.Context, scWatchCntlr *StorageClassWat
 		return err
 	}
 	// Get datastores from VC
-	sharedDatastores, vsanDirectDatastores, err := cnsvsphere.GetCandidateDatastoresInCluster(ctx, &vc, spCtl.clusterID)
-	if err != nil {
-		log.Errorf("Failed to find datastores from VC. Err: %+v", err)
-		return err
+	var datastores []*cnsvsphere.DatastoreInfo
+	if spCtl.clusterFlavor == cnstypes.CnsClusterFlavorVanilla {
+		datastores, err = spCtl.refreshNodeVMHosts(ctx, &vc)
+		if err != nil {
+			log.Errorf("Failed to find datastores accessible to the node VMs. Err: %+v", err)
+			return err
+		}
+	} else {
+		sharedDatastores, vsanDirectDatastores, err := cnsvsphere.GetCandidateDatastoresInCluster(ctx, &vc, spCtl.clusterID)
+		if err != nil {
+			log.Errorf("Failed to find datastores from VC. Err: %+v", err)
+			return err
+		}
+		datastores = append(sharedDatastores, vsanDirectDatastores...)
 	}
-	datastores := append(sharedDatastores, vsanDirectDatastores...)
 	validStoragePoolNames := make(map[string]bool)
 	// create StoragePools that are missing and add them to intendedStateMap
 	for _, dsInfo := range datastores {
@@ -226,6 +248,7 @@ func ReconcileAllStoragePools(ctx context.Context, scWatchCntlr *StorageClassWat
 			log.Errorf("Error reconciling StoragePool for datastore %s. Err: %v", dsInfo.Reference().Value, err)
 			continue
 		}
+		spCtl.recordCapacity(intendedState.spName, intendedState.capacity.Value(), intendedState.freeSpace.Value())
 		err = spCtl.applyIntendedState(ctx, intendedState)
 		if err != nil {
 			log.Errorf("Error applying intended state of StoragePool %s. Err: %v", intendedState.spName, err)
@@ -233,7 +256,8 @@ func ReconcileAllStoragePools(ctx context.Context, scWatchCntlr *StorageClassWat
 		}
 
 		// create vsan-sna StoragePools for local vsan datastore
-		if intendedState.dsType == vsanDsType && !intendedState.isRemoteVsan {
+		if intendedState.dsType == vsanDsType && !intendedState.isRemoteVsan &&
+			spCtl.clusterFlavor != cnstypes.CnsClusterFlavorVanilla {
 			err := spCtl.updateVsanSnaIntendedState(ctx, intendedState, validStoragePoolNames, scWatchCntlr)
 			if err != nil {
 				log.Errorf("Error updating intended state of vSAN SNA StoragePools. Err: %v", err)
@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sync"
 	"time"
 
 	cnstypes "github.com/vmware/govmomi/cns/types"
@@ -31,27 +32,78 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 
+	spv1alpha1 "sigs.k8s.io/vsphere-csi-driver/pkg/apis/storagepool/cns/v1alpha1"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/volume"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/vsphere"
 	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/vsphere"
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/pkg/common/config"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/syncer/k8scloudoperator"
 )
 
+const (
+	defaultVolumeMigrationConcurrency = 1
+	defaultVolumeMigrationMaxRetries  = 3
+)
+
+// migrationRetryInterval is multiplied by the number of attempts to get the delay before retrying a relocation
+var migrationRetryInterval = 30 * time.Second
+
 // migrationController is responsible for processing CNS volume relocation to another StoragePool
 type migrationController struct {
 	vc        *cnsvsphere.VirtualCenter
 	clusterID string
+	// concurrency is the number of volumes relocated concurrently
+	concurrency int
+	// interval is the minimum interval between the start of two relocations
+	interval time.Duration
+	// maxRetries is the number of times a failed relocation is retried
+	maxRetries int64
+	// pacingLock protects nextRelocation, the earliest time the next relocation can start at
+	pacingLock     sync.Mutex
+	nextRelocation time.Time
+	// relocateVolume relocates the CNS volume with the given ID to the given StoragePool
+	relocateVolume func(ctx context.Context, volumeID string, targetSPName string) error
 }
 
-func initMigrationController(vc *cnsvsphere.VirtualCenter, clusterID string) *migrationController {
+func initMigrationController(vc *cnsvsphere.VirtualCenter, cfg *cnsconfig.Config) *migrationController {
 	migrationCntlr := migrationController{
-		vc:        vc,
-		clusterID: clusterID,
+		vc:          vc,
+		clusterID:   cfg.Global.ClusterID,
+		concurrency: defaultVolumeMigrationConcurrency,
+		interval:    time.Duration(cfg.Global.VolumeMigrationIntervalInSec) * time.Second,
+		maxRetries:  defaultVolumeMigrationMaxRetries,
+	}
+	migrationCntlr.relocateVolume = migrationCntlr.relocateCNSVolume
+	if cfg.Global.VolumeMigrationConcurrency > 0 {
+		migrationCntlr.concurrency = cfg.Global.VolumeMigrationConcurrency
+	}
+	if cfg.Global.VolumeMigrationMaxRetries > 0 {
+		migrationCntlr.maxRetries = int64(cfg.Global.VolumeMigrationMaxRetries)
 	}
 	return &migrationCntlr
 }
 
+// waitForRelocationSlot blocks until the interval since the start of the previous relocation has elapsed
+func (m *migrationController) waitForRelocationSlot(ctx context.Context) error {
+	m.pacingLock.Lock()
+	start := time.Now()
+	if m.nextRelocation.After(start) {
+		start = m.nextRelocation
+	}
+	m.nextRelocation = start.Add(m.interval)
+	m.pacingLock.Unlock()
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 func (m *migrationController) relocateCNSVolume(ctx context.Context, volumeID string, targetSPName string) error {
 	log := logger.GetLogger(ctx)
 	k8sDynamicClient, spResource, err := getSPClient(ctx)
@@ -110,13 +162,6 @@ func (m *migrationController) migrateVolume(ctx context.Context, pvc *unstructur
 	pvcName := pvc.GetName()
 	pvcNamespace := pvc.GetNamespace()
 
-	defer func() {
-		_, err := removeTargetSPAnnotationOnPVC(ctx, pvcName, pvcNamespace)
-		if err != nil {
-			log.Errorf("Failed to remove target SP annotation from PVC %v. Error: %v", pvcName, err)
-		}
-	}()
-
 	pvResource := schema.GroupVersionResource{Group: "", Version: "v1", Resource: "persistentvolumes"}
 	k8sDynamicClient, spResource, err := getSPClient(ctx)
 	if err != nil {
@@ -148,7 +193,7 @@ func (m *migrationController) migrateVolume(ctx context.Context, pvc *unstructur
 	}
 	log.Debugf("Migrating volume %v to SP %v", volumeID, targetSP.GetName())
 
-	err = m.relocateCNSVolume(ctx, volumeID, targetSPName)
+	err = m.relocateVolume(ctx, volumeID, targetSPName)
 	if err != nil {
 		log.Errorf("Could not migrate PVC %v to StoragePool %v. Error: %v", pvcName, targetSPName, err)
 		return false, err
@@ -160,66 +205,193 @@ func (m *migrationController) migrateVolume(ctx context.Context, pvc *unstructur
 	return true, nil
 }
 
+// migrationJob is the migration of a PVC by MigrateVolumes
+type migrationJob struct {
+	pvc *unstructured.Unstructured
+	// sourceSPName is the StoragePool the migration status is recorded in, empty if unknown
+	sourceSPName string
+	status       spv1alpha1.VolumeMigrationStatus
+}
+
 // MigrateVolumes given a list of PVC migrates the corresponding volume for each PVC to the target SP specified by
 // targetSPAnnotationKey (cns.vmware.com/migrate-to-storagepool) annotation. If the annotation is not present on the PVC
 // the corresponding migration fails. The function returns a tuple consisting of list of PVCs for which migration succeeded
-// and a list of PVCs for which migration failed. The migration is performed sequentially one by one by calling CNS Relocate API.
-// If abortOnFirstFailure is true then after encountering first migration failure it aborts remaining PVC migrations and adds them
-// to unsuccessfulMigrations list.
+// and a list of PVCs for which migration failed. Up to m.concurrency volumes are migrated concurrently by calling CNS
+// Relocate API, the relocations being started at least m.interval apart. A failed relocation is retried up to
+// m.maxRetries times.
+// The migration of each PVC is tracked in the diskDecomm status of its source StoragePool. The number of attempts of
+// the migrations interrupted by a restart of the syncer, i.e. still queued or running in the status, is carried over.
+// If abortOnFirstFailure is true then after the first migration failing all its retries it aborts remaining PVC
+// migrations and adds them to unsuccessfulMigrations list.
 // On successful migration k8scloudoperator.StoragePoolAnnotationKey annotation is updated on PVC to reflect new StoragePool
 // targetSPAnnotationKey annotation is removed from PVC for both successful and unsuccessful migrations.
 func (m *migrationController) MigrateVolumes(ctx context.Context, pvcList []*unstructured.Unstructured, abortOnFirstFailure bool) (successfulMigrations []*unstructured.Unstructured, unsuccessfulMigrations []*unstructured.Unstructured) {
 	log := logger.GetLogger(ctx)
-	shouldAbort := false
 	successfulMigrations = make([]*unstructured.Unstructured, 0)
 	unsuccessfulMigrations = make([]*unstructured.Unstructured, 0)
+
+	jobs := make(chan *migrationJob, len(pvcList))
+	previousStatuses := make(map[string]map[string]spv1alpha1.VolumeMigrationStatus)
 	for _, pvc := range pvcList {
-		pvcName := pvc.GetName()
-		pvcNamespace := pvc.GetNamespace()
-		if shouldAbort {
-			log.Infof("Migration for PVC %v has been aborted.", pvcName)
-			_, err := removeTargetSPAnnotationOnPVC(ctx, pvcName, pvcNamespace)
+		job := m.newMigrationJob(ctx, pvc, previousStatuses)
+		if job.sourceSPName != "" {
+			err := updateVolumeMigrationStatus(ctx, job.sourceSPName, job.status)
 			if err != nil {
-				log.Errorf("Failed to remove target SP annotation from PVC %v. Error: %v", pvcName, err)
+				log.Warnf("Failed to record migration of PVC %v as queued. Error: %v", pvc.GetName(), err)
 			}
-			unsuccessfulMigrations = append(unsuccessfulMigrations, pvc)
-			continue
 		}
+		jobs <- job
+	}
+	close(jobs)
 
-		// check if disk decomm. of source StoragePool has been aborted/ terminated
-		sourceSPName, found, err := unstructured.NestedString(pvc.Object, "metadata", "annotations", k8scloudoperator.StoragePoolAnnotationKey)
-		if !found || err != nil || sourceSPName == "" {
-			// log the error and assume that source SP is under disk decommission
-			log.Warnf("Could not get source StoragePool name for PVC %v. Error: %v", pvcName, err)
-		} else {
-			drainMode, found, err := getDrainMode(ctx, sourceSPName)
-			if (!found || (drainMode != fullDataEvacuationMM && drainMode != ensureAccessibilityMM)) && err == nil {
-				log.Infof("Disk decommission of StoragePool %v has been aborted/ terminated. Aborting migration of %v", sourceSPName, pvcName)
-				_, err := removeTargetSPAnnotationOnPVC(ctx, pvcName, pvcNamespace)
-				if err != nil {
-					log.Errorf("Failed to remove target SP annotation from PVC %v. Error: %v", pvcName, err)
+	var (
+		lock        sync.Mutex
+		shouldAbort bool
+		wg          sync.WaitGroup
+	)
+	workers := m.concurrency
+	if workers > len(pvcList) {
+		workers = len(pvcList)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				pvcName := job.pvc.GetName()
+				lock.Lock()
+				aborted := shouldAbort
+				lock.Unlock()
+				var err error
+				if aborted {
+					log.Infof("Migration for PVC %v has been aborted.", pvcName)
+					err = fmt.Errorf("migration aborted after the failure of another migration")
+					m.recordMigration(ctx, job, migrationFailed, err)
+				} else {
+					err = m.migrateVolumeWithRetries(ctx, job)
 				}
-				unsuccessfulMigrations = append(unsuccessfulMigrations, pvc)
-				if abortOnFirstFailure {
-					shouldAbort = true
+
+				_, removeErr := removeTargetSPAnnotationOnPVC(ctx, pvcName, job.pvc.GetNamespace())
+				if removeErr != nil {
+					log.Errorf("Failed to remove target SP annotation from PVC %v. Error: %v", pvcName, removeErr)
+				}
+				lock.Lock()
+				if err != nil {
+					unsuccessfulMigrations = append(unsuccessfulMigrations, job.pvc)
+					if abortOnFirstFailure {
+						shouldAbort = true
+					}
+				} else {
+					successfulMigrations = append(successfulMigrations, job.pvc)
 				}
-				continue
+				lock.Unlock()
 			}
+		}()
+	}
+	wg.Wait()
+	log.Infof("Total number of successful migrations: %v, unsuccessful migrations: %v", len(successfulMigrations), len(unsuccessfulMigrations))
+	return successfulMigrations, unsuccessfulMigrations
+}
+
+// newMigrationJob returns the queued migration of the given PVC. previousStatuses caches the migration statuses
+// of the source StoragePools by StoragePool name.
+func (m *migrationController) newMigrationJob(ctx context.Context, pvc *unstructured.Unstructured,
+	previousStatuses map[string]map[string]spv1alpha1.VolumeMigrationStatus) *migrationJob {
+	log := logger.GetLogger(ctx)
+	targetSPName, _, _ := unstructured.NestedString(pvc.Object, "metadata", "annotations", targetSPAnnotationKey)
+	job := &migrationJob{
+		pvc: pvc,
+		status: spv1alpha1.VolumeMigrationStatus{
+			PVCName:           pvc.GetName(),
+			PVCNamespace:      pvc.GetNamespace(),
+			TargetStoragePool: targetSPName,
+			State:             migrationQueued,
+		},
+	}
+	sourceSPName, found, err := unstructured.NestedString(pvc.Object, "metadata", "annotations", k8scloudoperator.StoragePoolAnnotationKey)
+	if !found || err != nil || sourceSPName == "" {
+		log.Warnf("Could not get source StoragePool name for PVC %v, its migration status will not be recorded. Error: %v", pvc.GetName(), err)
+		return job
+	}
+	job.sourceSPName = sourceSPName
+	statuses, ok := previousStatuses[sourceSPName]
+	if !ok {
+		statuses, err = getVolumeMigrationStatuses(ctx, sourceSPName)
+		if err != nil {
+			log.Warnf("Could not get migration statuses of StoragePool %v. Error: %v", sourceSPName, err)
 		}
+		previousStatuses[sourceSPName] = statuses
+	}
+	previous, ok := statuses[migrationStatusKey(job.status.PVCNamespace, job.status.PVCName)]
+	if ok && (previous.State == migrationQueued || previous.State == migrationRunning) &&
+		previous.TargetStoragePool == targetSPName {
+		log.Infof("Resuming migration of PVC %v to StoragePool %v after %d attempt(s)", pvc.GetName(), targetSPName, previous.Attempts)
+		job.status.Attempts = previous.Attempts
+		job.status.LastError = previous.LastError
+	}
+	return job
+}
+
+// recordMigration updates the state of the given migration in the status of its source StoragePool
+func (m *migrationController) recordMigration(ctx context.Context, job *migrationJob, state string, err error) {
+	log := logger.GetLogger(ctx)
+	job.status.State = state
+	if err != nil {
+		job.status.LastError = err.Error()
+	}
+	if job.sourceSPName == "" {
+		return
+	}
+	if err := updateVolumeMigrationStatus(ctx, job.sourceSPName, job.status); err != nil {
+		log.Warnf("Failed to record migration of PVC %v as %v. Error: %v", job.status.PVCName, state, err)
+	}
+}
 
-		done, err := m.migrateVolume(ctx, pvc)
-		if !done || err != nil {
-			log.Errorf("Error while migrating PVC %v. Error: %v", pvcName, err)
-			unsuccessfulMigrations = append(unsuccessfulMigrations, pvc)
-			if abortOnFirstFailure {
-				shouldAbort = true
+// migrateVolumeWithRetries migrates the volume of the given job, retrying failed relocations until the job has been
+// attempted m.maxRetries+1 times or the disk decommission of its source StoragePool is aborted
+func (m *migrationController) migrateVolumeWithRetries(ctx context.Context, job *migrationJob) error {
+	log := logger.GetLogger(ctx)
+	pvcName := job.pvc.GetName()
+	for {
+		// check if disk decomm. of source StoragePool has been aborted/ terminated
+		if job.sourceSPName != "" {
+			drainMode, found, err := getDrainMode(ctx, job.sourceSPName)
+			if (!found || (drainMode != fullDataEvacuationMM && drainMode != ensureAccessibilityMM)) && err == nil {
+				log.Infof("Disk decommission of StoragePool %v has been aborted/ terminated. Aborting migration of %v", job.sourceSPName, pvcName)
+				err = fmt.Errorf("disk decommission of StoragePool %v has been aborted", job.sourceSPName)
+				m.recordMigration(ctx, job, migrationFailed, err)
+				return err
 			}
-			continue
 		}
-		successfulMigrations = append(successfulMigrations, pvc)
+		if err := m.waitForRelocationSlot(ctx); err != nil {
+			return err
+		}
+
+		job.status.Attempts++
+		m.recordMigration(ctx, job, migrationRunning, nil)
+		done, err := m.migrateVolume(ctx, job.pvc)
+		if done && err == nil {
+			m.recordMigration(ctx, job, migrationDone, nil)
+			return nil
+		}
+		if err == nil {
+			err = fmt.Errorf("migration of PVC %v did not complete", pvcName)
+		}
+		log.Errorf("Error while migrating PVC %v, attempt %d. Error: %v", pvcName, job.status.Attempts, err)
+		if job.status.Attempts > m.maxRetries {
+			m.recordMigration(ctx, job, migrationFailed, err)
+			return err
+		}
+		m.recordMigration(ctx, job, migrationQueued, err)
+
+		timer := time.NewTimer(time.Duration(job.status.Attempts) * migrationRetryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
 	}
-	log.Infof("Total number of successful migrations: %v, unsuccessful migrations: %v", len(successfulMigrations), len(unsuccessfulMigrations))
-	return successfulMigrations, unsuccessfulMigrations
 }
 
 func updateSourceSPAnnotationOnPVC(ctx context.Context, pvcName, pvcNamespace, newSourceSPName string) error {
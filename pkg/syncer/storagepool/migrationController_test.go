@@ -0,0 +1,282 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storagepool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	spv1alpha1 "sigs.k8s.io/vsphere-csi-driver/pkg/apis/storagepool/cns/v1alpha1"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/syncer/k8scloudoperator"
+)
+
+const testNamespace = "ns"
+
+var (
+	testPVCResource = schema.GroupVersionResource{Version: "v1", Resource: "persistentvolumeclaims"}
+	testSPResource  = spv1alpha1.SchemeGroupVersion.WithResource("storagepools")
+)
+
+// newTestPVC returns a PVC placed on the given StoragePool and to be migrated to the given target StoragePool,
+// along with its PV
+func newTestPVC(name, sourceSPName, targetSPName string) (*unstructured.Unstructured, *unstructured.Unstructured) {
+	pvc := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "PersistentVolumeClaim",
+		"spec":       map[string]interface{}{"volumeName": "pv-" + name},
+	}}
+	pvc.SetName(name)
+	pvc.SetNamespace(testNamespace)
+	pvc.SetAnnotations(map[string]string{
+		k8scloudoperator.StoragePoolAnnotationKey: sourceSPName,
+		targetSPAnnotationKey:                     targetSPName,
+	})
+	pv := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "PersistentVolume",
+		"spec": map[string]interface{}{
+			"csi": map[string]interface{}{"volumeHandle": "vol-" + name},
+		},
+	}}
+	pv.SetName("pv-" + name)
+	return pvc, pv
+}
+
+// newTestMigration returns a migration controller relocating volumes with relocate, and the PVCs to migrate from
+// sp-1 under disk decommission to sp-2. getSPClient returns a fake client until the test ends.
+func newTestMigration(t *testing.T, pvcCount int, relocate func(ctx context.Context, volumeID string, targetSPName string) error) (
+	*migrationController, dynamic.Interface, []*unstructured.Unstructured) {
+	sourceSP := newTestStoragePool("sp-1", "", "50Gi")
+	_ = unstructured.SetNestedField(sourceSP.Object, fullDataEvacuationMM, "spec", "parameters", drainModeField)
+	objects := []runtime.Object{sourceSP, newTestStoragePool("sp-2", "", "50Gi")}
+	pvcs := make([]*unstructured.Unstructured, 0)
+	for i := 0; i < pvcCount; i++ {
+		pvc, pv := newTestPVC(fmt.Sprintf("pvc-%d", i), "sp-1", "sp-2")
+		objects = append(objects, pvc, pv)
+		pvcs = append(pvcs, pvc)
+	}
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objects...)
+	savedGetSPClient, savedRetryInterval := getSPClient, migrationRetryInterval
+	getSPClient = func(ctx context.Context) (dynamic.Interface, *schema.GroupVersionResource, error) {
+		return client, &testSPResource, nil
+	}
+	migrationRetryInterval = time.Millisecond
+	t.Cleanup(func() {
+		getSPClient, migrationRetryInterval = savedGetSPClient, savedRetryInterval
+	})
+	m := &migrationController{concurrency: 1, maxRetries: defaultVolumeMigrationMaxRetries, relocateVolume: relocate}
+	return m, client, pvcs
+}
+
+func getTestMigrationStatuses(ctx context.Context, t *testing.T) map[string]spv1alpha1.VolumeMigrationStatus {
+	statuses, err := getVolumeMigrationStatuses(ctx, "sp-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return statuses
+}
+
+func TestMigrateVolumesWorkerPool(t *testing.T) {
+	ctx := context.Background()
+	var (
+		lock       sync.Mutex
+		running    int
+		maxRunning int
+	)
+	m, client, pvcs := newTestMigration(t, 6, func(ctx context.Context, volumeID string, targetSPName string) error {
+		lock.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lock.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		lock.Lock()
+		running--
+		lock.Unlock()
+		return nil
+	})
+	m.concurrency = 3
+	successful, unsuccessful := m.MigrateVolumes(ctx, pvcs, true)
+	if len(successful) != len(pvcs) || len(unsuccessful) != 0 {
+		t.Fatalf("expected all migrations to succeed, got %d successful and %d unsuccessful", len(successful), len(unsuccessful))
+	}
+	if maxRunning != m.concurrency {
+		t.Errorf("expected %d concurrent relocations, got %d", m.concurrency, maxRunning)
+	}
+
+	statuses := getTestMigrationStatuses(ctx, t)
+	for _, pvc := range pvcs {
+		status, ok := statuses[migrationStatusKey(testNamespace, pvc.GetName())]
+		if !ok || status.State != migrationDone || status.Attempts != 1 || status.TargetStoragePool != "sp-2" ||
+			status.LastTransitionTime.IsZero() {
+			t.Errorf("unexpected migration status of PVC %s: %+v", pvc.GetName(), status)
+		}
+		migratedPVC, err := client.Resource(testPVCResource).Namespace(testNamespace).Get(ctx, pvc.GetName(), metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		annotations := migratedPVC.GetAnnotations()
+		if annotations[k8scloudoperator.StoragePoolAnnotationKey] != "sp-2" || annotations[targetSPAnnotationKey] != "" {
+			t.Errorf("unexpected annotations of migrated PVC %s: %v", pvc.GetName(), annotations)
+		}
+	}
+}
+
+func TestMigrateVolumesPacing(t *testing.T) {
+	ctx := context.Background()
+	var (
+		lock   sync.Mutex
+		starts []time.Time
+	)
+	m, _, pvcs := newTestMigration(t, 3, func(ctx context.Context, volumeID string, targetSPName string) error {
+		lock.Lock()
+		starts = append(starts, time.Now())
+		lock.Unlock()
+		return nil
+	})
+	m.concurrency = 3
+	m.interval = 100 * time.Millisecond
+	if _, unsuccessful := m.MigrateVolumes(ctx, pvcs, false); len(unsuccessful) != 0 {
+		t.Fatalf("expected all migrations to succeed, got %d unsuccessful", len(unsuccessful))
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	for i := 1; i < len(starts); i++ {
+		// allow for the time between the end of the wait and the relocation
+		if gap := starts[i].Sub(starts[i-1]); gap < m.interval-10*time.Millisecond {
+			t.Errorf("expected relocations to start %v apart, relocation %d started %v after the previous one", m.interval, i, gap)
+		}
+	}
+}
+
+func TestMigrateVolumesRetries(t *testing.T) {
+	ctx := context.Background()
+	attempts := make(map[string]int)
+	var lock sync.Mutex
+	m, _, pvcs := newTestMigration(t, 2, func(ctx context.Context, volumeID string, targetSPName string) error {
+		lock.Lock()
+		defer lock.Unlock()
+		attempts[volumeID]++
+		// the volume of pvc-0 is relocated on its second attempt, the one of pvc-1 never is
+		if volumeID == "vol-pvc-0" && attempts[volumeID] == 2 {
+			return nil
+		}
+		return errors.New("relocation failed")
+	})
+	m.maxRetries = 2
+	successful, unsuccessful := m.MigrateVolumes(ctx, pvcs, false)
+	if len(successful) != 1 || successful[0].GetName() != "pvc-0" || len(unsuccessful) != 1 || unsuccessful[0].GetName() != "pvc-1" {
+		t.Fatalf("expected the migration of pvc-0 only to succeed, got %d successful and %d unsuccessful", len(successful), len(unsuccessful))
+	}
+	if attempts["vol-pvc-0"] != 2 || attempts["vol-pvc-1"] != int(m.maxRetries)+1 {
+		t.Errorf("unexpected relocation attempts %v", attempts)
+	}
+	statuses := getTestMigrationStatuses(ctx, t)
+	if status := statuses[migrationStatusKey(testNamespace, "pvc-0")]; status.State != migrationDone || status.Attempts != 2 {
+		t.Errorf("unexpected migration status of pvc-0: %+v", status)
+	}
+	if status := statuses[migrationStatusKey(testNamespace, "pvc-1")]; status.State != migrationFailed ||
+		status.Attempts != m.maxRetries+1 || status.LastError != "relocation failed" {
+		t.Errorf("unexpected migration status of pvc-1: %+v", status)
+	}
+}
+
+func TestMigrateVolumesAbortOnFirstFailure(t *testing.T) {
+	ctx := context.Background()
+	relocations := 0
+	m, _, pvcs := newTestMigration(t, 3, func(ctx context.Context, volumeID string, targetSPName string) error {
+		relocations++
+		return errors.New("relocation failed")
+	})
+	m.maxRetries = 0
+	successful, unsuccessful := m.MigrateVolumes(ctx, pvcs, true)
+	if len(successful) != 0 || len(unsuccessful) != len(pvcs) {
+		t.Fatalf("expected all migrations to fail, got %d successful and %d unsuccessful", len(successful), len(unsuccessful))
+	}
+	if relocations != 1 {
+		t.Errorf("expected the migrations to be aborted after the first failure, got %d relocations", relocations)
+	}
+	for key, status := range getTestMigrationStatuses(ctx, t) {
+		if status.State != migrationFailed || status.LastError == "" {
+			t.Errorf("unexpected migration status of %s: %+v", key, status)
+		}
+	}
+}
+
+func TestMigrateVolumesResume(t *testing.T) {
+	ctx := context.Background()
+	m, _, pvcs := newTestMigration(t, 1, func(ctx context.Context, volumeID string, targetSPName string) error {
+		return nil
+	})
+	// migration interrupted by a restart of the syncer during its second attempt
+	err := updateVolumeMigrationStatus(ctx, "sp-1", spv1alpha1.VolumeMigrationStatus{
+		PVCName: "pvc-0", PVCNamespace: testNamespace, TargetStoragePool: "sp-2", State: migrationRunning, Attempts: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, unsuccessful := m.MigrateVolumes(ctx, pvcs, false); len(unsuccessful) != 0 {
+		t.Fatalf("expected the migration to succeed, got %d unsuccessful", len(unsuccessful))
+	}
+	if status := getTestMigrationStatuses(ctx, t)[migrationStatusKey(testNamespace, "pvc-0")]; status.State != migrationDone ||
+		status.Attempts != 3 {
+		t.Errorf("expected the attempts of the interrupted migration to be carried over, got %+v", status)
+	}
+}
+
+func TestClearVolumeMigrationStatuses(t *testing.T) {
+	ctx := context.Background()
+	m, _, pvcs := newTestMigration(t, 2, func(ctx context.Context, volumeID string, targetSPName string) error {
+		return nil
+	})
+	m.MigrateVolumes(ctx, pvcs, true)
+	if statuses := getTestMigrationStatuses(ctx, t); len(statuses) != len(pvcs) {
+		t.Fatalf("expected %d migration statuses, got %v", len(pvcs), statuses)
+	}
+
+	// the migration statuses are cleared once the disk decommission is done
+	if err := updateDrainStatus(ctx, "sp-1", drainSuccessStatus, ""); err != nil {
+		t.Fatal(err)
+	}
+	if statuses := getTestMigrationStatuses(ctx, t); len(statuses) != 0 {
+		t.Errorf("expected no migration status once the disk decommission is done, got %v", statuses)
+	}
+
+	// or cancelled
+	m.MigrateVolumes(ctx, pvcs, true)
+	if err := clearVolumeMigrationStatuses(ctx, "sp-1"); err != nil {
+		t.Fatal(err)
+	}
+	if statuses := getTestMigrationStatuses(ctx, t); len(statuses) != 0 {
+		t.Errorf("expected no migration status once the disk decommission is cancelled, got %v", statuses)
+	}
+	if err := clearVolumeMigrationStatuses(ctx, "missing"); err != nil {
+		t.Errorf("expected clearing the migration statuses of a deleted StoragePool to succeed, got %v", err)
+	}
+}
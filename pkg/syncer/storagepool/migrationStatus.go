@@ -0,0 +1,157 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storagepool
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+
+	spv1alpha1 "sigs.k8s.io/vsphere-csi-driver/pkg/apis/storagepool/cns/v1alpha1"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+)
+
+const (
+	// migrationsStatusField is the field of the diskDecomm status of a StoragePool tracking the migration of
+	// each PVC away from the StoragePool. Keys are <PVC namespace>/<PVC name>.
+	migrationsStatusField = "migrations"
+
+	migrationQueued  = "queued"
+	migrationRunning = "running"
+	migrationDone    = "done"
+	migrationFailed  = "failed"
+)
+
+func migrationStatusKey(pvcNamespace, pvcName string) string {
+	return pvcNamespace + "/" + pvcName
+}
+
+// getVolumeMigrationStatuses returns the migration statuses recorded in the given StoragePool by PVC namespace/name
+func getVolumeMigrationStatuses(ctx context.Context, storagePoolName string) (map[string]spv1alpha1.VolumeMigrationStatus, error) {
+	log := logger.GetLogger(ctx)
+	k8sDynamicClient, spResource, err := getSPClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sp, err := k8sDynamicClient.Resource(*spResource).Get(ctx, storagePoolName, metav1.GetOptions{})
+	if err != nil {
+		log.Errorf("Could not get StoragePool with name %v. Error: %v", storagePoolName, err)
+		return nil, err
+	}
+	statuses := make(map[string]spv1alpha1.VolumeMigrationStatus)
+	migrations, _, err := unstructured.NestedMap(sp.Object, "status", "diskDecomm", migrationsStatusField)
+	if err != nil {
+		return nil, err
+	}
+	for key, obj := range migrations {
+		status := spv1alpha1.VolumeMigrationStatus{}
+		statusObj, ok := obj.(map[string]interface{})
+		if !ok {
+			log.Warnf("Ignoring invalid migration status of %v in StoragePool %v", key, storagePoolName)
+			continue
+		}
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(statusObj, &status)
+		if err != nil {
+			log.Warnf("Ignoring invalid migration status of %v in StoragePool %v. Error: %v", key, storagePoolName, err)
+			continue
+		}
+		statuses[key] = status
+	}
+	return statuses, nil
+}
+
+// updateVolumeMigrationStatus records the given migration status in the given StoragePool. Each PVC has its own key
+// in the migrations status, so that the statuses of the PVCs migrated concurrently are merged.
+func updateVolumeMigrationStatus(ctx context.Context, storagePoolName string, status spv1alpha1.VolumeMigrationStatus) error {
+	log := logger.GetLogger(ctx)
+	status.LastTransitionTime = metav1.Now()
+	patch := map[string]interface{}{
+		"status": map[string]interface{}{
+			"diskDecomm": map[string]interface{}{
+				migrationsStatusField: map[string]interface{}{
+					migrationStatusKey(status.PVCNamespace, status.PVCName): status,
+				},
+			},
+		},
+	}
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		log.Errorf("Could not marshal patch for migration status. Error: %v", err)
+		return err
+	}
+	task := func() (done bool, err error) {
+		k8sDynamicClient, spResource, err := getSPClient(ctx)
+		if err != nil {
+			return false, err
+		}
+		_, err = k8sDynamicClient.Resource(*spResource).Patch(ctx, storagePoolName, k8stypes.MergePatchType, patchBytes, metav1.PatchOptions{})
+		if err != nil {
+			log.Errorf("Failed to update migration status of PVC %v in StoragePool %v. Error: %v", status.PVCName, storagePoolName, err)
+			return false, err
+		}
+		log.Debugf("Successfully updated migration status of PVC %v in StoragePool %v to %v", status.PVCName, storagePoolName, status.State)
+		return true, nil
+	}
+	baseDuration := time.Duration(100) * time.Millisecond
+	thresholdDuration := time.Duration(10) * time.Second
+	_, err = ExponentialBackoff(task, baseDuration, thresholdDuration, 1.5, 5)
+	return err
+}
+
+// clearVolumeMigrationStatuses removes the migration statuses recorded in the given StoragePool once its disk
+// decommission is done or cancelled, so that they do not accumulate across disk decommissions
+func clearVolumeMigrationStatuses(ctx context.Context, storagePoolName string) error {
+	log := logger.GetLogger(ctx)
+	patch := map[string]interface{}{
+		"status": map[string]interface{}{
+			"diskDecomm": map[string]interface{}{
+				migrationsStatusField: nil,
+			},
+		},
+	}
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		log.Errorf("Could not marshal patch for migration statuses. Error: %v", err)
+		return err
+	}
+	task := func() (done bool, err error) {
+		k8sDynamicClient, spResource, err := getSPClient(ctx)
+		if err != nil {
+			return false, err
+		}
+		_, err = k8sDynamicClient.Resource(*spResource).Patch(ctx, storagePoolName, k8stypes.MergePatchType, patchBytes, metav1.PatchOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return true, nil
+			}
+			log.Errorf("Failed to clear migration statuses of StoragePool %v. Error: %v", storagePoolName, err)
+			return false, err
+		}
+		log.Debugf("Successfully cleared migration statuses of StoragePool %v", storagePoolName)
+		return true, nil
+	}
+	baseDuration := time.Duration(100) * time.Millisecond
+	thresholdDuration := time.Duration(10) * time.Second
+	_, err = ExponentialBackoff(task, baseDuration, thresholdDuration, 1.5, 5)
+	return err
+}
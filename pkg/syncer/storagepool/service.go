@@ -87,7 +87,8 @@ func InitStoragePoolService(ctx context.Context, configInfo *commontypes.ConfigI
 	}
 
 	// Start the services
-	spController, err := newSPController(vc, configInfo.Cfg.Global.ClusterID)
+	spController, err := newSPController(vc, configInfo.Cfg.Global.ClusterID, cnstypes.CnsClusterFlavorWorkload,
+		configInfo.Cfg.Global.StoragePoolCapacityWarningDays)
 	if err != nil {
 		log.Errorf("Failed starting StoragePool controller. Err: %+v", err)
 		return err
@@ -114,7 +115,7 @@ func InitStoragePoolService(ctx context.Context, configInfo *commontypes.ConfigI
 		}
 	}()
 
-	migrationController := initMigrationController(vc, configInfo.Cfg.Global.ClusterID)
+	migrationController := initMigrationController(vc, configInfo.Cfg)
 	go func() {
 		diskDecommEnablementTicker := time.NewTicker(common.DefaultFeatureEnablementCheckInterval)
 		defer diskDecommEnablementTicker.Stop()
@@ -179,9 +180,16 @@ func ResetVC(ctx context.Context, vc *cnsvsphere.VirtualCenter) {
 	defaultStoragePoolServiceLock.Lock()
 	defer defaultStoragePoolServiceLock.Unlock()
 
+	if defaultStoragePoolService.spController == nil {
+		log.Debugf("StoragePool service is not initialized")
+		return
+	}
 	defaultStoragePoolService.spController.vc = vc
 	defaultStoragePoolService.scWatchCntlr.vc = vc
-	defaultStoragePoolService.migrationCntlr.vc = vc
+	// there is no migration controller in Vanilla clusters
+	if defaultStoragePoolService.migrationCntlr != nil {
+		defaultStoragePoolService.migrationCntlr.vc = vc
+	}
 	// PC listener will automatically reestablish its session with VC
 	log.Debugf("Successfully reset VC connection in StoragePool service")
 }
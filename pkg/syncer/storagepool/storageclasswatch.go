@@ -23,6 +23,7 @@ import (
 	"reflect"
 	"strings"
 
+	cnstypes "github.com/vmware/govmomi/cns/types"
 	"github.com/vmware/govmomi/vim25/soap"
 	vimtypes "github.com/vmware/govmomi/vim25/types"
 	storagev1 "k8s.io/api/storage/v1"
@@ -175,6 +176,15 @@ func (w *StorageClassWatch) needsRefreshStorageClassCache(ctx context.Context, s
 	log := logger.GetLogger(ctx)
 
 	thisStoragePolicyID := getStoragePolicyIDFromSC(sc)
+	if thisStoragePolicyID == "" && w.spController.clusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
+		getStoragePolicyNameFromSC(sc) != "" {
+		// Vanilla StorageClasses refer to the policy by name, look up the policy ID resolved by the last refresh
+		thisStoragePolicyID = w.getCachedStoragePolicyID(sc.Name)
+		if thisStoragePolicyID == "" {
+			log.Infof("StorageClassWatch cache refresh due to %s", sc.Name)
+			return eventType != watch.Deleted
+		}
+	}
 	if thisStoragePolicyID == "" {
 		// Our cache only has StorageClasses created by vSphere
 		return false
@@ -198,6 +208,37 @@ func (w *StorageClassWatch) needsRefreshStorageClassCache(ctx context.Context, s
 	return false
 }
 
+// getCachedStoragePolicyID returns the ID of the storage policy of the given StorageClass in the cache, if any
+func (w *StorageClassWatch) getCachedStoragePolicyID(scName string) string {
+	for policyID, sc := range w.policyToScMap {
+		if sc.Name == scName {
+			return policyID
+		}
+	}
+	return ""
+}
+
+// getStoragePolicyID returns the ID of the storage policy of the given StorageClass. Vanilla StorageClasses may refer
+// to the policy by name, which is resolved by SPBM. It returns "" if the StorageClass has no storage policy or if
+// its policy could not be found.
+func (w *StorageClassWatch) getStoragePolicyID(ctx context.Context, sc *storagev1.StorageClass) string {
+	log := logger.GetLogger(ctx)
+	policyID := getStoragePolicyIDFromSC(sc)
+	if policyID != "" || w.spController.clusterFlavor != cnstypes.CnsClusterFlavorVanilla {
+		return policyID
+	}
+	policyName := getStoragePolicyNameFromSC(sc)
+	if policyName == "" {
+		return ""
+	}
+	policyID, err := w.vc.GetStoragePolicyIDByName(ctx, policyName)
+	if err != nil {
+		log.Warnf("Failed to get the ID of storage policy %q of StorageClass %s. Err: %v", policyName, sc.Name, err)
+		return ""
+	}
+	return policyID
+}
+
 // Refresh the storage class cache and do a full remediation
 // The cache contains a convenient lookup table mapping from policyId to storage class content
 // we also ensure to put our annotation on the storage classes
@@ -213,7 +254,7 @@ func (w *StorageClassWatch) refreshStorageClassCache(ctx context.Context) error
 		return err
 	}
 	for idx, sc := range scList.Items {
-		policyID := getStoragePolicyIDFromSC(&sc)
+		policyID := w.getStoragePolicyID(ctx, &sc)
 		if policyID == "" {
 			continue
 		}
@@ -179,7 +179,7 @@ func makeStoragePoolName(dsName string) string {
 }
 
 // getSPClient returns the StoragePool dynamic client
-func getSPClient(ctx context.Context) (dynamic.Interface, *schema.GroupVersionResource, error) {
+var getSPClient = func(ctx context.Context) (dynamic.Interface, *schema.GroupVersionResource, error) {
 	log := logger.GetLogger(ctx)
 	// Create a client to create/udpate StoragePool instances
 	cfg, err := config.GetConfig()
@@ -268,8 +268,22 @@ func getStoragePolicyIDFromSC(sc *v1.StorageClass) string {
 	return ""
 }
 
+// Returns the name of the storage policy referred by the given StorageClass, as used by Vanilla clusters
+func getStoragePolicyNameFromSC(sc *v1.StorageClass) string {
+	if sc.Provisioner != types.Name {
+		return ""
+	}
+	for key, value := range sc.Parameters {
+		if strings.ToLower(key) == common.AttributeStoragePolicyName {
+			return value
+		}
+	}
+	return ""
+}
+
 // updateDrainStatus updates the status of the disk decommission request. newStatus can either be "done" or "fail".
-// In case of "fail" we also add the reason for failure given by errorString. In case of "done" errorString is not considered.
+// In case of "fail" we also add the reason for failure given by errorString. In case of "done" errorString is not considered
+// and the migration statuses are cleared.
 func updateDrainStatus(ctx context.Context, storagePoolName string, newStatus string, errorString string) error {
 	log := logger.GetLogger(ctx)
 	task := func() (done bool, err error) {
@@ -298,10 +312,12 @@ func updateDrainStatus(ctx context.Context, storagePoolName string, newStatus st
 					},
 				}
 			} else {
+				// the migration statuses are only kept until the disk decommission completes
 				patch = map[string]interface{}{
 					"status": map[string]interface{}{
 						"diskDecomm": map[string]interface{}{
-							drainStatusField: newStatus,
+							drainStatusField:      newStatus,
+							migrationsStatusField: nil,
 						},
 					},
 				}
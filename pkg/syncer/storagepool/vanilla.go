@@ -0,0 +1,247 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storagepool
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	"github.com/vmware/govmomi/object"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+
+	spv1alpha1 "sigs.k8s.io/vsphere-csi-driver/pkg/apis/storagepool/cns/v1alpha1"
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
+	k8s "sigs.k8s.io/vsphere-csi-driver/pkg/kubernetes"
+	commontypes "sigs.k8s.io/vsphere-csi-driver/pkg/syncer/types"
+)
+
+// vanillaReconcileInterval is the interval at which the StoragePools of Vanilla clusters are reconciled. Unlike in
+// Supervisor clusters, there is no property collector listener updating them as the datastores change.
+var vanillaReconcileInterval = 5 * time.Minute
+
+// newK8sClient creates the client listing the k8s nodes of Vanilla clusters
+var newK8sClient = k8s.NewClient
+
+// InitVanillaStoragePoolService initializes the StoragePool service of Vanilla clusters once the VanillaStoragePool
+// feature is enabled. It creates a StoragePool for each datastore accessible to the k8s node VMs with the nodes it
+// is accessible from, its capacity and the StorageClasses whose storage policy it is compatible with.
+// Disk decommission is not supported in Vanilla clusters.
+func InitVanillaStoragePoolService(ctx context.Context, configInfo *commontypes.ConfigInfo, coInitParams *interface{}) error {
+	log := logger.GetLogger(ctx)
+	enablementTicker := time.NewTicker(common.DefaultFeatureEnablementCheckInterval)
+	defer enablementTicker.Stop()
+	for ; true; <-enablementTicker.C {
+		coCommonInterface, err := commonco.GetContainerOrchestratorInterface(ctx, common.Kubernetes,
+			cnstypes.CnsClusterFlavorVanilla, *coInitParams)
+		if err != nil {
+			log.Errorf("Failed to create CO agnostic interface. Error: %v", err)
+			continue
+		}
+		if coCommonInterface.IsFSSEnabled(ctx, common.VanillaStoragePool) {
+			break
+		}
+		log.Debugf("VanillaStoragePool feature is disabled on the cluster")
+	}
+	log.Infof("Initializing Storage Pool Service for Vanilla cluster")
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		log.Errorf("Failed to get Kubernetes config. Err: %+v", err)
+		return err
+	}
+	crdKind := reflect.TypeOf(spv1alpha1.StoragePool{}).Name()
+	crdSingular := "storagepool"
+	crdPlural := "storagepools"
+	crdName := crdPlural + "." + spv1alpha1.SchemeGroupVersion.Group
+	err = k8s.CreateCustomResourceDefinitionFromSpec(ctx, crdName, crdSingular, crdPlural,
+		crdKind, spv1alpha1.SchemeGroupVersion.Group, spv1alpha1.SchemeGroupVersion.Version, apiextensionsv1beta1.ClusterScoped)
+	if err != nil {
+		log.Errorf("Failed to create %q CRD. Err: %+v", crdKind, err)
+		return err
+	}
+
+	vc, err := commontypes.GetVirtualCenterInstance(ctx, configInfo, false)
+	if err != nil {
+		log.Errorf("Failed to get vCenter from ConfigInfo. Err: %+v", err)
+		return err
+	}
+	err = vc.ConnectPbm(ctx)
+	if err != nil {
+		log.Errorf("Failed to connect to SPBM service. Err: %+v", err)
+		return err
+	}
+
+	spController, err := newSPController(vc, configInfo.Cfg.Global.ClusterID, cnstypes.CnsClusterFlavorVanilla,
+		configInfo.Cfg.Global.StoragePoolCapacityWarningDays)
+	if err != nil {
+		log.Errorf("Failed starting StoragePool controller. Err: %+v", err)
+		return err
+	}
+	// the StorageClass watch reconciles all the StoragePools whenever the StorageClasses change
+	scWatchCntlr, err := startStorageClassWatch(ctx, spController, cfg)
+	if err != nil {
+		log.Errorf("Failed starting the Storageclass watch. Err: %+v", err)
+		return err
+	}
+
+	defaultStoragePoolServiceLock.Lock()
+	defaultStoragePoolService.spController = spController
+	defaultStoragePoolService.scWatchCntlr = scWatchCntlr
+	defaultStoragePoolService.clusterID = configInfo.Cfg.Global.ClusterID
+	defaultStoragePoolServiceLock.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(vanillaReconcileInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				start := time.Now()
+				err := ReconcileAllStoragePools(logger.NewContextWithLogger(ctx), scWatchCntlr, spController)
+				if err != nil {
+					log.Errorf("Error reconciling StoragePool instances. Err: %+v", err)
+					prometheus.StoragePoolReconcileHistVec.WithLabelValues(prometheus.PrometheusStoragePoolReconcileAllOpType,
+						prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
+				} else {
+					prometheus.StoragePoolReconcileHistVec.WithLabelValues(prometheus.PrometheusStoragePoolReconcileAllOpType,
+						prometheus.PrometheusPassStatus).Observe(time.Since(start).Seconds())
+				}
+			case <-ctx.Done():
+				log.Info("Vanilla StoragePool reconcile loop ends")
+				return
+			}
+		}
+	}()
+	log.Infof("Done initializing Storage Pool Service for Vanilla cluster")
+	return nil
+}
+
+// refreshNodeVMHosts looks up the VMs of the k8s nodes by their provider ID and records the hosts they are running on
+// in hostMoIDToNodeNames. It returns the datastores mounted on more than one of these hosts. The host-local datastores
+// are left out as Vanilla clusters do not provision volumes with topology, so a volume placed on them could only be
+// attached to the node VMs of a single host. When all the node VMs run on a single host, all its datastores are
+// returned.
+func (c *SpController) refreshNodeVMHosts(ctx context.Context, vc *cnsvsphere.VirtualCenter) ([]*cnsvsphere.DatastoreInfo, error) {
+	log := logger.GetLogger(ctx)
+	k8sClient, err := newK8sClient(ctx)
+	if err != nil {
+		log.Errorf("Failed to create k8s client for cluster, err=%+v", err)
+		return nil, err
+	}
+	// TODO: Replace this direct API call with an informer
+	nodeList, err := k8sClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("Failed getting all k8s nodes in cluster, err=%+v", err)
+		return nil, err
+	}
+	dcs, err := vc.GetDatacenters(ctx)
+	if err != nil {
+		log.Errorf("Failed to get datacenters from VC. Err: %+v", err)
+		return nil, err
+	}
+
+	hostMoIDToNodeNames := make(map[string][]string)
+	// datastores accessible to the node VMs by URL, along with the moids of the node hosts they are mounted on
+	accessibleDatastores := make([]*cnsvsphere.DatastoreInfo, 0)
+	datastoreHosts := make(map[string]map[string]bool)
+	for _, node := range nodeList.Items {
+		nodeUUID := common.GetUUIDFromProviderID(node.Spec.ProviderID)
+		if nodeUUID == "" {
+			log.Debugf("Ignoring node %s without provider ID", node.Name)
+			continue
+		}
+		var nodeVM *cnsvsphere.VirtualMachine
+		for _, dc := range dcs {
+			vm, err := dc.GetVirtualMachineByUUID(ctx, nodeUUID, false)
+			if err == nil {
+				nodeVM = vm
+				break
+			}
+		}
+		if nodeVM == nil {
+			log.Warnf("Could not find the VM of node %s with UUID %s", node.Name, nodeUUID)
+			continue
+		}
+		host, err := nodeVM.GetHostSystem(ctx)
+		if err != nil {
+			log.Warnf("Could not get the host of node %s. Err: %v", node.Name, err)
+			continue
+		}
+		hostMoID := host.Reference().Value
+		hostMoIDToNodeNames[hostMoID] = append(hostMoIDToNodeNames[hostMoID], node.Name)
+		nodeDatastores, err := nodeVM.GetAllAccessibleDatastores(ctx)
+		if err != nil {
+			log.Warnf("Could not get the datastores accessible to node %s. Err: %v", node.Name, err)
+			continue
+		}
+		for _, ds := range nodeDatastores {
+			if _, ok := datastoreHosts[ds.Info.Url]; !ok {
+				datastoreHosts[ds.Info.Url] = make(map[string]bool)
+				accessibleDatastores = append(accessibleDatastores, ds)
+			}
+			datastoreHosts[ds.Info.Url][hostMoID] = true
+		}
+	}
+	datastores := make([]*cnsvsphere.DatastoreInfo, 0)
+	for _, ds := range accessibleDatastores {
+		if len(datastoreHosts[ds.Info.Url]) > 1 || len(hostMoIDToNodeNames) == 1 {
+			datastores = append(datastores, ds)
+		} else {
+			log.Debugf("Ignoring datastore %s mounted on a single node host", ds.Info.Url)
+		}
+	}
+	c.hostMoIDToNodeNames = hostMoIDToNodeNames
+	log.Debugf("Node VMs by host: %v", hostMoIDToNodeNames)
+	return datastores, nil
+}
+
+// findAccessibleNodeVMs returns the names of the k8s node VMs running on the hosts on which the given datastore
+// is mounted and accessible. The values in the map tells whether the host of the node VM is in Maintenance Mode.
+func findAccessibleNodeVMs(ctx context.Context, datastore *object.Datastore,
+	hostMoIDToNodeNames map[string][]string) (map[string]bool, error) {
+	log := logger.GetLogger(ctx)
+	hosts, err := datastore.AttachedHosts(ctx)
+	if err != nil {
+		log.Infof("Failed to get attached hosts of datastore %s, err=%+v", datastore.Reference().Value, err)
+		return nil, err
+	}
+	nodes := make(map[string]bool)
+	for _, host := range hosts {
+		nodeNames, ok := hostMoIDToNodeNames[host.Reference().Value]
+		if !ok {
+			continue
+		}
+		inMM, err := getHostInMaintenanceMode(ctx, host)
+		if err != nil {
+			log.Errorf("Error finding the host %s Maintenance Mode state: %v", host.Reference().Value, err)
+			inMM = true
+		}
+		for _, nodeName := range nodeNames {
+			nodes[nodeName] = inMM
+		}
+	}
+	log.Infof("Accessible node VMs in MM for datastore %s: %v", datastore.Reference().Value, nodes)
+	return nodes, nil
+}
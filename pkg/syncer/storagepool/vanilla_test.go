@@ -0,0 +1,301 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storagepool
+
+import (
+	"context"
+	"crypto/tls"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	pbmsim "github.com/vmware/govmomi/pbm/simulator"
+	"github.com/vmware/govmomi/simulator"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/vsphere"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/pkg/csi/types"
+)
+
+// newTestVirtualCenter returns a VirtualCenter connected to a vcsim VPX inventory with SPBM, and its cleanup function
+func newTestVirtualCenter(ctx context.Context, t *testing.T) (*cnsvsphere.VirtualCenter, func()) {
+	model := simulator.VPX()
+	if err := model.Create(); err != nil {
+		t.Fatal(err)
+	}
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterSDK(pbmsim.New())
+	s := model.Service.NewServer()
+	cleanup := func() {
+		s.Close()
+		model.Remove()
+	}
+
+	password, _ := s.URL.User.Password()
+	port, err := strconv.Atoi(s.URL.Port())
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	vc := &cnsvsphere.VirtualCenter{Config: &cnsvsphere.VirtualCenterConfig{
+		Host:     s.URL.Hostname(),
+		Port:     port,
+		Username: s.URL.User.Username(),
+		Password: password,
+		Insecure: true,
+	}}
+	if err := vc.Connect(ctx); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	return vc, cleanup
+}
+
+// vanillaTestInventory is the part of the vcsim inventory used by the tests of Vanilla StoragePools
+type vanillaTestInventory struct {
+	// standaloneVM runs on the standalone host, clusterVM on clusterHost, a host of the cluster
+	standaloneVM, clusterVM *object.VirtualMachine
+	clusterHost             *object.HostSystem
+	// sharedDatastore is accessible from all the hosts, localDatastore only from clusterHost
+	sharedDatastore   *object.Datastore
+	localDatastore    *object.Datastore
+	localDatastoreURL string
+}
+
+func newVanillaTestInventory(ctx context.Context, t *testing.T, vc *cnsvsphere.VirtualCenter) *vanillaTestInventory {
+	finder := find.NewFinder(vc.Client.Client, false)
+	dc, err := finder.DefaultDatacenter(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	finder.SetDatacenter(dc)
+	inventory := &vanillaTestInventory{}
+	if inventory.standaloneVM, err = finder.VirtualMachine(ctx, "DC0_H0_VM0"); err != nil {
+		t.Fatal(err)
+	}
+	if inventory.clusterVM, err = finder.VirtualMachine(ctx, "DC0_C0_RP0_VM0"); err != nil {
+		t.Fatal(err)
+	}
+	if inventory.clusterHost, err = inventory.clusterVM.HostSystem(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if inventory.sharedDatastore, err = finder.Datastore(ctx, "LocalDS_0"); err != nil {
+		t.Fatal(err)
+	}
+	hostDatastoreSystem, err := inventory.clusterHost.ConfigManager().DatastoreSystem(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inventory.localDatastore, err = hostDatastoreSystem.CreateLocalDatastore(ctx, "local-ds", t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	inventory.localDatastoreURL = simulator.Map.Get(inventory.localDatastore.Reference()).(*simulator.Datastore).Info.GetDatastoreInfo().Url
+	return inventory
+}
+
+// nodeForVM returns the k8s node of the given node VM
+func nodeForVM(vm *object.VirtualMachine) *v1.Node {
+	uuid := simulator.Map.Get(vm.Reference()).(*simulator.VirtualMachine).Config.Uuid
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: vm.Name()},
+		Spec:       v1.NodeSpec{ProviderID: "vsphere://" + uuid},
+	}
+}
+
+func TestRefreshNodeVMHosts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vc, cleanup := newTestVirtualCenter(ctx, t)
+	defer cleanup()
+	inventory := newVanillaTestInventory(ctx, t, vc)
+	standaloneHost, err := inventory.standaloneVM.HostSystem(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sharedDatastoreURL := simulator.Map.Get(inventory.sharedDatastore.Reference()).(*simulator.Datastore).Info.GetDatastoreInfo().Url
+	defer func(f func(ctx context.Context) (clientset.Interface, error)) { newK8sClient = f }(newK8sClient)
+
+	tests := []struct {
+		name          string
+		nodes         []*v1.Node
+		expectedHosts map[string][]string
+		expectedURLs  []string
+	}{
+		{
+			name: "host-local datastore left out",
+			nodes: []*v1.Node{nodeForVM(inventory.standaloneVM), nodeForVM(inventory.clusterVM),
+				{ObjectMeta: metav1.ObjectMeta{Name: "no-provider-id"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "unknown-vm"},
+					Spec: v1.NodeSpec{ProviderID: "vsphere://00000000-0000-0000-0000-000000000000"}}},
+			expectedHosts: map[string][]string{
+				standaloneHost.Reference().Value:        {inventory.standaloneVM.Name()},
+				inventory.clusterHost.Reference().Value: {inventory.clusterVM.Name()},
+			},
+			expectedURLs: []string{sharedDatastoreURL},
+		},
+		{
+			name:  "single node host",
+			nodes: []*v1.Node{nodeForVM(inventory.clusterVM)},
+			expectedHosts: map[string][]string{
+				inventory.clusterHost.Reference().Value: {inventory.clusterVM.Name()},
+			},
+			expectedURLs: []string{inventory.localDatastoreURL, sharedDatastoreURL},
+		},
+	}
+	for _, test := range tests {
+		k8sClient := fake.NewSimpleClientset()
+		for _, node := range test.nodes {
+			if _, err := k8sClient.CoreV1().Nodes().Create(ctx, node, metav1.CreateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		newK8sClient = func(ctx context.Context) (clientset.Interface, error) {
+			return k8sClient, nil
+		}
+		c := &SpController{vc: vc, clusterFlavor: cnstypes.CnsClusterFlavorVanilla}
+		datastores, err := c.refreshNodeVMHosts(ctx, vc)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		urls := make([]string, 0)
+		for _, ds := range datastores {
+			urls = append(urls, ds.Info.Url)
+		}
+		sort.Strings(urls)
+		sort.Strings(test.expectedURLs)
+		if !reflect.DeepEqual(urls, test.expectedURLs) {
+			t.Errorf("%s: expected datastores %v, got %v", test.name, test.expectedURLs, urls)
+		}
+		if !reflect.DeepEqual(c.hostMoIDToNodeNames, test.expectedHosts) {
+			t.Errorf("%s: expected node VMs by host %v, got %v", test.name, test.expectedHosts, c.hostMoIDToNodeNames)
+		}
+	}
+}
+
+func TestFindAccessibleNodeVMs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vc, cleanup := newTestVirtualCenter(ctx, t)
+	defer cleanup()
+	inventory := newVanillaTestInventory(ctx, t, vc)
+	standaloneHost, err := inventory.standaloneVM.HostSystem(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostMoIDToNodeNames := map[string][]string{
+		inventory.clusterHost.Reference().Value: {"node-1", "node-2"},
+		standaloneHost.Reference().Value:        {"node-3"},
+	}
+
+	nodes, err := findAccessibleNodeVMs(ctx, inventory.localDatastore, hostMoIDToNodeNames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]bool{"node-1": false, "node-2": false}; !reflect.DeepEqual(nodes, expected) {
+		t.Errorf("expected accessible node VMs %v, got %v", expected, nodes)
+	}
+
+	task, err := inventory.clusterHost.EnterMaintenanceMode(ctx, 0, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = task.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	nodes, err = findAccessibleNodeVMs(ctx, inventory.localDatastore, hostMoIDToNodeNames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]bool{"node-1": true, "node-2": true}; !reflect.DeepEqual(nodes, expected) {
+		t.Errorf("expected the node VMs of the host in maintenance mode to be flagged, got %v", nodes)
+	}
+}
+
+func TestGetStoragePolicyID(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vc, cleanup := newTestVirtualCenter(ctx, t)
+	defer cleanup()
+	defaultPolicyID, err := vc.GetStoragePolicyIDByName(ctx, "vSAN Default Storage Policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newStorageClass := func(name, provisioner string, parameters map[string]string) *storagev1.StorageClass {
+		return &storagev1.StorageClass{
+			ObjectMeta:  metav1.ObjectMeta{Name: name},
+			Provisioner: provisioner,
+			Parameters:  parameters,
+		}
+	}
+	byName := newStorageClass("by-name", csitypes.Name, map[string]string{"StoragePolicyName": "vSAN Default Storage Policy"})
+	byID := newStorageClass("by-id", csitypes.Name, map[string]string{"storagePolicyID": "policy-id"})
+	unknownPolicy := newStorageClass("unknown-policy", csitypes.Name, map[string]string{"storagepolicyname": "unknown"})
+	inTree := newStorageClass("in-tree", "kubernetes.io/vsphere-volume",
+		map[string]string{"storagepolicyname": "vSAN Default Storage Policy"})
+
+	vanilla := &StorageClassWatch{vc: vc, spController: &SpController{clusterFlavor: cnstypes.CnsClusterFlavorVanilla}}
+	workload := &StorageClassWatch{vc: vc, spController: &SpController{clusterFlavor: cnstypes.CnsClusterFlavorWorkload}}
+	tests := []struct {
+		name     string
+		w        *StorageClassWatch
+		sc       *storagev1.StorageClass
+		expected string
+	}{
+		{"policy name in Vanilla cluster", vanilla, byName, defaultPolicyID},
+		{"policy ID in Vanilla cluster", vanilla, byID, "policy-id"},
+		{"unknown policy name", vanilla, unknownPolicy, ""},
+		{"in-tree StorageClass", vanilla, inTree, ""},
+		{"policy name in Supervisor cluster", workload, byName, ""},
+		{"policy ID in Supervisor cluster", workload, byID, "policy-id"},
+	}
+	for _, test := range tests {
+		if policyID := test.w.getStoragePolicyID(ctx, test.sc); policyID != test.expected {
+			t.Errorf("%s: expected policy ID %q, got %q", test.name, test.expected, policyID)
+		}
+	}
+
+	// StorageClasses referring to their policy by name are looked up in the cache by name
+	vanilla.policyToScMap = map[string]*storagev1.StorageClass{defaultPolicyID: byName}
+	modified := byName.DeepCopy()
+	modified.Parameters["diskformat"] = "thin"
+	refreshTests := []struct {
+		name      string
+		sc        *storagev1.StorageClass
+		eventType watch.EventType
+		expected  bool
+	}{
+		{"cached StorageClass unchanged", byName, watch.Modified, false},
+		{"cached StorageClass modified", modified, watch.Modified, true},
+		{"cached StorageClass deleted", byName, watch.Deleted, true},
+		{"uncached StorageClass added", unknownPolicy, watch.Added, true},
+		{"uncached StorageClass deleted", unknownPolicy, watch.Deleted, false},
+	}
+	for _, test := range refreshTests {
+		if refresh := vanilla.needsRefreshStorageClassCache(ctx, test.sc, test.eventType); refresh != test.expected {
+			t.Errorf("%s: expected refresh %v, got %v", test.name, test.expected, refresh)
+		}
+	}
+}
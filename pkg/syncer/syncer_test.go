@@ -37,6 +37,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clientset "k8s.io/client-go/kubernetes"
 	testclient "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
 
 	"sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/volume"
 	volumes "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/volume"
@@ -201,6 +202,7 @@ func TestSyncerWorkflows(t *testing.T) {
 	metadataSyncer.configInfo = configInfo
 	metadataSyncer.volumeManager = volumes.GetManager(ctx, virtualCenter)
 	metadataSyncer.host = virtualCenter.Config.Host
+	metadataSyncer.eventRecorder = &record.FakeRecorder{}
 
 	// Create the kubernetes client from config or env
 	// Here we should use a faked client to avoid test inteference with running
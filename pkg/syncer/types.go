@@ -24,6 +24,8 @@ import (
 	v1 "k8s.io/api/core/v1"
 	clientset "k8s.io/client-go/kubernetes"
 	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	volumes "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/volume"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/commonco"
@@ -50,6 +52,14 @@ const (
 
 	// default interval for csi volume health
 	defaultVolumeHealthIntervalInMin = 5
+	// number of volumes whose health is queried at once on vanilla clusters
+	volumeHealthQueryBatchSize = 100
+	// type of the PVC condition reporting the health of the volume on vanilla clusters
+	pvcConditionVolumeHealthy v1.PersistentVolumeClaimConditionType = "VolumeHealthy"
+	// reasons of the VolumeHealthy PVC condition
+	volumeHealthReasonHealthy               = "Healthy"
+	volumeHealthReasonDatastoreInaccessible = "DatastoreInaccessible"
+	volumeHealthReasonObjectDegraded        = "ObjectDegraded"
 
 	// default resync period for volume health reconciler
 	volumeHealthResyncPeriod = 10 * time.Minute
@@ -61,6 +71,19 @@ const (
 	volumeHealthWorkers = 10
 	// key for dynamically provisioned PV in volume attributes of PV spec
 	attribCSIProvisionerID = "storage.kubernetes.io/csiProvisionerIdentity"
+
+	// key for storage policy compliance status annotation on PVC
+	annStoragePolicyCompliance = "csi.vmware.com/storagepolicy.compliance"
+	// key for annotation on PVC requesting a new storage policy name for the volume
+	annStoragePolicyRequested = "csi.vmware.com/storagepolicy.requested"
+	// key for annotation on PVC recording the last requested storage policy name processed by the syncer
+	annStoragePolicyObserved = "csi.vmware.com/storagepolicy.observed"
+	// key for annotation on PVC reporting the result of the last storage policy change
+	annStoragePolicyChangeStatus = "csi.vmware.com/storagepolicy.change-status"
+	// key for annotation on PVC reporting details about the last storage policy change
+	annStoragePolicyChangeMessage = "csi.vmware.com/storagepolicy.change-message"
+	// default interval for storage policy compliance monitoring
+	defaultStoragePolicyComplianceIntervalInMin = 30
 )
 
 var (
@@ -95,12 +118,18 @@ type metadataSyncInformer struct {
 	host               string
 	cnsOperatorClient  client.Client
 	supervisorClient   clientset.Interface
+	k8sClient          clientset.Interface
 	configInfo         *types.ConfigInfo
 	k8sInformerManager *k8s.InformerManager
 	pvLister           corelisters.PersistentVolumeLister
 	pvcLister          corelisters.PersistentVolumeClaimLister
 	podLister          corelisters.PodLister
 	coCommonInterface  commonco.COCommonInterface
+	eventRecorder      record.EventRecorder
+	// fullSyncReportClient publishes FullSyncReport instances, if set
+	fullSyncReportClient client.Client
+	// storagePolicyChangeQueue holds the keys of the PVCs requesting a storage policy change
+	storagePolicyChangeQueue workqueue.RateLimitingInterface
 }
 
 const (
@@ -112,4 +141,6 @@ const (
 	resizeRetryIntervalMax = 5 * time.Minute
 	// resizeWorkers represents the number of running worker threads
 	resizeWorkers = 10
+	// storagePolicyChangeWorkers represents the number of storage policy changes applied at the same time
+	storagePolicyChangeWorkers = 4
 )
@@ -5,14 +5,13 @@ import (
 
 	"k8s.io/client-go/tools/cache"
 
-	cnstypes "github.com/vmware/govmomi/cns/types"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 
 	"sigs.k8s.io/vsphere-csi-driver/pkg/apis/migration"
-	volumes "sigs.k8s.io/vsphere-csi-driver/pkg/common/cns-lib/volume"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/prometheus"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/types"
 	csitypes "sigs.k8s.io/vsphere-csi-driver/pkg/csi/types"
@@ -61,7 +60,7 @@ func getBoundPVs(ctx context.Context, metadataSyncer *metadataSyncInformer) ([]*
 }
 
 // fullSyncGetInlineMigratedVolumesInfo is a helper function for retrieving  inline PV information from Pods
-func fullSyncGetInlineMigratedVolumesInfo(ctx context.Context, metadataSyncer *metadataSyncInformer, migrationFeatureState bool) (map[string]string, error) {
+func fullSyncGetInlineMigratedVolumesInfo(ctx context.Context, metadataSyncer *metadataSyncInformer, migrationFeatureState bool, dryRun bool) (map[string]string, error) {
 	log := logger.GetLogger(ctx)
 	inlineVolumes := make(map[string]string)
 	// Get all Pods from kubernetes
@@ -74,11 +73,14 @@ func fullSyncGetInlineMigratedVolumesInfo(ctx context.Context, metadataSyncer *m
 		for _, volume := range pod.Spec.Volumes {
 			// Check if migration is ON and volumes if of type vSphereVolume
 			if migrationFeatureState && volume.VsphereVolume != nil {
-				volumeHandle, err := volumeMigrationService.GetVolumeID(ctx, &migration.VolumeSpec{VolumePath: volume.VsphereVolume.VolumePath, StoragePolicyName: volume.VsphereVolume.StoragePolicyName})
+				volumeHandle, err := fullSyncGetMigratedVolumeID(ctx, &migration.VolumeSpec{VolumePath: volume.VsphereVolume.VolumePath, StoragePolicyName: volume.VsphereVolume.StoragePolicyName}, dryRun)
 				if err != nil {
 					log.Warnf("FullSync: Failed to get VolumeID from volumeMigrationService for volumePath: %s with error %+v", volume.VsphereVolume.VolumePath, err)
 					continue
 				}
+				if volumeHandle == "" {
+					continue
+				}
 				inlineVolumes[volumeHandle] = volume.VsphereVolume.VolumePath
 			}
 		}
@@ -129,45 +131,6 @@ func IsValidVolume(ctx context.Context, volume v1.Volume, pod *v1.Pod, metadataS
 	return true, pv, pvc
 }
 
-// fullSyncGetQueryResults returns list of CnsQueryResult retrieved using
-// queryFilter with offset and limit to query volumes using pagination
-// if volumeIds is empty, then all volumes from CNS will be retrieved by pagination
-func fullSyncGetQueryResults(ctx context.Context, volumeIds []cnstypes.CnsVolumeId, clusterID string, volumeManager volumes.Manager) ([]*cnstypes.CnsQueryResult, error) {
-	log := logger.GetLogger(ctx)
-	log.Debugf("FullSync: fullSyncGetQueryResults is called with volumeIds %v for clusterID %s", volumeIds, clusterID)
-	queryFilter := cnstypes.CnsQueryFilter{
-		VolumeIds: volumeIds,
-		Cursor: &cnstypes.CnsCursor{
-			Offset: 0,
-			Limit:  queryVolumeLimit,
-		},
-	}
-	if clusterID != "" {
-		queryFilter.ContainerClusterIds = []string{clusterID}
-	}
-	var allQueryResults []*cnstypes.CnsQueryResult
-	for {
-		log.Debugf("Query volumes with offset: %v and limit: %v", queryFilter.Cursor.Offset, queryFilter.Cursor.Limit)
-		queryResult, err := volumeManager.QueryVolume(ctx, queryFilter)
-		if err != nil {
-			log.Errorf("failed to QueryVolume using filter: %+v", queryFilter)
-			return nil, err
-		}
-		if queryResult == nil {
-			log.Info("Observed empty queryResult")
-			break
-		}
-		allQueryResults = append(allQueryResults, queryResult)
-		log.Infof("%v more volumes to be queried", queryResult.Cursor.TotalRecords-queryResult.Cursor.Offset)
-		if queryResult.Cursor.Offset == queryResult.Cursor.TotalRecords {
-			log.Info("Metadata retrieved for all requested volumes")
-			break
-		}
-		queryFilter.Cursor = &queryResult.Cursor
-	}
-	return allQueryResults, nil
-}
-
 // getPVCKey helps to get the PVC name from PVC object
 func getPVCKey(ctx context.Context, obj interface{}) (string, error) {
 	log := logger.GetLogger(ctx)
@@ -270,3 +233,12 @@ func initVolumeMigrationService(ctx context.Context, metadataSyncer *metadataSyn
 	}
 	return nil
 }
+
+// recordMetadataSyncOp records the outcome of a CNS metadata update performed by the given informer handler
+func recordMetadataSyncOp(handler string, err error) {
+	status := prometheus.PrometheusPassStatus
+	if err != nil {
+		status = prometheus.PrometheusFailStatus
+	}
+	prometheus.MetadataSyncOpsCounterVec.WithLabelValues(handler, status).Inc()
+}
@@ -7,9 +7,11 @@ import (
 	"testing"
 
 	"github.com/google/uuid"
+	cnstypes "github.com/vmware/govmomi/cns/types"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/syncer/k8scloudoperator"
 )
 
@@ -159,3 +161,59 @@ func TestGetSCNameFromPVC(t *testing.T) {
 	}
 	t.Log("testGetSCNameFromPVC: end")
 }
+
+func TestFullSyncReportRecordOperations(t *testing.T) {
+	createSpecArray := []cnstypes.CnsVolumeCreateSpec{
+		{
+			VolumeType:           common.BlockVolumeType,
+			BackingObjectDetails: &cnstypes.CnsBlockBackingDetails{BackingDiskId: "block-vol"},
+		},
+		{
+			VolumeType:           common.FileVolumeType,
+			BackingObjectDetails: &cnstypes.CnsVsanFileShareBackingDetails{CnsFileBackingDetails: cnstypes.CnsFileBackingDetails{BackingFileId: "file-vol"}},
+		},
+	}
+	updateSpecArray := []cnstypes.CnsVolumeMetadataUpdateSpec{
+		{VolumeId: cnstypes.CnsVolumeId{Id: "update-vol"}},
+	}
+	volToBeDeleted := []cnstypes.CnsVolumeId{{Id: "delete-vol-1"}, {Id: "delete-vol-2"}}
+
+	report := newFullSyncReport(true)
+	report.recordOperations(createSpecArray, updateSpecArray, volToBeDeleted)
+	report.recordFailure(fullSyncOperationDelete, "delete-vol-1", fmt.Errorf("in use"))
+
+	if !report.status.DryRun {
+		t.Errorf("expected dry run report")
+	}
+	if !reflect.DeepEqual(report.status.VolumesToCreate, []string{"block-vol", "file-vol"}) || report.status.CreateCount != 2 {
+		t.Errorf("unexpected volumes to create: %v", report.status.VolumesToCreate)
+	}
+	if !reflect.DeepEqual(report.status.VolumesToUpdate, []string{"update-vol"}) || report.status.UpdateCount != 1 {
+		t.Errorf("unexpected volumes to update: %v", report.status.VolumesToUpdate)
+	}
+	if !reflect.DeepEqual(report.status.VolumesToDelete, []string{"delete-vol-1", "delete-vol-2"}) || report.status.DeleteCount != 2 {
+		t.Errorf("unexpected volumes to delete: %v", report.status.VolumesToDelete)
+	}
+	if len(report.status.Failures) != 1 || report.status.Failures[0].VolumeID != "delete-vol-1" {
+		t.Errorf("unexpected failures: %v", report.status.Failures)
+	}
+	// Recording on a nil report is a no-op
+	var nilReport *fullSyncReport
+	nilReport.recordOperations(createSpecArray, updateSpecArray, volToBeDeleted)
+	nilReport.recordFailure(fullSyncOperationCreate, "block-vol", fmt.Errorf("failed"))
+}
+
+func TestVolumeHealthReason(t *testing.T) {
+	tests := map[string]string{
+		"green":   volumeHealthReasonHealthy,
+		"red":     volumeHealthReasonDatastoreInaccessible,
+		"yellow":  volumeHealthReasonObjectDegraded,
+		"unknown": "",
+		"":        "",
+	}
+	for healthStatus, expected := range tests {
+		if reason := volumeHealthReason(healthStatus); reason != expected {
+			t.Errorf("expected reason %q for health status %q, got %q", expected, healthStatus, reason)
+		}
+	}
+}
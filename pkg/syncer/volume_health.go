@@ -18,6 +18,8 @@ package syncer
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	cnstypes "github.com/vmware/govmomi/cns/types"
@@ -25,33 +27,22 @@ import (
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	clientset "k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/prometheus"
 	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/logger"
 )
 
 func csiGetVolumeHealthStatus(ctx context.Context, k8sclient clientset.Interface, metadataSyncer *metadataSyncInformer) {
 	log := logger.GetLogger(ctx)
 	log.Infof("csiGetVolumeHealthStatus: start")
-
-	//Call CNS QueryAll to get container volumes by cluster ID
-	queryFilter := cnstypes.CnsQueryFilter{
-		ContainerClusterIds: []string{
-			metadataSyncer.configInfo.Cfg.Global.ClusterID,
-		},
-	}
-
-	querySelection := cnstypes.CnsQuerySelection{
-		Names: []string{
-			string(cnstypes.QuerySelectionNameTypeHealthStatus),
-		},
-	}
-
-	queryAllResult, err := metadataSyncer.volumeManager.QueryAllVolume(ctx, queryFilter, querySelection)
-	if err != nil {
-		log.Errorf("csiGetVolumeHealthStatus: failed to queryAllVolume with err %+v", err)
-		return
-	}
+	start := time.Now()
+	status := prometheus.PrometheusFailStatus
+	defer func() {
+		prometheus.VolumeHealthPollHistVec.WithLabelValues(status).Observe(time.Since(start).Seconds())
+	}()
 
 	// Get K8s PVs in State "Bound"
 	k8sPVs, err := getBoundPVs(ctx, metadataSyncer)
@@ -77,8 +68,22 @@ func csiGetVolumeHealthStatus(ctx context.Context, k8sclient clientset.Interface
 		}
 	}
 
-	for _, vol := range queryAllResult.Volumes {
+	volumes, err := queryVolumeHealth(ctx, metadataSyncer, volumeHandleToPvcMap)
+	if err != nil {
+		log.Errorf("csiGetVolumeHealthStatus: failed to queryAllVolume with err %+v", err)
+		return
+	}
+
+	unhealthyVolumes := map[string]int{
+		volumeHealthReasonDatastoreInaccessible: 0,
+		volumeHealthReasonObjectDegraded:        0,
+	}
+	for _, vol := range volumes {
 		log.Debugf("Volume %q Health Status %q", vol.VolumeId.Id, vol.HealthStatus)
+		reason := volumeHealthReason(vol.HealthStatus)
+		if _, ok := unhealthyVolumes[reason]; ok {
+			unhealthyVolumes[reason]++
+		}
 
 		if pvc, ok := volumeHandleToPvcMap[vol.VolumeId.Id]; ok {
 			log.Debugf("csiGetVolumeHealthStatus: Found pvc %q for volume %q", pvc, vol.VolumeId.Id)
@@ -98,6 +103,15 @@ func csiGetVolumeHealthStatus(ctx context.Context, k8sclient clientset.Interface
 					metav1.SetMetaDataAnnotation(&pvc.ObjectMeta, annVolumeHealth, volHealthStatus)
 					metav1.SetMetaDataAnnotation(&pvc.ObjectMeta, annVolumeHealthTS, time.Now().Format(time.UnixDate))
 					log.Infof("set annotation for health to %s at time %s", volHealthStatus, time.Now().Format(time.UnixDate))
+					// On vanilla clusters, health transitions are recorded with the VolumeHealthy condition below
+					if found && val != volHealthStatus && metadataSyncer.clusterFlavor != cnstypes.CnsClusterFlavorVanilla {
+						eventType := v1.EventTypeNormal
+						if volHealthStatus != common.VolHealthStatusAccessible {
+							eventType = v1.EventTypeWarning
+						}
+						metadataSyncer.eventRecorder.Eventf(pvc, eventType, "VolumeHealthChanged",
+							"Health of volume %s changed from %s to %s", vol.VolumeId.Id, val, volHealthStatus)
+					}
 					_, err := k8sclient.CoreV1().PersistentVolumeClaims(pvc.Namespace).Update(ctx, pvc, metav1.UpdateOptions{})
 					if err != nil {
 						if apierrors.IsConflict(err) {
@@ -129,7 +143,155 @@ func csiGetVolumeHealthStatus(ctx context.Context, k8sclient clientset.Interface
 					}
 				}
 			}
+			if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla && reason != "" {
+				updateVolumeHealthCondition(ctx, k8sclient, metadataSyncer, pvc, vol.VolumeId.Id, reason)
+			}
 		}
 	}
+	for reason, count := range unhealthyVolumes {
+		prometheus.UnhealthyVolumesGaugeVec.WithLabelValues(reason).Set(float64(count))
+	}
+	status = prometheus.PrometheusPassStatus
 	log.Infof("GetVolumeHealthStatus: end")
 }
+
+// queryVolumeHealth returns the health status of the volumes of the cluster.
+// On vanilla clusters, the health of the volumes backing the given PVCs is queried
+// in batches of volumeHealthQueryBatchSize volumes, otherwise the health of all
+// the volumes of the cluster is queried at once.
+func queryVolumeHealth(ctx context.Context, metadataSyncer *metadataSyncInformer,
+	volumeHandleToPvcMap volumeHandlePVCMap) ([]cnstypes.CnsVolume, error) {
+	querySelection := cnstypes.CnsQuerySelection{
+		Names: []string{
+			string(cnstypes.QuerySelectionNameTypeHealthStatus),
+		},
+	}
+	if metadataSyncer.clusterFlavor != cnstypes.CnsClusterFlavorVanilla {
+		//Call CNS QueryAll to get container volumes by cluster ID
+		queryFilter := cnstypes.CnsQueryFilter{
+			ContainerClusterIds: []string{
+				metadataSyncer.configInfo.Cfg.Global.ClusterID,
+			},
+		}
+		queryAllResult, err := metadataSyncer.volumeManager.QueryAllVolume(ctx, queryFilter, querySelection)
+		if err != nil {
+			return nil, err
+		}
+		return queryAllResult.Volumes, nil
+	}
+
+	var volumes []cnstypes.CnsVolume
+	volumeIds := make([]cnstypes.CnsVolumeId, 0, len(volumeHandleToPvcMap))
+	for volumeHandle := range volumeHandleToPvcMap {
+		volumeIds = append(volumeIds, cnstypes.CnsVolumeId{Id: volumeHandle})
+	}
+	for start := 0; start < len(volumeIds); start += volumeHealthQueryBatchSize {
+		end := start + volumeHealthQueryBatchSize
+		if end > len(volumeIds) {
+			end = len(volumeIds)
+		}
+		queryFilter := cnstypes.CnsQueryFilter{
+			VolumeIds: volumeIds[start:end],
+		}
+		queryAllResult, err := metadataSyncer.volumeManager.QueryAllVolume(ctx, queryFilter, querySelection)
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, queryAllResult.Volumes...)
+	}
+	return volumes, nil
+}
+
+// volumeHealthReason returns the reason of the VolumeHealthy condition for the given
+// CNS volume health status, or an empty string if the health status is unknown
+func volumeHealthReason(healthStatus string) string {
+	switch healthStatus {
+	case string(pbmtypes.PbmHealthStatusForEntityGreen):
+		return volumeHealthReasonHealthy
+	case string(pbmtypes.PbmHealthStatusForEntityRed):
+		return volumeHealthReasonDatastoreInaccessible
+	case string(pbmtypes.PbmHealthStatusForEntityYellow):
+		return volumeHealthReasonObjectDegraded
+	default:
+		return ""
+	}
+}
+
+// updateVolumeHealthCondition sets the VolumeHealthy condition of the given pvc to the given
+// reason and records an event on the pvc when the reason changes
+func updateVolumeHealthCondition(ctx context.Context, k8sclient clientset.Interface,
+	metadataSyncer *metadataSyncInformer, pvc *v1.PersistentVolumeClaim, volumeID string, reason string) {
+	log := logger.GetLogger(ctx)
+	var oldCondition *v1.PersistentVolumeClaimCondition
+	for i := range pvc.Status.Conditions {
+		if pvc.Status.Conditions[i].Type == pvcConditionVolumeHealthy {
+			oldCondition = &pvc.Status.Conditions[i]
+			break
+		}
+	}
+	if oldCondition != nil && oldCondition.Reason == reason {
+		return
+	}
+
+	now := metav1.Now()
+	condition := v1.PersistentVolumeClaimCondition{
+		Type:               pvcConditionVolumeHealthy,
+		Status:             v1.ConditionFalse,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+		Reason:             reason,
+	}
+	eventType := v1.EventTypeWarning
+	switch reason {
+	case volumeHealthReasonHealthy:
+		condition.Status = v1.ConditionTrue
+		condition.Message = fmt.Sprintf("Volume %s is healthy", volumeID)
+		eventType = v1.EventTypeNormal
+	case volumeHealthReasonDatastoreInaccessible:
+		condition.Message = fmt.Sprintf("Datastore of volume %s is inaccessible", volumeID)
+	case volumeHealthReasonObjectDegraded:
+		condition.Message = fmt.Sprintf("Backing object of volume %s is degraded", volumeID)
+	}
+	if oldCondition != nil && oldCondition.Status == condition.Status {
+		condition.LastTransitionTime = oldCondition.LastTransitionTime
+	}
+
+	newPVC := pvc.DeepCopy()
+	newPVC.Status.Conditions = nil
+	for _, c := range pvc.Status.Conditions {
+		if c.Type != pvcConditionVolumeHealthy {
+			newPVC.Status.Conditions = append(newPVC.Status.Conditions, c)
+		}
+	}
+	newPVC.Status.Conditions = append(newPVC.Status.Conditions, condition)
+	oldData, err := json.Marshal(pvc)
+	if err != nil {
+		log.Errorf("updateVolumeHealthCondition: failed to marshal pvc %s/%s with err: %+v", pvc.Namespace, pvc.Name, err)
+		return
+	}
+	newData, err := json.Marshal(newPVC)
+	if err != nil {
+		log.Errorf("updateVolumeHealthCondition: failed to marshal pvc %s/%s with err: %+v", pvc.Namespace, pvc.Name, err)
+		return
+	}
+	// The patch is not bound to the resourceVersion of the pvc as conditions are merged by type
+	patchBytes, err := strategicpatch.CreateTwoWayMergePatch(oldData, newData, pvc)
+	if err != nil {
+		log.Errorf("updateVolumeHealthCondition: failed to create patch for pvc %s/%s with err: %+v",
+			pvc.Namespace, pvc.Name, err)
+		return
+	}
+	_, err = k8sclient.CoreV1().PersistentVolumeClaims(pvc.Namespace).Patch(ctx, pvc.Name,
+		apitypes.StrategicMergePatchType, patchBytes, metav1.PatchOptions{}, "status")
+	if err != nil {
+		log.Errorf("updateVolumeHealthCondition: failed to patch status of pvc %s/%s with err: %+v",
+			pvc.Namespace, pvc.Name, err)
+		return
+	}
+	log.Infof("updateVolumeHealthCondition: set condition %s of pvc %s/%s to %s", pvcConditionVolumeHealthy,
+		pvc.Namespace, pvc.Name, reason)
+	// Do not record an event for volumes observed healthy for the first time
+	if oldCondition != nil || reason != volumeHealthReasonHealthy {
+		metadataSyncer.eventRecorder.Event(pvc, eventType, "Volume"+reason, condition.Message)
+	}
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"sigs.k8s.io/vsphere-csi-driver/pkg/csi/service/common/prometheus"
+)
+
+// getVolumeHealthCondition returns the VolumeHealthy condition of the given pvc, if any
+func getVolumeHealthCondition(pvc *v1.PersistentVolumeClaim) *v1.PersistentVolumeClaimCondition {
+	for i := range pvc.Status.Conditions {
+		if pvc.Status.Conditions[i].Type == pvcConditionVolumeHealthy {
+			return &pvc.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+func TestUpdateVolumeHealthCondition(t *testing.T) {
+	ctx := context.Background()
+	resizing := v1.PersistentVolumeClaimCondition{Type: v1.PersistentVolumeClaimResizing, Status: v1.ConditionTrue}
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc-1", Namespace: testNamespace},
+		Status:     v1.PersistentVolumeClaimStatus{Conditions: []v1.PersistentVolumeClaimCondition{resizing}},
+	}
+	k8sClient := testclient.NewSimpleClientset(pvc)
+	eventRecorder := record.NewFakeRecorder(10)
+	metadataSyncer := &metadataSyncInformer{eventRecorder: eventRecorder}
+
+	steps := []struct {
+		reason          string
+		conditionStatus v1.ConditionStatus
+		event           string
+	}{
+		// volumes observed healthy for the first time do not get an event
+		{volumeHealthReasonHealthy, v1.ConditionTrue, ""},
+		{volumeHealthReasonObjectDegraded, v1.ConditionFalse,
+			"Warning VolumeObjectDegraded Backing object of volume vol-1 is degraded"},
+		{volumeHealthReasonObjectDegraded, v1.ConditionFalse, ""},
+		{volumeHealthReasonDatastoreInaccessible, v1.ConditionFalse,
+			"Warning VolumeDatastoreInaccessible Datastore of volume vol-1 is inaccessible"},
+		{volumeHealthReasonHealthy, v1.ConditionTrue, "Normal VolumeHealthy Volume vol-1 is healthy"},
+	}
+	var lastTransitionTime metav1.Time
+	for i, step := range steps {
+		current, err := k8sClient.CoreV1().PersistentVolumeClaims(testNamespace).Get(ctx, "pvc-1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		updateVolumeHealthCondition(ctx, k8sClient, metadataSyncer, current, "vol-1", step.reason)
+
+		updated, err := k8sClient.CoreV1().PersistentVolumeClaims(testNamespace).Get(ctx, "pvc-1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		condition := getVolumeHealthCondition(updated)
+		if condition == nil || condition.Reason != step.reason || condition.Status != step.conditionStatus {
+			t.Fatalf("step %d: expected condition %s/%s, got %+v", i, step.reason, step.conditionStatus, condition)
+		}
+		if len(updated.Status.Conditions) != 2 || updated.Status.Conditions[0].Type != resizing.Type {
+			t.Errorf("step %d: expected the other conditions to be kept, got %+v", i, updated.Status.Conditions)
+		}
+		// the transition time only changes with the status of the condition
+		if i > 0 && steps[i-1].conditionStatus == step.conditionStatus &&
+			!condition.LastTransitionTime.Equal(&lastTransitionTime) {
+			t.Errorf("step %d: expected last transition time %v to be kept, got %v", i, lastTransitionTime,
+				condition.LastTransitionTime)
+		}
+		lastTransitionTime = condition.LastTransitionTime
+
+		select {
+		case event := <-eventRecorder.Events:
+			if event != step.event {
+				t.Errorf("step %d: expected event %q, got %q", i, step.event, event)
+			}
+		default:
+			if step.event != "" {
+				t.Errorf("step %d: expected event %q, got none", i, step.event)
+			}
+		}
+	}
+}
+
+func TestQueryVolumeHealthInBatches(t *testing.T) {
+	ctx := context.Background()
+	volumeManager := &fakeVolumeManager{healthStatus: make(map[string]string)}
+	metadataSyncer := &metadataSyncInformer{
+		volumeManager: volumeManager,
+		clusterFlavor: cnstypes.CnsClusterFlavorVanilla,
+	}
+	volumeHandleToPvcMap := make(volumeHandlePVCMap)
+	totalVolumes := 2*volumeHealthQueryBatchSize + 1
+	for i := 0; i < totalVolumes; i++ {
+		volumeHandleToPvcMap[fmt.Sprintf("vol-%d", i)] = &v1.PersistentVolumeClaim{}
+	}
+
+	volumes, err := queryVolumeHealth(ctx, metadataSyncer, volumeHandleToPvcMap)
+	if err != nil {
+		t.Fatalf("queryVolumeHealth failed: %v", err)
+	}
+	if len(volumes) != totalVolumes {
+		t.Errorf("expected %d volumes, got %d", totalVolumes, len(volumes))
+	}
+	if len(volumeManager.queryAllFilters) != 3 {
+		t.Fatalf("expected 3 queries, got %d", len(volumeManager.queryAllFilters))
+	}
+	queried := make(map[string]bool)
+	for _, filter := range volumeManager.queryAllFilters {
+		if len(filter.VolumeIds) > volumeHealthQueryBatchSize {
+			t.Errorf("expected at most %d volumes per query, got %d", volumeHealthQueryBatchSize, len(filter.VolumeIds))
+		}
+		for _, volumeID := range filter.VolumeIds {
+			queried[volumeID.Id] = true
+		}
+	}
+	if len(queried) != totalVolumes {
+		t.Errorf("expected all the %d volumes to be queried once, got %d", totalVolumes, len(queried))
+	}
+}
+
+func TestCsiGetVolumeHealthStatusVanilla(t *testing.T) {
+	ctx := context.Background()
+	healthStatus := map[string]string{
+		"vol-green":   "green",
+		"vol-red":     "red",
+		"vol-red-2":   "red",
+		"vol-yellow":  "yellow",
+		"vol-unknown": "unknown",
+	}
+	k8sClient := testclient.NewSimpleClientset()
+	pvIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	pvcIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for name := range healthStatus {
+		pv, pvc := newCompliancePVAndPVC(name)
+		if err := pvIndexer.Add(pv); err != nil {
+			t.Fatal(err)
+		}
+		if err := pvcIndexer.Add(pvc); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := k8sClient.CoreV1().PersistentVolumeClaims(testNamespace).Create(ctx, pvc, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	metadataSyncer := &metadataSyncInformer{
+		volumeManager: &fakeVolumeManager{healthStatus: healthStatus},
+		clusterFlavor: cnstypes.CnsClusterFlavorVanilla,
+		pvLister:      corelisters.NewPersistentVolumeLister(pvIndexer),
+		pvcLister:     corelisters.NewPersistentVolumeClaimLister(pvcIndexer),
+		eventRecorder: record.NewFakeRecorder(100),
+	}
+
+	csiGetVolumeHealthStatus(ctx, k8sClient, metadataSyncer)
+
+	expectedUnhealthy := map[string]float64{
+		volumeHealthReasonDatastoreInaccessible: 2,
+		volumeHealthReasonObjectDegraded:        1,
+	}
+	for reason, expected := range expectedUnhealthy {
+		if count := testutil.ToFloat64(prometheus.UnhealthyVolumesGaugeVec.WithLabelValues(reason)); count != expected {
+			t.Errorf("expected %v unhealthy volumes with reason %s, got %v", expected, reason, count)
+		}
+	}
+	expectedReasons := map[string]string{
+		"vol-green":   volumeHealthReasonHealthy,
+		"vol-red":     volumeHealthReasonDatastoreInaccessible,
+		"vol-red-2":   volumeHealthReasonDatastoreInaccessible,
+		"vol-yellow":  volumeHealthReasonObjectDegraded,
+		"vol-unknown": "",
+	}
+	for name, expectedReason := range expectedReasons {
+		pvc, err := k8sClient.CoreV1().PersistentVolumeClaims(testNamespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		condition := getVolumeHealthCondition(pvc)
+		if expectedReason == "" {
+			if condition != nil {
+				t.Errorf("expected no health condition on pvc %s, got %+v", name, condition)
+			}
+			continue
+		}
+		if condition == nil || condition.Reason != expectedReason {
+			t.Errorf("expected health condition %s on pvc %s, got %+v", expectedReason, name, condition)
+		}
+	}
+}